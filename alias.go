@@ -0,0 +1,61 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// AliasComponent maps the old name of a renamed component to the current
+// type c, so that snapshots that were written before the rename can still
+// be loaded by Unmarshal, LoadScene and ApplyReplication. The type of c is
+// registered like with RegisterComponent.
+//
+// For example after renaming OldPos to Pos:
+//    ecs.AliasComponent("OldPos", Pos{})
+func (ecs *ECS) AliasComponent(old string, c interface{}) error {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	t := reflect.TypeOf(c)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	name := ecs.names.typeName(t)
+	if old == name {
+		return ecs.misuse(fmt.Errorf("alias '%s' is the name of the component itself", old))
+	}
+
+	if current, ok := ecs.compMetaCache[old]; ok {
+		return ecs.misuse(fmt.Errorf("alias '%s' is the name of %s: %w", old, current, ErrTypeConflict))
+	}
+
+	if err := ecs.cacheComponent(name, t); err != nil {
+		return ecs.misuse(err)
+	}
+
+	if ecs.aliases == nil {
+		ecs.aliases = map[string]string{}
+	}
+	ecs.aliases[old] = name
+
+	return nil
+}
+
+// resolveAlias returns the current name of a aliased component
+// name. Keyed names are resolved by their component name.
+func (ecs *ECS) resolveAlias(comp string) string {
+	if len(ecs.aliases) == 0 {
+		return comp
+	}
+
+	name, key, keyed := splitKeyed(comp)
+	if current, ok := ecs.aliases[name]; ok {
+		if keyed {
+			return keyedName(current, key)
+		}
+		return current
+	}
+
+	return comp
+}
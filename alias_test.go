@@ -0,0 +1,36 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_AliasComponent(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.AliasComponent("OldPos", Pos{}))
+	assert.NoError(t, ecs.AliasComponent("Effect", &StatusEffect{}))
+
+	snapshot := `[
+		{"ID":1,"Type":"Unit","Components":{"OldPos":{"X":1,"Y":2},"Health":{"Value":3,"Max":4}}},
+		{"ID":2,"Type":"DynamicUnit","Components":{"OldPos":{"X":5,"Y":6},"Effect#poison":{"Damage":2}}}
+	]`
+	assert.NoError(t, ecs.Unmarshal(bytes.NewBufferString(snapshot)))
+
+	assert.Equal(t, Pos{X: 1, Y: 2}, ecs.MustGet(1).GetEntity().(*Unit).Pos)
+
+	c, err := ecs.MustGet(2).GetEntity().(*DynamicUnit).GetComponent("Pos")
+	assert.NoError(t, err)
+	assert.Equal(t, &Pos{X: 5, Y: 6}, c)
+	assert.Equal(t, []string{"poison"}, ecs.MustGet(2).Keys(StatusEffect{}))
+
+	// New snapshots are written with the current names.
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.NotContains(t, buf.String(), "OldPos")
+
+	assert.Error(t, ecs.AliasComponent("Pos", Pos{}))
+	assert.ErrorIs(t, ecs.AliasComponent("Health", Pos{}), ErrTypeConflict)
+}
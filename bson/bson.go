@@ -0,0 +1,217 @@
+// Package bson encodes the entities of a kinshi.ECS as BSON documents,
+// so that worlds can be stored directly in MongoDB. The documents follow
+// the JSON form of kinshi.ECS.MarshalEntity, so the same components
+// need to be registered and the same decode hooks apply. The id of the
+// entity is stored as _id:
+//    {"_id": 1, "Type": "Unit", "Components": {"Pos": {"X": 1, "Y": 2}}}
+//
+// Integers are stored as int64 and other numbers as double.
+//
+// For example you want to store a entity in a collection:
+//    doc, err := bson.MarshalEntity(ecs, id)
+//    // ...
+//    _, err = coll.InsertOne(ctx, doc)
+package bson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	mbson "go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"io"
+	"sort"
+)
+
+// MarshalEntity encodes a single entity as BSON document.
+func MarshalEntity(ecs *kinshi.ECS, id kinshi.EntityID) ([]byte, error) {
+	data, err := ecs.MarshalEntity(id)
+	if err != nil {
+		return nil, err
+	}
+	return fromJSON(data)
+}
+
+// UnmarshalEntity decodes a single document in the form of
+// MarshalEntity and adds it like kinshi.ECS.UnmarshalEntity.
+func UnmarshalEntity(ecs *kinshi.ECS, doc []byte) (kinshi.EntityID, error) {
+	data, err := toJSON(doc)
+	if err != nil {
+		return kinshi.EntityNone, err
+	}
+	return ecs.UnmarshalEntity(data)
+}
+
+// Marshal writes all entities as a sequence of documents, the same
+// format that mongodump writes for a collection.
+func Marshal(w io.Writer, ecs *kinshi.ECS) error {
+	var buf bytes.Buffer
+	if err := ecs.Marshal(&buf); err != nil {
+		return err
+	}
+
+	var ents []json.RawMessage
+	if err := json.Unmarshal(buf.Bytes(), &ents); err != nil {
+		return err
+	}
+
+	for i := range ents {
+		doc, err := fromJSON(ents[i])
+		if err != nil {
+			return err
+		}
+
+		if _, err := w.Write(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unmarshal reads a sequence of documents written by Marshal and replaces
+// all entities of the ECS, like kinshi.ECS.Unmarshal.
+func Unmarshal(r io.Reader, ecs *kinshi.ECS) error {
+	br := bufio.NewReader(r)
+
+	ents := []json.RawMessage{}
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(br, size[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		n := binary.LittleEndian.Uint32(size[:])
+		if n < 5 {
+			return fmt.Errorf("invalid document size %d", n)
+		}
+
+		doc := make([]byte, n)
+		copy(doc, size[:])
+		if _, err := io.ReadFull(br, doc[4:]); err != nil {
+			return err
+		}
+
+		data, err := toJSON(doc)
+		if err != nil {
+			return err
+		}
+		ents = append(ents, data)
+	}
+
+	data, err := json.Marshal(ents)
+	if err != nil {
+		return err
+	}
+	return ecs.Unmarshal(bytes.NewReader(data))
+}
+
+// fromJSON converts a entity from the JSON form into a document.
+func fromJSON(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var obj map[string]interface{}
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	d := toBSON(obj).(primitive.D)
+	for i := range d {
+		if d[i].Key == "ID" {
+			id := d[i]
+			id.Key = "_id"
+			copy(d[1:i+1], d[:i])
+			d[0] = id
+			break
+		}
+	}
+
+	return mbson.Marshal(d)
+}
+
+// toBSON converts a generic JSON value. The keys of objects are sorted so
+// that the documents are deterministic.
+func toBSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		d := make(primitive.D, len(keys))
+		for i, k := range keys {
+			d[i] = primitive.E{Key: k, Value: toBSON(v[k])}
+		}
+		return d
+	case []interface{}:
+		a := make(primitive.A, len(v))
+		for i := range v {
+			a[i] = toBSON(v[i])
+		}
+		return a
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// toJSON converts a document into the JSON form of a entity.
+func toJSON(doc []byte) ([]byte, error) {
+	var d primitive.D
+	if err := mbson.Unmarshal(doc, &d); err != nil {
+		return nil, err
+	}
+
+	obj, err := generic(d)
+	if err != nil {
+		return nil, err
+	}
+
+	m := obj.(map[string]interface{})
+	if id, ok := m["_id"]; ok {
+		delete(m, "_id")
+		m["ID"] = id
+	}
+
+	return json.Marshal(m)
+}
+
+// generic converts a BSON value into a generic JSON value.
+func generic(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case primitive.D:
+		m := make(map[string]interface{}, len(v))
+		for i := range v {
+			val, err := generic(v[i].Value)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", v[i].Key, err)
+			}
+			m[v[i].Key] = val
+		}
+		return m, nil
+	case primitive.A:
+		a := make([]interface{}, len(v))
+		for i := range v {
+			val, err := generic(v[i])
+			if err != nil {
+				return nil, err
+			}
+			a[i] = val
+		}
+		return a, nil
+	case nil, bool, string, int32, int64, float64:
+		return v, nil
+	}
+	return nil, fmt.Errorf("unsupported bson value of type %T", v)
+}
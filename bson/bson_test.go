@@ -0,0 +1,80 @@
+package bson
+
+import (
+	"bytes"
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	mbson "go.mongodb.org/mongo-driver/bson"
+	"testing"
+)
+
+type Stats struct {
+	Speed float64
+	Names []string
+	Items map[string]int
+}
+
+func TestMarshalEntity(t *testing.T) {
+	ecs := testutil.NewECS(t, Stats{})
+	id, _ := ecs.AddEntity(&testutil.Unit{Pos: testutil.Pos{X: 1, Y: 2}})
+	ew := ecs.MustGet(id)
+	assert.NoError(t, ew.Set(Stats{Speed: 1.5, Names: []string{"a", "b"}, Items: map[string]int{"gold": 3}}))
+	assert.NoError(t, ew.Set(testutil.Hostile{}))
+
+	doc, err := MarshalEntity(ecs, id)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	raw := mbson.Raw(doc)
+	assert.NoError(t, raw.Validate())
+	first, _ := raw.IndexErr(0)
+	assert.Equal(t, "_id", first.Key())
+	assert.Equal(t, int64(1), raw.Lookup("_id").Int64())
+	assert.Equal(t, "Unit", raw.Lookup("Type").StringValue())
+	assert.Equal(t, int64(2), raw.Lookup("Components", "Pos", "Y").Int64())
+	assert.Equal(t, 1.5, raw.Lookup("Components", "Stats", "Speed").Double())
+	assert.Equal(t, int64(3), raw.Lookup("Components", "Stats", "Items", "gold").Int64())
+
+	other := testutil.NewECS(t, Stats{})
+	got, err := UnmarshalEntity(other, doc)
+	assert.NoError(t, err)
+	assert.Equal(t, id, got)
+
+	a, _ := ecs.MarshalEntity(id)
+	b, _ := other.MarshalEntity(id)
+	assert.JSONEq(t, string(a), string(b))
+
+	_, err = MarshalEntity(ecs, 10)
+	assert.ErrorIs(t, err, kinshi.ErrNotFound)
+
+	_, err = UnmarshalEntity(other, []byte{1, 2})
+	assert.Error(t, err)
+}
+
+func TestMarshal(t *testing.T) {
+	ecs := testutil.NewECS(t, Stats{})
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&testutil.Unit{Pos: testutil.Pos{X: i}})
+	}
+	assert.NoError(t, ecs.MustGet(3).Set(testutil.Hostile{}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, Marshal(&buf, ecs))
+
+	other := testutil.NewECS(t, Stats{})
+	_, _ = other.AddEntity(&testutil.Unit{})
+	assert.NoError(t, Unmarshal(bytes.NewReader(buf.Bytes()), other))
+	assert.Equal(t, 10, other.Len())
+
+	var want, got bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&want))
+	assert.NoError(t, other.Marshal(&got))
+	assert.JSONEq(t, want.String(), got.String())
+
+	assert.Error(t, Unmarshal(bytes.NewReader(buf.Bytes()[:buf.Len()-3]), other))
+
+	assert.NoError(t, Unmarshal(bytes.NewReader(nil), other))
+	assert.Equal(t, 0, other.Len())
+}
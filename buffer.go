@@ -0,0 +1,156 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// doubleBuffers holds the next state of the double buffered components.
+type doubleBuffers struct {
+	mtx   sync.Mutex
+	types map[string]struct{}
+	next  map[EntityID]map[string]reflect.Value
+}
+
+// buffered checks if the component is double buffered.
+func (db *doubleBuffers) buffered(name string) bool {
+	if db == nil {
+		return false
+	}
+
+	_, ok := db.types[name]
+	return ok
+}
+
+// get returns the next buffer of the component. It's created as
+// copy of the current state on first access in the tick.
+func (db *doubleBuffers) get(id EntityID, name string, current interface{}) reflect.Value {
+	db.mtx.Lock()
+	defer db.mtx.Unlock()
+
+	comps, ok := db.next[id]
+	if !ok {
+		comps = map[string]reflect.Value{}
+		db.next[id] = comps
+	}
+
+	next, ok := comps[name]
+	if !ok {
+		next = deepCopy(reflect.ValueOf(current))
+		comps[name] = next
+	}
+
+	return next
+}
+
+// WithDoubleBuffering keeps a current and a next state of the given
+// component types (or names). Systems read the current state as usual with View and
+// write the next one with ViewNext. SwapBuffers applies the next state
+// at the end of the tick, so all systems of a tick read the same
+// consistent previous state, regardless of the order or parallelism in
+// which they run.
+//
+// For example:
+//    ecs := kinshi.New(kinshi.WithDoubleBuffering(Pos{}))
+//
+//    // Systems (may run in parallel)
+//    ew.View(func(p *Pos, v *Velocity) {
+//        ew.ViewNext(func(next *Pos) {
+//            next.X = p.X + v.X
+//        })
+//    })
+//
+//    // End of tick
+//    ecs.SwapBuffers()
+//
+// Writing double buffered components with View or Set still changes the
+// current state directly.
+func WithDoubleBuffering(types ...interface{}) Option {
+	return func(ecs *ECS) {
+		if ecs.buffers == nil {
+			ecs.buffers = &doubleBuffers{
+				types: map[string]struct{}{},
+				next:  map[EntityID]map[string]reflect.Value{},
+			}
+		}
+
+		ecs.afterOptions(func() {
+			for _, name := range ecs.names.componentNames(types) {
+				ecs.buffers.types[name] = struct{}{}
+			}
+		})
+	}
+}
+
+// ViewNext calls fn with pointers to the next state of the requested double
+// buffered components, see WithDoubleBuffering. The next state starts as
+// a copy of the current state on first access in a tick and replaces the
+// current state on SwapBuffers.
+func (ew *EntityWrap) ViewNext(fn interface{}) error {
+	if reflect.TypeOf(fn).Kind() != reflect.Func {
+		return ew.parent.misuse(fmt.Errorf("fn not function"))
+	}
+
+	buffers := ew.parent.buffers
+	compNames := ew.parent.names.viewSignature(reflect.TypeOf(fn))
+	callInstances := getCallArgs()
+	defer putCallArgs(callInstances)
+
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	ew.parent.enterView()
+	defer ew.parent.leaveView()
+
+	for i := range compNames {
+		if !buffers.buffered(compNames[i]) {
+			return ew.parent.misuse(fmt.Errorf("component '%s' isn't double buffered: %w", compNames[i], ErrNotFound))
+		}
+
+		ptr, err := ew.parent.names.fetchComponent(ew.ent, compNames[i])
+		if err != nil {
+			return ew.parent.misuse(fmt.Errorf("view on missing component '%s': %w", compNames[i], err))
+		}
+
+		*callInstances = append(*callInstances, buffers.get(ew.ent.ID(), compNames[i], ptr))
+	}
+
+	// If the user supplied function returns a error return it
+	return callError(reflect.ValueOf(fn).Call(*callInstances))
+}
+
+// SwapBuffers replaces the current state of all double buffered
+// components that have been written with ViewNext by their next state.
+// It should be called once at the end of every tick.
+func (ecs *ECS) SwapBuffers() {
+	if ecs.buffers == nil {
+		return
+	}
+
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	ecs.buffers.mtx.Lock()
+	defer ecs.buffers.mtx.Unlock()
+
+	for id, comps := range ecs.buffers.next {
+		entry, _, ok := ecs.findEntity(id)
+		if !ok {
+			continue
+		}
+
+		ecs.preserve(id)
+		for _, next := range comps {
+			_ = ecs.names.setComponentValue(entry.Ent, next.Interface())
+		}
+		ecs.touch(entry.Ent)
+	}
+
+	ecs.buffers.next = map[EntityID]map[string]reflect.Value{}
+}
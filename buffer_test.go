@@ -0,0 +1,50 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestDoubleBuffering(t *testing.T) {
+	ecs := New(WithDoubleBuffering(Pos{}))
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: i}})
+	}
+
+	// Every unit moves to the position of its neighbour, which
+	// only works if all units read the previous positions.
+	var wg sync.WaitGroup
+	for _, ew := range ecs.Iterate(Pos{}) {
+		wg.Add(1)
+		go func(ew *EntityWrap) {
+			defer wg.Done()
+
+			neighbour, err := ecs.Get(ew.GetEntity().ID()%10 + 1)
+			assert.NoError(t, err)
+
+			assert.NoError(t, neighbour.View(func(p *Pos) {
+				assert.NoError(t, ew.ViewNext(func(next *Pos) {
+					next.Y = p.X
+				}))
+			}))
+		}(ew)
+	}
+	wg.Wait()
+
+	for _, ew := range ecs.Iterate(Pos{}) {
+		assert.Equal(t, 0, ew.GetEntity().(*Unit).Pos.Y, "next state was applied before swap")
+	}
+
+	ecs.SwapBuffers()
+
+	for _, ew := range ecs.Iterate(Pos{}) {
+		u := ew.GetEntity().(*Unit)
+		assert.Equal(t, (u.Pos.X+1)%10, u.Pos.Y, "next state wasn't applied")
+	}
+
+	ew, _ := ecs.Iterate(Pos{}).First()
+	assert.Error(t, ew.ViewNext(func(h *Health) {}), "view next on component that isn't double buffered")
+}
@@ -0,0 +1,117 @@
+package kinshi
+
+import (
+	"sync"
+)
+
+// changeTracker keeps indexes that are derived from components in sync
+// with the entities. Writes, inserts and removals mark the ids of the
+// entities as dirty, so a sync only needs to look at those entities
+// instead of the whole world. After a reset the next sync rebuilds the
+// index from all entities.
+type changeTracker struct {
+	mtx     sync.Mutex
+	dirty   map[EntityID]struct{}
+	indexed map[EntityID]struct{}
+	synced  bool
+}
+
+// reset forces a update of all entities on the next sync.
+func (ct *changeTracker) reset() {
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+
+	ct.dirty = nil
+	ct.synced = false
+}
+
+// mark records that the entity with the id changed. Marks are only
+// needed while the tracker is synced, a rebuild looks at all entities.
+func (ct *changeTracker) mark(id EntityID) {
+	ct.mtx.Lock()
+	defer ct.mtx.Unlock()
+
+	if !ct.synced {
+		return
+	}
+
+	if ct.dirty == nil {
+		ct.dirty = map[EntityID]struct{}{}
+	}
+	ct.dirty[id] = struct{}{}
+}
+
+// sync calls update for all entities that changed since the last sync.
+// update returns false if the entity isn't indexed. remove is called for
+// previously indexed entities that are gone or returned false. The caller
+// needs to hold the lock of the ECS and serialize calls to the tracker.
+func (ct *changeTracker) sync(ecs *ECS, update func(id EntityID, ent Entity) bool, remove func(id EntityID)) {
+	ct.mtx.Lock()
+	dirty, rebuild := ct.dirty, !ct.synced
+	ct.dirty = nil
+	ct.synced = true
+	ct.mtx.Unlock()
+
+	if rebuild {
+		indexed := make(map[EntityID]struct{}, len(ct.indexed))
+		for i := range ecs.entities {
+			ent := ecs.entities[i].Ent
+			if update(ent.ID(), ent) {
+				indexed[ent.ID()] = struct{}{}
+			}
+		}
+
+		for id := range ct.indexed {
+			if _, ok := indexed[id]; !ok {
+				remove(id)
+			}
+		}
+
+		ct.indexed = indexed
+		return
+	}
+
+	for id := range dirty {
+		if entry, _, ok := ecs.findEntity(id); ok && update(id, entry.Ent) {
+			ct.indexed[id] = struct{}{}
+			continue
+		}
+
+		if _, ok := ct.indexed[id]; ok {
+			remove(id)
+			delete(ct.indexed, id)
+		}
+	}
+}
+
+// track registers the tracker, so that it's marked by all changes.
+func (ecs *ECS) track(ct *changeTracker) {
+	ecs.trackersMtx.Lock()
+	defer ecs.trackersMtx.Unlock()
+
+	trackers, _ := ecs.trackers.Load().([]*changeTracker)
+	ecs.trackers.Store(append(append([]*changeTracker{}, trackers...), ct))
+}
+
+// untrack unregisters the tracker.
+func (ecs *ECS) untrack(ct *changeTracker) {
+	ecs.trackersMtx.Lock()
+	defer ecs.trackersMtx.Unlock()
+
+	trackers, _ := ecs.trackers.Load().([]*changeTracker)
+	kept := make([]*changeTracker, 0, len(trackers))
+	for i := range trackers {
+		if trackers[i] != ct {
+			kept = append(kept, trackers[i])
+		}
+	}
+	ecs.trackers.Store(kept)
+}
+
+// markDirty marks the entity with the id as changed in all trackers.
+func (ecs *ECS) markDirty(id EntityID) {
+	trackers, _ := ecs.trackers.Load().([]*changeTracker)
+	for i := range trackers {
+		trackers[i].mark(id)
+	}
+}
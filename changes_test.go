@@ -0,0 +1,61 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChangeTracker_Dirty(t *testing.T) {
+	ecs := New()
+	ct := &changeTracker{}
+	ecs.track(ct)
+
+	var ids []EntityID
+	for i := 0; i < 100; i++ {
+		id, _ := ecs.AddEntity(&Unit{})
+		ids = append(ids, id)
+	}
+	dyn := &DynamicUnit{}
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	var updated, removed []EntityID
+	run := func() {
+		updated, removed = nil, nil
+		ecs.rlock()
+		ct.sync(ecs, func(id EntityID, ent Entity) bool {
+			updated = append(updated, id)
+			return true
+		}, func(id EntityID) {
+			removed = append(removed, id)
+		})
+		ecs.RUnlock()
+	}
+
+	// The first sync looks at all entities.
+	run()
+	assert.Len(t, updated, 101)
+
+	run()
+	assert.Empty(t, updated, "unchanged entities were updated")
+
+	// Only the changed entities are looked at again.
+	assert.NoError(t, ecs.MustGet(ids[3]).Set(Pos{X: 1}))
+	assert.NoError(t, ecs.MustGet(ids[7]).View(func(p *Pos) {}))
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 1}))
+	_ = ecs.RemoveByID(ids[9])
+	run()
+	assert.ElementsMatch(t, []EntityID{ids[3], ids[7], idDyn}, updated)
+	assert.Equal(t, []EntityID{ids[9]}, removed)
+
+	// Removed entities don't report their changes anymore.
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 2}))
+	_ = ecs.RemoveByID(idDyn)
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 3}))
+	run()
+	assert.Empty(t, updated)
+	assert.Equal(t, []EntityID{idDyn}, removed)
+
+	ct.reset()
+	run()
+	assert.Len(t, updated, 99)
+}
@@ -0,0 +1,54 @@
+package kinshi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"sort"
+)
+
+// Checksum computes a deterministic 64-bit FNV-1a hash over the ids,
+// types and components of all entities. Components are hashed in their
+// JSON encoded form, so fields that are excluded from serialization
+// (e.g. with `json:"-"`) don't influence the checksum.
+//
+// Two worlds with the same state produce the same checksum, which makes
+// it cheap to detect desyncs between lockstep peers or replays.
+//
+// Components that can't be encoded as JSON (e.g. with channel or func
+// fields) can't be hashed either, which is considered misuse and panics.
+func (ecs *ECS) Checksum() uint64 {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	h := fnv.New64a()
+
+	var idBuf [8]byte
+	var names []string
+	for i := range ecs.entities {
+		se := ecs.names.serializeEntity(&ecs.entities[i])
+
+		binary.LittleEndian.PutUint64(idBuf[:], uint64(se.ID))
+		_, _ = h.Write(idBuf[:])
+		_, _ = h.Write([]byte(se.Type))
+
+		names = names[:0]
+		for name := range se.Components {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			data, err := json.Marshal(se.Components[name])
+			if err != nil {
+				panic(fmt.Sprintf("kinshi: component '%s' of entity %d can't be hashed: %s", name, se.ID, err))
+			}
+
+			_, _ = h.Write([]byte(name))
+			_, _ = h.Write(data)
+		}
+	}
+
+	return h.Sum64()
+}
@@ -0,0 +1,46 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Checksum(t *testing.T) {
+	ecs := New()
+
+	dyn := &DynamicUnit{Name: Name{Value: "dyn"}}
+	_ = dyn.SetComponent(&Velocity{X: 1})
+	_ = dyn.SetComponent(&Pos{X: 2})
+	_, _ = ecs.AddEntity(dyn)
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "unit"}})
+
+	sum := ecs.Checksum()
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, sum, ecs.Checksum(), "checksum isn't deterministic")
+	}
+
+	assert.Equal(t, sum, ecs.Clone().Checksum(), "checksum of clone differs")
+
+	_ = ecs.MustGet(id).View(func(p *Pos) {
+		p.Y = 1
+	})
+
+	assert.NotEqual(t, sum, ecs.Checksum(), "checksum didn't change")
+}
+
+type Channel struct {
+	C chan int
+}
+
+func TestECS_ChecksumUnencodable(t *testing.T) {
+	ecs := New()
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Channel{C: make(chan int)})
+	_, _ = ecs.AddEntity(dyn)
+
+	assert.Panics(t, func() {
+		ecs.Checksum()
+	})
+}
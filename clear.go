@@ -0,0 +1,43 @@
+package kinshi
+
+import (
+	"sync/atomic"
+)
+
+// OnRemove registers a hook that is called for every entity that is
+// removed from the ECS, no matter if by RemoveEntity, Clear, unloading
+// a scene or a command buffer. The entity still has its id when the
+// hook is called.
+//
+// Important: Hooks are called while the ECS is locked, so they must
+// not call back into the ECS. Use a CommandBuffer to queue follow-up
+// mutations instead.
+func (ecs *ECS) OnRemove(fn func(ent Entity)) {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	ecs.removeHooks = append(ecs.removeHooks, fn)
+}
+
+// Clear removes all entities from the ECS and fires the removal hooks
+// for each of them. Registered types, hooks and options are kept, so a
+// level can be restarted without building a new ECS. If resetIDs is true
+// the id counter starts from the beginning again.
+func (ecs *ECS) Clear(resetIDs bool) {
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	for i := range ecs.entities {
+		ecs.detach(ecs.entities[i].Ent)
+	}
+
+	ecs.entities = []entityEntry{}
+	ecs.scenes = map[SceneID][]EntityID{}
+	ecs.typeCounts = map[string]int{}
+	ecs.invalidateIndexes()
+
+	if resetIDs {
+		atomic.StoreUint64(&ecs.idCounter, 0)
+	}
+}
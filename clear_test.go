@@ -0,0 +1,39 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Clear(t *testing.T) {
+	ecs := New()
+
+	var removed []EntityID
+	ecs.OnRemove(func(ent Entity) {
+		removed = append(removed, ent.ID())
+	})
+
+	var units []*Unit
+	for i := 0; i < 5; i++ {
+		u := &Unit{}
+		_, _ = ecs.AddEntity(u)
+		units = append(units, u)
+	}
+
+	_ = ecs.RemoveEntity(units[0])
+	assert.Equal(t, []EntityID{1}, removed, "hook wasn't fired on remove")
+
+	ecs.Clear(false)
+	assert.Equal(t, []EntityID{1, 2, 3, 4, 5}, removed, "hook wasn't fired on clear")
+	assert.Equal(t, 0, ecs.Iterate().Count())
+	for _, u := range units {
+		assert.Equal(t, EntityNone, u.ID(), "id wasn't reset")
+	}
+
+	id, _ := ecs.AddEntity(&Unit{})
+	assert.Equal(t, EntityID(6), id, "id counter was reset")
+
+	ecs.Clear(true)
+	id, _ = ecs.AddEntity(&Unit{})
+	assert.Equal(t, EntityID(1), id, "id counter wasn't reset")
+}
@@ -0,0 +1,154 @@
+package kinshi
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// dynamicResetter is implemented by BaseDynamicEntity and
+// used to detach cloned dynamic entities from the original.
+type dynamicResetter interface {
+	resetComponents()
+}
+
+// cloneEntity creates a deep copy of the entity including
+// all dynamic components.
+func cloneEntity(ent Entity) Entity {
+	c := deepCopy(reflect.ValueOf(ent)).Interface().(Entity)
+
+	if dyn, ok := ent.(DynamicEntity); ok {
+		if reset, ok := c.(dynamicResetter); ok {
+			reset.resetComponents()
+
+			comps := dyn.GetComponents()
+			for i := range comps {
+				_ = c.(DynamicEntity).SetComponent(deepCopy(reflect.ValueOf(comps[i])).Interface())
+			}
+
+			if ke, ok := ent.(KeyedEntity); ok {
+				for _, kc := range ke.GetKeyedComponents() {
+					_ = c.(KeyedEntity).SetKeyed(kc.Key, deepCopy(reflect.ValueOf(kc.Value)).Interface())
+				}
+			}
+		}
+	}
+
+	setVersion(c, entityVersion(ent))
+
+	return c
+}
+
+// Clone creates a independent deep copy of the ECS. All entities and
+// their dynamic components are copied, so changes to the clone won't
+// affect the original and vice versa. Registered types are carried over.
+//
+// Important: Unexported fields of components can't be accessed by
+// reflection and are only copied shallow. Dynamic entities need to
+// embed BaseDynamicEntity to get their dynamic components copied.
+func (ecs *ECS) Clone() *ECS {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	c := ecs.cloneShell()
+	c.entities = make([]entityEntry, len(ecs.entities))
+	for i := range ecs.entities {
+		c.entities[i] = entityEntry{
+			TypeName: ecs.entities[i].TypeName,
+			Ent:      cloneEntity(ecs.entities[i].Ent),
+		}
+		c.attach(c.entities[i].Ent)
+	}
+
+	return c
+}
+
+// cloneShell creates a ECS with the options, the registered types and
+// the bookkeeping of the entities of ecs, but without the entities
+// themselves. The caller needs to hold the lock.
+func (ecs *ECS) cloneShell() *ECS {
+	c := New()
+	c.names = ecs.names
+	c.idCounter = atomic.LoadUint64(&ecs.idCounter)
+	c.strict = ecs.strict
+	if ecs.profiler != nil {
+		WithProfiler()(c)
+	}
+	if ecs.compLocks != nil {
+		WithComponentLocks()(c)
+	}
+	if ecs.buffers != nil {
+		for name := range ecs.buffers.types {
+			WithDoubleBuffering(name)(c)
+		}
+	}
+	if ecs.history != nil {
+		for name := range ecs.history.types {
+			WithHistory(ecs.history.size, name)(c)
+		}
+	}
+	ecs.indexMtx.Lock()
+	for key, fi := range ecs.indexes {
+		if c.indexes == nil {
+			c.indexes = map[indexKey]*fieldIndex{}
+		}
+		c.indexes[key] = newFieldIndex(key, fi.t, c.names)
+		c.indexes[key].unique = fi.unique
+		c.track(&c.indexes[key].changes)
+	}
+	ecs.indexMtx.Unlock()
+	if len(ecs.decodeHooks) > 0 {
+		WithDecodeHooks(ecs.decodeHooks...)(c)
+	}
+	c.routines = ecs.routines
+	c.parThreshold = ecs.parThreshold
+	c.sceneCounter = ecs.sceneCounter
+
+	for k, v := range ecs.metaCache {
+		c.metaCache[k] = v
+	}
+
+	for k, v := range ecs.compMetaCache {
+		c.compMetaCache[k] = v
+	}
+
+	ecs.compTypes.Range(func(k, v interface{}) bool {
+		c.compTypes.Store(k, v)
+		return true
+	})
+
+	ecs.autoTypes.Range(func(k, v interface{}) bool {
+		c.autoTypes.Store(k, v)
+		return true
+	})
+
+	for k, v := range ecs.aliases {
+		if c.aliases == nil {
+			c.aliases = map[string]string{}
+		}
+		c.aliases[k] = v
+	}
+
+	for k, v := range ecs.netIDs {
+		c.bindNetID(v, k)
+	}
+
+	for k, v := range ecs.uuids {
+		c.bindUUID(v, k)
+	}
+
+	for k, v := range ecs.typeCounts {
+		c.typeCounts[k] = v
+	}
+
+	ecs.unknownMtx.Lock()
+	for k, v := range ecs.unknown {
+		c.setUnknown(k, v)
+	}
+	ecs.unknownMtx.Unlock()
+
+	for k, v := range ecs.scenes {
+		c.scenes[k] = append([]EntityID{}, v...)
+	}
+
+	return c
+}
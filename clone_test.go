@@ -0,0 +1,38 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Clone(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{Name: Name{Value: "unit"}})
+
+	dyn := &DynamicUnit{Name: Name{Value: "dyn"}}
+	_ = dyn.SetComponent(&Velocity{X: 1, Y: 2})
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	c := ecs.Clone()
+
+	diff, err := Diff(ecs, c)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty(), "clone differs from original")
+
+	_ = c.MustGet(idUnit).View(func(n *Name) {
+		n.Value = "changed"
+	})
+	_ = c.MustGet(idDyn).View(func(v *Velocity) {
+		v.X = 100
+	})
+	_, _ = c.AddEntity(&Unit{})
+
+	_ = ecs.MustGet(idUnit).View(func(n *Name) {
+		assert.Equal(t, "unit", n.Value, "static component is shared")
+	})
+	_ = ecs.MustGet(idDyn).View(func(v *Velocity) {
+		assert.Equal(t, 1.0, v.X, "dynamic component is shared")
+	})
+	assert.Equal(t, 2, ecs.Iterate().Count(), "entity was added to original")
+}
@@ -0,0 +1,295 @@
+// Package columnar exports components of a kinshi.ECS as columnar tables,
+// so that large simulation runs can be analyzed with tools like pandas,
+// DuckDB or Apache Arrow without writing converters. The tables are
+// written as Apache Parquet files.
+//
+// Every selected entity is a row with its id and type. The fields of the
+// components are flattened into columns named after the component and
+// the field path, e.g. Pos_X or Health_Max, using the same field names
+// as the JSON form of the components. Values that aren't numbers,
+// booleans or strings, like slices and maps, are stored as JSON strings.
+//
+// For example in DuckDB:
+//    SELECT Type, avg(Health_Value) FROM 'units.parquet' GROUP BY Type
+package columnar
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"github.com/xitongsys/parquet-go/writer"
+	"io"
+	"reflect"
+	"strings"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// Source provides the entities that are exported and the names of their
+// types. It's implemented by kinshi.ECS and kinshi.Snapshot, so a
+// snapshot can be exported in the background while the simulation
+// continues.
+type Source interface {
+	Iterate(types ...interface{}) kinshi.EntityIterator
+	TypeName(v interface{}) string
+}
+
+// column is a single column of the table. get extracts the value of the
+// column from the component value and returns nil for null.
+type column struct {
+	meta string
+	get  func(v reflect.Value) interface{}
+}
+
+// getter returns the value a column is based on. ok is false if a
+// pointer on the way is nil.
+type getter func(v reflect.Value) (reflect.Value, bool)
+
+type tableBuilder struct {
+	columns []column
+	stack   []reflect.Type
+}
+
+// leaf adds a column for the value of type t that is returned by get.
+func (b *tableBuilder) leaf(name string, t reflect.Type, get getter) {
+	kind := ""
+	var conv func(v reflect.Value) interface{}
+
+	switch {
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		kind = "type=BYTE_ARRAY, convertedtype=UTF8"
+		conv = func(v reflect.Value) interface{} {
+			if !v.CanAddr() {
+				c := reflect.New(v.Type()).Elem()
+				c.Set(v)
+				v = c
+			}
+
+			text, err := v.Addr().Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return nil
+			}
+			return string(text)
+		}
+	default:
+		switch t.Kind() {
+		case reflect.Bool:
+			kind = "type=BOOLEAN"
+			conv = func(v reflect.Value) interface{} { return v.Bool() }
+		case reflect.Int8, reflect.Int16, reflect.Int32:
+			kind = "type=INT32"
+			conv = func(v reflect.Value) interface{} { return int32(v.Int()) }
+		case reflect.Int, reflect.Int64:
+			kind = "type=INT64"
+			conv = func(v reflect.Value) interface{} { return v.Int() }
+		case reflect.Uint8, reflect.Uint16:
+			kind = "type=INT32"
+			conv = func(v reflect.Value) interface{} { return int32(v.Uint()) }
+		case reflect.Uint32:
+			kind = "type=INT64"
+			conv = func(v reflect.Value) interface{} { return int64(v.Uint()) }
+		case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+			kind = "type=INT64, convertedtype=UINT_64"
+			conv = func(v reflect.Value) interface{} { return int64(v.Uint()) }
+		case reflect.Float32:
+			kind = "type=FLOAT"
+			conv = func(v reflect.Value) interface{} { return float32(v.Float()) }
+		case reflect.Float64:
+			kind = "type=DOUBLE"
+			conv = func(v reflect.Value) interface{} { return v.Float() }
+		case reflect.String:
+			kind = "type=BYTE_ARRAY, convertedtype=UTF8"
+			conv = func(v reflect.Value) interface{} { return v.String() }
+		case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+			// Can't be represented.
+			return
+		}
+	}
+
+	if kind == "" {
+		kind = "type=BYTE_ARRAY, convertedtype=UTF8"
+		conv = func(v reflect.Value) interface{} {
+			data, err := json.Marshal(v.Interface())
+			if err != nil {
+				return nil
+			}
+			return string(data)
+		}
+	}
+
+	b.columns = append(b.columns, column{
+		meta: fmt.Sprintf("name=%s, %s, repetitiontype=OPTIONAL", name, kind),
+		get: func(v reflect.Value) interface{} {
+			val, ok := get(v)
+			if !ok {
+				return nil
+			}
+			return conv(val)
+		},
+	})
+}
+
+// add adds the columns for the value of type t. Structs are flattened,
+// except for recursive types which are stored as JSON.
+func (b *tableBuilder) add(name string, t reflect.Type, get getter) {
+	if t.Kind() == reflect.Ptr {
+		b.add(name, t.Elem(), func(v reflect.Value) (reflect.Value, bool) {
+			p, ok := get(v)
+			if !ok || p.IsNil() {
+				return reflect.Value{}, false
+			}
+			return p.Elem(), true
+		})
+		return
+	}
+
+	if t.Kind() != reflect.Struct || t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PtrTo(t).Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		b.leaf(name, t, get)
+		return
+	}
+
+	for i := range b.stack {
+		if b.stack[i] == t {
+			b.leaf(name, t, get)
+			return
+		}
+	}
+
+	b.stack = append(b.stack, t)
+	defer func() {
+		b.stack = b.stack[:len(b.stack)-1]
+	}()
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		field := i
+		fieldGet := func(v reflect.Value) (reflect.Value, bool) {
+			s, ok := get(v)
+			if !ok {
+				return reflect.Value{}, false
+			}
+			return s.Field(field), true
+		}
+
+		fieldName := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// Embedded structs without name are flattened like in encoding/json.
+		if f.Anonymous && fieldName == "" && ft.Kind() == reflect.Struct {
+			b.add(name, f.Type, fieldGet)
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if fieldName == "" {
+			fieldName = f.Name
+		}
+		b.add(name+"_"+fieldName, f.Type, fieldGet)
+	}
+}
+
+// component is a selected component and the columns of its fields.
+type component struct {
+	name  string
+	first int
+	count int
+}
+
+// WriteParquet writes the entities of src that contain all given
+// components as Parquet table to w. The components are passed as zero
+// values like for kinshi.ECS.Iterate. Besides the ID and Type of the
+// entities the table contains the columns of the given components.
+//
+// For example you want to analyze the units after a run:
+//    f, _ := os.Create("units.parquet")
+//    defer f.Close()
+//    err := columnar.WriteParquet(f, ecs, Pos{}, Health{})
+func WriteParquet(w io.Writer, src Source, types ...interface{}) error {
+	b := &tableBuilder{}
+	self := func(v reflect.Value) (reflect.Value, bool) {
+		return v, true
+	}
+
+	comps := make([]component, len(types))
+	for i := range types {
+		if types[i] == nil {
+			return fmt.Errorf("component %d is nil", i)
+		}
+
+		if _, ok := types[i].(string); ok {
+			return fmt.Errorf("component '%s' needs to be passed as value", types[i])
+		}
+
+		t := reflect.TypeOf(types[i])
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		comps[i] = component{name: src.TypeName(types[i]), first: len(b.columns)}
+		b.add(comps[i].name, t, self)
+		comps[i].count = len(b.columns) - comps[i].first
+	}
+
+	meta := []string{
+		"name=ID, type=INT64, convertedtype=UINT_64",
+		"name=Type, type=BYTE_ARRAY, convertedtype=UTF8",
+	}
+	for i := range b.columns {
+		meta = append(meta, b.columns[i].meta)
+	}
+
+	pw, err := writer.NewCSVWriterFromWriter(meta, w, 1)
+	if err != nil {
+		return err
+	}
+
+	for _, ew := range src.Iterate(types...) {
+		// The rows are buffered until they are flushed, so
+		// they can't be reused.
+		row := make([]interface{}, len(meta))
+
+		ent := ew.GetEntity()
+		row[0] = int64(ent.ID())
+		row[1] = ew.TypeName(ent)
+
+		names := ew.Components()
+		values := ew.ComponentValues()
+		for _, c := range comps {
+			for j := range names {
+				if names[j] != c.name {
+					continue
+				}
+
+				v := reflect.ValueOf(values[j]).Elem()
+				for k := c.first; k < c.first+c.count; k++ {
+					row[2+k] = b.columns[k].get(v)
+				}
+				break
+			}
+		}
+
+		if err := pw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	return pw.WriteStop()
+}
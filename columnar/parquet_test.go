@@ -0,0 +1,90 @@
+package columnar
+
+import (
+	"bytes"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+	"testing"
+)
+
+type Pos struct {
+	X, Y int
+}
+
+type Stats struct {
+	Speed  float32
+	Items  []string
+	Target *Pos
+	Label  string `json:"label"`
+	Secret string `json:"-"`
+	hidden int
+}
+
+type Unit struct {
+	kinshi.BaseEntity
+	Pos
+	Stats
+}
+
+type Dummy struct {
+	kinshi.BaseEntity
+	Pos
+}
+
+type row struct {
+	ID           int64    `parquet:"name=ID, type=INT64, convertedtype=UINT_64"`
+	Type         string   `parquet:"name=Type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PosX         *int64   `parquet:"name=Pos_X, type=INT64, repetitiontype=OPTIONAL"`
+	PosY         *int64   `parquet:"name=Pos_Y, type=INT64, repetitiontype=OPTIONAL"`
+	StatsSpeed   *float32 `parquet:"name=Stats_Speed, type=FLOAT, repetitiontype=OPTIONAL"`
+	StatsItems   *string  `parquet:"name=Stats_Items, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+	StatsTargetX *int64   `parquet:"name=Stats_Target_X, type=INT64, repetitiontype=OPTIONAL"`
+	StatsTargetY *int64   `parquet:"name=Stats_Target_Y, type=INT64, repetitiontype=OPTIONAL"`
+	StatsLabel   *string  `parquet:"name=Stats_label, type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"`
+}
+
+func TestWriteParquet(t *testing.T) {
+	ecs := kinshi.New()
+	_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: 1, Y: 2}, Stats: Stats{Speed: 0.5, Items: []string{"sword"}, Label: "a", Secret: "s"}})
+	_, _ = ecs.AddEntity(&Dummy{Pos: Pos{X: 5}})
+	_, _ = ecs.AddEntity(&Unit{Stats: Stats{Target: &Pos{X: 3, Y: 4}}})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, WriteParquet(buf, ecs, Pos{}, Stats{}))
+
+	pf, err := buffer.NewBufferFile(buf.Bytes())
+	assert.NoError(t, err)
+
+	pr, err := reader.NewParquetReader(pf, new(row), 1)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer pr.ReadStop()
+
+	assert.Equal(t, int64(2), pr.GetNumRows())
+	assert.Len(t, pr.SchemaHandler.SchemaElements, 10)
+
+	rows := make([]row, pr.GetNumRows())
+	assert.NoError(t, pr.Read(&rows))
+
+	assert.Equal(t, int64(1), rows[0].ID)
+	assert.Equal(t, "Unit", rows[0].Type)
+	assert.Equal(t, int64(1), *rows[0].PosX)
+	assert.Equal(t, int64(2), *rows[0].PosY)
+	assert.Equal(t, float32(0.5), *rows[0].StatsSpeed)
+	assert.Equal(t, `["sword"]`, *rows[0].StatsItems)
+	assert.Nil(t, rows[0].StatsTargetX)
+	assert.Equal(t, "a", *rows[0].StatsLabel)
+
+	assert.Equal(t, int64(3), rows[1].ID)
+	assert.Equal(t, "null", *rows[1].StatsItems)
+	assert.Equal(t, int64(3), *rows[1].StatsTargetX)
+	assert.Equal(t, int64(4), *rows[1].StatsTargetY)
+}
+
+func TestWriteParquet_Invalid(t *testing.T) {
+	assert.Error(t, WriteParquet(&bytes.Buffer{}, kinshi.New(), "Pos"))
+	assert.Error(t, WriteParquet(&bytes.Buffer{}, kinshi.New(), nil))
+}
@@ -0,0 +1,289 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// CommandType is the kind of mutation a Command performs.
+type CommandType string
+
+const (
+	CommandAddEntity       = CommandType("add_entity")
+	CommandRemoveEntity    = CommandType("remove_entity")
+	CommandSetComponent    = CommandType("set_component")
+	CommandRemoveComponent = CommandType("remove_component")
+)
+
+// Command is a single serializable mutation of the world.
+type Command struct {
+	Type       CommandType
+	ID         EntityID
+	EntityType string          `json:",omitempty"`
+	Component  string          `json:",omitempty"`
+	Data       json.RawMessage `json:",omitempty"`
+
+	// Live values that are used instead of Data
+	// when the command is applied from a buffer.
+	ent   Entity
+	value interface{}
+}
+
+// CommandLog is a ordered log of all commands that have been applied
+// through command buffers. It can be encoded as JSON and later be
+// replayed against a fresh world with Replay.
+type CommandLog struct {
+	mtx      sync.Mutex
+	Commands []Command
+}
+
+func (log *CommandLog) append(cmd Command) {
+	log.mtx.Lock()
+	defer log.mtx.Unlock()
+
+	log.Commands = append(log.Commands, cmd)
+}
+
+// SetCommandLog sets the log that all commands applied through
+// command buffers of this ECS are recorded to. Pass nil to stop
+// recording. Mutations that don't go through a command buffer
+// are not recorded.
+func (ecs *ECS) SetCommandLog(log *CommandLog) {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	ecs.commandLog = log
+}
+
+// CommandBuffer queues mutations so that they can be applied at a
+// later point, for example after iterating over the entities. It is
+// safe to queue commands from multiple go routines.
+type CommandBuffer struct {
+	sync.Mutex
+	ecs      *ECS
+	commands []Command
+}
+
+// NewCommandBuffer creates a new empty command buffer for the ECS.
+func (ecs *ECS) NewCommandBuffer() *CommandBuffer {
+	return &CommandBuffer{ecs: ecs}
+}
+
+func (cb *CommandBuffer) push(cmd Command) {
+	cb.Lock()
+	defer cb.Unlock()
+
+	cb.commands = append(cb.commands, cmd)
+}
+
+// Len returns the number of queued commands.
+func (cb *CommandBuffer) Len() int {
+	cb.Lock()
+	defer cb.Unlock()
+
+	return len(cb.commands)
+}
+
+// AddEntity queues the insertion of the entity. The id of the entity
+// is reserved immediately so that it can already be referenced.
+func (cb *CommandBuffer) AddEntity(ent Entity) (EntityID, error) {
+	if reflect.TypeOf(ent).Kind() != reflect.Ptr {
+		return EntityNone, fmt.Errorf("please pass your entity as pointer")
+	}
+
+	if ent.ID() == 0 {
+		ent.SetID(cb.ecs.nextId())
+	}
+
+	cb.push(Command{
+		Type:       CommandAddEntity,
+		ID:         ent.ID(),
+		EntityType: cb.ecs.names.getTypeName(ent),
+		ent:        ent,
+	})
+
+	return ent.ID(), nil
+}
+
+// RemoveEntity queues the removal of the entity with the given id.
+func (cb *CommandBuffer) RemoveEntity(id EntityID) {
+	cb.push(Command{
+		Type: CommandRemoveEntity,
+		ID:   id,
+	})
+}
+
+// SetComponent queues overwriting (or adding in case of a dynamic
+// entity) the component of the entity with a copy of c.
+func (cb *CommandBuffer) SetComponent(id EntityID, c interface{}) {
+	val := reflect.ValueOf(c)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	cb.push(Command{
+		Type:      CommandSetComponent,
+		ID:        id,
+		Component: cb.ecs.names.getTypeName(c),
+		value:     deepCopy(val).Interface(),
+	})
+}
+
+// RemoveComponent queues the removal of a dynamic component. If
+// c is a string the component will be removed by name.
+func (cb *CommandBuffer) RemoveComponent(id EntityID, c interface{}) {
+	name, ok := c.(string)
+	if !ok {
+		name = cb.ecs.names.getTypeName(c)
+	}
+
+	cb.push(Command{
+		Type:      CommandRemoveComponent,
+		ID:        id,
+		Component: name,
+	})
+}
+
+// Flush applies all queued commands in order and clears the buffer.
+// If a command fails the remaining commands are still applied and
+// the first error is returned.
+func (cb *CommandBuffer) Flush() error {
+	cb.Lock()
+	commands := cb.commands
+	cb.commands = nil
+	cb.Unlock()
+
+	cb.ecs.checkMutation()
+	cb.ecs.lock()
+	defer cb.ecs.Unlock()
+
+	var firstErr error
+	for i := range commands {
+		if err := cb.ecs.applyCommand(&commands[i]); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		if cb.ecs.commandLog != nil {
+			if err := commands[i].capture(cb.ecs.names); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			cb.ecs.commandLog.append(commands[i])
+		}
+	}
+
+	return firstErr
+}
+
+// capture encodes the live values of the command into Data.
+func (cmd *Command) capture(names *typeNames) error {
+	var err error
+
+	switch cmd.Type {
+	case CommandAddEntity:
+		se := names.serializeEntity(&entityEntry{TypeName: cmd.EntityType, Ent: cmd.ent})
+		cmd.Data, err = json.Marshal(se.Components)
+	case CommandSetComponent:
+		cmd.Data, err = json.Marshal(cmd.value)
+	}
+
+	cmd.ent = nil
+	cmd.value = nil
+
+	return err
+}
+
+// applyCommand applies a single command. If the command contains live
+// values they are used, otherwise Data is decoded through the registered
+// types. The caller needs to hold the write lock.
+func (ecs *ECS) applyCommand(cmd *Command) error {
+	switch cmd.Type {
+	case CommandAddEntity:
+		ent := entityEntry{TypeName: cmd.EntityType, Ent: cmd.ent}
+
+		if ent.Ent == nil {
+			se := serializedEntity{ID: cmd.ID, Type: cmd.EntityType}
+			if err := json.Unmarshal(cmd.Data, &se.Components); err != nil {
+				return err
+			}
+
+			var ok bool
+			var err error
+			if ent, ok, err = ecs.deserializeEntity(&se); !ok || (err != nil && ecs.strict) {
+				return err
+			}
+			ent.Ent.SetID(cmd.ID)
+		} else if err := ecs.cacheType(ent.Ent); err != nil {
+			return err
+		}
+
+		return ecs.insertEntity(ent)
+	case CommandRemoveEntity:
+		_, idx, ok := ecs.findEntity(cmd.ID)
+		if !ok {
+			return ErrNotFound
+		}
+		ecs.removeAt(idx)
+		return nil
+	case CommandSetComponent:
+		entry, _, ok := ecs.findEntity(cmd.ID)
+		if !ok {
+			return ErrNotFound
+		}
+		ecs.preserve(cmd.ID)
+
+		if cmd.value != nil {
+			return ecs.touched(entry.Ent, ecs.names.setComponentValue(entry.Ent, cmd.value))
+		}
+
+		var val interface{}
+		if err := json.Unmarshal(cmd.Data, &val); err != nil {
+			return err
+		}
+		return ecs.touched(entry.Ent, ecs.decodeComponent(entry.Ent, cmd.Component, val))
+	case CommandRemoveComponent:
+		entry, _, ok := ecs.findEntity(cmd.ID)
+		if !ok {
+			return ErrNotFound
+		}
+
+		dyn, ok := entry.Ent.(DynamicEntity)
+		if !ok {
+			return fmt.Errorf("static component can't be removed")
+		}
+		return ecs.touched(entry.Ent, dyn.RemoveComponent(cmd.Component))
+	}
+
+	return fmt.Errorf("unknown command type '%s'", cmd.Type)
+}
+
+// Replay applies all commands of the log in order to the ECS. Replaying
+// a log against a fresh world with the same registered types reproduces
+// the recorded mutations exactly, including the entity ids.
+func Replay(log *CommandLog, ecs *ECS) error {
+	log.mtx.Lock()
+	defer log.mtx.Unlock()
+
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	for i := range log.Commands {
+		cmd := log.Commands[i]
+		cmd.ent = nil
+		cmd.value = nil
+
+		if err := ecs.applyCommand(&cmd); err != nil {
+			return fmt.Errorf("command %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
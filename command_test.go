@@ -0,0 +1,88 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCommandBuffer(t *testing.T) {
+	ecs := New()
+	ecs.RegisterComponent(Velocity{})
+
+	log := &CommandLog{}
+	ecs.SetCommandLog(log)
+
+	cb := ecs.NewCommandBuffer()
+	for i := 0; i < 10; i++ {
+		_, err := cb.AddEntity(&Unit{Name: Name{Value: "unit"}})
+		assert.NoError(t, err)
+	}
+	idDyn, _ := cb.AddEntity(&DynamicUnit{Name: Name{Value: "dyn"}})
+
+	assert.Equal(t, 0, ecs.Iterate().Count(), "commands were applied before flush")
+	assert.NoError(t, cb.Flush(), "flush failed")
+	assert.Equal(t, 11, ecs.Iterate().Count(), "entities weren't added")
+	assert.Equal(t, 0, cb.Len(), "buffer wasn't cleared")
+
+	// Queue mutations while iterating
+	for _, ew := range ecs.IterateSpecific(Unit{}) {
+		if ew.GetEntity().ID()%2 == 0 {
+			cb.RemoveEntity(ew.GetEntity().ID())
+		} else {
+			cb.SetComponent(ew.GetEntity().ID(), Pos{X: 5, Y: 5})
+		}
+	}
+	cb.SetComponent(idDyn, &Velocity{X: 1})
+	assert.NoError(t, cb.Flush(), "flush failed")
+
+	cb.RemoveComponent(idDyn, Name{})
+	assert.Error(t, cb.Flush(), "removing static component didn't fail")
+
+	assert.Equal(t, 5, ecs.IterateSpecific(Unit{}).Count(), "entities weren't removed")
+	assert.Equal(t, 5, ecs.Iterate(Pos{}).Count())
+	_ = ecs.MustGet(idDyn).View(func(v *Velocity) {
+		assert.Equal(t, 1.0, v.X, "dynamic component wasn't set")
+	})
+
+	data, err := json.Marshal(log)
+	if !assert.NoError(t, err, "couldn't encode log") {
+		return
+	}
+
+	var decoded CommandLog
+	if !assert.NoError(t, json.Unmarshal(data, &decoded), "couldn't decode log") {
+		return
+	}
+
+	replayed := New()
+	replayed.RegisterEntity(&Unit{})
+	replayed.RegisterEntity(&DynamicUnit{})
+	replayed.RegisterComponent(Velocity{})
+	assert.NoError(t, Replay(&decoded, replayed), "replay failed")
+
+	sum := ecs.Checksum()
+	replayedSum := replayed.Checksum()
+	assert.Equal(t, sum, replayedSum, "replayed world differs")
+}
+
+func TestCommandBuffer_Touch(t *testing.T) {
+	ecs := New(WithSpatialIndex(Pos{}, NewHashGrid(8)))
+	assert.NoError(t, ecs.Index(Name{}, "Value"))
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "old"}})
+	assert.Equal(t, 1, ecs.GetByIndex(Name{}, "Value", "old").Count())
+	assert.Equal(t, 1, ecs.IterateNear(0, 0, 1).Count())
+	version := ecs.MustGet(id).Version()
+
+	cb := ecs.NewCommandBuffer()
+	cb.SetComponent(id, Name{Value: "new"})
+	cb.SetComponent(id, Pos{X: 50, Y: 50})
+	assert.NoError(t, cb.Flush())
+
+	assert.Greater(t, ecs.MustGet(id).Version(), version, "version didn't change")
+	assert.Equal(t, 0, ecs.GetByIndex(Name{}, "Value", "old").Count())
+	assert.Equal(t, 1, ecs.GetByIndex(Name{}, "Value", "new").Count())
+	assert.Equal(t, 0, ecs.IterateNear(0, 0, 1).Count())
+	assert.Equal(t, 1, ecs.IterateNear(50, 50, 1).Count())
+}
@@ -0,0 +1,117 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// CompiledView is a precompiled view on a fixed set of components that is
+// created by CompileView. The field of every component is resolved once per
+// entity type, so running a compiled view doesn't need to walk the function
+// signature or to look up fields by name.
+type CompiledView struct {
+	parent *ECS
+	names  []string
+
+	mtx    sync.RWMutex
+	fields map[reflect.Type][]int
+	comps  sync.Pool
+}
+
+// CompileView creates a compiled view on the given components.
+//
+// For example:
+//    cv := ecs.CompileView(Pos{}, Velocity{})
+//    for _, ew := range ecs.Iterate(Pos{}, Velocity{}) {
+//        cv.Run(ew.GetEntity(), func(c []interface{}) {
+//            p, v := c[0].(*Pos), c[1].(*Velocity)
+//            p.X += v.X
+//        })
+//    }
+func (ecs *ECS) CompileView(types ...interface{}) *CompiledView {
+	ecs.checkQueryTypes(types...)
+
+	names := ecs.componentNames(types)
+	return &CompiledView{
+		parent: ecs,
+		names:  names,
+		fields: map[reflect.Type][]int{},
+		comps: sync.Pool{
+			New: func() interface{} {
+				return make([]interface{}, len(names))
+			},
+		},
+	}
+}
+
+// resolve returns the field indices of the components for the
+// entity type. Components that aren't a field are marked with -1.
+func (cv *CompiledView) resolve(t reflect.Type) []int {
+	cv.mtx.RLock()
+	fields, ok := cv.fields[t]
+	cv.mtx.RUnlock()
+
+	if ok {
+		return fields
+	}
+
+	fields = make([]int, len(cv.names))
+	for i := range cv.names {
+		fields[i] = -1
+		if t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct {
+			if sc, ok := cv.parent.names.staticField(t.Elem(), cv.names[i]); ok && sc.kind == componentValue {
+				fields[i] = sc.index
+			}
+		}
+	}
+
+	cv.mtx.Lock()
+	cv.fields[t] = fields
+	cv.mtx.Unlock()
+
+	return fields
+}
+
+// Run calls fn with pointers to the components of the entity in the order
+// in which the types were passed to CompileView. The slice is reused, so
+// it must not be stored.
+func (cv *CompiledView) Run(ent Entity, fn func(c []interface{})) error {
+	cv.parent.rlock()
+	defer cv.parent.RUnlock()
+
+	cv.parent.enterView()
+	defer cv.parent.leaveView()
+
+	fields := cv.resolve(reflect.TypeOf(ent))
+	comps := cv.comps.Get().([]interface{})
+	defer func() {
+		for i := range comps {
+			comps[i] = nil
+		}
+		cv.comps.Put(comps)
+	}()
+
+	val := reflect.ValueOf(ent).Elem()
+	for i := range fields {
+		if fields[i] >= 0 {
+			comps[i] = val.Field(fields[i]).Addr().Interface()
+			continue
+		}
+
+		ptr, err := cv.parent.names.fetchComponent(ent, cv.names[i])
+		if err != nil {
+			return cv.parent.misuse(fmt.Errorf("view on missing component '%s': %w", cv.names[i], err))
+		}
+		comps[i] = ptr
+	}
+
+	unlock := cv.parent.lockComponents(cv.names...)
+	defer unlock()
+
+	cv.parent.preserve(ent.ID())
+	fn(comps)
+	cv.parent.touch(ent)
+
+	return nil
+}
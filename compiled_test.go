@@ -0,0 +1,62 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_CompileView(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1}, Health: Health{Value: 10}})
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Pos{X: 2})
+	_ = dyn.SetComponent(&Health{Value: 20})
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	cv := ecs.CompileView(Pos{}, Health{})
+	for i := 0; i < 3; i++ {
+		for _, ew := range ecs.Iterate(Pos{}, Health{}) {
+			assert.NoError(t, cv.Run(ew.GetEntity(), func(c []interface{}) {
+				p, h := c[0].(*Pos), c[1].(*Health)
+				p.X += h.Value
+			}))
+		}
+	}
+
+	_ = ecs.MustGet(idUnit).View(func(p *Pos) {
+		assert.Equal(t, 31, p.X)
+	})
+	_ = ecs.MustGet(idDyn).View(func(p *Pos) {
+		assert.Equal(t, 62, p.X)
+	})
+
+	assert.Error(t, ecs.CompileView(Velocity{}).Run(ecs.MustGet(idUnit).GetEntity(), func(c []interface{}) {
+		t.Error("view on missing component was run")
+	}))
+}
+
+func BenchmarkCompiledView_Run(b *testing.B) {
+	ecs := New()
+	id, _ := ecs.AddEntity(&Unit{})
+	ent := ecs.MustGet(id).GetEntity()
+
+	b.Run("View", func(b *testing.B) {
+		ew := ecs.MustGet(id)
+		for i := 0; i < b.N; i++ {
+			_ = ew.View(func(p *Pos, h *Health) {
+				p.X++
+			})
+		}
+	})
+
+	b.Run("CompiledView", func(b *testing.B) {
+		cv := ecs.CompileView(Pos{}, Health{})
+		for i := 0; i < b.N; i++ {
+			_ = cv.Run(ent, func(c []interface{}) {
+				c[0].(*Pos).X++
+			})
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package kinshi
+
+import (
+	"sort"
+	"sync"
+)
+
+// componentLocks holds a read write mutex per component name.
+type componentLocks struct {
+	mtx   sync.Mutex
+	locks map[string]*sync.RWMutex
+}
+
+func (cl *componentLocks) get(name string) *sync.RWMutex {
+	cl.mtx.Lock()
+	defer cl.mtx.Unlock()
+
+	l, ok := cl.locks[name]
+	if !ok {
+		l = &sync.RWMutex{}
+		cl.locks[name] = l
+	}
+	return l
+}
+
+// WithComponentLocks guards every component type with its own lock. The
+// lock of the ECS only protects the structure of the world, i.e. which
+// entities exist and which components they have, and is only held shared
+// while components are accessed. So by default two systems that write to
+// the same component from different go routines race, and the only way
+// to prevent that is to serialize them through the lock of the ECS.
+//
+// With this option View, UpdateAll, CompiledView.Run, Set, Decode, Apply
+// and ViewKeyed additionally lock the components they access exclusively
+// and Into locks its component shared. Systems that write Pos don't block
+// systems that read Health, while systems that share a component are
+// serialized.
+//
+// The locks of a call are always taken in the order of the component
+// names, so calls on overlapping components can't dead lock each other.
+// A View must not be nested into a View or UpdateAll that accesses the
+// same component though, because a lock isn't reentrant. ViewSpecific,
+// Each and Iterate don't lock any components.
+func WithComponentLocks() Option {
+	return func(ecs *ECS) {
+		ecs.compLocks = &componentLocks{
+			locks: map[string]*sync.RWMutex{},
+		}
+	}
+}
+
+// lockComponents exclusively locks the components with the given names
+// and returns a function that unlocks them again. The caller needs to hold
+// the lock of the ECS.
+func (ecs *ECS) lockComponents(names ...string) func() {
+	return ecs.lockNamed(names, false)
+}
+
+// rlockComponents is like lockComponents, but locks the components
+// shared.
+func (ecs *ECS) rlockComponents(names ...string) func() {
+	return ecs.lockNamed(names, true)
+}
+
+func (ecs *ECS) lockNamed(names []string, shared bool) func() {
+	if ecs.compLocks == nil {
+		return func() {}
+	}
+
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+
+	var locks []*sync.RWMutex
+	for i := range sorted {
+		if i > 0 && sorted[i] == sorted[i-1] {
+			continue
+		}
+
+		l := ecs.compLocks.get(sorted[i])
+		if shared {
+			l.RLock()
+		} else {
+			l.Lock()
+		}
+		locks = append(locks, l)
+	}
+
+	return func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			if shared {
+				locks[i].RUnlock()
+			} else {
+				locks[i].Unlock()
+			}
+		}
+	}
+}
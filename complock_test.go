@@ -0,0 +1,110 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithComponentLocks(t *testing.T) {
+	ecs := New(WithComponentLocks())
+
+	id, _ := ecs.AddEntity(&Unit{})
+	ew := ecs.MustGet(id)
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_ = ew.View(func(p *Pos) {
+					p.X++
+				})
+				_ = ecs.UpdateAll(func(p *Pos, h *Health) {
+					p.Y++
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	_ = ew.View(func(p *Pos) {
+		assert.Equal(t, Pos{X: 400, Y: 400}, *p)
+	})
+
+	// A view on a different component isn't blocked.
+	inside := make(chan struct{})
+	release := make(chan struct{})
+	go func() {
+		_ = ew.View(func(p *Pos) {
+			close(inside)
+			<-release
+		})
+	}()
+	<-inside
+
+	done := make(chan struct{})
+	go func() {
+		_ = ew.View(func(h *Health) {})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("view on other component was blocked")
+	}
+
+	blocked := make(chan struct{})
+	go func() {
+		_ = ew.Set(Pos{})
+		close(blocked)
+	}()
+
+	select {
+	case <-blocked:
+		t.Error("set on locked component wasn't blocked")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-blocked
+}
+
+func TestWithComponentLocks_Order(t *testing.T) {
+	ecs := New(WithComponentLocks())
+
+	id, _ := ecs.AddEntity(&Unit{})
+	ew := ecs.MustGet(id)
+
+	// Views that request the same components in a different order
+	// don't dead lock each other.
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if g%2 == 0 {
+					_ = ew.View(func(p *Pos, h *Health) {
+						p.X++
+					})
+				} else {
+					_ = ew.View(func(h *Health, p *Pos) {
+						h.Value++
+					})
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	var pos Pos
+	var health Health
+	assert.NoError(t, ew.Into(&pos))
+	assert.NoError(t, ew.Into(&health))
+	assert.Equal(t, 400, pos.X)
+	assert.Equal(t, 400, health.Value)
+}
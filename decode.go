@@ -0,0 +1,210 @@
+package kinshi
+
+import (
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultDecodeHooks are always used to decode components during
+// Unmarshal. They restore values that encoding/json writes as strings:
+//   - time.Time and time.Duration
+//   - types that implement encoding.TextUnmarshaler, like most enums
+//   - EntityID and NetID given as strings
+var DefaultDecodeHooks = []mapstructure.DecodeHookFunc{
+	mapstructure.StringToTimeHookFunc(time.RFC3339Nano),
+	mapstructure.StringToTimeDurationHookFunc(),
+	mapstructure.TextUnmarshallerHookFunc(),
+	StringToIDHookFunc(),
+}
+
+// StringToIDHookFunc returns a decode hook that converts
+// strings to EntityID and NetID.
+func StringToIDHookFunc() mapstructure.DecodeHookFunc {
+	return func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+
+		switch t {
+		case reflect.TypeOf(EntityID(0)):
+			id, err := strconv.ParseUint(reflect.ValueOf(data).String(), 10, 64)
+			return EntityID(id), err
+		case reflect.TypeOf(NetID(0)):
+			id, err := strconv.ParseUint(reflect.ValueOf(data).String(), 10, 64)
+			return NetID(id), err
+		}
+
+		return data, nil
+	}
+}
+
+// WithDecodeHooks registers decode hooks on creation. See RegisterDecodeHook.
+func WithDecodeHooks(hooks ...mapstructure.DecodeHookFunc) Option {
+	return func(ecs *ECS) {
+		ecs.decodeHooks = append(ecs.decodeHooks, hooks...)
+		ecs.decodeHook = ecs.composeDecodeHooks()
+	}
+}
+
+// RegisterDecodeHook adds hooks that are used to convert the decoded JSON
+// data into the types of the components during Unmarshal, LoadScene and
+// ApplyReplication. The hooks run before DefaultDecodeHooks, in the order
+// in which they were registered.
+//
+// For example you want to load a enum from its name:
+//    ecs.RegisterDecodeHook(func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+//        if f.Kind() == reflect.String && t == reflect.TypeOf(Faction(0)) {
+//            return ParseFaction(data.(string))
+//        }
+//        return data, nil
+//    })
+func (ecs *ECS) RegisterDecodeHook(hooks ...mapstructure.DecodeHookFunc) {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	WithDecodeHooks(hooks...)(ecs)
+}
+
+// composeDecodeHooks chains the internal hook that restores interfaces
+// and maps, the registered hooks and the default hooks.
+func (ecs *ECS) composeDecodeHooks() mapstructure.DecodeHookFunc {
+	all := make([]mapstructure.DecodeHookFunc, 0, len(ecs.decodeHooks)+len(DefaultDecodeHooks)+1)
+	all = append(all, ecs.fidelityHook)
+	all = append(all, ecs.decodeHooks...)
+	all = append(all, DefaultDecodeHooks...)
+	return mapstructure.ComposeDecodeHookFunc(all...)
+}
+
+// decode decodes the input into output with the registered hooks. Just
+// like encoding/json the json tags are respected and embedded structs
+// are squashed.
+func (ecs *ECS) decode(input interface{}, output interface{}) error {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: ecs.decodeHook,
+		Result:     output,
+		TagName:    "json",
+		Squash:     true,
+	})
+	if err != nil {
+		return err
+	}
+
+	return dec.Decode(input)
+}
+
+// NewEntity creates a entity of the registered type with the given name
+// without adding it. The components are decoded from generic data like
+// maps and slices, just like Unmarshal decodes them from JSON. Components
+// that aren't static are set as dynamic components, which need to be
+// registered with RegisterComponent.
+//
+// For example you want to spawn a entity that is described in a script:
+//    ent, err := ecs.NewEntity("Unit", map[string]interface{}{
+//        "Pos": map[string]interface{}{"X": 10, "Y": 2},
+//    })
+func (ecs *ECS) NewEntity(typeName string, components map[string]interface{}) (Entity, error) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	meta, ok := ecs.lookupType(typeName)
+	if !ok {
+		return nil, fmt.Errorf("entity type '%s': %w", typeName, ErrNotFound)
+	}
+
+	ent := reflect.New(meta.t).Interface().(Entity)
+	for comp, val := range components {
+		if err := ecs.decodeComponent(ent, comp, val); err != nil {
+			return nil, fmt.Errorf("component '%s': %w", comp, err)
+		}
+	}
+
+	return ent, nil
+}
+
+// Decode decodes generic data like maps and slices into the component of
+// the wrapped Entity with the given name. Fields that are missing in the
+// data are reset. If the Entity has no static component of that name a
+// new registered dynamic component is set.
+//
+// For example:
+//    ew.Decode("Pos", map[string]interface{}{"X": 10, "Y": 2})
+func (ew *EntityWrap) Decode(name string, data interface{}) error {
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	unlock := ew.parent.lockComponents(name)
+	defer unlock()
+
+	if err := ew.parent.decodeComponent(ew.ent, name, data); err != nil {
+		return fmt.Errorf("decode of component '%s': %w", name, err)
+	}
+	ew.parent.touch(ew.ent)
+
+	return nil
+}
+
+// Apply decodes generic data like maps into existing components of the
+// wrapped Entity. In contrast to Decode the fields that are missing in the
+// data keep their values, so console commands, editors or data driven
+// effects can patch entities. Either all components are changed or none,
+// if a component is missing or can't be decoded.
+//
+// For example:
+//    ew.Apply(map[string]interface{}{
+//        "Pos":    map[string]interface{}{"X": 3},
+//        "Health": map[string]interface{}{"Value": 10},
+//    })
+func (ew *EntityWrap) Apply(components map[string]interface{}) error {
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	resolved := make([]string, len(names))
+	for i := range names {
+		resolved[i] = ew.parent.resolveAlias(names[i])
+	}
+
+	unlock := ew.parent.lockComponents(resolved...)
+	defer unlock()
+
+	ptrs := make([]reflect.Value, len(names))
+	values := make([]reflect.Value, len(names))
+	for i := range names {
+		ptr, err := ew.parent.names.fetchComponent(ew.ent, resolved[i])
+		if err != nil {
+			return fmt.Errorf("apply to component '%s': %w", names[i], err)
+		}
+
+		ptrs[i] = reflect.ValueOf(ptr)
+		values[i] = reflect.New(ptrs[i].Type().Elem())
+		values[i].Elem().Set(deepCopy(ptrs[i].Elem()))
+
+		if err := ew.parent.decode(components[names[i]], values[i].Interface()); err != nil {
+			return fmt.Errorf("apply to component '%s': %w", names[i], err)
+		}
+	}
+
+	for i := range ptrs {
+		ptrs[i].Elem().Set(values[i].Elem())
+	}
+	ew.parent.touch(ew.ent)
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package kinshi
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Faction int
+
+const (
+	FactionNone Faction = iota
+	FactionOrcs
+	FactionElves
+)
+
+var factionNames = []string{"none", "orcs", "elves"}
+
+func (f Faction) MarshalText() ([]byte, error) {
+	return []byte(factionNames[f]), nil
+}
+
+func (f *Faction) UnmarshalText(text []byte) error {
+	for i := range factionNames {
+		if factionNames[i] == string(text) {
+			*f = Faction(i)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown faction '%s'", text)
+}
+
+type Mood int
+
+type Timed struct {
+	Spawned  time.Time
+	Cooldown time.Duration
+	Faction  Faction
+	Target   EntityID
+}
+
+type Moody struct {
+	Value Mood
+}
+
+type TimedUnit struct {
+	BaseEntity
+	Timed
+	Moody
+}
+
+func TestDecodeHooks_Defaults(t *testing.T) {
+	ecs := New(WithStrict())
+	assert.NoError(t, ecs.RegisterEntity(&TimedUnit{}))
+
+	spawned := time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC)
+	_, err := ecs.AddEntity(&TimedUnit{Timed: Timed{
+		Spawned:  spawned,
+		Cooldown: 1500 * time.Millisecond,
+		Faction:  FactionElves,
+		Target:   42,
+	}})
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.RegisterEntity(&TimedUnit{}))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	timed := loaded.Iterate(Timed{})[0].GetEntity().(*TimedUnit).Timed
+	assert.True(t, spawned.Equal(timed.Spawned))
+	assert.Equal(t, 1500*time.Millisecond, timed.Cooldown)
+	assert.Equal(t, FactionElves, timed.Faction)
+	assert.Equal(t, EntityID(42), timed.Target)
+
+	// Ids and durations are also accepted as strings.
+	snapshot := `[{"ID":1,"Type":"TimedUnit","Components":{"Timed":{"Cooldown":"2s","Target":"7","Faction":"orcs"}}}]`
+	assert.NoError(t, loaded.Unmarshal(bytes.NewBufferString(snapshot)))
+
+	timed = loaded.Iterate(Timed{})[0].GetEntity().(*TimedUnit).Timed
+	assert.Equal(t, 2*time.Second, timed.Cooldown)
+	assert.Equal(t, EntityID(7), timed.Target)
+	assert.Equal(t, FactionOrcs, timed.Faction)
+}
+
+func TestDecodeHooks_Register(t *testing.T) {
+	moods := map[string]Mood{"calm": 1, "angry": 2}
+
+	ecs := New(WithStrict())
+	assert.NoError(t, ecs.RegisterEntity(&TimedUnit{}))
+
+	snapshot := `[{"ID":1,"Type":"TimedUnit","Components":{"Moody":{"Value":"angry"}}}]`
+	assert.Error(t, ecs.Unmarshal(bytes.NewBufferString(snapshot)))
+
+	ecs.RegisterDecodeHook(func(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+		if f.Kind() == reflect.String && t == reflect.TypeOf(Mood(0)) {
+			return moods[data.(string)], nil
+		}
+		return data, nil
+	})
+	assert.NoError(t, ecs.Unmarshal(bytes.NewBufferString(snapshot)))
+	assert.Equal(t, Mood(2), ecs.Iterate(Moody{})[0].GetEntity().(*TimedUnit).Moody.Value)
+
+	// Clones keep the hooks.
+	clone := ecs.Clone()
+	assert.NoError(t, clone.Unmarshal(bytes.NewBufferString(snapshot)))
+}
+
+func TestNewEntity(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Pos{}))
+
+	ent, err := ecs.NewEntity("Unit", map[string]interface{}{
+		"Pos":  map[string]interface{}{"X": 10, "Y": 2.0},
+		"Name": map[string]interface{}{"Value": "Gandalf"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, &Unit{Pos: Pos{X: 10, Y: 2}, Name: Name{Value: "Gandalf"}}, ent)
+	assert.Empty(t, ecs.Iterate())
+
+	id, _ := ecs.AddEntity(ent)
+	ew := ecs.MustGet(id)
+	assert.NoError(t, ew.Decode("Pos", map[string]interface{}{"X": 1}))
+	assert.Equal(t, Pos{X: 1}, ent.(*Unit).Pos)
+
+	ent, err = ecs.NewEntity("DynamicUnit", map[string]interface{}{"Pos": map[string]interface{}{"X": 3}})
+	assert.NoError(t, err)
+	assert.True(t, ecs.Access(ent).Has(Pos{}))
+
+	_, err = ecs.NewEntity("Missing", nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = ecs.NewEntity("Unit", map[string]interface{}{"Velocity": map[string]interface{}{}})
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Error(t, ew.Decode("Pos", map[string]interface{}{"X": "left"}))
+}
+
+func TestEntityWrap_Apply(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+
+	idUnit, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1, Y: 2}, Health: Health{Value: 5, Max: 10}})
+	idDyn, _ := ecs.AddEntity(&DynamicUnit{})
+	assert.NoError(t, ecs.MustGet(idDyn).Set(Velocity{X: 1, Y: 1}))
+
+	ew := ecs.MustGet(idUnit)
+	assert.NoError(t, ew.Apply(map[string]interface{}{
+		"Pos":    map[string]interface{}{"X": 3},
+		"Health": map[string]interface{}{"Value": 10},
+	}))
+	assert.NoError(t, ew.View(func(p *Pos, h *Health) {
+		assert.Equal(t, Pos{X: 3, Y: 2}, *p)
+		assert.Equal(t, Health{Value: 10, Max: 10}, *h)
+	}))
+
+	assert.NoError(t, ecs.MustGet(idDyn).Apply(map[string]interface{}{"Velocity": map[string]interface{}{"Y": 4}}))
+	assert.NoError(t, ecs.MustGet(idDyn).View(func(v *Velocity) {
+		assert.Equal(t, Velocity{X: 1, Y: 4}, *v)
+	}))
+
+	// Nothing is changed if a component fails.
+	assert.ErrorIs(t, ew.Apply(map[string]interface{}{
+		"Pos":      map[string]interface{}{"X": 7},
+		"Velocity": map[string]interface{}{"X": 1},
+	}), ErrNotFound)
+	assert.Error(t, ew.Apply(map[string]interface{}{
+		"Pos":    map[string]interface{}{"X": 7},
+		"Health": map[string]interface{}{"Value": "full"},
+	}))
+	assert.NoError(t, ew.View(func(p *Pos, h *Health) {
+		assert.Equal(t, Pos{X: 3, Y: 2}, *p)
+		assert.Equal(t, 10, h.Value)
+	}))
+}
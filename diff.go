@@ -0,0 +1,194 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+)
+
+// WorldDiff describes the changes that are needed to get
+// from one world to another.
+type WorldDiff struct {
+	Added   []EntityID
+	Removed []EntityID
+	Changed []EntityDiff
+}
+
+// EntityDiff describes the changes of a single entity
+// that is present in both worlds.
+type EntityDiff struct {
+	ID                EntityID
+	TypeChanged       bool
+	AddedComponents   []string
+	RemovedComponents []string
+	ChangedComponents []string
+}
+
+// Empty returns true if no differences were found.
+func (d WorldDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// rawEntity is a serialized entity with components in their
+// compact JSON encoding so that they can be compared bytewise.
+type rawEntity struct {
+	ID         EntityID
+	NetID      NetID `json:",omitempty"`
+	Type       string
+	Components map[string]json.RawMessage
+	Tags       []string `json:",omitempty"`
+}
+
+func (ecs *ECS) rawEntities() (map[EntityID]rawEntity, error) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	raw := make(map[EntityID]rawEntity, len(ecs.entities))
+	for i := range ecs.entities {
+		re, err := ecs.rawEntity(&ecs.entities[i])
+		if err != nil {
+			return nil, err
+		}
+		raw[re.ID] = re
+	}
+
+	return raw, nil
+}
+
+// rawEntity encodes a single entity. The caller needs to hold the lock.
+func (ecs *ECS) rawEntity(entry *entityEntry) (rawEntity, error) {
+	se := ecs.names.serializeEntity(entry)
+
+	re := rawEntity{
+		ID:         se.ID,
+		NetID:      ecs.entityNetIDs[se.ID],
+		Type:       se.Type,
+		Components: make(map[string]json.RawMessage, len(se.Components)),
+	}
+
+	for name, comp := range se.Components {
+		data, err := json.Marshal(comp)
+		if err != nil {
+			return re, err
+		}
+		re.Components[name] = data
+	}
+
+	return re, nil
+}
+
+func readRawEntities(reader io.Reader) (map[EntityID]rawEntity, error) {
+	var res []rawEntity
+
+	dec := json.NewDecoder(reader)
+	if err := dec.Decode(&res); err != nil {
+		return nil, err
+	}
+
+	raw := make(map[EntityID]rawEntity, len(res))
+	for i := range res {
+		for name, comp := range res[i].Components {
+			buf := &bytes.Buffer{}
+			if err := json.Compact(buf, comp); err != nil {
+				return nil, err
+			}
+			res[i].Components[name] = buf.Bytes()
+		}
+		if res[i].Components == nil {
+			res[i].Components = map[string]json.RawMessage{}
+		}
+		for _, name := range res[i].Tags {
+			res[i].Components[name] = json.RawMessage("{}")
+		}
+		res[i].Tags = nil
+		raw[res[i].ID] = res[i]
+	}
+
+	return raw, nil
+}
+
+func diffRaw(a map[EntityID]rawEntity, b map[EntityID]rawEntity) WorldDiff {
+	var diff WorldDiff
+
+	for id, ea := range a {
+		eb, ok := b[id]
+		if !ok {
+			diff.Removed = append(diff.Removed, id)
+			continue
+		}
+
+		ed := EntityDiff{
+			ID:          id,
+			TypeChanged: ea.Type != eb.Type,
+		}
+
+		for name, ca := range ea.Components {
+			cb, ok := eb.Components[name]
+			if !ok {
+				ed.RemovedComponents = append(ed.RemovedComponents, name)
+			} else if !bytes.Equal(ca, cb) {
+				ed.ChangedComponents = append(ed.ChangedComponents, name)
+			}
+		}
+
+		for name := range eb.Components {
+			if _, ok := ea.Components[name]; !ok {
+				ed.AddedComponents = append(ed.AddedComponents, name)
+			}
+		}
+
+		if ed.TypeChanged || len(ed.AddedComponents) > 0 || len(ed.RemovedComponents) > 0 || len(ed.ChangedComponents) > 0 {
+			sort.Strings(ed.AddedComponents)
+			sort.Strings(ed.RemovedComponents)
+			sort.Strings(ed.ChangedComponents)
+			diff.Changed = append(diff.Changed, ed)
+		}
+	}
+
+	for id := range b {
+		if _, ok := a[id]; !ok {
+			diff.Added = append(diff.Added, id)
+		}
+	}
+
+	sort.Slice(diff.Added, func(i, j int) bool { return diff.Added[i] < diff.Added[j] })
+	sort.Slice(diff.Removed, func(i, j int) bool { return diff.Removed[i] < diff.Removed[j] })
+	sort.Slice(diff.Changed, func(i, j int) bool { return diff.Changed[i].ID < diff.Changed[j].ID })
+
+	return diff
+}
+
+// Diff reports the entities and components that have been added,
+// removed or changed to get from world a to world b. Components are
+// compared by their serialized JSON form.
+func Diff(a, b *ECS) (WorldDiff, error) {
+	ra, err := a.rawEntities()
+	if err != nil {
+		return WorldDiff{}, err
+	}
+
+	rb, err := b.rawEntities()
+	if err != nil {
+		return WorldDiff{}, err
+	}
+
+	return diffRaw(ra, rb), nil
+}
+
+// DiffSnapshot works like Diff but compares the world against
+// a snapshot that was written by Marshal. The snapshot is treated
+// as world b.
+func DiffSnapshot(a *ECS, snapshot io.Reader) (WorldDiff, error) {
+	ra, err := a.rawEntities()
+	if err != nil {
+		return WorldDiff{}, err
+	}
+
+	rb, err := readRawEntities(snapshot)
+	if err != nil {
+		return WorldDiff{}, err
+	}
+
+	return diffRaw(ra, rb), nil
+}
@@ -0,0 +1,56 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := New()
+	a.RegisterComponent(Velocity{})
+
+	idUnit, _ := a.AddEntity(&Unit{Name: Name{Value: "unit"}})
+	idDyn, _ := a.AddEntity(&DynamicUnit{Name: Name{Value: "dyn"}})
+	idRemoved, _ := a.AddEntity(&Unit{Name: Name{Value: "removed"}})
+
+	buf := &bytes.Buffer{}
+	if !assert.NoError(t, a.Marshal(buf), "couldn't marshal ECS") {
+		return
+	}
+	snapshot := buf.Bytes()
+
+	b := New()
+	b.RegisterEntity(&Unit{})
+	b.RegisterEntity(&DynamicUnit{})
+	b.RegisterComponent(Velocity{})
+	if !assert.NoError(t, b.Unmarshal(bytes.NewBuffer(snapshot)), "couldn't unmarshal ECS") {
+		return
+	}
+
+	diff, err := Diff(a, b)
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty(), "diff of identical worlds isn't empty")
+
+	_ = b.MustGet(idUnit).View(func(n *Name) {
+		n.Value = "changed"
+	})
+	_ = b.MustGet(idDyn).ViewSpecific(func(u *DynamicUnit) {
+		_ = u.SetComponent(&Velocity{X: 1})
+	})
+	_ = b.RemoveEntity(b.MustGet(idRemoved).GetEntity())
+	idAdded, _ := b.AddEntity(&Unit{})
+
+	diff, err = Diff(a, b)
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{idAdded}, diff.Added)
+	assert.Equal(t, []EntityID{idRemoved}, diff.Removed)
+	assert.Equal(t, []EntityDiff{
+		{ID: idUnit, ChangedComponents: []string{"Name"}},
+		{ID: idDyn, AddedComponents: []string{"Velocity"}},
+	}, diff.Changed)
+
+	diff, err = DiffSnapshot(a, bytes.NewBuffer(snapshot))
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty(), "diff against own snapshot isn't empty")
+}
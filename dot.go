@@ -0,0 +1,155 @@
+package kinshi
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var (
+	entityIDType = reflect.TypeOf(EntityID(0))
+	uuidType     = reflect.TypeOf(UUID{})
+)
+
+// dotEdge is a reference from a component field to a entity.
+type dotEdge struct {
+	field string
+	to    EntityID
+}
+
+// collectRefs walks v and calls fn for every EntityID and UUID that is
+// reachable through exported fields, pointers, interfaces, slices,
+// arrays and maps. path is the field path of v.
+func collectRefs(v reflect.Value, path string, visited map[uintptr]bool, fn func(path string, ref reflect.Value)) {
+	switch v.Type() {
+	case entityIDType, uuidType:
+		fn(path, v)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || visited[v.Pointer()] {
+			return
+		}
+		visited[v.Pointer()] = true
+		collectRefs(v.Elem(), path, visited, fn)
+	case reflect.Interface:
+		if !v.IsNil() {
+			collectRefs(v.Elem(), path, visited, fn)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if f := v.Type().Field(i); f.PkgPath == "" {
+				collectRefs(v.Field(i), path+"."+f.Name, visited, fn)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			collectRefs(v.Index(i), path, visited, fn)
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			collectRefs(iter.Value(), path, visited, fn)
+		}
+	}
+}
+
+// dotQuote quotes s as DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// ExportDOT writes a Graphviz DOT graph of all entities to w. Every
+// entity is a node that lists its components. Fields of components that
+// hold a EntityID or the UUID of a entity, also inside of slices, maps
+// and nested structs, become edges that are labeled with the field path.
+// References to entities that don't exist are drawn as dashed nodes, so
+// dangling references stand out.
+//
+// The graph can be rendered with the dot tool:
+//    f, _ := os.Create("world.dot")
+//    _ = ecs.ExportDOT(f)
+//    // dot -Tsvg world.dot -o world.svg
+func (ecs *ECS) ExportDOT(w io.Writer) error {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	b := &strings.Builder{}
+	b.WriteString("digraph kinshi {\n")
+	b.WriteString("  node [shape=box];\n")
+
+	missing := map[EntityID]bool{}
+	var edges []string
+
+	for i := range ecs.entities {
+		entry := ecs.entities[i]
+		id := entry.Ent.ID()
+
+		label := fmt.Sprintf("%s %d\n", entry.TypeName, id)
+		var refs []dotEdge
+
+		for _, c := range ecs.names.collectComponents(entry.Ent) {
+			label += "\n" + c.Name
+
+			collectRefs(reflect.ValueOf(c.Value), c.Name, map[uintptr]bool{}, func(path string, ref reflect.Value) {
+				to, ok := EntityNone, false
+				switch r := ref.Interface().(type) {
+				case EntityID:
+					to, ok = r, r != EntityNone
+				case UUID:
+					if r != UUIDNone {
+						to, ok = ecs.uuids[r]
+					}
+				}
+
+				if ok {
+					refs = append(refs, dotEdge{field: path, to: to})
+				}
+			})
+		}
+
+		fmt.Fprintf(b, "  e%d [label=%s];\n", id, dotQuote(label))
+
+		sort.Slice(refs, func(i, j int) bool {
+			if refs[i].field != refs[j].field {
+				return refs[i].field < refs[j].field
+			}
+			return refs[i].to < refs[j].to
+		})
+
+		for j := range refs {
+			if j > 0 && refs[j] == refs[j-1] {
+				continue
+			}
+
+			if _, _, ok := ecs.findEntity(refs[j].to); !ok {
+				missing[refs[j].to] = true
+			}
+			edges = append(edges, fmt.Sprintf("  e%d -> e%d [label=%s];\n", id, refs[j].to, dotQuote(refs[j].field)))
+		}
+	}
+
+	ids := make([]EntityID, 0, len(missing))
+	for id := range missing {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		fmt.Fprintf(b, "  e%d [label=%s, style=dashed];\n", id, dotQuote(fmt.Sprintf("missing %d", id)))
+	}
+
+	for i := range edges {
+		b.WriteString(edges[i])
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
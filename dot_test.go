@@ -0,0 +1,71 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+type Owner struct {
+	Of EntityID
+}
+
+type Quest struct {
+	Steps []EntityID
+	Giver UUID
+}
+
+type QuestGiver struct {
+	BaseEntity
+	Name
+}
+
+type QuestItem struct {
+	BaseEntity
+	Owner
+	Quest
+}
+
+func TestECS_ExportDOT(t *testing.T) {
+	ecs := New()
+
+	giver, _ := ecs.AddEntity(&QuestGiver{Name: Name{Value: `Old "Tom"`}})
+	uuid, _ := ecs.AssignUUID(giver)
+
+	_, _ = ecs.AddEntity(&QuestItem{Owner: Owner{Of: giver}, Quest: Quest{Steps: []EntityID{giver, 42}, Giver: uuid}})
+	_, _ = ecs.AddEntity(&QuestItem{})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.ExportDOT(buf))
+	assert.Equal(t, `digraph kinshi {
+  node [shape=box];
+  e1 [label="QuestGiver 1\n\nName"];
+  e2 [label="QuestItem 2\n\nOwner\nQuest"];
+  e3 [label="QuestItem 3\n\nOwner\nQuest"];
+  e42 [label="missing 42", style=dashed];
+  e2 -> e1 [label="Owner.Of"];
+  e2 -> e1 [label="Quest.Giver"];
+  e2 -> e1 [label="Quest.Steps"];
+  e2 -> e42 [label="Quest.Steps"];
+}
+`, buf.String())
+}
+
+func TestCollectRefs(t *testing.T) {
+	type node struct {
+		Next  *node
+		Refs  map[string]EntityID
+		Other interface{}
+		id    EntityID
+	}
+
+	n := &node{Refs: map[string]EntityID{"a": 1}, Other: EntityID(2), id: 3}
+	n.Next = n
+
+	var found []EntityID
+	collectRefs(reflect.ValueOf(n), "Node", map[uintptr]bool{}, func(path string, ref reflect.Value) {
+		found = append(found, ref.Interface().(EntityID))
+	})
+	assert.ElementsMatch(t, []EntityID{1, 2}, found)
+}
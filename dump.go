@@ -0,0 +1,82 @@
+package kinshi
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// writeEntity writes a readable multi-line representation
+// of the entity and all its components to w.
+func (tn *typeNames) writeEntity(w io.Writer, typeName string, ent Entity) error {
+	if _, err := fmt.Fprintf(w, "%s %d\n", typeName, ent.ID()); err != nil {
+		return err
+	}
+
+	for _, c := range tn.collectComponents(ent) {
+		suffix := ""
+		if c.Dynamic {
+			suffix = " (dynamic)"
+		}
+
+		if _, err := fmt.Fprintf(w, "  %s%s: %+v\n", c.Name, suffix, reflect.ValueOf(c.Value).Elem().Interface()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dump writes a readable representation of the entities with the
+// given ids to w. If no ids are given all entities are written.
+//
+// For example:
+//    Unit 1
+//      Health: {Value:100 Max:150}
+//      Velocity (dynamic): {X:0.5 Y:0.1}
+func (ecs *ECS) Dump(w io.Writer, ids ...EntityID) error {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	if len(ids) == 0 {
+		for i := range ecs.entities {
+			if err := ecs.names.writeEntity(w, ecs.entities[i].TypeName, ecs.entities[i].Ent); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := range ids {
+		entry, _, ok := ecs.findEntity(ids[i])
+		if !ok {
+			if _, err := fmt.Fprintf(w, "<entity %d not found>\n", ids[i]); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := ecs.names.writeEntity(w, entry.TypeName, entry.Ent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// String returns a readable multi-line representation
+// of the wrapped Entity and all its components.
+func (ew *EntityWrap) String() string {
+	if ew.ent == nil || !ew.Valid() {
+		return "<invalid entity>"
+	}
+
+	ew.rlock()
+	defer ew.runlock()
+
+	buf := &strings.Builder{}
+	_ = ew.parent.names.writeEntity(buf, ew.parent.names.getTypeName(ew.ent), ew.ent)
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
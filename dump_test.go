@@ -0,0 +1,30 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Dump(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{Health: Health{Value: 100, Max: 150}})
+
+	dyn := &DynamicUnit{Name: Name{Value: "dyn"}}
+	_ = dyn.SetComponent(&Velocity{X: 0.5, Y: 0.1})
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	assert.Equal(t, "DynamicUnit 2\n  Name: {Value:dyn}\n  Velocity (dynamic): {X:0.5 Y:0.1}", ecs.MustGet(idDyn).String())
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Dump(buf, idUnit, 100))
+	assert.Equal(t, "Unit 1\n  Health: {Value:100 Max:150}\n  Pos: {X:0 Y:0}\n  Name: {Value:}\n<entity 100 not found>\n", buf.String())
+
+	buf.Reset()
+	assert.NoError(t, ecs.Dump(buf))
+	assert.Contains(t, buf.String(), "Unit 1\n")
+	assert.Contains(t, buf.String(), "DynamicUnit 2\n")
+
+	assert.Equal(t, "<invalid entity>", ecs.Access(&Unit{}).String())
+}
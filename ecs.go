@@ -1,6 +1,7 @@
 package kinshi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,22 +9,35 @@ import (
 	"io"
 	"reflect"
 	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
 	ErrNotFound      = errors.New("not found")
 	ErrNoID          = errors.New("not id")
 	ErrAlreadyExists = errors.New("already exists")
+	ErrMultiple      = errors.New("multiple found")
+	ErrTypeConflict  = errors.New("type name used by different types")
+	ErrVersion       = errors.New("entity changed since version")
+	ErrDecrypt       = errors.New("save file can't be decrypted")
+	ErrCorrupt       = errors.New("save file is corrupt")
+	ErrReadOnly      = errors.New("entity is read only")
 )
 
 type typeMeta struct {
-	t      reflect.Type
-	fields map[string]struct{}
+	t        reflect.Type
+	fields   map[string]struct{}
+	optional map[string]int
 }
 
 type serializedEntity struct {
 	ID         EntityID
+	NetID      NetID `json:",omitempty"`
+	UUID       UUID
+	Version    uint64
 	Type       string
 	Components map[string]interface{}
 }
@@ -36,53 +50,304 @@ type entityEntry struct {
 type ECS struct {
 	sync.RWMutex
 	idCounter     uint64
+	snapshotSize  int64
+	strict        bool
+	readOnly      bool
+	viewsMtx      sync.Mutex
+	views         map[uint64]int
 	entities      []entityEntry
 	metaCache     map[string]typeMeta
 	compMetaCache map[string]reflect.Type
 	routines      int
+	parThreshold  int
+	pool          *workerPool
+	decodeHooks   []mapstructure.DecodeHookFunc
+	decodeHook    mapstructure.DecodeHookFunc
+	aliases       map[string]string
+	names         *typeNames
+	autoTypes     sync.Map
+	compTypes     sync.Map
+	published     atomic.Value
+	compLocks     *componentLocks
+	buffers       *doubleBuffers
+	history       *histories
+	spatial       *spatialState
+	indexMtx      sync.Mutex
+	indexes       map[indexKey]*fieldIndex
+	trackersMtx   sync.Mutex
+	trackers      atomic.Value
+	snapshotsMtx  sync.Mutex
+	snapshots     atomic.Value
+	sceneCounter  uint64
+	scenes        map[SceneID][]EntityID
+	netIDs        map[NetID]EntityID
+	entityNetIDs  map[EntityID]NetID
+	uuids         map[UUID]EntityID
+	entityUUIDs   map[EntityID]UUID
+	unknownMtx    sync.Mutex
+	unknown       map[EntityID]unknownComponents
+	commandLog    *CommandLog
+	profiler      *profiler
+	counters      *counters
+	pprofLabels   bool
+	removeHooks   []func(Entity)
+	typeCounts    map[string]int
+	dynCounts     componentCounts
+	optionalOnce  sync.Once
+	optional      *optionalCounts
+	pending       []func()
 }
 
 // New creates a new instance of a ECS
-func New() *ECS {
-	return &ECS{
+func New(opts ...Option) *ECS {
+	ecs := &ECS{
 		entities:      []entityEntry{},
 		metaCache:     map[string]typeMeta{},
 		compMetaCache: map[string]reflect.Type{},
 		routines:      1,
+		parThreshold:  DefaultParallelThreshold,
+		pool:          &workerPool{},
+		scenes:        map[SceneID][]EntityID{},
+		netIDs:        map[NetID]EntityID{},
+		entityNetIDs:  map[EntityID]NetID{},
+		uuids:         map[UUID]EntityID{},
+		entityUUIDs:   map[EntityID]UUID{},
+		typeCounts:    map[string]int{},
+		names:         defaultNames,
+		pending:       []func(){},
 	}
+
+	for i := range opts {
+		opts[i](ecs)
+	}
+
+	for i := range ecs.pending {
+		ecs.pending[i]()
+	}
+	ecs.pending = nil
+
+	if ecs.decodeHook == nil {
+		ecs.decodeHook = ecs.composeDecodeHooks()
+	}
+
+	return ecs
 }
 
+// nextId reserves the next free id. The counter is atomic so that
+// ids can be reserved while the ECS is locked, e.g. inside of a View.
 func (ecs *ECS) nextId() EntityID {
-	ecs.Lock()
-	defer ecs.Unlock()
-
-	ecs.idCounter += 1
-	return EntityID(ecs.idCounter)
+	return EntityID(atomic.AddUint64(&ecs.idCounter, 1))
 }
 
-func (ecs *ECS) cacheComponent(name string, t reflect.Type) {
+func (ecs *ECS) cacheComponent(name string, t reflect.Type) error {
+	if cached, ok := ecs.compMetaCache[name]; ok && cached != t {
+		return fmt.Errorf("component '%s' is %s and %s: %w", name, cached, t, ErrTypeConflict)
+	}
+
+	if err := ecs.claimName(name, t); err != nil {
+		return err
+	}
+
 	ecs.compMetaCache[name] = t
+	return nil
+}
+
+// checkName checks that the component name isn't used by another type
+// than t yet. Within a ECS a name identifies a single type, regardless of
+// whether it was registered or recorded from a dynamic entity, so that
+// types of different packages with the same bare name can't be mixed up.
+// Use WithQualifiedNames to use both.
+func (ecs *ECS) checkName(name string, t reflect.Type) error {
+	if cur, ok := ecs.compTypes.Load(name); ok && cur.(reflect.Type) != t {
+		return fmt.Errorf("component '%s' is %s and %s: %w", name, cur, t, ErrTypeConflict)
+	}
+	return nil
+}
+
+// claimName binds the component name to t, see checkName.
+func (ecs *ECS) claimName(name string, t reflect.Type) error {
+	if cur, loaded := ecs.compTypes.LoadOrStore(name, t); loaded && cur.(reflect.Type) != t {
+		return fmt.Errorf("component '%s' is %s and %s: %w", name, cur, t, ErrTypeConflict)
+	}
+	return nil
+}
+
+// recordType claims the name of a component that was set on a dynamic
+// entity and records its type, so that it can be decoded later on.
+func (ecs *ECS) recordType(name string, t reflect.Type) error {
+	if err := ecs.claimName(name, t); err != nil {
+		return err
+	}
+	ecs.autoTypes.LoadOrStore(name, t)
+	return nil
+}
+
+// checkNames checks the dynamic and keyed components of entities that
+// are about to be added against the names that are in use and against
+// each other, see checkName.
+func (ecs *ECS) checkNames(ents ...Entity) error {
+	var seen map[string]reflect.Type
+	check := func(c interface{}) error {
+		t := reflect.TypeOf(c)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		name := ecs.names.typeName(t)
+		if err := ecs.checkName(name, t); err != nil {
+			return err
+		}
+
+		if len(ents) == 1 {
+			return nil
+		}
+		if seen == nil {
+			seen = map[string]reflect.Type{}
+		}
+		if cur, ok := seen[name]; ok && cur != t {
+			return fmt.Errorf("component '%s' is %s and %s: %w", name, cur, t, ErrTypeConflict)
+		}
+		seen[name] = t
+		return nil
+	}
+
+	for _, ent := range ents {
+		if dyn, ok := ent.(DynamicEntity); ok {
+			for _, c := range dyn.GetComponents() {
+				if err := check(c); err != nil {
+					return err
+				}
+			}
+		}
+
+		if ke, ok := ent.(KeyedEntity); ok {
+			for _, kc := range ke.GetKeyedComponents() {
+				if err := check(kc.Value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
 }
 
-func (ecs *ECS) cacheType(ent Entity) {
-	tn := getTypeName(ent)
-	if _, ok := ecs.metaCache[tn]; ok {
-		return
+// componentNames returns the names of the given components like
+// typeNames.componentNames. A type whose name is used by another type in
+// this ECS gets a name that matches no component, so that e.g. a query
+// for a.Pos doesn't match the entities holding a b.Pos.
+func (ecs *ECS) componentNames(types []interface{}) []string {
+	names := ecs.names.componentNames(types)
+	for i := range types {
+		if _, ok := types[i].(string); ok || types[i] == nil {
+			continue
+		}
+
+		t := reflect.TypeOf(types[i])
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if cur, ok := ecs.compTypes.Load(names[i]); ok && cur.(reflect.Type) != t {
+			names[i] = unmatchedName
+		}
 	}
+	return names
+}
+
+// unmatchedName is a component name that no type can have.
+const unmatchedName = "\x00"
 
+func (ecs *ECS) cacheType(ent Entity) error {
+	tn := ecs.names.getTypeName(ent)
 	t := reflect.TypeOf(ent).Elem()
-	ecs.metaCache[tn] = typeMeta{
+
+	if meta, ok := ecs.metaCache[tn]; ok {
+		if meta.t != t {
+			return fmt.Errorf("entity type '%s' is %s and %s: %w", tn, meta.t, t, ErrTypeConflict)
+		}
+		return nil
+	}
+
+	meta := typeMeta{
 		t:      t,
 		fields: map[string]struct{}{},
 	}
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if field.Type.Kind() == reflect.Struct {
-			ecs.metaCache[tn].fields[field.Name] = struct{}{}
-			ecs.cacheComponent(field.Type.Name(), field.Type)
+	for _, sc := range ecs.names.staticComponents(t) {
+		switch sc.kind {
+		case componentValue:
+			if err := ecs.cacheComponent(sc.name, t.Field(sc.index).Type); err != nil {
+				return err
+			}
+			meta.fields[sc.name] = struct{}{}
+			continue
+		case componentPtr:
+			if err := ecs.cacheComponent(sc.name, t.Field(sc.index).Type.Elem()); err != nil {
+				return err
+			}
+		}
+
+		// Pointer and interface fields can be nil, so their
+		// presence has to be checked per entity.
+		if meta.optional == nil {
+			meta.optional = map[string]int{}
+		}
+		meta.optional[sc.name] = sc.index
+	}
+
+	ecs.metaCache[tn] = meta
+	return nil
+}
+
+// lookupType returns the meta of the entity type with the given name.
+// Bare names are accepted for qualified types, so that snapshots from
+// before the switch to qualified names can be read.
+func (ecs *ECS) lookupType(name string) (typeMeta, bool) {
+	if meta, ok := ecs.metaCache[name]; ok || strings.Contains(name, ".") {
+		return meta, ok
+	}
+
+	var found []typeMeta
+	for k, meta := range ecs.metaCache {
+		if bareName(k) == name {
+			found = append(found, meta)
 		}
 	}
+
+	if len(found) != 1 {
+		return typeMeta{}, false
+	}
+	return found[0], true
+}
+
+// lookupComponent works like lookupType for components. Types that
+// were recorded from dynamic entities are used if the component wasn't
+// registered explicitly.
+func (ecs *ECS) lookupComponent(name string) (reflect.Type, bool) {
+	if t, ok := ecs.compMetaCache[name]; ok {
+		return t, true
+	}
+
+	if t, ok := ecs.autoTypes.Load(name); ok {
+		return t.(reflect.Type), true
+	}
+
+	if strings.Contains(name, ".") {
+		return nil, false
+	}
+
+	var found []reflect.Type
+	for k, t := range ecs.compMetaCache {
+		if bareName(k) == name {
+			found = append(found, t)
+		}
+	}
+
+	if len(found) != 1 {
+		return nil, false
+	}
+	return found[0], true
 }
 
 func (ecs *ECS) findEntity(id EntityID) (*entityEntry, int, bool) {
@@ -90,188 +355,634 @@ func (ecs *ECS) findEntity(id EntityID) (*entityEntry, int, bool) {
 	found := sort.Search(l, func(i int) bool {
 		return ecs.entities[i].Ent.ID() >= id
 	})
-	if found == l {
-		return nil, 0, false
+	if found == l || ecs.entities[found].Ent.ID() != id {
+		return nil, found, false
 	}
 	return &ecs.entities[found], found, true
 }
 
+// insertEntity inserts the entry into the storage while keeping
+// it sorted by id. The caller needs to hold the write lock.
+func (ecs *ECS) insertEntity(entry entityEntry) error {
+	id := entry.Ent.ID()
+
+	_, idx, ok := ecs.findEntity(id)
+	if ok {
+		return ErrAlreadyExists
+	}
+
+	if err := ecs.checkNames(entry.Ent); err != nil {
+		return err
+	}
+
+	if err := ecs.checkUnique(entry.Ent); err != nil {
+		return err
+	}
+
+	ecs.countAdd()
+	ecs.typeCounts[entry.TypeName] += 1
+	ecs.attach(entry.Ent)
+
+	if idx == len(ecs.entities) {
+		ecs.entities = append(ecs.entities, entry)
+	} else {
+		ecs.entities = append(ecs.entities, entityEntry{})
+		copy(ecs.entities[idx+1:], ecs.entities[idx:])
+		ecs.entities[idx] = entry
+	}
+
+	ecs.markDirty(id)
+
+	for {
+		counter := atomic.LoadUint64(&ecs.idCounter)
+		if uint64(id) <= counter || atomic.CompareAndSwapUint64(&ecs.idCounter, counter, uint64(id)) {
+			break
+		}
+	}
+
+	return nil
+}
+
+// hasComponents checks if the entity contains all the static or dynamic
+// components with the given names. The caller needs to hold the lock.
+func (ecs *ECS) hasComponents(entry *entityEntry, names []string) bool {
+	return matchComponents(ecs.metaCache[entry.TypeName], entry.Ent, names)
+}
+
+// matchComponents checks if the entity contains all the components with
+// the given names, either as one of the static fields or as a dynamic
+// component.
+func matchComponents(meta typeMeta, ent Entity, names []string) bool {
+	dyn, isDyn := ent.(DynamicEntity)
+
+	for i := range names {
+		if _, ok := meta.fields[names[i]]; ok {
+			continue
+		}
+
+		if idx, ok := meta.optional[names[i]]; ok && !reflect.ValueOf(ent).Elem().Field(idx).IsNil() {
+			continue
+		}
+
+		if isDyn && dyn.HasComponent(names[i]) == nil {
+			continue
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// removeAt removes the entity at the given index from the
+// storage and resets its id. The caller needs to hold the write lock.
+func (ecs *ECS) removeAt(idx int) {
+	entry := ecs.entities[idx]
+	ecs.entities = append(ecs.entities[:idx], ecs.entities[idx+1:]...)
+	ecs.markDirty(entry.Ent.ID())
+	ecs.uncountType(entry.TypeName)
+	ecs.detach(entry.Ent)
+}
+
+// replaceAt replaces the entity at the given index with the entry, which
+// needs to have the same id. The replaced entity is detached without
+// running the remove hooks. The caller needs to hold the write lock.
+func (ecs *ECS) replaceAt(idx int, entry entityEntry) {
+	old := ecs.entities[idx]
+	ecs.preserve(old.Ent.ID())
+	ecs.uncountType(old.TypeName)
+	ecs.typeCounts[entry.TypeName] += 1
+	old.Ent.SetID(EntityNone)
+	if rec, ok := old.Ent.(componentRecorder); ok {
+		rec.setOwner(nil)
+	}
+	ecs.attach(entry.Ent)
+	ecs.entities[idx] = entry
+	ecs.markDirty(entry.Ent.ID())
+}
+
+func (ecs *ECS) uncountType(typeName string) {
+	if ecs.typeCounts[typeName] <= 1 {
+		delete(ecs.typeCounts, typeName)
+	} else {
+		ecs.typeCounts[typeName] -= 1
+	}
+}
+
+// detach fires the removal hooks, drops all index entries and resets
+// the id of a entity that was removed from the storage.
+func (ecs *ECS) detach(ent Entity) {
+	ecs.preserve(ent.ID())
+	ecs.countRemove()
+
+	for i := range ecs.removeHooks {
+		ecs.removeHooks[i](ent)
+	}
+
+	ecs.unbindNetID(ent.ID())
+	ecs.unbindUUID(ent.ID())
+	ecs.setUnknown(ent.ID(), nil)
+	ent.SetID(EntityNone)
+
+	if rec, ok := ent.(componentRecorder); ok {
+		rec.setOwner(nil)
+	}
+}
+
+// serializeEntity collects all static and dynamic components
+// of the entry into the serializable form.
+func (tn *typeNames) serializeEntity(entry *entityEntry) serializedEntity {
+	se := serializedEntity{
+		ID:         entry.Ent.ID(),
+		Type:       entry.TypeName,
+		Components: map[string]interface{}{},
+	}
+
+	val := reflect.ValueOf(entry.Ent).Elem()
+	for _, sc := range tn.staticComponents(val.Type()) {
+		if v, ok := sc.value(val); ok {
+			se.Components[sc.name] = v
+		}
+	}
+
+	if dyn, ok := entry.Ent.(DynamicEntity); ok {
+		comps := dyn.GetComponents()
+		for i := range comps {
+			se.Components[tn.getTypeName(comps[i])] = tn.encodeValue(reflect.ValueOf(comps[i]))
+		}
+	}
+
+	if ke, ok := entry.Ent.(KeyedEntity); ok {
+		for _, kc := range ke.GetKeyedComponents() {
+			se.Components[keyedName(kc.Name, kc.Key)] = tn.encodeValue(reflect.ValueOf(kc.Value))
+		}
+	}
+
+	return se
+}
+
+// deserializeEntity creates a new entity instance from the serialized
+// form. The id of the entity is not set. If the type of the entity
+// isn't registered false is returned. Components that fail to decode
+// are skipped and the first error is returned alongside the entity.
+// The caller needs to hold the lock.
+func (ecs *ECS) deserializeEntity(se *serializedEntity) (entityEntry, bool, error) {
+	var firstErr error
+	ent, ok := ecs.deserializeEntityFn(se, func(comp string, err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	})
+	return ent, ok, firstErr
+}
+
+// deserializeEntityFn works like deserializeEntity but calls failed for
+// every component that fails to decode, or with a empty name if the type
+// of the entity isn't registered.
+func (ecs *ECS) deserializeEntityFn(se *serializedEntity, failed func(comp string, err error)) (entityEntry, bool) {
+	meta, ok := ecs.lookupType(se.Type)
+	if !ok {
+		failed("", fmt.Errorf("entity type '%s': %w", se.Type, ErrNotFound))
+		return entityEntry{}, false
+	}
+
+	newInstance := reflect.New(meta.t)
+	ent := newInstance.Interface().(Entity)
+
+	for comp, val := range se.Components {
+		if err := ecs.decodeComponent(ent, comp, val); err != nil {
+			failed(comp, fmt.Errorf("component '%s' of entity %d: %w", comp, se.ID, err))
+			continue
+		}
+	}
+
+	return entityEntry{
+		TypeName: ecs.names.getTypeName(ent),
+		Ent:      ent,
+	}, true
+}
+
+// decodeComponent decodes val into the component with the given name. If
+// the entity has a static component of that name it will be overwritten,
+// otherwise a new registered dynamic component will be set. Names of
+// keyed components are in the form "Name#key". Aliased names are
+// resolved to the current names.
+func (ecs *ECS) decodeComponent(ent Entity, comp string, val interface{}) error {
+	comp = ecs.resolveAlias(comp)
+
+	if sc, ok := ecs.names.staticField(reflect.TypeOf(ent).Elem(), comp); ok {
+		field := reflect.ValueOf(ent).Elem().Field(sc.index)
+
+		switch sc.kind {
+		case componentPtr:
+			ptr := reflect.New(field.Type().Elem())
+			if err := ecs.decode(val, ptr.Interface()); err != nil {
+				return err
+			}
+			field.Set(ptr)
+			return nil
+		case componentInterface:
+			return ecs.decodeInterface(field, val)
+		}
+
+		field.Set(reflect.Zero(field.Type()))
+		return ecs.decode(val, field.Addr().Interface())
+	}
+
+	dyn, ok := ent.(DynamicEntity)
+	if !ok {
+		return errUnknownComponent
+	}
+
+	name, key, keyed := splitKeyed(comp)
+
+	compType, ok := ecs.lookupComponent(name)
+	if !ok {
+		return errUnknownComponent
+	}
+
+	newComponent := reflect.New(compType)
+	if err := ecs.decode(val, newComponent.Interface()); err != nil {
+		return err
+	}
+
+	if keyed {
+		ke, ok := ent.(KeyedEntity)
+		if !ok {
+			return ErrNotFound
+		}
+		return ke.SetKeyed(key, newComponent.Interface())
+	}
+
+	return dyn.SetComponent(newComponent.Interface())
+}
+
+// decodeInterface decodes val into the interface field. The concrete
+// type needs to be registered with RegisterComponent. If the type
+// implements the interface by value a value is set, otherwise a pointer.
+func (ecs *ECS) decodeInterface(field reflect.Value, val interface{}) error {
+	ic, ok := val.(interfaceComponent)
+	if !ok {
+		if err := ecs.decode(val, &ic); err != nil {
+			return err
+		}
+	}
+
+	concrete, ok := ecs.lookupComponent(ic.Type)
+	if !ok {
+		return fmt.Errorf("concrete type '%s': %w", ic.Type, ErrNotFound)
+	}
+
+	ptr := reflect.New(concrete)
+	if err := ecs.decode(ic.Value, ptr.Interface()); err != nil {
+		return err
+	}
+
+	switch {
+	case concrete.AssignableTo(field.Type()):
+		field.Set(ptr.Elem())
+	case ptr.Type().AssignableTo(field.Type()):
+		field.Set(ptr)
+	default:
+		return fmt.Errorf("'%s' doesn't implement %s: %w", ic.Type, field.Type(), ErrTypeConflict)
+	}
+
+	return nil
+}
+
 // Unmarshal reads a JSON encoded ECS snapshot and loads
 // all the entities from it. The inner storage will be overwritten
 // so all entities that have been added before will be deleted.
 //
 // Important: If you want to serialize dynamic entities you need
 // to register all possible components with RegisterComponent()
-// before!
+// before! Components that have been set on dynamic entities while
+// they were stored in this ECS are registered automatically. In
+// strict mode unknown entity types and components that can't be
+// decoded result in a error, otherwise they are skipped, see
+// UnmarshalWithReport. Components of unknown types are kept as raw JSON
+// and written out again by the next Marshal, so e.g. data of a mod that
+// isn't loaded right now survives a save.
+//
+// Values that JSON can't represent directly, like time.Time or enums,
+// are converted by decode hooks, see RegisterDecodeHook.
 func (ecs *ECS) Unmarshal(reader io.Reader) error {
-	ecs.Lock()
+	return ecs.unmarshal(reader, nil, nil)
+}
+
+// unmarshal implements Unmarshal. If progress isn't nil it's called after
+// each decoded and after each added entity. If report isn't nil all
+// entities and components that fail to decode are added to it.
+func (ecs *ECS) unmarshal(reader io.Reader, progress func(entities int, total int, bytes int64), report *UnmarshalReport) error {
+	ecs.checkMutation()
+	ecs.lock()
 	defer ecs.Unlock()
 
 	var ses []serializedEntity
 
 	dec := json.NewDecoder(reader)
-	if err := dec.Decode(&ses); err != nil {
+	tok, err := dec.Token()
+	if err != nil {
 		return err
 	}
 
+	if tok != nil {
+		if tok != json.Delim('[') {
+			return fmt.Errorf("snapshot isn't a array of entities")
+		}
+
+		for dec.More() {
+			var se serializedEntity
+			if err := dec.Decode(&se); err != nil {
+				return err
+			}
+			ses = append(ses, se)
+
+			if progress != nil {
+				progress(0, 0, dec.InputOffset())
+			}
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return err
+		}
+	}
+
 	ecs.entities = []entityEntry{}
+	ecs.dynCounts.reset()
+	ecs.scenes = map[SceneID][]EntityID{}
+	ecs.netIDs = map[NetID]EntityID{}
+	ecs.entityNetIDs = map[EntityID]NetID{}
+	ecs.uuids = map[UUID]EntityID{}
+	ecs.entityUUIDs = map[EntityID]UUID{}
+	ecs.unknown = nil
+	ecs.typeCounts = map[string]int{}
+	ecs.invalidateIndexes()
 
 	for i := range ses {
-		ent := entityEntry{
-			TypeName: ses[i].Type,
-			Ent:      nil,
-		}
-
-		if meta, ok := ecs.metaCache[ses[i].Type]; ok {
-			newInstance := reflect.New(meta.t)
-
-			for comp, val := range ses[i].Components {
-				field := newInstance.Elem().FieldByName(comp)
-				if field.IsValid() {
-					if err := mapstructure.Decode(val, field.Addr().Interface()); err != nil {
-						// TODO: Error handling
-						continue
-					}
-				} else {
-					if dyn, ok := newInstance.Interface().(DynamicEntity); ok {
-						if compType, ok := ecs.compMetaCache[comp]; ok {
-							newComponent := reflect.New(compType)
-
-							if err := mapstructure.Decode(val, newComponent.Interface()); err != nil {
-								// TODO: Error handling
-								continue
-							}
-
-							_ = dyn.SetComponent(newComponent.Interface())
-						}
-					}
-				}
+		var firstErr error
+		var unknown unknownComponents
+		ent, ok := ecs.deserializeEntityFn(&ses[i], func(comp string, err error) {
+			if firstErr == nil {
+				firstErr = err
+			}
+
+			if errors.Is(err, errUnknownComponent) {
+				unknown.add(comp, ses[i].Components[comp])
+			}
+
+			if report != nil {
+				report.Failures = append(report.Failures, UnmarshalFailure{
+					ID:        ses[i].ID,
+					Type:      ses[i].Type,
+					Component: comp,
+					Err:       err,
+				})
 			}
+		})
+		if firstErr != nil && ecs.strict {
+			return firstErr
+		}
 
-			ent.Ent = newInstance.Interface().(Entity)
+		if ok {
 			ent.Ent.SetID(ses[i].ID)
+			setVersion(ent.Ent, ses[i].Version)
+			ecs.attach(ent.Ent)
 			ecs.entities = append(ecs.entities, ent)
+			ecs.typeCounts[ent.TypeName] += 1
+
+			if ses[i].NetID != NetIDNone {
+				ecs.bindNetID(ses[i].ID, ses[i].NetID)
+			}
+
+			if ses[i].UUID != UUIDNone {
+				ecs.bindUUID(ses[i].ID, ses[i].UUID)
+			}
+
+			ecs.setUnknown(ses[i].ID, unknown)
+		}
+
+		if progress != nil {
+			progress(i+1, len(ses), dec.InputOffset())
 		}
 	}
 
 	if len(ecs.entities) > 0 {
-		ecs.idCounter = uint64(ecs.entities[len(ecs.entities)-1].Ent.ID()) + 1
+		atomic.StoreUint64(&ecs.idCounter, uint64(ecs.entities[len(ecs.entities)-1].Ent.ID())+1)
 	} else {
-		ecs.idCounter = 0
+		atomic.StoreUint64(&ecs.idCounter, 0)
+	}
+
+	if report != nil {
+		report.Loaded = len(ecs.entities)
+		sort.SliceStable(report.Failures, func(i, j int) bool {
+			a, b := report.Failures[i], report.Failures[j]
+			return a.ID < b.ID || a.ID == b.ID && a.Component < b.Component
+		})
 	}
 
 	return nil
 }
 
-// Marshal encodes all entities into JSON.
+// Marshal encodes all entities into JSON. Tag components, which are
+// empty structs like Dead{}, are written as a list of names in Tags.
 func (ecs *ECS) Marshal(writer io.Writer) error {
-	ecs.Lock()
+	return ecs.marshal(writer, nil)
+}
+
+// marshal implements Marshal. The entities are encoded one by one, if
+// progress isn't nil it's called after each written entity.
+func (ecs *ECS) marshal(writer io.Writer, progress func(entities int, total int, bytes int64)) error {
+	ecs.lock()
 	defer ecs.Unlock()
 
-	var ses []serializedEntity
-	for i := range ecs.entities {
-		se := serializedEntity{
-			ID:         ecs.entities[i].Ent.ID(),
-			Type:       ecs.entities[i].TypeName,
-			Components: map[string]interface{}{},
-		}
+	cw := &countingWriter{w: writer}
+	defer func() {
+		atomic.StoreInt64(&ecs.snapshotSize, cw.n)
+	}()
 
-		val := reflect.ValueOf(ecs.entities[i].Ent).Elem()
-		for j := 0; j < val.NumField(); j++ {
-			name := val.Type().Field(j).Name
-			if name == "BaseEntity" || name == "BaseDynamicEntity" {
-				continue
-			}
+	if len(ecs.entities) == 0 {
+		_, err := io.WriteString(cw, "null\n")
+		return err
+	}
 
-			field := val.Field(j)
-			if field.Kind() != reflect.Struct {
-				continue
-			}
+	for i := range ecs.entities {
+		se := ecs.names.serializeEntity(&ecs.entities[i])
+		se.NetID = ecs.entityNetIDs[se.ID]
+		se.UUID = ecs.entityUUIDs[se.ID]
+		se.Version = entityVersion(ecs.entities[i].Ent)
+		ecs.addUnknown(&se)
+
+		// The same form as a json.Encoder with a tab as indent.
+		data, err := json.MarshalIndent(se, "\t", "\t")
+		if err != nil {
+			return err
+		}
 
-			se.Components[name] = field.Interface()
+		sep := ",\n\t"
+		if i == 0 {
+			sep = "[\n\t"
 		}
 
-		if dyn, ok := ecs.entities[i].Ent.(DynamicEntity); ok {
-			comps := dyn.GetComponents()
-			for i := range comps {
-				se.Components[getTypeName(comps[i])] = comps[i]
-			}
+		if _, err := io.WriteString(cw, sep); err != nil {
+			return err
+		}
+		if _, err := cw.Write(data); err != nil {
+			return err
 		}
 
-		ses = append(ses, se)
+		if progress != nil {
+			progress(i+1, len(ecs.entities), cw.n)
+		}
 	}
 
-	enc := json.NewEncoder(writer)
-	enc.SetIndent("", "\t")
-	return enc.Encode(ses)
+	_, err := io.WriteString(cw, "\n]\n")
+	return err
 }
 
-// RegisterEntity caches information about a entity.
-func (ecs *ECS) RegisterEntity(ent Entity) {
-	ecs.cacheType(ent)
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+// RegisterEntity caches information about a entity. ErrTypeConflict
+// is returned if a different type with the same name was registered.
+func (ecs *ECS) RegisterEntity(ent Entity) error {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	return ecs.misuse(ecs.cacheType(ent))
 }
 
 // RegisterComponent caches information about components
 // this is needed if you want to serialize dynamic entities
 // as the reflection information needs to be available
-// before the unmarshal.
-func (ecs *ECS) RegisterComponent(c interface{}) {
+// before the unmarshal. The concrete types of values that are
+// held by interfaces inside of components need to be registered
+// the same way. ErrTypeConflict is returned if a different type
+// with the same name was registered.
+func (ecs *ECS) RegisterComponent(c interface{}) error {
+	ecs.lock()
+	defer ecs.Unlock()
+
 	if reflect.ValueOf(c).Kind() == reflect.Ptr {
-		ecs.cacheComponent(getTypeName(c), reflect.TypeOf(c).Elem())
-	} else {
-		ecs.cacheComponent(getTypeName(c), reflect.TypeOf(c))
+		return ecs.misuse(ecs.cacheComponent(ecs.names.getTypeName(c), reflect.TypeOf(c).Elem()))
 	}
+	return ecs.misuse(ecs.cacheComponent(ecs.names.getTypeName(c), reflect.TypeOf(c)))
 }
 
 // SetRoutineCount sets the number of go routines
 // that are allowed to spawn to parallelize searches
-// over the entities.
+// over the entities. The go routines are kept in a
+// pool and reused, see Close.
 func (ecs *ECS) SetRoutineCount(n int) {
-	ecs.Lock()
+	ecs.lock()
 	defer ecs.Unlock()
 
 	ecs.routines = n
 }
 
+// DefaultParallelThreshold is the default number of entities
+// below which searches aren't parallelized.
+const DefaultParallelThreshold = 1024
+
+// SetParallelThreshold sets the number of entities below which searches
+// run inline in the calling go routine instead of spawning go routines.
+// For small worlds the overhead of the go routines outweighs the gain.
+func (ecs *ECS) SetParallelThreshold(n int) {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	ecs.parThreshold = n
+}
+
 // AddEntity adds a Entity to the ECS storage and
 // returns the assigned EntityID.
 func (ecs *ECS) AddEntity(ent Entity) (EntityID, error) {
-	if reflect.TypeOf(ent).Kind() != reflect.Ptr {
-		return EntityNone, fmt.Errorf("please pass your entity as pointer")
+	if ent == nil || reflect.TypeOf(ent).Kind() != reflect.Ptr {
+		return EntityNone, ecs.misuse(fmt.Errorf("please pass your entity as pointer"))
 	}
 
+	ecs.checkMutation()
+
 	if ent.ID() == 0 {
 		ent.SetID(ecs.nextId())
 	}
 
-	ecs.Lock()
+	ecs.lock()
 	defer ecs.Unlock()
 
-	ecs.cacheType(ent)
-
-	if _, _, ok := ecs.findEntity(ent.ID()); ok {
-		return ent.ID(), ErrAlreadyExists
+	if err := ecs.cacheType(ent); err != nil {
+		return ent.ID(), ecs.misuse(err)
 	}
 
-	ecs.entities = append(ecs.entities, entityEntry{
-		TypeName: getTypeName(ent),
+	if err := ecs.insertEntity(entityEntry{
+		TypeName: ecs.names.getTypeName(ent),
 		Ent:      ent,
-	})
+	}); err != nil {
+		// Violated unique constraints wrap ErrAlreadyExists and are no
+		// misuse, in contrast to a id that is already used.
+		if errors.Is(err, ErrAlreadyExists) && err != ErrAlreadyExists {
+			return ent.ID(), err
+		}
+		return ent.ID(), ecs.misuse(err)
+	}
+
 	return ent.ID(), nil
 }
 
+// AddEntityW adds a Entity like AddEntity and returns the wrapped Entity,
+// so it can be configured right away without a further Get.
+//
+// For example:
+//    ew, err := ecs.AddEntityW(&Unit{})
+//    if err == nil {
+//        ew.Set(Pos{X: 10, Y: 2})
+//    }
+func (ecs *ECS) AddEntityW(ent Entity) (*EntityWrap, error) {
+	if _, err := ecs.AddEntity(ent); err != nil {
+		return nil, err
+	}
+	return ecs.Access(ent), nil
+}
+
 // RemoveEntity removes a Entity from the ECS storage.
 func (ecs *ECS) RemoveEntity(ent Entity) error {
 	if ent.ID() == 0 {
-		return ErrNoID
+		return ecs.misuse(ErrNoID)
 	}
 
-	ecs.Lock()
+	ecs.checkMutation()
+	ecs.lock()
 	defer ecs.Unlock()
 
-	if _, id, ok := ecs.findEntity(ent.ID()); ok {
-		ecs.entities = append(ecs.entities[:id], ecs.entities[id+1:]...)
-		ent.SetID(EntityNone)
+	if _, idx, ok := ecs.findEntity(ent.ID()); ok {
+		ecs.removeAt(idx)
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+// RemoveByID removes the Entity with the given id from the ECS storage.
+func (ecs *ECS) RemoveByID(id EntityID) error {
+	if id == EntityNone {
+		return ecs.misuse(ErrNoID)
+	}
+
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	if _, idx, ok := ecs.findEntity(id); ok {
+		ecs.removeAt(idx)
 		return nil
 	}
 
@@ -283,6 +994,21 @@ func (ecs *ECS) RemoveEntity(ent Entity) error {
 type EntityWrap struct {
 	parent *ECS
 	ent    Entity
+	held   bool
+}
+
+// rlock locks the parent for reading unless the
+// wrap is used inside of Each, which holds the lock.
+func (ew *EntityWrap) rlock() {
+	if !ew.held {
+		ew.parent.rlock()
+	}
+}
+
+func (ew *EntityWrap) runlock() {
+	if !ew.held {
+		ew.parent.RUnlock()
+	}
 }
 
 // GetEntity returns the wrapped Entity.
@@ -290,6 +1016,12 @@ func (ew *EntityWrap) GetEntity() Entity {
 	return ew.ent
 }
 
+// TypeName returns the name by which the ECS of the wrapped Entity
+// identifies the type of the entity or component v.
+func (ew *EntityWrap) TypeName(v interface{}) string {
+	return ew.parent.TypeName(v)
+}
+
 // View calls fn with pointer to requested components. If you change
 // any data it will directly modify the Entity data. The pointer that
 // the fn functions is called with are pointing straight to the components.
@@ -300,54 +1032,59 @@ func (ew *EntityWrap) GetEntity() Entity {
 //    	p.Y += v.Y
 //    })
 func (ew *EntityWrap) View(fn interface{}) error {
+	return ew.view(fn, nil)
+}
+
+// view implements View. If version isn't nil fn is only
+// called if the entity is still at that version.
+func (ew *EntityWrap) view(fn interface{}, version *uint64) error {
 	if reflect.TypeOf(fn).Kind() != reflect.Func {
-		return fmt.Errorf("fn not function")
+		return ew.parent.misuse(fmt.Errorf("fn not function"))
 	}
 
-	fnType := reflect.TypeOf(fn)
-	var callInstances []reflect.Value
+	compNames := ew.parent.names.viewSignature(reflect.TypeOf(fn))
+	callInstances := getCallArgs()
+	defer putCallArgs(callInstances)
 
-	ew.parent.RLock()
-	defer ew.parent.RUnlock()
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
 
-	for i := 0; i < fnType.NumIn(); i++ {
-		compName := fnType.In(i).Elem().Name()
+	ew.parent.enterView()
+	defer ew.parent.leaveView()
 
-		ptr, err := fetchPtrOfType(ew.ent, compName)
+	for i := range compNames {
+		ptr, err := ew.parent.names.fetchComponent(ew.ent, compNames[i])
 		if err != nil {
-			if dyn, ok := ew.ent.(DynamicEntity); ok {
-				ptr, err := dyn.GetComponent(compName)
-				if err != nil {
-					return err
-				}
-				callInstances = append(callInstances, reflect.ValueOf(ptr))
-				continue
-			} else {
-				return err
-			}
+			return ew.parent.misuse(fmt.Errorf("view on missing component '%s': %w", compNames[i], err))
 		}
 
-		callInstances = append(callInstances, reflect.ValueOf(ptr))
+		*callInstances = append(*callInstances, reflect.ValueOf(ptr))
 	}
 
-	res := reflect.ValueOf(fn).Call(callInstances)
+	unlock := ew.parent.lockComponents(compNames...)
+	defer unlock()
 
-	// If the user supplied function returns a error return it
-	if len(res) == 1 {
-		if res[0].Interface() != nil {
-			err, ok := res[0].Interface().(error)
-			if ok && err != nil {
-				return err
-			}
-		}
+	if version != nil && !claimVersion(ew.ent, *version) {
+		return fmt.Errorf("entity %d: %w %d", ew.ent.ID(), ErrVersion, *version)
 	}
 
-	return nil
+	res := reflect.ValueOf(fn).Call(*callInstances)
+	if version == nil {
+		ew.parent.touch(ew.ent)
+	}
+
+	// If the user supplied function returns a error return it
+	return callError(res)
 }
 
 // ViewSpecific calls fn with pointer to the specific requested struct.
 // Its like fetching a named Entity. Changes to the struct data directly
 // applies to the Entity.
+// A error is returned if the Entity isn't of the type of the argument.
 //
 // For example you want to get a view on the Player{} Entity struct:
 //    ew.ViewSpecific(func(p *Player) {
@@ -355,32 +1092,149 @@ func (ew *EntityWrap) View(fn interface{}) error {
 //    })
 func (ew *EntityWrap) ViewSpecific(fn interface{}) error {
 	if reflect.TypeOf(fn).Kind() != reflect.Func {
-		return fmt.Errorf("fn not function")
+		return ew.parent.misuse(fmt.Errorf("fn not function"))
 	}
 
 	fnType := reflect.TypeOf(fn)
 	if fnType.NumIn() != 1 {
-		return fmt.Errorf("fn needs a single argument")
+		return ew.parent.misuse(fmt.Errorf("fn needs a single argument"))
 	}
 
-	ew.parent.RLock()
-	defer ew.parent.RUnlock()
+	if entType := reflect.TypeOf(ew.ent); !entType.AssignableTo(fnType.In(0)) {
+		return ew.parent.misuse(fmt.Errorf("fn expected %s, entity is %s", fnType.In(0), entType))
+	}
+
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	ew.parent.enterView()
+	defer ew.parent.leaveView()
 
 	res := reflect.ValueOf(fn).Call([]reflect.Value{reflect.ValueOf(ew.ent)})
+	ew.parent.touch(ew.ent)
 
 	// If the user supplied function returns a error return it
-	if len(res) == 1 {
-		if res[0].Interface() != nil {
-			err, ok := res[0].Interface().(error)
-			if ok && err != nil {
-				return err
-			}
-		}
+	return callError(res)
+}
+
+// Set overwrites a component of the wrapped Entity with the value of c.
+// If the Entity has a static component of that type it will be set,
+// otherwise a copy of c is set as dynamic component.
+//
+// For example you want to teleport the Entity:
+//    ew.Set(Pos{X: 10, Y: 2})
+func (ew *EntityWrap) Set(c interface{}) error {
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	unlock := ew.parent.lockComponents(ew.parent.names.getTypeName(c))
+	defer unlock()
+
+	if err := ew.parent.names.setComponentValue(ew.ent, c); err != nil {
+		return ew.parent.misuse(fmt.Errorf("set on missing component '%s': %w", ew.parent.names.getTypeName(c), err))
+	}
+	ew.parent.touch(ew.ent)
+
+	return nil
+}
+
+// Into copies the current value of a component of the wrapped Entity into
+// dst, which needs to be a pointer to the component type. In contrast to
+// View the copy is deep and owned by the caller, so it can be used after
+// the lock is released, e.g. to send it over the network or to hand it to
+// another goroutine.
+//
+// For example:
+//    var pos Pos
+//    err := ew.Into(&pos)
+func (ew *EntityWrap) Into(dst interface{}) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return ew.parent.misuse(fmt.Errorf("dst not a pointer"))
 	}
 
+	name := ew.parent.names.getTypeName(dst)
+
+	ew.rlock()
+	defer ew.runlock()
+
+	unlock := ew.parent.rlockComponents(name)
+	defer unlock()
+
+	ptr, err := ew.parent.names.fetchComponent(ew.ent, name)
+	if err != nil {
+		return ew.parent.misuse(fmt.Errorf("into on missing component '%s': %w", name, err))
+	}
+
+	val := reflect.ValueOf(ptr).Elem()
+	if val.Type() != rv.Elem().Type() {
+		return ew.parent.misuse(fmt.Errorf("component '%s' is %s, dst is %s", name, val.Type(), rv.Type()))
+	}
+
+	rv.Elem().Set(deepCopy(val))
 	return nil
 }
 
+// Has checks if the wrapped Entity contains the static or dynamic
+// component c. If c is a string the component is checked by name.
+func (ew *EntityWrap) Has(c interface{}) bool {
+	return ew.HasAll(c)
+}
+
+// HasAll checks if the wrapped Entity contains all of the given static
+// or dynamic components. Strings are checked as component names.
+//
+// For example:
+//    if ew.HasAll(Pos{}, Velocity{}) {
+//        // The Entity can move
+//    }
+func (ew *EntityWrap) HasAll(c ...interface{}) bool {
+	ew.rlock()
+	defer ew.runlock()
+
+	return ew.parent.hasComponents(&entityEntry{TypeName: ew.parent.names.getTypeName(ew.ent), Ent: ew.ent}, ew.parent.componentNames(c))
+}
+
+// Components returns the names of all static components in field
+// order followed by the names of all dynamic components sorted by name.
+func (ew *EntityWrap) Components() []string {
+	ew.rlock()
+	defer ew.runlock()
+
+	comps := ew.parent.names.collectComponents(ew.ent)
+	names := make([]string, len(comps))
+	for i := range comps {
+		names[i] = comps[i].Name
+	}
+
+	return names
+}
+
+// ComponentValues returns pointers to copies of all components in the
+// same order as Components. Like in Into the copies are deep and owned by
+// the caller, so they can be used after the lock is released, but changes
+// to them don't modify the Entity. Use View or Set to change components.
+func (ew *EntityWrap) ComponentValues() []interface{} {
+	ew.rlock()
+	defer ew.runlock()
+
+	comps := ew.parent.names.collectComponents(ew.ent)
+	values := make([]interface{}, len(comps))
+	for i := range comps {
+		values[i] = deepCopy(reflect.ValueOf(comps[i].Value)).Interface()
+	}
+
+	return values
+}
+
 // Valid checks if the wrapped Entity is valid (and present).
 func (ew *EntityWrap) Valid() bool {
 	return ew.ent.ID() != EntityNone
@@ -393,8 +1247,91 @@ func (it EntityIterator) Count() int {
 	return len(it)
 }
 
+// First returns the first found entity or ErrNotFound
+// if the iterator is empty.
+func (it EntityIterator) First() (*EntityWrap, error) {
+	if len(it) == 0 {
+		return nil, ErrNotFound
+	}
+	return it[0], nil
+}
+
+// Single returns the only found entity. If no entity was found ErrNotFound
+// and if more than one entity was found ErrMultiple is returned.
+//
+// For example you want to fetch the player:
+//    ew, err := ecs.IterateSpecific(Player{}).Single()
+func (it EntityIterator) Single() (*EntityWrap, error) {
+	switch len(it) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+		return it[0], nil
+	}
+	return nil, ErrMultiple
+}
+
+// scanChunk is the number of entities that are matched
+// between two checks for a cancelled scan.
+const scanChunk = 1024
+
+// scan matches all entities of the read view with the given number of go
+// routines and returns the wraps of the matched entities ordered by id.
+// Small worlds are scanned inline. The scan is aborted with the error of
+// ctx if it's cancelled.
+func (ecs *ECS) scan(ctx context.Context, labels context.Context, rv *readView, routines int, match func(i int) bool) ([]*EntityWrap, error) {
+	if routines < 1 || len(rv.entries) < rv.threshold {
+		routines = 1
+	}
+
+	results := make([][]*EntityWrap, routines)
+
+	worker := func(w int, start int, l int) {
+		for i := start; i < start+l && i < len(rv.entries); i++ {
+			if (i-start)%scanChunk == 0 && ctx.Err() != nil {
+				return
+			}
+
+			if match(i) {
+				results[w] = append(results[w], ecs.wrap(rv.entries[i].Ent))
+			}
+		}
+	}
+
+	// The labels can only be set on a go routine of our
+	// own, so labeled scans always use the pool.
+	if routines == 1 && labels == nil {
+		worker(0, 0, len(rv.entries))
+	} else {
+		step := len(rv.entries)/routines + 1
+		ecs.pool.run(routines, labels, func(w int) {
+			worker(w, step*w, step)
+		})
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	total := 0
+	for i := range results {
+		total += len(results[i])
+	}
+
+	if total == 0 {
+		return nil, nil
+	}
+
+	foundEnts := make([]*EntityWrap, 0, total)
+	for i := range results {
+		foundEnts = append(foundEnts, results[i]...)
+	}
+
+	return foundEnts, nil
+}
+
 // Iterate searches for entities that contain all the given types and returns
-// a iterator that can be range'd over.
+// a iterator that can be range'd over. The entities are ordered by id.
 //
 // For example you want to get fetch all entities containing a
 // Pos{} and Velocity{} component:
@@ -402,51 +1339,24 @@ func (it EntityIterator) Count() int {
 //        // Work with the EntityWrap
 //    }
 func (ecs *ECS) Iterate(types ...interface{}) EntityIterator {
-	ecs.RLock()
-	defer ecs.RUnlock()
-
-	wg := sync.WaitGroup{}
-	mtx := sync.Mutex{}
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
 
-	wg.Add(ecs.routines)
+	rv := ecs.reads()
 
 	var foundEnts []*EntityWrap
 
-	step := len(ecs.entities)/ecs.routines + 1
-	for w := 0; w < ecs.routines; w++ {
-		go func(start int, l int) {
-			var localFoundEnts []*EntityWrap
-
-			for i := start; i < start+l && i < len(ecs.entities); i++ {
-				allFound := true
-				for j := range types {
-					if val, ok := ecs.metaCache[ecs.entities[i].TypeName]; ok {
-						if _, ok := val.fields[getTypeName(types[j])]; ok {
-							continue
-						}
-					}
-
-					if dyn, ok := ecs.entities[i].Ent.(DynamicEntity); ok && dyn.HasComponent(types[j]) == nil {
-
-					} else {
-						allFound = false
-						break
-					}
-				}
-				if allFound {
-					localFoundEnts = append(localFoundEnts, &EntityWrap{parent: ecs, ent: ecs.entities[i].Ent})
-				}
-			}
-
-			mtx.Lock()
-			foundEnts = append(foundEnts, localFoundEnts...)
-			mtx.Unlock()
-
-			wg.Done()
-		}(step*w, step)
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("Iterate", types), len(rv.entries), len(foundEnts), time.Since(start))
+		}()
 	}
 
-	wg.Wait()
+	names := ecs.componentNames(types)
+	foundEnts, _ = ecs.scan(context.Background(), ecs.queryLabels("Iterate", types), rv, rv.routines, func(i int) bool {
+		return rv.has(i, names)
+	})
 
 	return foundEnts
 }
@@ -460,37 +1370,24 @@ func (ecs *ECS) Iterate(types ...interface{}) EntityIterator {
 //        // Work with the EntityWrap
 //    }
 func (ecs *ECS) IterateSpecific(t interface{}) EntityIterator {
-	ecs.RLock()
-	defer ecs.RUnlock()
-
-	wg := sync.WaitGroup{}
-	mtx := sync.Mutex{}
+	ecs.checkQueryTypes(t)
+	ecs.countIterate()
 
-	wg.Add(ecs.routines)
+	rv := ecs.reads()
 
 	var foundEnts []*EntityWrap
 
-	searchName := getTypeName(t)
-	step := len(ecs.entities)/ecs.routines + 1
-	for w := 0; w < ecs.routines; w++ {
-		var localFoundEnts []*EntityWrap
-
-		go func(start int, l int) {
-			for i := start; i < start+l && i < len(ecs.entities); i++ {
-				if ecs.entities[i].TypeName == searchName {
-					foundEnts = append(foundEnts, &EntityWrap{parent: ecs, ent: ecs.entities[i].Ent})
-				}
-			}
-
-			mtx.Lock()
-			foundEnts = append(foundEnts, localFoundEnts...)
-			mtx.Unlock()
-
-			wg.Done()
-		}(step*w, step)
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("IterateSpecific", []interface{}{t}), len(rv.entries), len(foundEnts), time.Since(start))
+		}()
 	}
 
-	wg.Wait()
+	searchName := ecs.names.getTypeName(t)
+	foundEnts, _ = ecs.scan(context.Background(), ecs.queryLabels("IterateSpecific", []interface{}{t}), rv, rv.routines, func(i int) bool {
+		return rv.entries[i].TypeName == searchName
+	})
 
 	return foundEnts
 }
@@ -498,14 +1395,22 @@ func (ecs *ECS) IterateSpecific(t interface{}) EntityIterator {
 // IterateID returns a iterator that can be range'd over for
 // the given Entity ids.
 func (ecs *ECS) IterateID(ids ...EntityID) EntityIterator {
-	ecs.RLock()
-	defer ecs.RUnlock()
+	ecs.countIterate()
+
+	rv := ecs.reads()
 
 	var foundEnts []*EntityWrap
 
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record("IterateID", len(ids), len(foundEnts), time.Since(start))
+		}()
+	}
+
 	for i := range ids {
-		if v, _, ok := ecs.findEntity(ids[i]); ok {
-			foundEnts = append(foundEnts, &EntityWrap{parent: ecs, ent: v.Ent})
+		if idx, ok := rv.find(ids[i]); ok {
+			foundEnts = append(foundEnts, ecs.wrap(rv.entries[idx].Ent))
 		}
 	}
 
@@ -514,11 +1419,10 @@ func (ecs *ECS) IterateID(ids ...EntityID) EntityIterator {
 
 // Get fetches a Entity by id.
 func (ecs *ECS) Get(id EntityID) (*EntityWrap, error) {
-	ecs.RLock()
-	defer ecs.RUnlock()
+	rv := ecs.reads()
 
-	if v, _, ok := ecs.findEntity(id); ok {
-		return &EntityWrap{parent: ecs, ent: v.Ent}, nil
+	if idx, ok := rv.find(id); ok {
+		return &EntityWrap{parent: ecs, ent: rv.entries[idx].Ent}, nil
 	}
 	return nil, ErrNotFound
 }
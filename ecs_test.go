@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/stretchr/testify/assert"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -128,6 +129,14 @@ func TestECS(t *testing.T) {
 			assert.NoError(t, ecs.MustGet(ent.GetEntity().ID()).View(func(n *Name) {
 				assert.Equal(t, fmt.Sprint(i), n.Value, "change wasn't observed")
 			}), "failed while view")
+
+			// Views on a different type fail instead of panicking
+			err := ent.ViewSpecific(func(unit *Unit) {
+				t.Error("fn called with wrong type")
+			})
+			if assert.Error(t, err) {
+				assert.Equal(t, "fn expected *kinshi.Unit, entity is *kinshi.DynamicUnit", err.Error())
+			}
 		}
 	})
 
@@ -307,3 +316,202 @@ func BenchmarkECS_View(b *testing.B) {
 		}
 	}
 }
+
+func TestEntityWrap_Set(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{})
+	idDyn, _ := ecs.AddEntity(&DynamicUnit{})
+
+	assert.NoError(t, ecs.MustGet(idUnit).Set(Pos{X: 10, Y: 2}), "set of static component failed")
+	assert.NoError(t, ecs.MustGet(idUnit).Set(&Health{Value: 5}), "set with pointer failed")
+	assert.Error(t, ecs.MustGet(idUnit).Set(Velocity{}), "set of missing component on static entity didn't fail")
+
+	assert.NoError(t, ecs.MustGet(idUnit).View(func(p *Pos, h *Health) {
+		assert.Equal(t, Pos{X: 10, Y: 2}, *p)
+		assert.Equal(t, 5, h.Value)
+	}))
+
+	vel := Velocity{X: 1}
+	assert.NoError(t, ecs.MustGet(idDyn).Set(vel), "set of dynamic component failed")
+	vel.X = 2
+
+	assert.NoError(t, ecs.MustGet(idDyn).View(func(v *Velocity) {
+		assert.Equal(t, 1.0, v.X, "dynamic component aliases the passed value")
+	}))
+}
+
+func TestEntityWrap_Into(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1, Y: 2}})
+	idDyn, _ := ecs.AddEntity(&DynamicUnit{})
+	assert.NoError(t, ecs.MustGet(idDyn).Set(Velocity{X: 3}))
+
+	var pos Pos
+	assert.NoError(t, ecs.MustGet(idUnit).Into(&pos), "into of static component failed")
+	assert.Equal(t, Pos{X: 1, Y: 2}, pos)
+
+	// The copy doesn't alias the component
+	pos.X = 10
+	assert.NoError(t, ecs.MustGet(idUnit).View(func(p *Pos) {
+		assert.Equal(t, 1, p.X, "copy aliases the component")
+	}))
+
+	var vel Velocity
+	assert.NoError(t, ecs.MustGet(idDyn).Into(&vel), "into of dynamic component failed")
+	assert.Equal(t, 3.0, vel.X)
+
+	assert.Error(t, ecs.MustGet(idUnit).Into(&vel), "into of missing component didn't fail")
+	assert.Error(t, ecs.MustGet(idUnit).Into(pos), "into of non pointer didn't fail")
+}
+
+func TestEntityWrap_Has(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{})
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{})
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	unit := ecs.MustGet(idUnit)
+	assert.True(t, unit.Has(Pos{}))
+	assert.True(t, unit.Has(&Pos{}))
+	assert.True(t, unit.Has("Health"))
+	assert.True(t, unit.HasAll(Pos{}, Health{}, Name{}))
+	assert.False(t, unit.Has(Velocity{}))
+	assert.False(t, unit.HasAll(Pos{}, Velocity{}))
+
+	wrap := ecs.MustGet(idDyn)
+	assert.True(t, wrap.HasAll(Name{}, Velocity{}), "static and dynamic components weren't found")
+	assert.False(t, wrap.Has(Pos{}))
+
+	assert.Equal(t, 1, ecs.Iterate("Name", "Velocity").Count(), "query by name failed")
+}
+
+func TestEntityWrap_Components(t *testing.T) {
+	ecs := New()
+
+	dyn := &DynamicUnit{Name: Name{Value: "dyn"}}
+	_ = dyn.SetComponent(&Velocity{X: 1})
+	_ = dyn.SetComponent(&Pos{X: 2})
+	id, _ := ecs.AddEntity(dyn)
+
+	ew := ecs.MustGet(id)
+	assert.Equal(t, []string{"Name", "Pos", "Velocity"}, ew.Components())
+
+	values := ew.ComponentValues()
+	if assert.Len(t, values, 3) {
+		assert.Equal(t, &Name{Value: "dyn"}, values[0])
+		assert.Equal(t, &Pos{X: 2}, values[1])
+		assert.Equal(t, &Velocity{X: 1}, values[2])
+
+		values[0].(*Name).Value = "changed"
+		values[1].(*Pos).X = 5
+		assert.Equal(t, "dyn", dyn.Name.Value, "value points to the component")
+		assert.Equal(t, &Pos{X: 2}, ew.ComponentValues()[1], "value points to the component")
+	}
+}
+
+func TestECS_AddEntityW(t *testing.T) {
+	ecs := New()
+
+	ew, err := ecs.AddEntityW(&Unit{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, ew.Valid())
+	assert.NoError(t, ew.Set(Pos{X: 10, Y: 2}))
+
+	assert.NoError(t, ecs.MustGet(ew.GetEntity().ID()).View(func(p *Pos) {
+		assert.Equal(t, Pos{X: 10, Y: 2}, *p)
+	}))
+
+	dup := &Unit{}
+	dup.SetID(ew.GetEntity().ID())
+	ew, err = ecs.AddEntityW(dup)
+	assert.Error(t, err)
+	assert.Nil(t, ew)
+}
+
+func TestECS_RemoveByID(t *testing.T) {
+	ecs := New()
+
+	u := &Unit{}
+	id, _ := ecs.AddEntity(u)
+	other, _ := ecs.AddEntity(&Unit{})
+
+	assert.NoError(t, ecs.RemoveByID(id))
+	assert.Equal(t, EntityNone, u.ID(), "id wasn't reset")
+	assert.Equal(t, ErrNotFound, ecs.RemoveByID(id), "entity was removed twice")
+	assert.Equal(t, ErrNoID, ecs.RemoveByID(EntityNone))
+
+	_, err := ecs.Get(other)
+	assert.NoError(t, err, "wrong entity was removed")
+}
+
+func TestEntityIterator_First(t *testing.T) {
+	ecs := New()
+	ecs.SetRoutineCount(4)
+	ecs.SetParallelThreshold(0)
+
+	for i := 0; i < 100; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	ew, err := ecs.Iterate(Pos{}).First()
+	if assert.NoError(t, err) {
+		assert.Equal(t, EntityID(1), ew.GetEntity().ID())
+	}
+
+	it := ecs.Iterate()
+	for i := 1; i < len(it); i++ {
+		assert.True(t, it[i-1].GetEntity().ID() < it[i].GetEntity().ID(), "iterator isn't ordered by id")
+	}
+
+	_, err = ecs.Iterate(Velocity{}).First()
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestEntityIterator_Single(t *testing.T) {
+	ecs := New()
+
+	_, err := ecs.IterateSpecific(Unit{}).Single()
+	assert.Equal(t, ErrNotFound, err)
+
+	id, _ := ecs.AddEntity(&Unit{})
+	ew, err := ecs.IterateSpecific(Unit{}).Single()
+	if assert.NoError(t, err) {
+		assert.Equal(t, id, ew.GetEntity().ID())
+	}
+
+	_, _ = ecs.AddEntity(&Unit{})
+	_, err = ecs.IterateSpecific(Unit{}).Single()
+	assert.Equal(t, ErrMultiple, err)
+}
+
+func TestEntityWrap_ViewSignatureCache(t *testing.T) {
+	ecs := New()
+	id, _ := ecs.AddEntity(&Unit{})
+	ew := ecs.MustGet(id)
+
+	view := func(p *Pos, h *Health) {}
+	assert.NoError(t, ew.View(view))
+
+	names, ok := ecs.names.views.Load(reflect.TypeOf(view))
+	if assert.True(t, ok, "signature wasn't cached") {
+		assert.Equal(t, []string{"Pos", "Health"}, names)
+	}
+
+	// Closures of the same type share the signature.
+	for i := 0; i < 3; i++ {
+		x := i
+		assert.NoError(t, ew.View(func(p *Pos, h *Health) {
+			p.X += x
+		}))
+	}
+	_ = ew.View(func(p *Pos) {
+		assert.Equal(t, 3, p.X)
+	})
+}
@@ -2,8 +2,11 @@ package kinshi
 
 import (
 	"fmt"
+	"math/bits"
 	"reflect"
+	"sort"
 	"sync"
+	"sync/atomic"
 )
 
 type EntityID uint64
@@ -22,7 +25,8 @@ type Entity interface {
 // Entity interface and should be embedded into
 // your own structs to make it a entity.
 type BaseEntity struct {
-	id EntityID
+	id      EntityID
+	version uint64
 }
 
 // ID returns the assigned id of the entity
@@ -36,6 +40,32 @@ func (b *BaseEntity) SetID(id EntityID) {
 	b.id = id
 }
 
+// versioned is implemented by BaseEntity. The version is
+// incremented on every write to the components of the entity.
+type versioned interface {
+	loadVersion() uint64
+	storeVersion(v uint64)
+	bumpVersion()
+	claimVersion(v uint64) bool
+}
+
+func (b *BaseEntity) loadVersion() uint64 {
+	return atomic.LoadUint64(&b.version)
+}
+
+func (b *BaseEntity) storeVersion(v uint64) {
+	atomic.StoreUint64(&b.version, v)
+}
+
+func (b *BaseEntity) bumpVersion() {
+	atomic.AddUint64(&b.version, 1)
+}
+
+// claimVersion increments the version only if it is still v.
+func (b *BaseEntity) claimVersion(v uint64) bool {
+	return atomic.CompareAndSwapUint64(&b.version, v, v+1)
+}
+
 // DynamicEntity is a special entity with the option
 // to dynamically add and remove components.
 type DynamicEntity interface {
@@ -47,17 +77,263 @@ type DynamicEntity interface {
 	GetComponents() []interface{}
 }
 
+// KeyedEntity is a dynamic entity that can hold several instances of the
+// same component type under different keys, like multiple status effects.
+// Keyed components are matched by queries for their type, but can't be
+// viewed by View, use EntityWrap.ViewKeyed instead.
+type KeyedEntity interface {
+	DynamicEntity
+	SetKeyed(key string, c interface{}) error
+	RemoveKeyed(c interface{}, key string) error
+	GetKeyed(name string, key string) (interface{}, error)
+	GetKeyedComponents() []KeyedComponent
+}
+
+// KeyedComponent is a component instance that is stored under a key.
+type KeyedComponent struct {
+	Name  string
+	Key   string
+	Value interface{}
+}
+
 // BaseDynamicEntity is the base implementation of the
 // DynamicEntity interface and should be embedded into
 // your own structs to make it a dynamic entity.
+//
+// The components are stored in slices sorted by name, because most
+// entities only have a few dynamic components. A index is only built
+// if a entity holds many of them. Tags, components that are empty
+// structs like Dead{}, only need their presence to be stored, so they
+// are kept as flags.
 type BaseDynamicEntity struct {
 	BaseEntity
 	sync.Mutex
-	components map[string]interface{}
+	names  []string
+	values []interface{}
+	index  map[string]int
+	keyed  []KeyedComponent
+	tags   uint64
+
+	// naming derives the names the components are stored by. It's the
+	// namer of the owner once the entity was added to a ECS.
+	naming *typeNames
+
+	// owner is set while the entity is stored in a ECS. The types of
+	// all set components are recorded in it, so that the ECS can decode
+	// them later on without explicit registration, and changes are
+	// reported to its indexes.
+	owner *ECS
+}
+
+// dynamicIndexThreshold is the number of components above
+// which a index is used to look up components by name.
+const dynamicIndexThreshold = 8
+
+// resetComponents drops all components and resets the lock without
+// touching the old state. This is used to detach a shallow copy of
+// an entity from the original.
+func (b *BaseDynamicEntity) resetComponents() {
+	b.Mutex = sync.Mutex{}
+	b.names = nil
+	b.values = nil
+	b.index = nil
+	b.keyed = nil
+	b.tags = 0
+	b.naming = nil
+	b.owner = nil
+}
+
+// typeNames returns the names the components are stored by.
+// The caller needs to hold the lock.
+func (b *BaseDynamicEntity) typeNames() *typeNames {
+	if b.naming != nil {
+		return b.naming
+	}
+	return defaultNames
+}
+
+// rename stores the components by the names derived by tn.
+func (b *BaseDynamicEntity) rename(tn *typeNames) {
+	// Just like on change the slices are copied, so that slices
+	// that were returned by GetComponents stay untouched.
+	b.values = append([]interface{}(nil), b.values...)
+	for i := range b.values {
+		b.names[i] = tn.getTypeName(b.values[i])
+	}
+	sort.Sort(&componentsByName{names: b.names, values: b.values})
+	b.index = nil
+
+	b.keyed = append([]KeyedComponent(nil), b.keyed...)
+	for i := range b.keyed {
+		b.keyed[i].Name = tn.getTypeName(b.keyed[i].Value)
+	}
+	sort.Slice(b.keyed, func(i, j int) bool {
+		return b.keyed[i].Name < b.keyed[j].Name || b.keyed[i].Name == b.keyed[j].Name && b.keyed[i].Key < b.keyed[j].Key
+	})
+
+	b.naming = tn
+}
+
+// setOwner sets the ECS that stores the entity and records the
+// types of the present components. If the ECS derives names with
+// another namer, the components are renamed.
+func (b *BaseDynamicEntity) setOwner(owner *ECS) {
+	b.Lock()
+	defer b.Unlock()
+
+	b.countAll(-1)
+	b.owner = owner
+	if owner == nil {
+		return
+	}
+
+	if owner.names != b.typeNames() {
+		b.rename(owner.names)
+	}
+	b.countAll(1)
+
+	// Conflicting names are rejected before the entity is added, see
+	// ECS.checkNames.
+	for i := range b.names {
+		_ = owner.recordType(b.names[i], reflect.TypeOf(b.values[i]).Elem())
+	}
+
+	for i := range b.keyed {
+		_ = owner.recordType(b.keyed[i].Name, reflect.TypeOf(b.keyed[i].Value).Elem())
+	}
+
+	for bit := uint(0); bit < maxTags; bit++ {
+		if b.tags&(1<<bit) != 0 {
+			t := tagType(bit)
+			_ = owner.recordType(owner.names.typeName(t), t)
+		}
+	}
+}
+
+// storedType returns the type of the plain, tag or keyed component that
+// is stored under the name.
+func (b *BaseDynamicEntity) storedType(name string) (reflect.Type, bool) {
+	if i, ok := b.find(name); ok {
+		return reflect.TypeOf(b.values[i]).Elem(), true
+	}
+
+	if bit, ok := b.hasTag(name); ok {
+		return tagType(bit), true
+	}
+
+	if i, _ := b.findKeyed(name, ""); i < len(b.keyed) && b.keyed[i].Name == name {
+		return reflect.TypeOf(b.keyed[i].Value).Elem(), true
+	}
+
+	return nil, false
+}
+
+// holds checks if the component that is stored under the name is of the
+// type of c, so that a component of another package with the same name
+// isn't mistaken for it.
+func (b *BaseDynamicEntity) holds(name string, c interface{}) bool {
+	t := reflect.TypeOf(c)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	cur, ok := b.storedType(name)
+	return !ok || cur == t
+}
+
+// claim checks that the name isn't used by another type than t, neither
+// on the entity nor in the owner, and records t in the owner.
+func (b *BaseDynamicEntity) claim(name string, t reflect.Type) error {
+	if cur, ok := b.storedType(name); ok && cur != t {
+		return fmt.Errorf("component '%s' is %s and %s: %w", name, cur, t, ErrTypeConflict)
+	}
+
+	if b.owner != nil {
+		return b.owner.recordType(name, t)
+	}
+	return nil
+}
+
+// write prepares a change of the components, see ECS.write. The lock
+// must not be held, because snapshots that still share the entity read
+// it to copy it.
+func (b *BaseDynamicEntity) write() error {
+	b.Lock()
+	owner, id := b.owner, b.id
+	b.Unlock()
+
+	if owner == nil {
+		return nil
+	}
+	return owner.write(id)
+}
+
+// countAll adds all components to the counts of the owner, or removes
+// them if delta is negative. The caller needs to hold the lock.
+func (b *BaseDynamicEntity) countAll(delta int) {
+	if b.owner == nil {
+		return
+	}
+
+	for i := range b.names {
+		b.owner.dynCounts.add(b.names[i], delta, reflect.TypeOf(b.values[i]).Elem())
+	}
+
+	for bit := uint(0); bit < maxTags; bit++ {
+		if b.tags&(1<<bit) != 0 {
+			t := tagType(bit)
+			b.owner.dynCounts.add(b.typeNames().typeName(t), delta, t)
+		}
+	}
+
+	for i := range b.keyed {
+		b.owner.dynCounts.addKeyed(delta, reflect.TypeOf(b.keyed[i].Value).Elem())
+	}
+}
+
+// counted reports a added or removed component to the
+// owner. The caller needs to hold the lock.
+func (b *BaseDynamicEntity) counted(name string, delta int, t reflect.Type) {
+	if b.owner != nil {
+		b.owner.dynCounts.add(name, delta, t)
+	}
+}
+
+// changed bumps the version after a change of the dynamic components
+// and reports it to the owner.
+func (b *BaseDynamicEntity) changed() {
+	b.bumpVersion()
+	if b.owner != nil {
+		b.owner.markDirty(b.id)
+	}
+}
+
+// find returns the position of the component with the given name or
+// the position at which it would be inserted if it isn't present.
+func (b *BaseDynamicEntity) find(name string) (int, bool) {
+	if len(b.names) > dynamicIndexThreshold {
+		if b.index == nil {
+			b.index = make(map[string]int, len(b.names))
+			for i := range b.names {
+				b.index[b.names[i]] = i
+			}
+		}
+
+		if i, ok := b.index[name]; ok {
+			return i, true
+		}
+	}
+
+	i := sort.SearchStrings(b.names, name)
+	return i, i < len(b.names) && b.names[i] == name
 }
 
 // SetComponents sets or adds a component with the data of c.
 func (b *BaseDynamicEntity) SetComponent(c interface{}) error {
+	if err := b.write(); err != nil {
+		return err
+	}
+
 	b.Lock()
 	defer b.Unlock()
 
@@ -65,31 +341,118 @@ func (b *BaseDynamicEntity) SetComponent(c interface{}) error {
 		return fmt.Errorf("component needs to be passed as pointer")
 	}
 
-	if b.components == nil {
-		b.components = map[string]interface{}{}
+	name := b.typeNames().getTypeName(c)
+	if err := b.claim(name, reflect.TypeOf(c).Elem()); err != nil {
+		return err
+	}
+
+	i, ok := b.find(name)
+
+	if bit, isTag := tagBit(c); isTag {
+		if ok {
+			b.removeAt(i)
+		}
+		if b.tags&(1<<bit) == 0 {
+			b.counted(name, 1, tagType(bit))
+		}
+		b.tags |= 1 << bit
+
+		b.changed()
+		return nil
+	}
+
+	// The values are copied on change, so that slices that were
+	// returned by GetComponents stay untouched.
+	values := make([]interface{}, len(b.values), len(b.values)+1)
+	copy(values, b.values)
+
+	if ok {
+		values[i] = c
+	} else {
+		values = append(values, nil)
+		copy(values[i+1:], values[i:])
+		values[i] = c
+
+		b.names = append(b.names, "")
+		copy(b.names[i+1:], b.names[i:])
+		b.names[i] = name
+		b.index = nil
+
+		b.counted(name, 1, reflect.TypeOf(c).Elem())
 	}
 
-	b.components[getTypeName(c)] = c
+	b.values = values
+
+	b.changed()
 	return nil
 }
 
 // RemoveComponent removes a component of the type c.
+// If c is a string the component will be removed by name.
 func (b *BaseDynamicEntity) RemoveComponent(c interface{}) error {
+	if err := b.write(); err != nil {
+		return err
+	}
+
 	b.Lock()
 	defer b.Unlock()
 
-	if b.components == nil {
-		b.components = map[string]interface{}{}
+	var typeName string
+	switch c.(type) {
+	case string:
+		typeName = c.(string)
+	default:
+		typeName = b.typeNames().getTypeName(c)
+		if !b.holds(typeName, c) {
+			return ErrNotFound
+		}
 	}
 
-	typeName := getTypeName(c)
-
-	if _, ok := b.components[typeName]; ok {
-		delete(b.components, typeName)
+	if bit, ok := b.hasTag(typeName); ok {
+		b.tags &^= 1 << bit
+		b.counted(typeName, -1, tagType(bit))
+		b.changed()
 		return nil
 	}
 
-	return ErrNotFound
+	i, ok := b.find(typeName)
+	if !ok {
+		return ErrNotFound
+	}
+
+	b.removeAt(i)
+	b.changed()
+	return nil
+}
+
+// removeAt removes the component at the given position.
+func (b *BaseDynamicEntity) removeAt(i int) {
+	b.counted(b.names[i], -1, reflect.TypeOf(b.values[i]).Elem())
+
+	values := make([]interface{}, 0, len(b.values)-1)
+	values = append(values, b.values[:i]...)
+	b.values = append(values, b.values[i+1:]...)
+
+	b.names = append(b.names[:i], b.names[i+1:]...)
+	b.index = nil
+
+	if len(b.values) == 0 {
+		b.values = nil
+	}
+}
+
+// hasTag checks if the tag with the given name is set.
+func (b *BaseDynamicEntity) hasTag(name string) (uint, bool) {
+	if b.tags == 0 {
+		return 0, false
+	}
+
+	for bit := uint(0); bit < maxTags; bit++ {
+		if b.tags&(1<<bit) != 0 && b.typeNames().typeName(tagType(bit)) == name {
+			return bit, true
+		}
+	}
+	return 0, false
 }
 
 // GetComponent tries to fetch a component by name.
@@ -97,58 +460,206 @@ func (b *BaseDynamicEntity) GetComponent(t string) (interface{}, error) {
 	b.Lock()
 	defer b.Unlock()
 
-	if b.components == nil {
-		b.components = map[string]interface{}{}
+	if i, ok := b.find(t); ok {
+		return b.values[i], nil
 	}
 
-	if val, ok := b.components[t]; ok {
-		return val, nil
+	if bit, ok := b.hasTag(t); ok {
+		return reflect.New(tagType(bit)).Interface(), nil
 	}
 
 	return nil, ErrNotFound
 }
 
-// HasComponent checks if the entity has a certain component.
-// If t is a string it will check if a component is present by name.
+// HasComponent checks if the entity has a certain component, either
+// plain or under any key. If t is a string it will check if a
+// component is present by name.
 // If t is a struct or a pointer to a struct the name of the type
 // will be used to check if the component is present.
 func (b *BaseDynamicEntity) HasComponent(t interface{}) error {
 	b.Lock()
 	defer b.Unlock()
 
-	if b.components == nil {
-		b.components = map[string]interface{}{}
-	}
-
 	var typeName string
 	switch t.(type) {
 	case string:
 		typeName = t.(string)
 	default:
-		typeName = getTypeName(t)
+		typeName = b.typeNames().getTypeName(t)
+		if !b.holds(typeName, t) {
+			return ErrNotFound
+		}
+	}
+
+	if _, ok := b.find(typeName); ok {
+		return nil
+	}
+
+	if _, ok := b.hasTag(typeName); ok {
+		return nil
 	}
 
-	if _, ok := b.components[typeName]; ok {
+	if i, _ := b.findKeyed(typeName, ""); i < len(b.keyed) && b.keyed[i].Name == typeName {
 		return nil
 	}
 
 	return ErrNotFound
 }
 
-// GetComponents returns a slice with all the component
-// instances as interface{}.
+// GetComponents returns a slice with all the component instances as
+// interface{} sorted by name. The slice is shared and must not be
+// modified, which makes this call allocation free. Because tags are only
+// stored as flags, the slice is built on the call if any tag is set.
 func (b *BaseDynamicEntity) GetComponents() []interface{} {
 	b.Lock()
 	defer b.Unlock()
 
-	if b.components == nil {
-		b.components = map[string]interface{}{}
+	if b.tags == 0 {
+		return b.values
+	}
+
+	values := make([]interface{}, 0, len(b.values)+bits.OnesCount64(b.tags))
+	names := make([]string, 0, cap(values))
+	for bit := uint(0); bit < maxTags; bit++ {
+		if b.tags&(1<<bit) != 0 {
+			t := tagType(bit)
+			values = append(values, reflect.New(t).Interface())
+			names = append(names, b.typeNames().typeName(t))
+		}
+	}
+
+	values = append(values, b.values...)
+	names = append(names, b.names...)
+	sort.Sort(&componentsByName{names: names, values: values})
+
+	return values
+}
+
+// componentsByName sorts components by their names.
+type componentsByName struct {
+	names  []string
+	values []interface{}
+}
+
+func (c *componentsByName) Len() int           { return len(c.names) }
+func (c *componentsByName) Less(i, j int) bool { return c.names[i] < c.names[j] }
+func (c *componentsByName) Swap(i, j int) {
+	c.names[i], c.names[j] = c.names[j], c.names[i]
+	c.values[i], c.values[j] = c.values[j], c.values[i]
+}
+
+// findKeyed returns the position of the keyed component or the position
+// at which it would be inserted if it isn't present.
+func (b *BaseDynamicEntity) findKeyed(name string, key string) (int, bool) {
+	i := sort.Search(len(b.keyed), func(i int) bool {
+		return b.keyed[i].Name > name || b.keyed[i].Name == name && b.keyed[i].Key >= key
+	})
+	return i, i < len(b.keyed) && b.keyed[i].Name == name && b.keyed[i].Key == key
+}
+
+// SetKeyed sets or adds the component c under the given key. Other
+// instances of the same type with different keys are kept.
+//
+// For example:
+//    _ = ent.SetKeyed("poison", &StatusEffect{Damage: 2})
+//    _ = ent.SetKeyed("burn", &StatusEffect{Damage: 5})
+func (b *BaseDynamicEntity) SetKeyed(key string, c interface{}) error {
+	if err := b.write(); err != nil {
+		return err
+	}
+
+	b.Lock()
+	defer b.Unlock()
+
+	if reflect.TypeOf(c).Kind() != reflect.Ptr {
+		return fmt.Errorf("component needs to be passed as pointer")
+	}
+
+	name := b.typeNames().getTypeName(c)
+	if err := b.claim(name, reflect.TypeOf(c).Elem()); err != nil {
+		return err
+	}
+
+	i, ok := b.findKeyed(name, key)
+
+	keyed := make([]KeyedComponent, len(b.keyed), len(b.keyed)+1)
+	copy(keyed, b.keyed)
+
+	if ok {
+		keyed[i].Value = c
+	} else {
+		keyed = append(keyed, KeyedComponent{})
+		copy(keyed[i+1:], keyed[i:])
+		keyed[i] = KeyedComponent{Name: name, Key: key, Value: c}
+
+		if b.owner != nil {
+			b.owner.dynCounts.addKeyed(1, reflect.TypeOf(c).Elem())
+		}
 	}
 
-	var comps []interface{}
-	for _, v := range b.components {
-		comps = append(comps, reflect.ValueOf(v).Interface())
+	b.keyed = keyed
+
+	b.changed()
+	return nil
+}
+
+// RemoveKeyed removes the component of the type c that is stored
+// under the given key. If c is a string it is used as name.
+func (b *BaseDynamicEntity) RemoveKeyed(c interface{}, key string) error {
+	if err := b.write(); err != nil {
+		return err
 	}
 
-	return comps
+	b.Lock()
+	defer b.Unlock()
+
+	var typeName string
+	switch c.(type) {
+	case string:
+		typeName = c.(string)
+	default:
+		typeName = b.typeNames().getTypeName(c)
+	}
+
+	i, ok := b.findKeyed(typeName, key)
+	if !ok {
+		return ErrNotFound
+	}
+
+	if b.owner != nil {
+		b.owner.dynCounts.addKeyed(-1, reflect.TypeOf(b.keyed[i].Value).Elem())
+	}
+
+	keyed := make([]KeyedComponent, 0, len(b.keyed)-1)
+	keyed = append(keyed, b.keyed[:i]...)
+	b.keyed = append(keyed, b.keyed[i+1:]...)
+
+	if len(b.keyed) == 0 {
+		b.keyed = nil
+	}
+
+	b.changed()
+	return nil
+}
+
+// GetKeyed tries to fetch the component with the given name and key.
+func (b *BaseDynamicEntity) GetKeyed(name string, key string) (interface{}, error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if i, ok := b.findKeyed(name, key); ok {
+		return b.keyed[i].Value, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// GetKeyedComponents returns all keyed components sorted by name and
+// key. Just like GetComponents the slice is shared and must not be
+// modified.
+func (b *BaseDynamicEntity) GetKeyedComponents() []KeyedComponent {
+	b.Lock()
+	defer b.Unlock()
+
+	return b.keyed
 }
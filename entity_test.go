@@ -0,0 +1,76 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type dynA struct{ V int }
+type dynB struct{ V int }
+type dynC struct{ V int }
+type dynD struct{ V int }
+type dynE struct{ V int }
+type dynF struct{ V int }
+type dynG struct{ V int }
+type dynH struct{ V int }
+type dynI struct{ V int }
+type dynJ struct{ V int }
+
+func TestBaseDynamicEntity_Order(t *testing.T) {
+	ent := &DynamicUnit{}
+
+	assert.NoError(t, ent.SetComponent(&Velocity{X: 1}))
+	assert.NoError(t, ent.SetComponent(&Health{Value: 10}))
+	assert.NoError(t, ent.SetComponent(&Pos{X: 1}))
+
+	comps := ent.GetComponents()
+	assert.Equal(t, []interface{}{&Health{Value: 10}, &Pos{X: 1}, &Velocity{X: 1}}, comps)
+
+	// Previously returned slices aren't modified by later changes.
+	assert.NoError(t, ent.SetComponent(&Pos{X: 2}))
+	assert.NoError(t, ent.RemoveComponent(Health{}))
+	assert.Equal(t, []interface{}{&Health{Value: 10}, &Pos{X: 1}, &Velocity{X: 1}}, comps)
+	assert.Equal(t, []interface{}{&Pos{X: 2}, &Velocity{X: 1}}, ent.GetComponents())
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = ent.GetComponents()
+	})
+	assert.Equal(t, 0.0, allocs)
+
+	assert.NoError(t, ent.RemoveComponent("Pos"))
+	assert.NoError(t, ent.RemoveComponent(Velocity{}))
+	assert.Len(t, ent.GetComponents(), 0)
+	assert.ErrorIs(t, ent.RemoveComponent(Velocity{}), ErrNotFound)
+}
+
+func TestBaseDynamicEntity_Index(t *testing.T) {
+	ent := &DynamicUnit{}
+
+	comps := []interface{}{&dynJ{}, &dynA{}, &dynI{}, &dynB{}, &dynH{}, &dynC{}, &dynG{}, &dynD{}, &dynF{}, &dynE{}}
+	for i := range comps {
+		assert.NoError(t, ent.SetComponent(comps[i]))
+	}
+	assert.Len(t, ent.GetComponents(), 10)
+
+	for i := range comps {
+		c, err := ent.GetComponent(defaultNames.getTypeName(comps[i]))
+		assert.NoError(t, err)
+		assert.Same(t, comps[i], c)
+	}
+
+	assert.NoError(t, ent.RemoveComponent(dynC{}))
+	assert.NoError(t, ent.SetComponent(&dynE{V: 5}))
+
+	assert.ErrorIs(t, ent.HasComponent(dynC{}), ErrNotFound)
+	assert.NoError(t, ent.HasComponent("dynJ"))
+
+	c, err := ent.GetComponent("dynE")
+	assert.NoError(t, err)
+	assert.Equal(t, &dynE{V: 5}, c)
+
+	var names []string
+	for _, c := range ent.GetComponents() {
+		names = append(names, defaultNames.getTypeName(c))
+	}
+	assert.Equal(t, []string{"dynA", "dynB", "dynD", "dynE", "dynF", "dynG", "dynH", "dynI", "dynJ"}, names)
+}
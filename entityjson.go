@@ -0,0 +1,114 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// MarshalEntity encodes a single entity into JSON. The form is the same
+// as the one of the entities written by Marshal.
+func (ecs *ECS) MarshalEntity(id EntityID) ([]byte, error) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	entry, _, ok := ecs.findEntity(id)
+	if !ok {
+		return nil, fmt.Errorf("entity %d: %w", id, ErrNotFound)
+	}
+
+	se := ecs.names.serializeEntity(entry)
+	se.NetID = ecs.entityNetIDs[id]
+	se.UUID = ecs.entityUUIDs[id]
+	se.Version = entityVersion(entry.Ent)
+	ecs.addUnknown(&se)
+
+	return json.Marshal(se)
+}
+
+// UnmarshalEntity decodes a single entity in the form of MarshalEntity
+// and adds it. If a entity with the id already exists it's replaced as a
+// whole, so components that are missing in the data are reset or removed.
+// A missing id adds the entity with a new id. The id of the entity is
+// returned.
+//
+// If the data contains a version the entity is only replaced if it's
+// still at that version, otherwise a error wrapping ErrVersion is
+// returned. In contrast to Unmarshal components that can't be decoded
+// always result in a error and nothing is changed, only components of
+// unknown types are kept as raw JSON like in Unmarshal.
+//
+// For example you want to rename a entity:
+//    data, _ := ecs.MarshalEntity(id)
+//    // Edit the JSON
+//    _, err := ecs.UnmarshalEntity(data)
+func (ecs *ECS) UnmarshalEntity(data []byte) (EntityID, error) {
+	var se serializedEntity
+	if err := json.Unmarshal(data, &se); err != nil {
+		return EntityNone, err
+	}
+
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	var firstErr error
+	var unknown unknownComponents
+	ent, ok := ecs.deserializeEntityFn(&se, func(comp string, err error) {
+		if comp != "" && errors.Is(err, errUnknownComponent) {
+			unknown.add(comp, se.Components[comp])
+		} else if firstErr == nil {
+			firstErr = err
+		}
+	})
+	if !ok || firstErr != nil {
+		return EntityNone, firstErr
+	}
+
+	if se.ID == EntityNone {
+		se.ID = ecs.nextId()
+	}
+	ent.Ent.SetID(se.ID)
+
+	if err := ecs.checkUnique(ent.Ent); err != nil {
+		return se.ID, err
+	}
+
+	if other, ok := ecs.netIDs[se.NetID]; ok && other != se.ID {
+		return se.ID, fmt.Errorf("net id %d is used by entity %d: %w", se.NetID, other, ErrAlreadyExists)
+	}
+
+	if other, ok := ecs.uuids[se.UUID]; ok && other != se.ID {
+		return se.ID, fmt.Errorf("uuid %s is used by entity %d: %w", se.UUID, other, ErrAlreadyExists)
+	}
+
+	if old, idx, ok := ecs.findEntity(se.ID); ok {
+		version := entityVersion(old.Ent)
+		if se.Version != 0 && se.Version != version {
+			return se.ID, fmt.Errorf("entity %d: %w %d", se.ID, ErrVersion, se.Version)
+		}
+
+		setVersion(ent.Ent, version)
+		bumpVersion(ent.Ent)
+		ecs.replaceAt(idx, ent)
+	} else {
+		setVersion(ent.Ent, se.Version)
+		if err := ecs.insertEntity(ent); err != nil {
+			return se.ID, err
+		}
+	}
+
+	if se.NetID != NetIDNone {
+		ecs.unbindNetID(se.ID)
+		ecs.bindNetID(se.ID, se.NetID)
+	}
+
+	if se.UUID != UUIDNone {
+		ecs.unbindUUID(se.ID)
+		ecs.bindUUID(se.ID, se.UUID)
+	}
+
+	ecs.setUnknown(se.ID, unknown)
+
+	return se.ID, nil
+}
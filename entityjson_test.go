@@ -0,0 +1,64 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMarshalEntity(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Pos{}))
+
+	id, _ := ecs.AddEntity(&Unit{Health: Health{Value: 5, Max: 10}, Name: Name{Value: "Gandalf"}})
+	netID, _ := ecs.AssignNetID(id)
+	assert.NoError(t, ecs.MustGet(id).Set(Pos{X: 1}))
+
+	data, err := ecs.MarshalEntity(id)
+	assert.NoError(t, err)
+
+	var generic map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &generic))
+	assert.Equal(t, "Unit", generic["Type"])
+	assert.Equal(t, float64(netID), generic["NetID"])
+
+	// Replace the entity with a edited version.
+	edited := strings.Replace(string(data), "Gandalf", "Saruman", 1)
+	replaced, err := ecs.UnmarshalEntity([]byte(edited))
+	assert.NoError(t, err)
+	assert.Equal(t, id, replaced)
+	assert.Equal(t, "Saruman", ecs.MustGet(id).GetEntity().(*Unit).Name.Value)
+	assert.Equal(t, 5, ecs.MustGet(id).GetEntity().(*Unit).Health.Value)
+	assert.Equal(t, 1, ecs.Count())
+
+	// The old version is rejected now.
+	_, err = ecs.UnmarshalEntity(data)
+	assert.ErrorIs(t, err, ErrVersion)
+
+	// Without a id a new entity is added.
+	added, err := ecs.UnmarshalEntity([]byte(`{"Type": "DynamicUnit", "Components": {"Pos": {"X": 3}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, id+1, added)
+	assert.True(t, ecs.MustGet(added).Has(Pos{}))
+
+	_, err = ecs.UnmarshalEntity([]byte(`{"Type": "DynamicUnit", "NetID": ` + string(mustJSON(netID)) + `}`))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	_, err = ecs.UnmarshalEntity([]byte(`{"Type": "Missing"}`))
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = ecs.UnmarshalEntity([]byte(`{"Type": "Unit", "Components": {"Pos": {"X": "left"}}}`))
+	assert.Error(t, err)
+	_, err = ecs.UnmarshalEntity([]byte(`{`))
+	assert.Error(t, err)
+	assert.Equal(t, 2, ecs.Count())
+
+	_, err = ecs.MarshalEntity(42)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func mustJSON(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
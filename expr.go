@@ -0,0 +1,615 @@
+package kinshi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// exprFunc evaluates a expression against a entity. The result is a
+// float64, string or bool. Missing components result in a error.
+type exprFunc func(ent Entity) (interface{}, error)
+
+// exprBuiltins are the functions that can be called in expressions.
+// The arguments are evaluated before the call.
+var exprBuiltins = map[string]func(names *typeNames, ent Entity, args []interface{}) (interface{}, error){
+	// has('Name') checks if the entity contains the component.
+	"has": func(names *typeNames, ent Entity, args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("has needs a single argument")
+		}
+
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("has needs a component name")
+		}
+
+		_, err := names.fetchComponent(ent, name)
+		return err == nil, nil
+	},
+	// tag('enemy') checks if the entity contains the tag component with
+	// the name, ignoring the case, e.g. Enemy{}.
+	"tag": func(names *typeNames, ent Entity, args []interface{}) (interface{}, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("tag needs a single argument")
+		}
+
+		name, ok := args[0].(string)
+		if !ok {
+			return nil, fmt.Errorf("tag needs a tag name")
+		}
+
+		if ptr, err := names.fetchComponent(ent, name); err == nil {
+			return isTag(ptr), nil
+		}
+
+		for _, c := range names.collectComponents(ent) {
+			if strings.EqualFold(c.Name, name) && isTag(c.Value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	},
+}
+
+type exprToken struct {
+	kind  rune // 'i' ident, 'n' number, 's' string, 'o' operator, 0 end
+	text  string
+	value interface{}
+	pos   int
+}
+
+func tokenizeExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsLetter(c) || c == '_':
+			start := i
+			for i < len(src) && (unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i])) || src[i] == '_' || src[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: 'i', text: src[start:i], pos: start})
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(src) && (unicode.IsDigit(rune(src[i])) || src[i] == '.' || src[i] == 'e' || src[i] == 'E') {
+				i++
+			}
+			f, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number '%s' at %d", src[start:i], start)
+			}
+			tokens = append(tokens, exprToken{kind: 'n', text: src[start:i], value: f, pos: start})
+		case c == '\'' || c == '"':
+			start := i
+			i++
+			var sb strings.Builder
+			for i < len(src) && rune(src[i]) != c {
+				if src[i] == '\\' && i+1 < len(src) {
+					i++
+				}
+				sb.WriteByte(src[i])
+				i++
+			}
+			if i >= len(src) {
+				return nil, fmt.Errorf("unterminated string at %d", start)
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: 's', text: src[start:i], value: sb.String(), pos: start})
+		default:
+			op := string(c)
+			if i+1 < len(src) {
+				switch two := src[i : i+2]; two {
+				case "==", "!=", "<=", ">=", "&&", "||":
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%<>=!(),", op) && len(op) == 1 {
+				return nil, fmt.Errorf("unexpected '%s' at %d", op, i)
+			}
+
+			start := i
+			i += len(op)
+
+			// A single = is accepted as comparison as well.
+			if op == "=" {
+				op = "=="
+			}
+			tokens = append(tokens, exprToken{kind: 'o', text: op, pos: start})
+		}
+	}
+
+	return append(tokens, exprToken{pos: len(src)}), nil
+}
+
+// exprParser is a recursive descent parser for filter expressions. The
+// precedence from low to high is: ||, &&, !, comparisons, + -, * / %
+// and the unary minus. AND, OR and NOT can be used as keywords.
+type exprParser struct {
+	names  *typeNames
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.pos]
+	if t.kind != 0 {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the operators or keywords.
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	t := p.peek()
+	for _, op := range ops {
+		if t.kind == 'o' && t.text == op || t.kind == 'i' && strings.EqualFold(t.text, op) && isExprKeyword(op) {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func isExprKeyword(s string) bool {
+	switch strings.ToUpper(s) {
+	case "AND", "OR", "NOT":
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at %d", fmt.Sprintf(format, args...), p.peek().pos)
+}
+
+func (p *exprParser) parseOr() (exprFunc, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("||", "OR"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr(left, right, true)
+	}
+}
+
+func (p *exprParser) parseAnd() (exprFunc, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, ok := p.accept("&&", "AND"); !ok {
+			return left, nil
+		}
+
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalExpr(left, right, false)
+	}
+}
+
+func (p *exprParser) parseNot() (exprFunc, error) {
+	if _, ok := p.accept("!", "NOT"); ok {
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+
+		return func(ent Entity) (interface{}, error) {
+			v, err := inner(ent)
+			if err != nil {
+				return nil, err
+			}
+			b, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("! on non bool value")
+			}
+			return !b, nil
+		}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprFunc, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	op, ok := p.accept("==", "!=", "<=", ">=", "<", ">")
+	if !ok {
+		return left, nil
+	}
+
+	right, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ent Entity) (interface{}, error) {
+		a, err := left(ent)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(ent)
+		if err != nil {
+			return nil, err
+		}
+		return compareValues(op, a, b)
+	}, nil
+}
+
+func (p *exprParser) parseSum() (exprFunc, error) {
+	left, err := p.parseProduct()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept("+", "-")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseProduct()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticExpr(op, left, right)
+	}
+}
+
+func (p *exprParser) parseProduct() (exprFunc, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.accept("*", "/", "%")
+		if !ok {
+			return left, nil
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = arithmeticExpr(op, left, right)
+	}
+}
+
+func (p *exprParser) parseUnary() (exprFunc, error) {
+	if _, ok := p.accept("-"); ok {
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return arithmeticExpr("*", inner, constExpr(-1.0)), nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprFunc, error) {
+	t := p.next()
+
+	switch t.kind {
+	case 'n', 's':
+		return constExpr(t.value), nil
+	case 'o':
+		if t.text != "(" {
+			break
+		}
+
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, p.errorf("missing ')'")
+		}
+		return inner, nil
+	case 'i':
+		switch strings.ToLower(t.text) {
+		case "true":
+			return constExpr(true), nil
+		case "false":
+			return constExpr(false), nil
+		}
+
+		if _, ok := p.accept("("); ok {
+			return p.parseCall(t)
+		}
+
+		return fieldExpr(p.names, t.text), nil
+	}
+
+	if t.kind == 0 {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	return nil, fmt.Errorf("unexpected '%s' at %d", t.text, t.pos)
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprFunc, error) {
+	fn, ok := exprBuiltins[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("unknown function '%s' at %d", name.text, name.pos)
+	}
+
+	var args []exprFunc
+	if _, ok := p.accept(")"); !ok {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+
+			if _, ok := p.accept(","); ok {
+				continue
+			}
+			if _, ok := p.accept(")"); ok {
+				break
+			}
+			return nil, p.errorf("missing ')'")
+		}
+	}
+
+	names := p.names
+	return func(ent Entity) (interface{}, error) {
+		values := make([]interface{}, len(args))
+		for i := range args {
+			v, err := args[i](ent)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return fn(names, ent, values)
+	}, nil
+}
+
+// parseExpr compiles the expression into a function. Components are
+// referenced by the names that are derived by names.
+func parseExpr(names *typeNames, src string) (exprFunc, error) {
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{names: names, tokens: tokens}
+	fn, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind != 0 {
+		return nil, p.errorf("unexpected '%s'", p.peek().text)
+	}
+
+	return fn, nil
+}
+
+func constExpr(v interface{}) exprFunc {
+	return func(ent Entity) (interface{}, error) {
+		return v, nil
+	}
+}
+
+func logicalExpr(left, right exprFunc, or bool) exprFunc {
+	return func(ent Entity) (interface{}, error) {
+		a, err := left(ent)
+		if err != nil {
+			return nil, err
+		}
+		ab, ok := a.(bool)
+		if !ok {
+			return nil, fmt.Errorf("logical operator on non bool value")
+		}
+		if ab == or {
+			return ab, nil
+		}
+
+		b, err := right(ent)
+		if err != nil {
+			return nil, err
+		}
+		bb, ok := b.(bool)
+		if !ok {
+			return nil, fmt.Errorf("logical operator on non bool value")
+		}
+		return bb, nil
+	}
+}
+
+func arithmeticExpr(op string, left, right exprFunc) exprFunc {
+	return func(ent Entity) (interface{}, error) {
+		a, err := left(ent)
+		if err != nil {
+			return nil, err
+		}
+		b, err := right(ent)
+		if err != nil {
+			return nil, err
+		}
+
+		if as, ok := a.(string); ok && op == "+" {
+			if bs, ok := b.(string); ok {
+				return as + bs, nil
+			}
+		}
+
+		af, aok := a.(float64)
+		bf, bok := b.(float64)
+		if !aok || !bok {
+			return nil, fmt.Errorf("'%s' on non numeric values", op)
+		}
+
+		switch op {
+		case "+":
+			return af + bf, nil
+		case "-":
+			return af - bf, nil
+		case "*":
+			return af * bf, nil
+		case "/":
+			return af / bf, nil
+		}
+		return math.Mod(af, bf), nil
+	}
+}
+
+func compareValues(op string, a, b interface{}) (interface{}, error) {
+	switch av := a.(type) {
+	case float64:
+		if bv, ok := b.(float64); ok {
+			switch op {
+			case "==":
+				return av == bv, nil
+			case "!=":
+				return av != bv, nil
+			case "<":
+				return av < bv, nil
+			case "<=":
+				return av <= bv, nil
+			case ">":
+				return av > bv, nil
+			}
+			return av >= bv, nil
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			switch op {
+			case "==":
+				return av == bv, nil
+			case "!=":
+				return av != bv, nil
+			case "<":
+				return av < bv, nil
+			case "<=":
+				return av <= bv, nil
+			case ">":
+				return av > bv, nil
+			}
+			return av >= bv, nil
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			switch op {
+			case "==":
+				return av == bv, nil
+			case "!=":
+				return av != bv, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("can't compare %v %s %v", a, op, b)
+}
+
+// fieldPaths caches the field indexes of "Field.Sub" paths by type.
+var fieldPaths sync.Map
+
+type fieldPathKey struct {
+	t    reflect.Type
+	path string
+}
+
+// fieldExpr reads a value of a component. The reference is in the form
+// "Component.Field.Sub", where the component can be referenced without
+// fields if it's a defined numeric, string or bool type.
+func fieldExpr(names *typeNames, ref string) exprFunc {
+	parts := strings.SplitN(ref, ".", 2)
+	comp, path := parts[0], ""
+	if len(parts) == 2 {
+		path = parts[1]
+	}
+
+	return func(ent Entity) (interface{}, error) {
+		ptr, err := names.fetchComponent(ent, comp)
+		if err != nil {
+			return nil, fmt.Errorf("component '%s': %w", comp, err)
+		}
+
+		val := reflect.ValueOf(ptr).Elem()
+		if path != "" {
+			if val, err = fieldByPath(val, path); err != nil {
+				return nil, fmt.Errorf("component '%s': %w", comp, err)
+			}
+		}
+
+		return exprValue(val)
+	}
+}
+
+func fieldByPath(val reflect.Value, path string) (reflect.Value, error) {
+	key := fieldPathKey{t: val.Type(), path: path}
+
+	var index []int
+	if cached, ok := fieldPaths.Load(key); ok {
+		index = cached.([]int)
+	} else {
+		t := val.Type()
+		for _, name := range strings.Split(path, ".") {
+			if t.Kind() != reflect.Struct {
+				return reflect.Value{}, fmt.Errorf("field '%s': %w", path, ErrNotFound)
+			}
+
+			f, ok := t.FieldByName(name)
+			if !ok || f.PkgPath != "" || len(f.Index) != 1 {
+				return reflect.Value{}, fmt.Errorf("field '%s': %w", path, ErrNotFound)
+			}
+
+			index = append(index, f.Index[0])
+			t = f.Type
+		}
+		fieldPaths.Store(key, index)
+	}
+
+	return val.FieldByIndex(index), nil
+}
+
+// exprValue converts the value into the types that expressions work with.
+func exprValue(val reflect.Value) (interface{}, error) {
+	switch val.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(val.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(val.Uint()), nil
+	case reflect.String:
+		return val.String(), nil
+	case reflect.Bool:
+		return val.Bool(), nil
+	}
+
+	return nil, fmt.Errorf("values of type '%s' can't be used in expressions", val.Type())
+}
@@ -0,0 +1,50 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseExpr(t *testing.T) {
+	unit := &Unit{Health: Health{Value: 3, Max: 10}, Pos: Pos{X: 4, Y: -2}, Name: Name{Value: "Gandalf"}}
+
+	cases := map[string]interface{}{
+		"Health.Value < Health.Max / 2":         true,
+		"Health.Value * 2 + 1":                  7.0,
+		"-(Pos.X - Pos.Y) % 4":                  -2.0,
+		"Pos.X >= 4 && Pos.Y = -2":              true,
+		"Pos.X > 4 || Name.Value == 'Gandalf'":  true,
+		"NOT (Pos.X > 1 AND Pos.Y < 0)":         false,
+		"!has('Velocity') and has('Health')":    true,
+		`Name.Value + " the Grey" != "Gandalf"`: true,
+		"Name.Value < 'Z' && true == (!false)":  true,
+		"2e1 / (Health.Max - 5)":                4.0,
+	}
+
+	for src, expected := range cases {
+		expr, err := parseExpr(defaultNames, src)
+		if !assert.NoError(t, err, src) {
+			continue
+		}
+
+		v, err := expr(unit)
+		assert.NoError(t, err, src)
+		assert.Equal(t, expected, v, src)
+	}
+}
+
+func TestParseExpr_Errors(t *testing.T) {
+	for _, src := range []string{"", "Health.Value <", "(Pos.X", "Pos.X & 1", "unknown(1)", "'open", "1 2"} {
+		_, err := parseExpr(defaultNames, src)
+		assert.Error(t, err, src)
+	}
+
+	unit := &Unit{}
+	for _, src := range []string{"Velocity.X > 0", "Health.Missing > 0", "Name.Value > 1", "Health > 0", "Pos.X && true"} {
+		expr, err := parseExpr(defaultNames, src)
+		if assert.NoError(t, err, src) {
+			_, err = expr(unit)
+			assert.Error(t, err, src)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package kinshi
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// counters are runtime counters that are only
+// tracked if they are published via expvar.
+type counters struct {
+	adds       uint64
+	removes    uint64
+	iterates   uint64
+	locks      uint64
+	lockWaitNs uint64
+}
+
+// lock acquires the write lock and tracks the time waited for it.
+func (ecs *ECS) lock() {
+	if ecs.counters == nil {
+		ecs.Lock()
+		ecs.invalidateReads()
+		return
+	}
+
+	start := time.Now()
+	ecs.Lock()
+	ecs.invalidateReads()
+	atomic.AddUint64(&ecs.counters.locks, 1)
+	atomic.AddUint64(&ecs.counters.lockWaitNs, uint64(time.Since(start)))
+}
+
+// rlock acquires the read lock and tracks the time waited for it.
+func (ecs *ECS) rlock() {
+	if ecs.counters == nil {
+		ecs.RLock()
+		return
+	}
+
+	start := time.Now()
+	ecs.RLock()
+	atomic.AddUint64(&ecs.counters.locks, 1)
+	atomic.AddUint64(&ecs.counters.lockWaitNs, uint64(time.Since(start)))
+}
+
+func (ecs *ECS) countAdd() {
+	if ecs.counters != nil {
+		atomic.AddUint64(&ecs.counters.adds, 1)
+	}
+}
+
+func (ecs *ECS) countRemove() {
+	if ecs.counters != nil {
+		atomic.AddUint64(&ecs.counters.removes, 1)
+	}
+}
+
+func (ecs *ECS) countIterate() {
+	if ecs.counters != nil {
+		atomic.AddUint64(&ecs.counters.iterates, 1)
+	}
+}
+
+// rate calculates the per second rate of a counter between two reads.
+// The rate is only updated if at least a second passed since the last
+// update, so that frequent reads don't result in noisy values.
+type rate struct {
+	sync.Mutex
+	counter *uint64
+	last    uint64
+	lastAt  time.Time
+	value   float64
+}
+
+func (r *rate) get() interface{} {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	current := atomic.LoadUint64(r.counter)
+
+	if elapsed := now.Sub(r.lastAt); elapsed >= time.Second {
+		r.value = float64(current-r.last) / elapsed.Seconds()
+		r.last = current
+		r.lastAt = now
+	}
+
+	return r.value
+}
+
+// WithExpvar publishes runtime counters of the ECS as a expvar.Map under
+// the given name. The map contains the number of entities, the total and
+// per second number of added and removed entities, the number of iterate
+// calls and the number of lock acquisitions with the total time spent
+// waiting for locks.
+//
+// If a map with the name is already published it will be reused.
+func WithExpvar(name string) Option {
+	return func(ecs *ECS) {
+		ecs.counters = &counters{}
+
+		m, ok := expvar.Get(name).(*expvar.Map)
+		if !ok {
+			m = expvar.NewMap(name)
+		}
+
+		loadFunc := func(v *uint64) expvar.Func {
+			return func() interface{} {
+				return atomic.LoadUint64(v)
+			}
+		}
+
+		now := time.Now()
+		addRate := &rate{counter: &ecs.counters.adds, lastAt: now}
+		removeRate := &rate{counter: &ecs.counters.removes, lastAt: now}
+
+		m.Set("entities", expvar.Func(func() interface{} {
+			ecs.RLock()
+			defer ecs.RUnlock()
+			return len(ecs.entities)
+		}))
+		m.Set("adds", loadFunc(&ecs.counters.adds))
+		m.Set("adds_per_second", expvar.Func(addRate.get))
+		m.Set("removes", loadFunc(&ecs.counters.removes))
+		m.Set("removes_per_second", expvar.Func(removeRate.get))
+		m.Set("iterates", loadFunc(&ecs.counters.iterates))
+		m.Set("locks", loadFunc(&ecs.counters.locks))
+		m.Set("lock_wait_ns", loadFunc(&ecs.counters.lockWaitNs))
+	}
+}
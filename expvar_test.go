@@ -0,0 +1,36 @@
+package kinshi
+
+import (
+	"expvar"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWithExpvar(t *testing.T) {
+	ecs := New(WithExpvar("kinshi_test"))
+
+	for i := 0; i < 5; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+	_ = ecs.RemoveEntity(ecs.MustGet(1).GetEntity())
+	ecs.Iterate(Pos{})
+	ecs.IterateSpecific(Unit{})
+
+	m, ok := expvar.Get("kinshi_test").(*expvar.Map)
+	if !assert.True(t, ok, "map wasn't published") {
+		return
+	}
+
+	assert.Equal(t, "4", m.Get("entities").String())
+	assert.Equal(t, "5", m.Get("adds").String())
+	assert.Equal(t, "1", m.Get("removes").String())
+	assert.Equal(t, "2", m.Get("iterates").String())
+	assert.NotEqual(t, "0", m.Get("locks").String())
+
+	// Publishing under the same name again reuses the map
+	assert.NotPanics(t, func() {
+		other := New(WithExpvar("kinshi_test"))
+		_, _ = other.AddEntity(&Unit{})
+		assert.Equal(t, "1", m.Get("entities").String())
+	})
+}
@@ -0,0 +1,322 @@
+package kinshi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// interfaceCache caches by type if values of the type
+// contain interfaces that need type information.
+var interfaceCache sync.Map
+
+var (
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// hasInterface checks if values of the type contain interface
+// fields, elements or map values.
+func hasInterface(t reflect.Type) bool {
+	if has, ok := interfaceCache.Load(t); ok {
+		return has.(bool)
+	}
+
+	has := containsInterface(t, map[reflect.Type]bool{})
+	interfaceCache.Store(t, has)
+	return has
+}
+
+func containsInterface(t reflect.Type, visited map[reflect.Type]bool) bool {
+	if visited[t] {
+		return false
+	}
+	visited[t] = true
+
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) {
+		return false
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		return true
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return containsInterface(t.Elem(), visited)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if containsInterface(t.Field(i).Type, visited) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// encodeValue prepares the value for JSON encoding. Values held by
+// interfaces are wrapped together with the name of their concrete type,
+// so that they can be restored on decoding. Values without interfaces
+// are returned as they are.
+func (tn *typeNames) encodeValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+
+	t := v.Type()
+	if !hasInterface(t) {
+		return v.Interface()
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		concrete := v.Elem()
+		ct := concrete.Type()
+		if ct.Kind() == reflect.Ptr {
+			ct = ct.Elem()
+		}
+
+		if ct.Name() == "" || ct.PkgPath() == "" {
+			return tn.encodeValue(concrete)
+		}
+
+		return interfaceComponent{
+			Type:  tn.typeName(ct),
+			Value: tn.encodeValue(concrete),
+		}
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return tn.encodeValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if t.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			res[i] = tn.encodeValue(v.Index(i))
+		}
+		return res
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+
+		res := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			res[encodeKey(iter.Key())] = tn.encodeValue(iter.Value())
+		}
+		return res
+	case reflect.Struct:
+		res := map[string]interface{}{}
+		tn.encodeFields(v, res)
+		return res
+	}
+
+	return v.Interface()
+}
+
+// encodeFields adds the fields of the struct to res following the
+// rules of encoding/json for field names and embedded structs.
+func (tn *typeNames) encodeFields(v reflect.Value, res map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx:]
+		}
+
+		fv := v.Field(i)
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			embedded := map[string]interface{}{}
+			tn.encodeFields(fv, embedded)
+			for k, val := range embedded {
+				if _, ok := res[k]; !ok {
+					res[k] = val
+				}
+			}
+			continue
+		}
+
+		if field.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		if strings.Contains(opts, ",omitempty") && isEmptyValue(fv) {
+			continue
+		}
+
+		res[name] = tn.encodeValue(fv)
+	}
+}
+
+// encodeKey converts a map key to a string like encoding/json does.
+func encodeKey(k reflect.Value) string {
+	if k.Kind() == reflect.String {
+		return k.String()
+	}
+
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if text, err := tm.MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+
+	return fmt.Sprint(k.Interface())
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
+
+// decodeKey parses a map key that was written by encodeKey.
+func decodeKey(s string, t reflect.Type) (reflect.Value, error) {
+	key := reflect.New(t)
+	if tu, ok := key.Interface().(encoding.TextUnmarshaler); ok {
+		return key.Elem(), tu.UnmarshalText([]byte(s))
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, t.Bits())
+		key.Elem().SetInt(i)
+		return key.Elem(), err
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		i, err := strconv.ParseUint(s, 10, t.Bits())
+		key.Elem().SetUint(i)
+		return key.Elem(), err
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, t.Bits())
+		key.Elem().SetFloat(f)
+		return key.Elem(), err
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		key.Elem().SetBool(b)
+		return key.Elem(), err
+	}
+
+	return key.Elem(), fmt.Errorf("unsupported map key type %s", t)
+}
+
+// fidelityHook restores what encodeValue and encoding/json wrote: values
+// of interfaces from their registered concrete types and maps that have
+// keys other than strings.
+func (ecs *ECS) fidelityHook(f reflect.Type, t reflect.Type, data interface{}) (interface{}, error) {
+	switch {
+	case t.Kind() == reflect.Interface:
+		ic, ok := wrappedInterface(data)
+		if !ok {
+			return data, nil
+		}
+
+		concrete, ok := ecs.lookupComponent(ic.Type)
+		if !ok {
+			return nil, fmt.Errorf("concrete type '%s': %w", ic.Type, ErrNotFound)
+		}
+
+		ptr := reflect.New(concrete)
+		if err := ecs.decode(ic.Value, ptr.Interface()); err != nil {
+			return nil, err
+		}
+
+		switch {
+		case concrete.Implements(t):
+			return ptr.Elem().Interface(), nil
+		case ptr.Type().Implements(t):
+			return ptr.Interface(), nil
+		}
+
+		return nil, fmt.Errorf("'%s' doesn't implement %s: %w", ic.Type, t, ErrTypeConflict)
+	case t.Kind() == reflect.Map && t.Key().Kind() != reflect.String && f.Kind() == reflect.Map && f.Key().Kind() == reflect.String:
+		in := reflect.ValueOf(data)
+		out := reflect.MakeMapWithSize(t, in.Len())
+
+		iter := in.MapRange()
+		for iter.Next() {
+			key, err := decodeKey(iter.Key().String(), t.Key())
+			if err != nil {
+				return nil, err
+			}
+
+			val := reflect.New(t.Elem())
+			if err := ecs.decode(iter.Value().Interface(), val.Interface()); err != nil {
+				return nil, err
+			}
+
+			out.SetMapIndex(key, val.Elem())
+		}
+
+		return out.Interface(), nil
+	}
+
+	return data, nil
+}
+
+// wrappedInterface checks if data is a value that was
+// wrapped by encodeValue or for a interface component.
+func wrappedInterface(data interface{}) (interfaceComponent, bool) {
+	switch v := data.(type) {
+	case interfaceComponent:
+		return v, true
+	case map[string]interface{}:
+		if len(v) != 2 {
+			return interfaceComponent{}, false
+		}
+
+		name, ok := v["Type"].(string)
+		if !ok {
+			return interfaceComponent{}, false
+		}
+
+		value, ok := v["Value"]
+		return interfaceComponent{Type: name, Value: value}, ok
+	}
+
+	return interfaceComponent{}, false
+}
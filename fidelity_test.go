@@ -0,0 +1,119 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type Equipment interface {
+	Slot() string
+}
+
+type Helmet struct {
+	Armor int
+}
+
+func (Helmet) Slot() string {
+	return "head"
+}
+
+type Ring struct {
+	Magic int
+}
+
+func (*Ring) Slot() string {
+	return "finger"
+}
+
+type Stacked struct {
+	Stack int
+}
+
+type Bag struct {
+	Stacked
+	Items   []Item
+	ByID    map[EntityID]Item
+	Slots   map[string]Equipment
+	Worn    []Equipment
+	Nested  [][]Item
+	Renamed int `json:"renamed,omitempty"`
+	Extra   interface{}
+}
+
+type Chest struct {
+	BaseEntity
+	Bag
+}
+
+func TestSerializationFidelity(t *testing.T) {
+	bag := Bag{
+		Stacked: Stacked{Stack: 3},
+		Items:   []Item{{Name: "Ore", Count: 2}, {Name: "Gem", Count: 1}},
+		ByID:    map[EntityID]Item{7: {Name: "Key", Count: 1}},
+		Slots:   map[string]Equipment{"head": Helmet{Armor: 5}, "finger": &Ring{Magic: 9}},
+		Worn:    []Equipment{&Ring{Magic: 1}, nil},
+		Nested:  [][]Item{{{Name: "Coin", Count: 10}}, nil},
+		Renamed: 4,
+		Extra:   Helmet{Armor: 1},
+	}
+
+	ecs := New(WithStrict())
+	assert.NoError(t, ecs.RegisterEntity(&Chest{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(&Bag{}))
+	assert.NoError(t, ecs.RegisterComponent(&Helmet{}))
+	assert.NoError(t, ecs.RegisterComponent(&Ring{}))
+
+	_, err := ecs.AddEntity(&Chest{Bag: bag})
+	assert.NoError(t, err)
+
+	dyn := &DynamicUnit{}
+	dynBag := bag
+	assert.NoError(t, dyn.SetComponent(&dynBag))
+	_, err = ecs.AddEntity(dyn)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.Contains(t, buf.String(), `"renamed": 4`)
+	assert.Contains(t, buf.String(), `"Stack": 3`)
+
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.RegisterEntity(&Chest{}))
+	assert.NoError(t, loaded.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, loaded.RegisterComponent(&Bag{}))
+	assert.NoError(t, loaded.RegisterComponent(&Helmet{}))
+	assert.NoError(t, loaded.RegisterComponent(&Ring{}))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	chests := loaded.IterateSpecific(Chest{})
+	assert.Len(t, chests, 1)
+	assert.Equal(t, bag, chests[0].GetEntity().(*Chest).Bag)
+
+	c, err := loaded.Iterate(Name{})[0].GetEntity().(*DynamicUnit).GetComponent("Bag")
+	assert.NoError(t, err)
+	assert.Equal(t, &bag, c)
+
+	// Worlds with the same content have the same checksum.
+	assert.Equal(t, ecs.Checksum(), loaded.Checksum())
+}
+
+func TestSerializationFidelity_Unregistered(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Chest{}))
+	assert.NoError(t, ecs.RegisterComponent(&Helmet{}))
+
+	_, err := ecs.AddEntity(&Chest{Bag: Bag{Worn: []Equipment{&Ring{Magic: 1}}}})
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.RegisterEntity(&Chest{}))
+	err = loaded.Unmarshal(bytes.NewReader(buf.Bytes()))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "concrete type 'Ring'")
+	}
+}
@@ -0,0 +1,429 @@
+// Package flat stores snapshots of a kinshi.ECS as FlatBuffers. In
+// contrast to the JSON snapshots of kinshi.ECS.Marshal the file doesn't
+// need to be decoded as a whole. Open only wraps the data, which can be
+// a memory mapped file, and single entities are looked up by their id
+// without copying or decoding anything else. This allows huge worlds of
+// which only the entities of the current region are loaded into the ECS.
+//
+// For example you want to stream in the entities of a region:
+//    data, _ := ioutil.ReadFile("world.kfb")
+//    snap, err := flat.Open(data)
+//    // ...
+//    err = snap.Load(ecs, regionIDs...)
+//
+// OpenFile memory maps a snapshot and World provides read only access to
+// its entities, which are decoded on first access.
+//
+// The file follows this schema, the components are kept in the JSON form
+// of kinshi.ECS.MarshalEntity:
+//    file_identifier "KNSH";
+//
+//    table Component {
+//        name: string;   // sorted
+//        data: [ubyte];  // JSON
+//    }
+//
+//    table Entity {
+//        id: ulong;      // sorted
+//        net_id: ulong;
+//        uuid: [ubyte];
+//        version: ulong;
+//        type: string;
+//        components: [Component];
+//        tags: [string];
+//    }
+//
+//    table Snapshot {
+//        entities: [Entity];
+//    }
+//
+//    root_type Snapshot;
+package flat
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"github.com/google/flatbuffers/go"
+	"io"
+	"sort"
+)
+
+// Identifier is the file identifier of the snapshots.
+const Identifier = "KNSH"
+
+// ErrInvalid is returned by Open if the data isn't a snapshot.
+var ErrInvalid = errors.New("not a flat snapshot")
+
+// The vtable offsets of the fields.
+const (
+	componentName = 4 + 2*iota
+	componentData
+)
+
+const (
+	entityID = 4 + 2*iota
+	entityNetID
+	entityUUID
+	entityVersion
+	entityType
+	entityComponents
+	entityTags
+)
+
+const snapshotEntities = 4
+
+// entityJSON is the JSON form of a entity, see kinshi.ECS.MarshalEntity.
+type entityJSON struct {
+	ID         kinshi.EntityID
+	NetID      kinshi.NetID `json:",omitempty"`
+	UUID       *kinshi.UUID `json:",omitempty"`
+	Version    uint64       `json:",omitempty"`
+	Type       string
+	Components map[string]json.RawMessage
+	Tags       []string `json:",omitempty"`
+}
+
+// Marshal writes a snapshot of all entities of the ECS.
+func Marshal(w io.Writer, ecs *kinshi.ECS) error {
+	var buf bytes.Buffer
+	if err := ecs.Marshal(&buf); err != nil {
+		return err
+	}
+
+	var ents []entityJSON
+	if err := json.Unmarshal(buf.Bytes(), &ents); err != nil {
+		return err
+	}
+
+	sort.Slice(ents, func(i, j int) bool {
+		return ents[i].ID < ents[j].ID
+	})
+
+	b := flatbuffers.NewBuilder(buf.Len())
+
+	offsets := make([]flatbuffers.UOffsetT, len(ents))
+	for i := range ents {
+		offsets[i] = buildEntity(b, &ents[i])
+	}
+
+	entities := vectorOf(b, offsets)
+
+	b.StartObject(1)
+	b.PrependUOffsetTSlot(0, entities, 0)
+	b.FinishWithFileIdentifier(b.EndObject(), []byte(Identifier))
+
+	_, err := w.Write(b.FinishedBytes())
+	return err
+}
+
+func buildEntity(b *flatbuffers.Builder, ent *entityJSON) flatbuffers.UOffsetT {
+	names := make([]string, 0, len(ent.Components))
+	for name := range ent.Components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var compact bytes.Buffer
+	comps := make([]flatbuffers.UOffsetT, len(names))
+	for i, name := range names {
+		compact.Reset()
+		_ = json.Compact(&compact, ent.Components[name])
+
+		n := b.CreateString(name)
+		d := b.CreateByteVector(compact.Bytes())
+
+		b.StartObject(2)
+		b.PrependUOffsetTSlot(0, n, 0)
+		b.PrependUOffsetTSlot(1, d, 0)
+		comps[i] = b.EndObject()
+	}
+	components := vectorOf(b, comps)
+
+	tags := make([]flatbuffers.UOffsetT, len(ent.Tags))
+	for i := range ent.Tags {
+		tags[i] = b.CreateString(ent.Tags[i])
+	}
+	tagVec := vectorOf(b, tags)
+
+	typ := b.CreateString(ent.Type)
+
+	var uuid flatbuffers.UOffsetT
+	if ent.UUID != nil {
+		uuid = b.CreateByteVector(ent.UUID[:])
+	}
+
+	b.StartObject(7)
+	b.PrependUint64Slot(0, uint64(ent.ID), 0)
+	b.PrependUint64Slot(1, uint64(ent.NetID), 0)
+	if uuid != 0 {
+		b.PrependUOffsetTSlot(2, uuid, 0)
+	}
+	b.PrependUint64Slot(3, ent.Version, 0)
+	b.PrependUOffsetTSlot(4, typ, 0)
+	b.PrependUOffsetTSlot(5, components, 0)
+	b.PrependUOffsetTSlot(6, tagVec, 0)
+	return b.EndObject()
+}
+
+func vectorOf(b *flatbuffers.Builder, offsets []flatbuffers.UOffsetT) flatbuffers.UOffsetT {
+	b.StartVector(4, len(offsets), 4)
+	for i := len(offsets) - 1; i >= 0; i-- {
+		b.PrependUOffsetT(offsets[i])
+	}
+	return b.EndVector(len(offsets))
+}
+
+// Snapshot is a read only view of a snapshot. The data is used as it is,
+// so it must not be changed while the snapshot is used. Only the header
+// is checked by Open, so only open snapshots of trusted sources.
+type Snapshot struct {
+	tab flatbuffers.Table
+}
+
+// Open wraps the data of a snapshot written by Marshal.
+func Open(data []byte) (*Snapshot, error) {
+	if len(data) < 8 || string(data[4:8]) != Identifier {
+		return nil, ErrInvalid
+	}
+
+	root := flatbuffers.GetUOffsetT(data)
+	if int(root)+4 > len(data) {
+		return nil, ErrInvalid
+	}
+
+	return &Snapshot{tab: flatbuffers.Table{Bytes: data, Pos: root}}, nil
+}
+
+// Len returns the number of entities.
+func (s *Snapshot) Len() int {
+	return vectorLen(&s.tab, snapshotEntities)
+}
+
+// At returns the i-th entity. The entities are sorted by id.
+func (s *Snapshot) At(i int) Entity {
+	return Entity{tab: vectorTable(&s.tab, snapshotEntities, i)}
+}
+
+// Find returns the entity with the id.
+func (s *Snapshot) Find(id kinshi.EntityID) (Entity, bool) {
+	n := s.Len()
+	i := sort.Search(n, func(i int) bool {
+		return s.At(i).ID() >= id
+	})
+
+	if i < n {
+		if ent := s.At(i); ent.ID() == id {
+			return ent, true
+		}
+	}
+	return Entity{}, false
+}
+
+// Load adds the entities with the ids to the ECS, see
+// kinshi.ECS.UnmarshalEntity. A entity that already exists is
+// replaced, which fails with kinshi.ErrVersion if it has been changed
+// since the snapshot was written. Without ids all entities are loaded.
+func (s *Snapshot) Load(ecs *kinshi.ECS, ids ...kinshi.EntityID) error {
+	load := func(ent Entity) error {
+		data, err := ent.JSON()
+		if err != nil {
+			return err
+		}
+
+		if _, err := ecs.UnmarshalEntity(data); err != nil {
+			return fmt.Errorf("entity %d: %w", ent.ID(), err)
+		}
+		return nil
+	}
+
+	if len(ids) == 0 {
+		for i := 0; i < s.Len(); i++ {
+			if err := load(s.At(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, id := range ids {
+		ent, ok := s.Find(id)
+		if !ok {
+			return fmt.Errorf("entity %d: %w", id, kinshi.ErrNotFound)
+		}
+
+		if err := load(ent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Unmarshal replaces all entities of the ECS with the ones of the
+// snapshot, like kinshi.ECS.Unmarshal.
+func Unmarshal(data []byte, ecs *kinshi.ECS) error {
+	s, err := Open(data)
+	if err != nil {
+		return err
+	}
+
+	ents := make([]json.RawMessage, s.Len())
+	for i := range ents {
+		if ents[i], err = s.At(i).JSON(); err != nil {
+			return err
+		}
+	}
+
+	buf, err := json.Marshal(ents)
+	if err != nil {
+		return err
+	}
+	return ecs.Unmarshal(bytes.NewReader(buf))
+}
+
+// Entity is a entity of a snapshot.
+type Entity struct {
+	tab flatbuffers.Table
+}
+
+// ID returns the id of the entity.
+func (e Entity) ID() kinshi.EntityID {
+	return kinshi.EntityID(e.tab.GetUint64Slot(entityID, 0))
+}
+
+// NetID returns the network id of the entity.
+func (e Entity) NetID() kinshi.NetID {
+	return kinshi.NetID(e.tab.GetUint64Slot(entityNetID, 0))
+}
+
+// UUID returns the UUID of the entity.
+func (e Entity) UUID() kinshi.UUID {
+	var u kinshi.UUID
+	if o := e.tab.Offset(entityUUID); o != 0 {
+		copy(u[:], e.tab.ByteVector(flatbuffers.UOffsetT(o)+e.tab.Pos))
+	}
+	return u
+}
+
+// Version returns the version of the entity.
+func (e Entity) Version() uint64 {
+	return e.tab.GetUint64Slot(entityVersion, 0)
+}
+
+// Type returns the type name of the entity.
+func (e Entity) Type() string {
+	if o := e.tab.Offset(entityType); o != 0 {
+		return e.tab.String(flatbuffers.UOffsetT(o) + e.tab.Pos)
+	}
+	return ""
+}
+
+// Components returns the names of the components, without the tags.
+func (e Entity) Components() []string {
+	names := make([]string, vectorLen(&e.tab, entityComponents))
+	for i := range names {
+		names[i] = componentAt(&e.tab, i).name()
+	}
+	return names
+}
+
+// Tags returns the names of the tag components.
+func (e Entity) Tags() []string {
+	tags := make([]string, vectorLen(&e.tab, entityTags))
+	if len(tags) == 0 {
+		return nil
+	}
+
+	vec := e.tab.Vector(flatbuffers.UOffsetT(e.tab.Offset(entityTags)))
+	for i := range tags {
+		tags[i] = e.tab.String(vec + flatbuffers.UOffsetT(i*4))
+	}
+	return tags
+}
+
+// Component returns the JSON of the component. The data points into the
+// snapshot and must not be changed.
+func (e Entity) Component(name string) ([]byte, bool) {
+	n := vectorLen(&e.tab, entityComponents)
+	i := sort.Search(n, func(i int) bool {
+		return componentAt(&e.tab, i).name() >= name
+	})
+
+	if i < n {
+		if c := componentAt(&e.tab, i); c.name() == name {
+			return c.data(), true
+		}
+	}
+	return nil, false
+}
+
+// Decode decodes the component into v, which is a pointer to the
+// component like in json.Unmarshal.
+func (e Entity) Decode(name string, v interface{}) error {
+	data, ok := e.Component(name)
+	if !ok {
+		return fmt.Errorf("component '%s': %w", name, kinshi.ErrNotFound)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// JSON returns the entity in the form of kinshi.ECS.MarshalEntity.
+func (e Entity) JSON() ([]byte, error) {
+	ent := entityJSON{
+		ID:         e.ID(),
+		NetID:      e.NetID(),
+		Version:    e.Version(),
+		Type:       e.Type(),
+		Components: map[string]json.RawMessage{},
+		Tags:       e.Tags(),
+	}
+
+	if u := e.UUID(); u != kinshi.UUIDNone {
+		ent.UUID = &u
+	}
+
+	for i := 0; i < vectorLen(&e.tab, entityComponents); i++ {
+		c := componentAt(&e.tab, i)
+		ent.Components[c.name()] = c.data()
+	}
+
+	return json.Marshal(ent)
+}
+
+type component struct {
+	tab flatbuffers.Table
+}
+
+func (c component) name() string {
+	if o := c.tab.Offset(componentName); o != 0 {
+		return c.tab.String(flatbuffers.UOffsetT(o) + c.tab.Pos)
+	}
+	return ""
+}
+
+func (c component) data() []byte {
+	if o := c.tab.Offset(componentData); o != 0 {
+		return c.tab.ByteVector(flatbuffers.UOffsetT(o) + c.tab.Pos)
+	}
+	return nil
+}
+
+func componentAt(tab *flatbuffers.Table, i int) component {
+	return component{tab: vectorTable(tab, entityComponents, i)}
+}
+
+// vectorLen returns the length of the vector in the slot.
+func vectorLen(tab *flatbuffers.Table, slot flatbuffers.VOffsetT) int {
+	if o := tab.Offset(slot); o != 0 {
+		return tab.VectorLen(flatbuffers.UOffsetT(o))
+	}
+	return 0
+}
+
+// vectorTable returns the i-th table of the vector in the slot.
+func vectorTable(tab *flatbuffers.Table, slot flatbuffers.VOffsetT, i int) flatbuffers.Table {
+	x := tab.Vector(flatbuffers.UOffsetT(tab.Offset(slot))) + flatbuffers.UOffsetT(i*4)
+	return flatbuffers.Table{Bytes: tab.Bytes, Pos: tab.Indirect(x)}
+}
@@ -0,0 +1,96 @@
+package flat
+
+import (
+	"bytes"
+	"errors"
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	for i := 0; i < 100; i++ {
+		id, _ := ecs.AddEntity(&testutil.Unit{Pos: testutil.Pos{X: i, Y: -i}})
+		if i%10 == 0 {
+			ew := ecs.MustGet(id)
+			assert.NoError(t, ew.Set(testutil.Health{Value: i, Max: 100}))
+			assert.NoError(t, ew.Set(testutil.Hostile{}))
+		}
+	}
+	assert.NoError(t, ecs.SetNetID(11, 7))
+	uuid, err := ecs.AssignUUID(11)
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Marshal(&buf, ecs))
+
+	snap, err := Open(buf.Bytes())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 100, snap.Len())
+
+	ent, ok := snap.Find(11)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, kinshi.EntityID(11), ent.ID())
+	assert.Equal(t, kinshi.NetID(7), ent.NetID())
+	assert.Equal(t, uuid, ent.UUID())
+	assert.Equal(t, "Unit", ent.Type())
+	assert.Equal(t, []string{"Health", "Pos"}, ent.Components())
+	assert.Equal(t, []string{"Hostile"}, ent.Tags())
+
+	data, ok := ent.Component("Pos")
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"X": 10, "Y": -10}`, string(data))
+
+	var health testutil.Health
+	assert.NoError(t, ent.Decode("Health", &health))
+	assert.Equal(t, testutil.Health{Value: 10, Max: 100}, health)
+	assert.True(t, errors.Is(ent.Decode("Velocity", &health), kinshi.ErrNotFound))
+
+	_, ok = snap.Find(101)
+	assert.False(t, ok)
+	_, ok = snap.Find(0)
+	assert.False(t, ok)
+
+	// Stream in only some entities.
+	region := testutil.NewECS(t)
+	assert.NoError(t, snap.Load(region, 11, 50))
+	assert.Equal(t, 2, region.Len())
+	assert.Equal(t, testutil.Pos{X: 10, Y: -10}, region.MustGet(11).GetEntity().(*testutil.Unit).Pos)
+	assert.True(t, region.MustGet(11).Has(testutil.Hostile{}))
+	got, _ := region.UUID(11)
+	assert.Equal(t, uuid, got)
+	assert.True(t, errors.Is(snap.Load(region, 200), kinshi.ErrNotFound))
+
+	// Load everything.
+	world := testutil.NewECS(t)
+	assert.NoError(t, Unmarshal(buf.Bytes(), world))
+	assert.Equal(t, 100, world.Len())
+
+	var want, all bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&want))
+	assert.NoError(t, world.Marshal(&all))
+	assert.JSONEq(t, want.String(), all.String())
+}
+
+func TestOpen(t *testing.T) {
+	_, err := Open([]byte("[]"))
+	assert.Equal(t, ErrInvalid, err)
+
+	_, err = Open([]byte{0xff, 0xff, 0, 0, 'K', 'N', 'S', 'H'})
+	assert.Equal(t, ErrInvalid, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Marshal(&buf, kinshi.New()))
+
+	snap, err := Open(buf.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, snap.Len())
+	_, ok := snap.Find(1)
+	assert.False(t, ok)
+}
@@ -0,0 +1,177 @@
+package flat
+
+import (
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"os"
+	"sync"
+)
+
+// File is a memory mapped snapshot. The pages are shared with other
+// processes that map the same file, so e.g. servers can share static
+// world data. The file must not be modified while it's open.
+type File struct {
+	*Snapshot
+	data []byte
+}
+
+// OpenFile memory maps the snapshot at the path. On systems without
+// mmap the file is read as a whole.
+func OpenFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Size() < 8 {
+		return nil, ErrInvalid
+	}
+
+	data, err := mmap(f, int(info.Size()))
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := Open(data)
+	if err != nil {
+		_ = munmap(data)
+		return nil, err
+	}
+
+	return &File{Snapshot: snap, data: data}, nil
+}
+
+// Close unmaps the file. The snapshot and worlds on top of it must not be
+// used afterwards, only the entities that were already returned by a
+// World stay valid.
+func (f *File) Close() error {
+	if f.data == nil {
+		return nil
+	}
+
+	err := munmap(f.data)
+	f.data = nil
+	return err
+}
+
+// World is a read only world on top of a snapshot. Entities are decoded
+// when they are accessed for the first time, so tools can inspect huge
+// worlds without loading them as a whole. The decoded entities are kept
+// in a private ECS, changes to them aren't written back to the snapshot
+// and should be avoided. A World is safe for concurrent use.
+type World struct {
+	snap *Snapshot
+	ecs  *kinshi.ECS
+	mtx  sync.Mutex
+}
+
+// World creates a world on top of the snapshot. The types are registered
+// like kinshi.ECS.Register, they need to contain the entity types and the
+// dynamic components which should be decoded.
+func (s *Snapshot) World(types ...interface{}) (*World, error) {
+	ecs := kinshi.New()
+	if err := ecs.Register(types...); err != nil {
+		return nil, err
+	}
+
+	return &World{snap: s, ecs: ecs}, nil
+}
+
+// Len returns the number of entities in the snapshot.
+func (w *World) Len() int {
+	return w.snap.Len()
+}
+
+// Materialized returns the number of entities that have been decoded.
+func (w *World) Materialized() int {
+	return w.ecs.Len()
+}
+
+// Get returns the entity with the id and decodes it if needed.
+func (w *World) Get(id kinshi.EntityID) (*kinshi.EntityWrap, error) {
+	if ew, err := w.ecs.Get(id); err == nil {
+		return ew, nil
+	}
+
+	ent, ok := w.snap.Find(id)
+	if !ok {
+		return nil, fmt.Errorf("entity %d: %w", id, kinshi.ErrNotFound)
+	}
+	return w.materialize(ent)
+}
+
+func (w *World) materialize(ent Entity) (*kinshi.EntityWrap, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if ew, err := w.ecs.Get(ent.ID()); err == nil {
+		return ew, nil
+	}
+
+	data, err := ent.JSON()
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := w.ecs.UnmarshalEntity(data)
+	if err != nil {
+		return nil, fmt.Errorf("entity %d: %w", ent.ID(), err)
+	}
+	return w.ecs.Get(id)
+}
+
+// Iterate returns all entities that contain the components, see
+// kinshi.ECS.Iterate. The components are matched by their names in the
+// snapshot, so only the matching entities are decoded. Entities that
+// can't be decoded are skipped.
+func (w *World) Iterate(types ...interface{}) kinshi.EntityIterator {
+	names := make([]string, len(types))
+	for i := range types {
+		if name, ok := types[i].(string); ok {
+			names[i] = name
+		} else {
+			names[i] = w.ecs.TypeName(types[i])
+		}
+	}
+
+	var it kinshi.EntityIterator
+	for i := 0; i < w.snap.Len(); i++ {
+		ent := w.snap.At(i)
+		if !ent.contains(names) {
+			continue
+		}
+
+		if ew, err := w.materialize(ent); err == nil {
+			it = append(it, ew)
+		}
+	}
+	return it
+}
+
+// contains checks if the entity has all named components or tags.
+func (e Entity) contains(names []string) bool {
+	for _, name := range names {
+		if _, ok := e.Component(name); ok {
+			continue
+		}
+
+		found := false
+		for _, tag := range e.Tags() {
+			if tag == name {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+	return true
+}
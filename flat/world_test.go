@@ -0,0 +1,80 @@
+package flat
+
+import (
+	"bytes"
+	"errors"
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWorld(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	for i := 0; i < 50; i++ {
+		id, _ := ecs.AddEntity(&testutil.Unit{Pos: testutil.Pos{X: i}})
+		if i%5 == 0 {
+			assert.NoError(t, ecs.MustGet(id).Set(testutil.Hostile{}))
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "kinshi")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Marshal(&buf, ecs))
+	path := filepath.Join(dir, "world.kfb")
+	assert.NoError(t, ioutil.WriteFile(path, buf.Bytes(), 0644))
+
+	f, err := OpenFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	world, err := f.World(&testutil.Unit{}, testutil.Hostile{})
+	assert.NoError(t, err)
+	assert.Equal(t, 50, world.Len())
+	assert.Equal(t, 0, world.Materialized())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ew, err := world.Get(7)
+			if assert.NoError(t, err) {
+				assert.Equal(t, 6, ew.GetEntity().(*testutil.Unit).Pos.X)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, world.Materialized())
+
+	_, err = world.Get(100)
+	assert.True(t, errors.Is(err, kinshi.ErrNotFound))
+
+	hostile := world.Iterate(testutil.Hostile{})
+	assert.Equal(t, 10, hostile.Count())
+	assert.Equal(t, 11, world.Materialized())
+	assert.Equal(t, 10, world.Iterate("Hostile", testutil.Pos{}).Count())
+	assert.Equal(t, 0, world.Iterate("Velocity").Count())
+
+	ew, _ := world.Get(7)
+	assert.NoError(t, f.Close())
+	assert.NoError(t, f.Close())
+	assert.Equal(t, 6, ew.GetEntity().(*testutil.Unit).Pos.X)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("{}"), 0644))
+	_, err = OpenFile(path)
+	assert.Equal(t, ErrInvalid, err)
+
+	_, err = OpenFile(filepath.Join(dir, "missing.kfb"))
+	assert.Error(t, err)
+}
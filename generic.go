@@ -0,0 +1,52 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// As returns the wrapped Entity as pointer to T. In contrast to
+// GetEntity the type is checked at compile time and a error is returned
+// if the Entity is of a different type. Just like GetEntity the pointer
+// points straight to the Entity, so prefer ViewAs to change it.
+//
+// For example:
+//    p, err := kinshi.As[Player](ew)
+func As[T any](ew *EntityWrap) (*T, error) {
+	ent, ok := interface{}(ew.ent).(*T)
+	if !ok {
+		return nil, ew.parent.misuse(fmt.Errorf("expected %s, entity is %s", reflect.TypeOf(ent), reflect.TypeOf(ew.ent)))
+	}
+	return ent, nil
+}
+
+// ViewAs calls fn with the wrapped Entity as pointer to T like
+// ViewSpecific, but without reflection. A error is returned if the
+// Entity is of a different type, otherwise the error of fn.
+//
+// For example:
+//    err := kinshi.ViewAs(ew, func(p *Player) error {
+//        p.Name.Value = "Bob"
+//        return nil
+//    })
+func ViewAs[T any](ew *EntityWrap, fn func(*T) error) error {
+	ent, err := As[T](ew)
+	if err != nil {
+		return err
+	}
+
+	ew.rlock()
+	defer ew.runlock()
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	ew.parent.enterView()
+	defer ew.parent.leaveView()
+
+	err = fn(ent)
+	ew.parent.touch(ew.ent)
+
+	return err
+}
@@ -0,0 +1,53 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAs(t *testing.T) {
+	ecs := New()
+	id, err := ecs.AddEntity(&Unit{Name: Name{Value: "Alice"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ew := ecs.MustGet(id)
+	unit, err := As[Unit](ew)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "Alice", unit.Name.Value)
+	}
+
+	_, err = As[DynamicUnit](ew)
+	if assert.Error(t, err) {
+		assert.Equal(t, "expected *kinshi.DynamicUnit, entity is *kinshi.Unit", err.Error())
+	}
+}
+
+func TestViewAs(t *testing.T) {
+	ecs := New()
+	id, err := ecs.AddEntity(&Unit{Name: Name{Value: "Alice"}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ew := ecs.MustGet(id)
+	version := ew.Version()
+	assert.NoError(t, ViewAs(ew, func(u *Unit) error {
+		u.Name.Value = "Bob"
+		return nil
+	}))
+	assert.Greater(t, ew.Version(), version)
+
+	assert.NoError(t, ew.View(func(n *Name) {
+		assert.Equal(t, "Bob", n.Value)
+	}))
+
+	assert.Error(t, ViewAs(ew, func(u *DynamicUnit) error {
+		t.Error("fn called with wrong type")
+		return nil
+	}))
+	assert.Equal(t, ErrNotFound, ViewAs(ew, func(u *Unit) error {
+		return ErrNotFound
+	}))
+}
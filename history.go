@@ -0,0 +1,166 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// historyRing holds the last values of a component of a entity. Ticks
+// in which the entity didn't have the component are stored as nil.
+type historyRing struct {
+	values []interface{}
+	next   int
+	count  int
+}
+
+func (r *historyRing) push(v interface{}) {
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+	if r.count < len(r.values) {
+		r.count++
+	}
+}
+
+// get returns the value that was pushed ticksAgo pushes ago.
+func (r *historyRing) get(ticksAgo int) (interface{}, bool) {
+	if ticksAgo < 0 || ticksAgo >= r.count {
+		return nil, false
+	}
+
+	v := r.values[(r.next-1-ticksAgo+len(r.values))%len(r.values)]
+	return v, v != nil
+}
+
+// histories holds the history of all tracked components.
+type histories struct {
+	mtx   sync.Mutex
+	size  int
+	types map[string]struct{}
+	rings map[EntityID]map[string]*historyRing
+}
+
+// WithHistory tracks the values of the given component types (or names) of
+// the last n ticks. If the option is used multiple times the largest n
+// is used for all components. RecordHistory needs to be called at the end of every
+// tick to record the current values, which can then be accessed with
+// EntityWrap.History. This enables lag compensation, rewind mechanics or
+// motion trails without keeping copies by hand.
+//
+// For example:
+//    ecs := kinshi.New(kinshi.WithHistory(30, Pos{}))
+//
+//    // End of tick
+//    ecs.RecordHistory()
+//
+//    // Position of 10 ticks ago
+//    old, err := ew.History(Pos{}, 10)
+//    if err == nil {
+//        trail = append(trail, old.(Pos))
+//    }
+func WithHistory(n int, types ...interface{}) Option {
+	return func(ecs *ECS) {
+		if n <= 0 {
+			return
+		}
+
+		if ecs.history == nil {
+			ecs.history = &histories{
+				types: map[string]struct{}{},
+				rings: map[EntityID]map[string]*historyRing{},
+			}
+		}
+
+		if n > ecs.history.size {
+			ecs.history.size = n
+		}
+		ecs.afterOptions(func() {
+			for _, name := range ecs.names.componentNames(types) {
+				ecs.history.types[name] = struct{}{}
+			}
+		})
+	}
+}
+
+// RecordHistory records the current values of all tracked components,
+// see WithHistory. The history of removed entities is dropped.
+func (ecs *ECS) RecordHistory() {
+	if ecs.history == nil {
+		return
+	}
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	h := ecs.history
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	rings := make(map[EntityID]map[string]*historyRing, len(h.rings))
+
+	for i := range ecs.entities {
+		ent := ecs.entities[i].Ent
+		comps := h.rings[ent.ID()]
+
+		for name := range h.types {
+			var value interface{}
+			if ptr, err := ecs.names.fetchComponent(ent, name); err == nil {
+				value = deepCopy(reflect.ValueOf(ptr).Elem()).Interface()
+			}
+
+			ring, ok := comps[name]
+			if !ok {
+				if value == nil {
+					continue
+				}
+
+				if comps == nil {
+					comps = map[string]*historyRing{}
+				}
+				ring = &historyRing{values: make([]interface{}, h.size)}
+				comps[name] = ring
+			}
+
+			ring.push(value)
+		}
+
+		if comps != nil {
+			rings[ent.ID()] = comps
+		}
+	}
+
+	h.rings = rings
+}
+
+// History returns a copy of the value the component c had ticksAgo
+// recorded ticks ago, where 0 is the last recorded tick. The component
+// needs to be tracked, see WithHistory. If the entity didn't have the
+// component at that time or the history doesn't reach back that far
+// ErrNotFound is returned.
+func (ew *EntityWrap) History(c interface{}, ticksAgo int) (interface{}, error) {
+	name := ew.parent.componentNames([]interface{}{c})[0]
+
+	h := ew.parent.history
+	if h == nil {
+		return nil, ew.parent.misuse(fmt.Errorf("component '%s' isn't tracked: %w", name, ErrNotFound))
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	if _, ok := h.types[name]; !ok {
+		return nil, ew.parent.misuse(fmt.Errorf("component '%s' isn't tracked: %w", name, ErrNotFound))
+	}
+
+	ring, ok := h.rings[ew.ent.ID()][name]
+	if !ok {
+		return nil, fmt.Errorf("history of component '%s': %w", name, ErrNotFound)
+	}
+
+	v, ok := ring.get(ticksAgo)
+	if !ok {
+		return nil, fmt.Errorf("history of component '%s' %d ticks ago: %w", name, ticksAgo, ErrNotFound)
+	}
+
+	return deepCopy(reflect.ValueOf(v)).Interface(), nil
+}
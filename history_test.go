@@ -0,0 +1,50 @@
+package kinshi
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_History(t *testing.T) {
+	ecs := New(WithHistory(3, Pos{}))
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+
+	id, _ := ecs.AddEntity(&Unit{})
+	ew := ecs.MustGet(id)
+
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, ew.Set(Pos{X: i}))
+		ecs.RecordHistory()
+	}
+
+	for ticksAgo, x := range []int{5, 4, 3} {
+		old, err := ew.History(Pos{}, ticksAgo)
+		if assert.NoError(t, err) {
+			assert.Equal(t, Pos{X: x}, old)
+		}
+	}
+
+	_, err := ew.History(Pos{}, 3)
+	assert.True(t, errors.Is(err, ErrNotFound), "history reaches further than tracked")
+
+	_, err = ew.History(Health{}, 0)
+	assert.True(t, errors.Is(err, ErrNotFound), "untracked component has history")
+
+	// History values are copies
+	old, _ := ew.History(Pos{}, 0)
+	pos := old.(Pos)
+	pos.X = 100
+	again, _ := ew.History(Pos{}, 0)
+	assert.Equal(t, Pos{X: 5}, again)
+
+	dyn, _ := ecs.AddEntity(&DynamicUnit{})
+	ecs.RecordHistory()
+	_, err = ecs.MustGet(dyn).History(Pos{}, 0)
+	assert.Error(t, err, "entity without component has history")
+
+	assert.NoError(t, ecs.RemoveByID(id))
+	ecs.RecordHistory()
+	assert.Empty(t, ecs.history.rings, "history of removed entity wasn't dropped")
+}
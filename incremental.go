@@ -0,0 +1,251 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// IncrementalSaver writes save files that only contain the entities which
+// changed since the previous save, so that large worlds can be saved
+// frequently. Changes are detected by the versions of the entities, see
+// EntityWrap.Version, entities that don't embed BaseEntity are always
+// written. The first save needs to be a full one with Save, which is
+// followed by any number of increments of SaveIncrement. LoadIncremental
+// reassembles the world.
+//
+// For example you might do a full save every few minutes and a increment
+// every few seconds:
+//    saver := kinshi.NewIncrementalSaver(ecs)
+//    err := saver.Save(base)
+//    // ...
+//    err = saver.SaveIncrement(inc1)
+//    err = saver.SaveIncrement(inc2)
+//
+//    err = other.LoadIncremental(base, []io.Reader{inc1, inc2})
+//
+// A IncrementalSaver isn't safe for concurrent use.
+type IncrementalSaver struct {
+	ecs      *ECS
+	opts     saveOptions
+	versions map[EntityID]uint64
+	base     uint32
+	sequence uint64
+	saved    bool
+}
+
+// saveIncrementPayload is the payload of a increment. Base is the
+// checksum of the full save and Sequence counts the increments since.
+type saveIncrementPayload struct {
+	Base     uint32
+	Sequence uint64
+	Entities []serializedEntity
+	Removed  []EntityID `json:",omitempty"`
+}
+
+// loadIncrementPayload is the counterpart of saveIncrementPayload that
+// keeps the entities in their JSON encoded form.
+type loadIncrementPayload struct {
+	Base     uint32
+	Sequence uint64
+	Entities []json.RawMessage
+	Removed  []EntityID
+}
+
+// NewIncrementalSaver creates a new IncrementalSaver for the ECS. The
+// options apply to all save files, see Save.
+func NewIncrementalSaver(ecs *ECS, opts ...SaveOption) *IncrementalSaver {
+	return &IncrementalSaver{
+		ecs:  ecs,
+		opts: newSaveOptions(opts),
+	}
+}
+
+// collect serializes the entities that changed since the previous save,
+// or all entities if all is set. The new versions of all entities and
+// the removed ids are returned as well.
+func (s *IncrementalSaver) collect(all bool) ([]serializedEntity, map[EntityID]uint64, []EntityID) {
+	ecs := s.ecs
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	var ses []serializedEntity
+	versions := make(map[EntityID]uint64, len(ecs.entities))
+	for i := range ecs.entities {
+		ent := ecs.entities[i].Ent
+		version := entityVersion(ent)
+		versions[ent.ID()] = version
+
+		if old, known := s.versions[ent.ID()]; !all && known && old == version {
+			if _, ok := ent.(versioned); ok {
+				continue
+			}
+		}
+
+		se := ecs.names.serializeEntity(&ecs.entities[i])
+		se.NetID = ecs.entityNetIDs[se.ID]
+		se.UUID = ecs.entityUUIDs[se.ID]
+		se.Version = version
+		ecs.addUnknown(&se)
+		ses = append(ses, se)
+	}
+
+	var removed []EntityID
+	for id := range s.versions {
+		if _, ok := versions[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i] < removed[j]
+	})
+
+	return ses, versions, removed
+}
+
+// Save writes a full save file like ECS.Save, which is the base for the
+// following increments.
+func (s *IncrementalSaver) Save(w io.Writer) error {
+	ses, versions, _ := s.collect(true)
+	if ses == nil {
+		ses = []serializedEntity{}
+	}
+
+	payload, err := json.Marshal(ses)
+	if err != nil {
+		return err
+	}
+
+	crc, err := writeSave(w, 0, payload, s.opts)
+	if err != nil {
+		return err
+	}
+
+	s.versions = versions
+	s.base = crc
+	s.sequence = 0
+	s.saved = true
+	return nil
+}
+
+// SaveIncrement writes the entities that were added or changed and the
+// ids of the entities that were removed since the previous save.
+func (s *IncrementalSaver) SaveIncrement(w io.Writer) error {
+	if !s.saved {
+		return fmt.Errorf("increment needs a full save first")
+	}
+
+	ses, versions, removed := s.collect(false)
+	if ses == nil {
+		ses = []serializedEntity{}
+	}
+
+	payload, err := json.Marshal(saveIncrementPayload{
+		Base:     s.base,
+		Sequence: s.sequence + 1,
+		Entities: ses,
+		Removed:  removed,
+	})
+	if err != nil {
+		return err
+	}
+
+	if _, err := writeSave(w, saveIncrement, payload, s.opts); err != nil {
+		return err
+	}
+
+	s.versions = versions
+	s.sequence += 1
+	return nil
+}
+
+// LoadIncremental loads a full save file of a IncrementalSaver and
+// applies the increments, which need to be in the order they were
+// written, like Load. Increments of a different full save or missing
+// increments result in a error and the ECS isn't changed.
+func (ecs *ECS) LoadIncremental(base io.Reader, increments []io.Reader, opts ...SaveOption) error {
+	o := newSaveOptions(opts)
+
+	flags, payload, crc, err := readSave(base, o)
+	if err != nil {
+		return err
+	}
+
+	if flags&saveIncrement != 0 {
+		return fmt.Errorf("base save file is a increment")
+	}
+
+	var ents []json.RawMessage
+	if err := json.Unmarshal(payload, &ents); err != nil {
+		return corrupted(err)
+	}
+
+	byID := make(map[EntityID]json.RawMessage, len(ents))
+	add := func(ents []json.RawMessage) error {
+		for i := range ents {
+			var se struct{ ID EntityID }
+			if err := json.Unmarshal(ents[i], &se); err != nil {
+				return corrupted(err)
+			}
+			byID[se.ID] = ents[i]
+		}
+		return nil
+	}
+
+	if err := add(ents); err != nil {
+		return err
+	}
+
+	for i := range increments {
+		flags, payload, _, err := readSave(increments[i], o)
+		if err != nil {
+			return fmt.Errorf("increment %d: %w", i+1, err)
+		}
+
+		if flags&saveIncrement == 0 {
+			return fmt.Errorf("increment %d is a full save file", i+1)
+		}
+
+		var inc loadIncrementPayload
+		if err := json.Unmarshal(payload, &inc); err != nil {
+			return fmt.Errorf("increment %d: %w", i+1, corrupted(err))
+		}
+
+		if inc.Base != crc {
+			return fmt.Errorf("increment %d belongs to a different save", i+1)
+		}
+
+		if inc.Sequence != uint64(i+1) {
+			return fmt.Errorf("increment %d has sequence number %d", i+1, inc.Sequence)
+		}
+
+		for _, id := range inc.Removed {
+			delete(byID, id)
+		}
+
+		if err := add(inc.Entities); err != nil {
+			return fmt.Errorf("increment %d: %w", i+1, err)
+		}
+	}
+
+	ids := make([]EntityID, 0, len(byID))
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	ents = ents[:0]
+	for _, id := range ids {
+		ents = append(ents, byID[id])
+	}
+
+	data, err := json.Marshal(ents)
+	if err != nil {
+		return err
+	}
+	return corrupted(ecs.Unmarshal(bytes.NewReader(data)))
+}
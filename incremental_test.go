@@ -0,0 +1,100 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestIncrementalSaver(t *testing.T) {
+	ecs := New()
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{Health: Health{Value: i}})
+	}
+
+	key := bytes.Repeat([]byte{1}, 16)
+	saver := NewIncrementalSaver(ecs, WithEncryption(key))
+	assert.Error(t, saver.SaveIncrement(&bytes.Buffer{}))
+
+	var base bytes.Buffer
+	assert.NoError(t, saver.Save(&base))
+
+	assert.NoError(t, ecs.MustGet(3).View(func(h *Health) { h.Value = 30 }))
+	assert.NoError(t, ecs.RemoveByID(5))
+
+	var inc1 bytes.Buffer
+	assert.NoError(t, saver.SaveIncrement(&inc1))
+
+	_, payload, _, err := readSave(bytes.NewReader(inc1.Bytes()), newSaveOptions([]SaveOption{WithEncryption(key)}))
+	assert.NoError(t, err)
+	var inc loadIncrementPayload
+	assert.NoError(t, json.Unmarshal(payload, &inc))
+	assert.Len(t, inc.Entities, 1)
+	assert.Equal(t, []EntityID{5}, inc.Removed)
+	assert.Equal(t, uint64(1), inc.Sequence)
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "new"}})
+	assert.NoError(t, ecs.MustGet(3).View(func(h *Health) { h.Value = 31 }))
+
+	var inc2, inc3 bytes.Buffer
+	assert.NoError(t, saver.SaveIncrement(&inc2))
+	assert.NoError(t, saver.SaveIncrement(&inc3))
+
+	load := func(base *bytes.Buffer, incs ...*bytes.Buffer) (*ECS, error) {
+		readers := make([]io.Reader, len(incs))
+		for i := range incs {
+			readers[i] = bytes.NewReader(incs[i].Bytes())
+		}
+
+		other := New()
+		_ = other.RegisterEntity(&Unit{})
+		_, _ = other.AddEntity(&Unit{})
+		return other, other.LoadIncremental(bytes.NewReader(base.Bytes()), readers, WithEncryption(key))
+	}
+
+	other, err := load(&base, &inc1, &inc2, &inc3)
+	if assert.NoError(t, err) {
+		var want, got bytes.Buffer
+		assert.NoError(t, ecs.Marshal(&want))
+		assert.NoError(t, other.Marshal(&got))
+		assert.JSONEq(t, want.String(), got.String())
+		assert.Equal(t, "new", other.MustGet(id).GetEntity().(*Unit).Name.Value)
+	}
+
+	other, err = load(&base, &inc1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 9, other.Len())
+		assert.Equal(t, 30, other.MustGet(3).GetEntity().(*Unit).Health.Value)
+	}
+
+	other, err = load(&base, &inc2)
+	assert.Error(t, err)
+	assert.Equal(t, 1, other.Len())
+
+	_, err = load(&inc1)
+	assert.Error(t, err)
+
+	_, err = load(&base, &base)
+	assert.Error(t, err)
+
+	// Increments of a new full save don't fit the old one.
+	var base2, inc4 bytes.Buffer
+	assert.NoError(t, saver.Save(&base2))
+	assert.NoError(t, saver.SaveIncrement(&inc4))
+	_, err = load(&base, &inc4)
+	assert.Error(t, err)
+
+	_, err = load(&base2, &inc4)
+	assert.NoError(t, err)
+
+	corrupt := bytes.NewBuffer(append([]byte{}, inc1.Bytes()[:inc1.Len()-1]...))
+	_, err = load(&base, corrupt)
+	assert.True(t, errors.Is(err, ErrCorrupt))
+
+	assert.Error(t, other.Load(bytes.NewReader(inc1.Bytes()), WithEncryption(key)))
+	assert.NoError(t, other.Load(bytes.NewReader(base.Bytes()), WithEncryption(key)))
+	assert.Equal(t, 10, other.Len())
+}
@@ -0,0 +1,338 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// indexKey identifies a field index.
+type indexKey struct {
+	comp, field string
+}
+
+// fieldIndex maps the values of a component field to the
+// entities that hold them.
+type fieldIndex struct {
+	mtx      sync.Mutex
+	key      indexKey
+	t        reflect.Type
+	names    *typeNames
+	unique   bool
+	values   map[interface{}]map[EntityID]struct{}
+	entities map[EntityID]interface{}
+	changes  changeTracker
+}
+
+func newFieldIndex(key indexKey, t reflect.Type, names *typeNames) *fieldIndex {
+	return &fieldIndex{
+		key:      key,
+		t:        t,
+		names:    names,
+		values:   map[interface{}]map[EntityID]struct{}{},
+		entities: map[EntityID]interface{}{},
+	}
+}
+
+// fieldValue returns the value of the indexed field of the entity.
+func (fi *fieldIndex) fieldValue(ent Entity) (interface{}, bool) {
+	ptr, err := fi.names.fetchComponent(ent, fi.key.comp)
+	if err != nil {
+		return nil, false
+	}
+
+	val := reflect.ValueOf(ptr).Elem()
+	if val.Kind() != reflect.Struct {
+		return nil, false
+	}
+
+	f := val.FieldByName(fi.key.field)
+	if !f.IsValid() || f.Type() != fi.t {
+		return nil, false
+	}
+
+	// Interfaces can hold values that can't be used as map key.
+	v := f.Interface()
+	if v != nil && !reflect.TypeOf(v).Comparable() {
+		return nil, false
+	}
+
+	return v, true
+}
+
+func (fi *fieldIndex) update(id EntityID, ent Entity) bool {
+	v, ok := fi.fieldValue(ent)
+	if !ok {
+		return false
+	}
+
+	if old, ok := fi.entities[id]; ok {
+		if old == v {
+			return true
+		}
+		fi.remove(id)
+	}
+
+	ids, ok := fi.values[v]
+	if !ok {
+		ids = map[EntityID]struct{}{}
+		fi.values[v] = ids
+	}
+	ids[id] = struct{}{}
+	fi.entities[id] = v
+
+	return true
+}
+
+func (fi *fieldIndex) remove(id EntityID) {
+	v, ok := fi.entities[id]
+	if !ok {
+		return
+	}
+
+	delete(fi.values[v], id)
+	if len(fi.values[v]) == 0 {
+		delete(fi.values, v)
+	}
+	delete(fi.entities, id)
+}
+
+// IndexOption configures a field index, see ECS.Index.
+type IndexOption func(fi *fieldIndex)
+
+// Unique rejects adding a entity whose indexed value is already used by
+// another entity with a error wrapping ErrAlreadyExists. This covers
+// AddEntity, command buffers, transactions and merges. Duplicates that
+// are created by writing to the components of present entities or by
+// Unmarshal can't be rejected and are returned together by GetByIndex.
+func Unique() IndexOption {
+	return func(fi *fieldIndex) {
+		fi.unique = true
+	}
+}
+
+// Index creates a index over the given field of the component c, so that
+// GetByIndex can look up entities by the value of the field without
+// scanning all entities. The field needs to be of a comparable type.
+// Creating a index that already exists only applies the options.
+//
+// The index is maintained automatically. Like the spatial index it's
+// updated on the next lookup after entities have been added, removed
+// or written to, see WithSpatialIndex.
+//
+// For example:
+//    ecs.Index(Name{}, "Value", kinshi.Unique())
+//    for _, ew := range ecs.GetByIndex(Name{}, "Value", "Gandalf") {
+//        // Work with the EntityWrap
+//    }
+func (ecs *ECS) Index(c interface{}, field string, opts ...IndexOption) error {
+	t := reflect.TypeOf(c)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return ecs.misuse(fmt.Errorf("index on '%v': component isn't a struct", reflect.TypeOf(c)))
+	}
+
+	f, ok := t.FieldByName(field)
+	if !ok || f.PkgPath != "" {
+		return ecs.misuse(fmt.Errorf("index on '%s.%s': %w", ecs.names.typeName(t), field, ErrNotFound))
+	}
+
+	if !f.Type.Comparable() {
+		return ecs.misuse(fmt.Errorf("index on '%s.%s': field type '%s' isn't comparable", ecs.names.typeName(t), field, f.Type))
+	}
+
+	key := indexKey{comp: ecs.names.typeName(t), field: field}
+
+	ecs.indexMtx.Lock()
+	defer ecs.indexMtx.Unlock()
+
+	fi, ok := ecs.indexes[key]
+	if !ok {
+		if ecs.indexes == nil {
+			ecs.indexes = map[indexKey]*fieldIndex{}
+		}
+
+		fi = newFieldIndex(key, f.Type, ecs.names)
+		ecs.indexes[key] = fi
+		ecs.track(&fi.changes)
+	}
+
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	for i := range opts {
+		opts[i](fi)
+	}
+
+	return nil
+}
+
+// checkUnique checks that the indexed values of the entity aren't used
+// by other entities yet. The caller needs to hold the write lock.
+func (ecs *ECS) checkUnique(ent Entity) error {
+	ecs.indexMtx.Lock()
+	defer ecs.indexMtx.Unlock()
+
+	for _, fi := range ecs.indexes {
+		if err := fi.checkUnique(ecs, ent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fi *fieldIndex) checkUnique(ecs *ECS, ent Entity) error {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	if !fi.unique {
+		return nil
+	}
+
+	v, ok := fi.fieldValue(ent)
+	if !ok {
+		return nil
+	}
+
+	fi.changes.sync(ecs, fi.update, fi.remove)
+
+	for id := range fi.values[v] {
+		if id != ent.ID() {
+			return fmt.Errorf("%s.%s '%v' of entity %d is used by entity %d: %w", fi.key.comp, fi.key.field, v, ent.ID(), id, ErrAlreadyExists)
+		}
+	}
+
+	return nil
+}
+
+// checkUniqueAll is like checkUnique for entities that are added at
+// once, so their indexed values also need to differ from each other. The
+// entities must not be part of the ECS yet. The caller needs to hold the
+// write lock.
+func (ecs *ECS) checkUniqueAll(ents []Entity) error {
+	ecs.indexMtx.Lock()
+	defer ecs.indexMtx.Unlock()
+
+	for _, fi := range ecs.indexes {
+		if err := fi.checkUniqueAll(ecs, ents); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fi *fieldIndex) checkUniqueAll(ecs *ECS, ents []Entity) error {
+	fi.mtx.Lock()
+	defer fi.mtx.Unlock()
+
+	if !fi.unique {
+		return nil
+	}
+
+	fi.changes.sync(ecs, fi.update, fi.remove)
+
+	seen := make(map[interface{}]EntityID, len(ents))
+	for _, ent := range ents {
+		v, ok := fi.fieldValue(ent)
+		if !ok {
+			continue
+		}
+
+		for id := range fi.values[v] {
+			return fmt.Errorf("%s.%s '%v' of entity %d is used by entity %d: %w", fi.key.comp, fi.key.field, v, ent.ID(), id, ErrAlreadyExists)
+		}
+
+		if id, ok := seen[v]; ok {
+			return fmt.Errorf("%s.%s '%v' of entity %d is used by entity %d: %w", fi.key.comp, fi.key.field, v, ent.ID(), id, ErrAlreadyExists)
+		}
+		seen[v] = ent.ID()
+	}
+
+	return nil
+}
+
+// invalidateIndexes forces a full sync of all trackers, which includes
+// the field indexes, the spatial index and the replicators. It's needed
+// if the storage is reset. The caller needs to hold the write lock.
+func (ecs *ECS) invalidateIndexes() {
+	trackers, _ := ecs.trackers.Load().([]*changeTracker)
+	for i := range trackers {
+		trackers[i].reset()
+	}
+}
+
+func (ecs *ECS) fieldIndex(c interface{}, field string) (*fieldIndex, bool) {
+	ecs.indexMtx.Lock()
+	defer ecs.indexMtx.Unlock()
+
+	fi, ok := ecs.indexes[indexKey{comp: ecs.componentNames([]interface{}{c})[0], field: field}]
+	return fi, ok
+}
+
+// indexValue converts the value to the type t of a indexed field. Only
+// conversions between values of the same kind are allowed, so that e.g.
+// a int isn't converted into a string.
+func indexValue(t reflect.Type, value interface{}) (interface{}, bool) {
+	val := reflect.ValueOf(value)
+	switch {
+	case !val.IsValid():
+		return nil, t.Kind() == reflect.Interface
+	case val.Type() == t:
+		return value, true
+	case t.Kind() == reflect.Interface:
+		return value, val.Type().Implements(t) && val.Type().Comparable()
+	case val.Kind() == t.Kind() && val.Type().ConvertibleTo(t):
+		return val.Convert(t).Interface(), true
+	}
+	return nil, false
+}
+
+// GetByIndex returns all entities whose field of the component c has the
+// given value, ordered by id. The field needs to be indexed with Index.
+// The value is converted to the type of the field if possible, so
+// untyped constants can be used for fields of defined types.
+func (ecs *ECS) GetByIndex(c interface{}, field string, value interface{}) EntityIterator {
+	ecs.countIterate()
+
+	fi, ok := ecs.fieldIndex(c, field)
+	if !ok {
+		_ = ecs.misuse(fmt.Errorf("field '%s.%s' isn't indexed: %w", TypeName(c), field, ErrNotFound))
+		return nil
+	}
+
+	value, ok = indexValue(fi.t, value)
+	if !ok {
+		return nil
+	}
+
+	rv := ecs.reads()
+
+	ecs.rlock()
+	fi.mtx.Lock()
+	fi.changes.sync(ecs, fi.update, fi.remove)
+	ids := make([]EntityID, 0, len(fi.values[value]))
+	for id := range fi.values[value] {
+		ids = append(ids, id)
+	}
+	fi.mtx.Unlock()
+	ecs.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	var foundEnts []*EntityWrap
+	for i := range ids {
+		if idx, ok := rv.find(ids[i]); ok {
+			foundEnts = append(foundEnts, ecs.wrap(rv.entries[idx].Ent))
+		}
+	}
+
+	return foundEnts
+}
@@ -0,0 +1,154 @@
+package kinshi
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type Title string
+
+type Rank struct {
+	Title Title
+	Level int
+	Tags  []string
+}
+
+type Noble struct {
+	BaseEntity
+	Name
+	Rank
+}
+
+func TestECS_Index(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Noble{}))
+	assert.NoError(t, ecs.Index(Name{}, "Value"))
+	assert.NoError(t, ecs.Index(Name{}, "Value"), "second index failed")
+	assert.NoError(t, ecs.Index(Rank{}, "Title"))
+	assert.Error(t, ecs.Index(Rank{}, "Tags"), "index on slice field")
+	assert.Error(t, ecs.Index(Rank{}, "Missing"), "index on missing field")
+
+	gandalf, _ := ecs.AddEntity(&Noble{Name: Name{Value: "Gandalf"}, Rank: Rank{Title: "wizard"}})
+	_, _ = ecs.AddEntity(&Noble{Name: Name{Value: "Aragorn"}, Rank: Rank{Title: "king"}})
+	saruman, _ := ecs.AddEntity(&Noble{Name: Name{Value: "Saruman"}, Rank: Rank{Title: "wizard"}})
+
+	assert.Equal(t, []EntityID{gandalf}, iteratorIDs(ecs.GetByIndex(Name{}, "Value", "Gandalf")))
+	assert.Equal(t, []EntityID{gandalf, saruman}, iteratorIDs(ecs.GetByIndex(Rank{}, "Title", "wizard")))
+	assert.Equal(t, []EntityID{gandalf, saruman}, iteratorIDs(ecs.GetByIndex(Rank{}, "Title", Title("wizard"))))
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", 10))
+
+	// Changes are picked up on the next lookup.
+	assert.NoError(t, ecs.MustGet(gandalf).View(func(n *Name) {
+		n.Value = "Gandalf the White"
+	}))
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", "Gandalf"))
+	assert.Equal(t, []EntityID{gandalf}, iteratorIDs(ecs.GetByIndex(Name{}, "Value", "Gandalf the White")))
+
+	assert.NoError(t, ecs.RemoveByID(saruman))
+	assert.Equal(t, []EntityID{gandalf}, iteratorIDs(ecs.GetByIndex(Rank{}, "Title", "wizard")))
+
+	cloned := ecs.Clone()
+	assert.Equal(t, []EntityID{gandalf}, iteratorIDs(cloned.GetByIndex(Rank{}, "Title", "wizard")), "index wasn't cloned")
+
+	assert.Empty(t, ecs.GetByIndex(Rank{}, "Level", 1), "lookup on field without index")
+}
+
+func TestECS_IndexReusedID(t *testing.T) {
+	ecs := New(WithSpatialIndex(Pos{}, NewHashGrid(8)))
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.Index(Name{}, "Value"))
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "old"}})
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.GetByIndex(Name{}, "Value", "old")))
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.IterateNear(0, 0, 1)))
+
+	// The new entity has the same id and version as the old one.
+	ecs.Clear(true)
+	reused, _ := ecs.AddEntity(&Unit{Name: Name{Value: "new"}, Pos: Pos{X: 50, Y: 50}})
+	assert.Equal(t, id, reused)
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", "old"))
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.GetByIndex(Name{}, "Value", "new")))
+	assert.Empty(t, ecs.IterateNear(0, 0, 1))
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.IterateNear(50, 50, 1)))
+
+	assert.NoError(t, ecs.Unmarshal(strings.NewReader(fmt.Sprintf(`[{"ID": %d, "Type": "Unit", "Components": {"Name": {"Value": "loaded"}}}]`, id))))
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", "new"))
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.GetByIndex(Name{}, "Value", "loaded")))
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.IterateNear(0, 0, 1)))
+
+	// Removing and adding between two lookups.
+	assert.NoError(t, ecs.RemoveByID(id))
+	other := &Unit{Name: Name{Value: "other"}}
+	other.SetID(id)
+	_, err := ecs.AddEntity(other)
+	assert.NoError(t, err)
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", "loaded"))
+	assert.Equal(t, []EntityID{id}, iteratorIDs(ecs.GetByIndex(Name{}, "Value", "other")))
+}
+
+type Tile struct {
+	BaseEntity
+	Pos
+}
+
+func TestECS_IndexUnique(t *testing.T) {
+	ecs := New(WithStrict())
+	assert.NoError(t, ecs.RegisterEntity(&Tile{}))
+	assert.NoError(t, ecs.RegisterEntity(&Noble{}))
+	assert.NoError(t, ecs.Index(Pos{}, "X", Unique()))
+	assert.NoError(t, ecs.Index(Name{}, "Value", Unique()))
+
+	for x := 0; x < 100; x++ {
+		_, err := ecs.AddEntity(&Tile{Pos: Pos{X: x}})
+		assert.NoError(t, err)
+	}
+
+	_, err := ecs.AddEntity(&Tile{Pos: Pos{X: 5}})
+	assert.True(t, errors.Is(err, ErrAlreadyExists), "duplicate wasn't rejected")
+	assert.Contains(t, err.Error(), "Pos.X '5'")
+
+	// Values of removed entities can be used again.
+	assert.NoError(t, ecs.RemoveByID(ecs.GetByIndex(Pos{}, "X", 5)[0].GetEntity().ID()))
+	_, err = ecs.AddEntity(&Tile{Pos: Pos{X: 5}})
+	assert.NoError(t, err)
+
+	// Changed values are checked against as well.
+	_ = ecs.GetByIndex(Pos{}, "X", 10)[0].View(func(p *Pos) {
+		p.X = 1000
+	})
+	_, err = ecs.AddEntity(&Tile{Pos: Pos{X: 10}})
+	assert.NoError(t, err)
+	_, err = ecs.AddEntity(&Tile{Pos: Pos{X: 1000}})
+	assert.True(t, errors.Is(err, ErrAlreadyExists), "changed value wasn't checked")
+
+	// So are values that are written by command buffers and transactions.
+	cb := ecs.NewCommandBuffer()
+	cb.SetComponent(ecs.GetByIndex(Pos{}, "X", 20)[0].GetEntity().ID(), Pos{X: 2000})
+	assert.NoError(t, cb.Flush())
+	_, err = ecs.AddEntity(&Tile{Pos: Pos{X: 2000}})
+	assert.True(t, errors.Is(err, ErrAlreadyExists), "buffered value wasn't checked")
+
+	assert.NoError(t, ecs.Transaction(func(tx *Tx) error {
+		tx.SetComponent(ecs.GetByIndex(Pos{}, "X", 30)[0].GetEntity().ID(), Pos{X: 3000})
+		return nil
+	}))
+	_, err = ecs.AddEntity(&Tile{Pos: Pos{X: 3000}})
+	assert.True(t, errors.Is(err, ErrAlreadyExists), "transacted value wasn't checked")
+
+	cb = ecs.NewCommandBuffer()
+	_, _ = cb.AddEntity(&Noble{Name: Name{Value: "Gandalf"}})
+	_, _ = cb.AddEntity(&Noble{Name: Name{Value: "Gandalf"}})
+	assert.True(t, errors.Is(cb.Flush(), ErrAlreadyExists), "duplicate in command buffer wasn't rejected")
+	assert.Len(t, ecs.GetByIndex(Name{}, "Value", "Gandalf"), 1)
+
+	err = ecs.Transaction(func(tx *Tx) error {
+		_, _ = tx.AddEntity(&Noble{Name: Name{Value: "Frodo"}})
+		_, _ = tx.AddEntity(&Noble{Name: Name{Value: "Gandalf"}})
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrAlreadyExists))
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", "Frodo"), "transaction wasn't rolled back")
+}
@@ -0,0 +1,324 @@
+// Package inspect provides a http.Handler that serves a browsable
+// view of all entities of a running kinshi.ECS and their live
+// component values. Changes can also be streamed as JSON over a
+// websocket. It is meant for debugging and shouldn't be exposed
+// publicly.
+package inspect
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Component is a single component of a inspected entity.
+type Component struct {
+	Name  string
+	Value interface{}
+}
+
+// Entity is a inspected entity with all its static
+// and dynamic components.
+type Entity struct {
+	ID         kinshi.EntityID
+	Type       string
+	Components []Component
+}
+
+// Names returns the names of all components of the entity.
+func (e Entity) Names() []string {
+	names := make([]string, len(e.Components))
+	for i := range e.Components {
+		names[i] = e.Components[i].Name
+	}
+	return names
+}
+
+// Has checks if the entity contains a component with the given name.
+func (e Entity) Has(name string) bool {
+	for i := range e.Components {
+		if e.Components[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter selects which entities are listed.
+type Filter struct {
+	// Type of the entities. An empty string matches all types.
+	Type string
+	// Components the entities need to contain. Tags are
+	// simply components without fields.
+	Components []string
+	// Limit is the maximum number of returned entities.
+	Limit int
+}
+
+// Handler serves the inspector.
+type Handler struct {
+	// StreamInterval is the interval in which the world is checked
+	// for changes by the websocket stream at /stream.
+	StreamInterval time.Duration
+
+	ecs *kinshi.ECS
+	mux *http.ServeMux
+}
+
+// New creates a new inspector for the given ECS. The handler can
+// be mounted under a prefix with http.StripPrefix.
+func New(ecs *kinshi.ECS) *Handler {
+	h := &Handler{
+		StreamInterval: 250 * time.Millisecond,
+		ecs:            ecs,
+		mux:            http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/", h.handleIndex)
+	h.mux.HandleFunc("/entity", h.handleEntity)
+	h.mux.HandleFunc("/api/entities", h.handleAPIEntities)
+	h.mux.HandleFunc("/api/entity", h.handleAPIEntity)
+	h.mux.HandleFunc("/stream", h.handleStream)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// Collect creates a inspected copy of the wrapped entity. The components
+// are enumerated and copied by the ECS while it's read locked, so the
+// entity isn't changed and its version stays the same.
+func Collect(ew *kinshi.EntityWrap) (Entity, error) {
+	ent := ew.GetEntity()
+	res := Entity{
+		ID:   ent.ID(),
+		Type: ew.TypeName(ent),
+	}
+
+	names := ew.Components()
+	values := ew.ComponentValues()
+	if len(names) != len(values) {
+		return Entity{}, fmt.Errorf("entity %d changed while it was collected", res.ID)
+	}
+
+	for i := range names {
+		comp, err := json.Marshal(values[i])
+		if err != nil {
+			return Entity{}, err
+		}
+		res.Components = append(res.Components, Component{Name: names[i], Value: json.RawMessage(comp)})
+	}
+
+	sort.Slice(res.Components, func(i, j int) bool {
+		return res.Components[i].Name < res.Components[j].Name
+	})
+
+	return res, nil
+}
+
+// Entities returns all entities that match the filter ordered by id.
+func (h *Handler) Entities(filter Filter) ([]Entity, error) {
+	var res []Entity
+
+	for _, ew := range h.ecs.Iterate() {
+		ent, err := Collect(ew)
+		if err != nil {
+			return nil, err
+		}
+
+		if !matches(ent, filter) {
+			continue
+		}
+
+		res = append(res, ent)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
+	})
+
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+
+	return res, nil
+}
+
+func matches(ent Entity, filter Filter) bool {
+	if filter.Type != "" && ent.Type != filter.Type {
+		return false
+	}
+
+	for i := range filter.Components {
+		if !ent.Has(filter.Components[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseFilter(r *http.Request) Filter {
+	filter := Filter{
+		Type:  strings.TrimSpace(r.URL.Query().Get("type")),
+		Limit: 500,
+	}
+
+	for _, c := range strings.Split(r.URL.Query().Get("components"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			filter.Components = append(filter.Components, c)
+		}
+	}
+
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil {
+		filter.Limit = limit
+	}
+
+	return filter
+}
+
+func (h *Handler) fetchEntity(r *http.Request) (Entity, int, error) {
+	id, err := strconv.ParseUint(r.URL.Query().Get("id"), 10, 64)
+	if err != nil {
+		return Entity{}, http.StatusBadRequest, err
+	}
+
+	ew, err := h.ecs.Get(kinshi.EntityID(id))
+	if err != nil {
+		return Entity{}, http.StatusNotFound, err
+	}
+
+	ent, err := Collect(ew)
+	if err != nil {
+		return Entity{}, http.StatusInternalServerError, err
+	}
+
+	return ent, http.StatusOK, nil
+}
+
+func (h *Handler) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	filter := parseFilter(r)
+	ents, err := h.Entities(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = indexTemplate.Execute(w, struct {
+		Filter     Filter
+		Components string
+		Entities   []Entity
+		Total      int
+	}{
+		Filter:     filter,
+		Components: strings.Join(filter.Components, ","),
+		Entities:   ents,
+		Total:      h.ecs.Iterate().Count(),
+	})
+}
+
+func (h *Handler) handleEntity(w http.ResponseWriter, r *http.Request) {
+	ent, status, err := h.fetchEntity(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_ = entityTemplate.Execute(w, ent)
+}
+
+func (h *Handler) handleAPIEntities(w http.ResponseWriter, r *http.Request) {
+	ents, err := h.Entities(parseFilter(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, ents)
+}
+
+func (h *Handler) handleAPIEntity(w http.ResponseWriter, r *http.Request) {
+	ent, status, err := h.fetchEntity(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	writeJSON(w, ent)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	_ = enc.Encode(v)
+}
+
+func prettyJSON(v interface{}) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
+
+var funcs = template.FuncMap{
+	"json": prettyJSON,
+	"join": strings.Join,
+}
+
+const style = `<style>
+body { font-family: monospace; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; vertical-align: top; }
+pre { margin: 0; }
+</style>`
+
+var indexTemplate = template.Must(template.New("index").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><title>kinshi inspector</title>` + style + `</head>
+<body>
+<h1>kinshi inspector</h1>
+<form method="get">
+	Type <input name="type" value="{{.Filter.Type}}">
+	Components <input name="components" value="{{.Components}}" placeholder="Pos,Velocity">
+	Limit <input name="limit" value="{{.Filter.Limit}}" size="5">
+	<button type="submit">Filter</button>
+</form>
+<p>Showing {{len .Entities}} of {{.Total}} entities</p>
+<table>
+<tr><th>ID</th><th>Type</th><th>Components</th></tr>
+{{range .Entities}}<tr><td><a href="entity?id={{.ID}}">{{.ID}}</a></td><td>{{.Type}}</td><td>{{join .Names ", "}}</td></tr>
+{{end}}</table>
+</body>
+</html>`))
+
+var entityTemplate = template.Must(template.New("entity").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<head><title>kinshi inspector - {{.ID}}</title>` + style + `</head>
+<body>
+<p><a href="./">back</a></p>
+<h1>{{.Type}} {{.ID}}</h1>
+<table>
+<tr><th>Component</th><th>Value</th></tr>
+{{range .Components}}<tr><td>{{.Name}}</td><td><pre>{{json .Value}}</pre></td></tr>
+{{end}}</table>
+</body>
+</html>`))
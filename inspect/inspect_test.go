@@ -0,0 +1,104 @@
+package inspect
+
+import (
+	"encoding/json"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Hostile struct{}
+
+type Unit struct {
+	kinshi.BaseDynamicEntity
+	Pos
+}
+
+func TestHandler(t *testing.T) {
+	ecs := kinshi.New()
+
+	for i := 0; i < 10; i++ {
+		u := &Unit{Pos: Pos{X: i}}
+		if i%2 == 0 {
+			_ = u.SetComponent(&Hostile{})
+		}
+		_, _ = ecs.AddEntity(u)
+	}
+
+	srv := httptest.NewServer(New(ecs))
+	defer srv.Close()
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(srv.URL + path)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, body := get("/api/entities?components=Hostile")
+	assert.Equal(t, http.StatusOK, status)
+
+	var ents []Entity
+	assert.NoError(t, json.Unmarshal([]byte(body), &ents))
+	assert.Len(t, ents, 5, "component filter didn't apply")
+
+	status, body = get("/api/entity?id=2")
+	assert.Equal(t, http.StatusOK, status)
+
+	var ent Entity
+	assert.NoError(t, json.Unmarshal([]byte(body), &ent))
+	assert.Equal(t, "Unit", ent.Type)
+	assert.Equal(t, []string{"Pos"}, ent.Names())
+
+	status, body = get("/?type=Unit")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, "Showing 10 of 10 entities")
+
+	status, _ = get("/entity?id=100")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+type Energy int
+
+type cooldown struct {
+	Left int
+}
+
+type Caster struct {
+	kinshi.BaseEntity
+	Pos
+	cooldown
+	Energy  Energy `kinshi:"component"`
+	Timeout time.Duration
+	Target  kinshi.EntityID
+}
+
+func TestCollect(t *testing.T) {
+	ecs := kinshi.New()
+	ecs.RegisterEntity(&Caster{})
+
+	id, _ := ecs.AddEntity(&Caster{Pos: Pos{X: 1}, Energy: 5, Timeout: time.Second, Target: 1})
+	ew := ecs.MustGet(id)
+	version := ew.Version()
+
+	ent, err := Collect(ew)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{"Energy", "Pos"}, ent.Names())
+	assert.JSONEq(t, "5", string(ent.Components[0].Value.(json.RawMessage)))
+	assert.Equal(t, version, ew.Version(), "collect changed the entity")
+}
@@ -0,0 +1,89 @@
+package inspect
+
+import (
+	"encoding/json"
+	"github.com/BigJk/kinshi"
+	"net/http"
+	"time"
+)
+
+// EventType is the kind of change a Event describes.
+type EventType string
+
+const (
+	EventAdd    = EventType("add")
+	EventRemove = EventType("remove")
+	EventChange = EventType("change")
+)
+
+// Event describes a single change of a entity. Add events contain
+// all components, change events only the changed ones.
+type Event struct {
+	Type              EventType
+	ID                kinshi.EntityID
+	EntityType        string                     `json:",omitempty"`
+	Components        map[string]json.RawMessage `json:",omitempty"`
+	RemovedComponents []string                   `json:",omitempty"`
+}
+
+// Events converts a replication payload into a list of events.
+func Events(payload kinshi.ReplicationPayload) []Event {
+	var events []Event
+
+	for _, re := range payload.Destroyed {
+		events = append(events, Event{Type: EventRemove, ID: re.ID})
+	}
+
+	for _, re := range payload.Created {
+		events = append(events, Event{Type: EventAdd, ID: re.ID, EntityType: re.Type, Components: re.Components})
+	}
+
+	for _, re := range payload.Updated {
+		events = append(events, Event{Type: EventChange, ID: re.ID, EntityType: re.Type, Components: re.Components, RemovedComponents: re.RemovedComponents})
+	}
+
+	return events
+}
+
+// handleStream upgrades to a websocket and sends the changes of the
+// world as JSON encoded Events. The first messages mirror the complete
+// world as add events, afterwards the world is checked for changes every
+// StreamInterval.
+func (h *Handler) handleStream(w http.ResponseWriter, r *http.Request) {
+	ws, err := upgrade(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer ws.Close()
+
+	rep := kinshi.NewReplicator(h.ecs)
+	defer rep.Close()
+
+	ticker := time.NewTicker(h.StreamInterval)
+	defer ticker.Stop()
+
+	for {
+		payload, err := rep.Tick()
+		if err != nil {
+			return
+		}
+
+		for _, e := range Events(payload) {
+			data, err := json.Marshal(e)
+			if err != nil {
+				return
+			}
+
+			if err := ws.WriteText(data); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ws.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package inspect
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFrame(t *testing.T, r *bufio.Reader) (byte, []byte) {
+	var head [2]byte
+	_, err := io.ReadFull(r, head[:])
+	if !assert.NoError(t, err) {
+		return 0, nil
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, _ = io.ReadFull(r, ext[:])
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, _ = io.ReadFull(r, ext[:])
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	payload := make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	assert.NoError(t, err)
+
+	return head[0] & 0x0F, payload
+}
+
+func TestHandler_Stream(t *testing.T) {
+	ecs := kinshi.New()
+	id, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1}})
+
+	h := New(ecs)
+	h.StreamInterval = 10 * time.Millisecond
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/stream")
+	if assert.NoError(t, err) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "plain request was accepted")
+		_ = resp.Body.Close()
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	_, _ = conn.Write([]byte("GET /stream HTTP/1.1\r\n" +
+		"Host: localhost\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n" +
+		"Sec-WebSocket-Version: 13\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	hs, err := http.ReadResponse(reader, nil)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusSwitchingProtocols, hs.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", hs.Header.Get("Sec-WebSocket-Accept"))
+
+	var e Event
+	op, payload := readFrame(t, reader)
+	assert.Equal(t, byte(opText), op)
+	assert.NoError(t, json.Unmarshal(payload, &e))
+	assert.Equal(t, EventAdd, e.Type)
+	assert.Equal(t, id, e.ID)
+
+	// Mutations need to be applied under the write lock to not
+	// race with the stream reading the world.
+	cb := ecs.NewCommandBuffer()
+	cb.SetComponent(id, Pos{X: 2})
+	assert.NoError(t, cb.Flush())
+
+	_, payload = readFrame(t, reader)
+	assert.NoError(t, json.Unmarshal(payload, &e))
+	assert.Equal(t, EventChange, e.Type)
+	assert.JSONEq(t, `{"X":2,"Y":0}`, string(e.Components["Pos"]))
+
+	_ = ecs.RemoveEntity(ecs.MustGet(id).GetEntity())
+
+	_, payload = readFrame(t, reader)
+	assert.NoError(t, json.Unmarshal(payload, &e))
+	assert.Equal(t, EventRemove, e.Type)
+}
@@ -0,0 +1,204 @@
+package inspect
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Minimal server side implementation of RFC 6455 that is
+// just enough to push JSON messages to a browser or tool.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+var errNotWebsocket = errors.New("not a websocket handshake")
+
+type wsConn struct {
+	sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+	closed chan struct{}
+	once   sync.Once
+}
+
+func headerContains(h http.Header, key string, value string) bool {
+	for _, v := range strings.Split(h.Get(key), ",") {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	_, _ = h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// upgrade performs the websocket handshake and takes over the connection.
+func upgrade(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-Websocket-Key")
+	if r.Method != http.MethodGet || key == "" || !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, errNotWebsocket
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("connection can't be hijacked")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n")
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	ws := &wsConn{
+		conn:   conn,
+		reader: rw.Reader,
+		closed: make(chan struct{}),
+	}
+	go ws.readLoop()
+
+	return ws, nil
+}
+
+func (ws *wsConn) writeFrame(op byte, payload []byte) error {
+	ws.Lock()
+	defer ws.Unlock()
+
+	header := []byte{0x80 | op, 0}
+	switch l := len(payload); {
+	case l < 126:
+		header[1] = byte(l)
+	case l <= 0xFFFF:
+		header[1] = 126
+		header = append(header, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+	default:
+		header[1] = 127
+		header = append(header, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+	}
+
+	if _, err := ws.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := ws.conn.Write(payload)
+	return err
+}
+
+// WriteText sends a single text message.
+func (ws *wsConn) WriteText(data []byte) error {
+	return ws.writeFrame(opText, data)
+}
+
+// readLoop handles control frames from the client and discards
+// everything else, as the stream is one directional.
+func (ws *wsConn) readLoop() {
+	defer ws.Close()
+
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(ws.reader, head[:]); err != nil {
+			return
+		}
+
+		op := head[0] & 0x0F
+		masked := head[1]&0x80 != 0
+		length := uint64(head[1] & 0x7F)
+
+		switch length {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+				return
+			}
+			length = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(ws.reader, ext[:]); err != nil {
+				return
+			}
+			length = binary.BigEndian.Uint64(ext[:])
+		}
+
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(ws.reader, mask[:]); err != nil {
+				return
+			}
+		}
+
+		if op >= opClose && length > 125 {
+			return
+		}
+
+		if op < opClose {
+			if _, err := io.CopyN(ioutil.Discard, ws.reader, int64(length)); err != nil {
+				return
+			}
+			continue
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(ws.reader, payload); err != nil {
+			return
+		}
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+
+		switch op {
+		case opClose:
+			_ = ws.writeFrame(opClose, payload)
+			return
+		case opPing:
+			if err := ws.writeFrame(opPong, payload); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Done is closed as soon as the connection is closed.
+func (ws *wsConn) Done() <-chan struct{} {
+	return ws.closed
+}
+
+// Close closes the underlying connection.
+func (ws *wsConn) Close() error {
+	var err error
+	ws.once.Do(func() {
+		close(ws.closed)
+		err = ws.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,53 @@
+// Package testutil contains the fixtures that are shared by the tests of
+// the encoding packages, so that they only need to declare the types
+// which are specific to their format.
+package testutil
+
+import (
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type Pos struct {
+	X, Y int
+}
+
+type Health struct {
+	Value, Max int
+}
+
+type Name struct {
+	Value string
+}
+
+type Loot struct {
+	Chance float64
+	Items  map[string]int
+}
+
+type Hostile struct{}
+
+// Unit is a dynamic entity with a position.
+type Unit struct {
+	kinshi.BaseDynamicEntity
+	Pos
+}
+
+// Orc is a dynamic entity with a name and health, as it's typically
+// described by a prefab.
+type Orc struct {
+	kinshi.BaseDynamicEntity
+	Name
+	Health
+}
+
+// NewECS creates a new ECS with the fixtures and the given additional
+// entities and components registered and fails the test if they can't be
+// registered.
+func NewECS(t *testing.T, types ...interface{}) *kinshi.ECS {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.Register(&Unit{}, &Orc{}, Health{}, Loot{}, Hostile{}))
+	assert.NoError(t, ecs.Register(types...))
+	return ecs
+}
@@ -0,0 +1,118 @@
+package kinshi
+
+import (
+	"sort"
+)
+
+// SortBy sorts the iterator in place with the given less function and
+// returns it. The sort is stable, so entities that are equal keep their
+// order by id.
+//
+// For example you want to draw all sprites in z-order:
+//    it := ecs.Iterate(Sprite{}).SortBy(func(a, b *kinshi.EntityWrap) bool {
+//        return z(a) < z(b)
+//    })
+func (it EntityIterator) SortBy(less func(a, b *EntityWrap) bool) EntityIterator {
+	sort.SliceStable(it, func(i, j int) bool {
+		return less(it[i], it[j])
+	})
+	return it
+}
+
+// IterateSorted works like Iterate but sorts the found
+// entities with the given less function.
+func (ecs *ECS) IterateSorted(less func(a, b *EntityWrap) bool, types ...interface{}) EntityIterator {
+	return ecs.Iterate(types...).SortBy(less)
+}
+
+// ids returns the set of entity ids in the iterator.
+func (it EntityIterator) ids() map[EntityID]struct{} {
+	ids := make(map[EntityID]struct{}, len(it))
+	for i := range it {
+		ids[it[i].ent.ID()] = struct{}{}
+	}
+	return ids
+}
+
+// filter returns the deduplicated entities of the
+// iterator for which keep returns true.
+func (it EntityIterator) filter(keep func(id EntityID) bool) EntityIterator {
+	var res EntityIterator
+
+	seen := make(map[EntityID]struct{}, len(it))
+	for i := range it {
+		id := it[i].ent.ID()
+		if _, ok := seen[id]; ok || !keep(id) {
+			continue
+		}
+		seen[id] = struct{}{}
+		res = append(res, it[i])
+	}
+
+	return res
+}
+
+// Intersect returns a new iterator with the entities that are contained in
+// both iterators. Entities are compared by id and keep the order of it.
+func (it EntityIterator) Intersect(other EntityIterator) EntityIterator {
+	ids := other.ids()
+	return it.filter(func(id EntityID) bool {
+		_, ok := ids[id]
+		return ok
+	})
+}
+
+// Union returns a new iterator with the entities that are contained in at
+// least one of the iterators. Entities are compared by id, the entities of
+// it come first followed by the new entities of other.
+func (it EntityIterator) Union(other EntityIterator) EntityIterator {
+	all := make(EntityIterator, 0, len(it)+len(other))
+	all = append(append(all, it...), other...)
+	return all.filter(func(id EntityID) bool {
+		return true
+	})
+}
+
+// Exclude returns a new iterator with the entities of it that aren't
+// contained in other. Entities are compared by id and keep the order of it.
+//
+// For example you want to fetch all living entities that aren't selected:
+//    it := ecs.Iterate(Health{}).Exclude(ecs.Iterate(Selected{}))
+func (it EntityIterator) Exclude(other EntityIterator) EntityIterator {
+	ids := other.ids()
+	return it.filter(func(id EntityID) bool {
+		_, ok := ids[id]
+		return !ok
+	})
+}
+
+// Reverse reverses the order of the iterator in place and returns it.
+func (it EntityIterator) Reverse() EntityIterator {
+	for i, j := 0, len(it)-1; i < j; i, j = i+1, j-1 {
+		it[i], it[j] = it[j], it[i]
+	}
+	return it
+}
+
+// Limit returns the first n entities of the iterator. Use
+// ECS.Query to stop the scan as soon as n entities are found.
+func (it EntityIterator) Limit(n int) EntityIterator {
+	if n < 0 {
+		n = 0
+	}
+	if n < len(it) {
+		return it[:n]
+	}
+	return it
+}
+
+// Offset returns the iterator without the first n entities.
+func (it EntityIterator) Offset(n int) EntityIterator {
+	if n >= len(it) {
+		return nil
+	}
+	if n < 0 {
+		n = 0
+	}
+	return it[n:]
+}
@@ -0,0 +1,84 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func posX(ew *EntityWrap) int {
+	var x int
+	_ = ew.View(func(p *Pos) {
+		x = p.X
+	})
+	return x
+}
+
+func TestEntityIterator_SortBy(t *testing.T) {
+	ecs := New()
+
+	for _, x := range []int{5, 1, 4, 1, 3} {
+		_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: x}})
+	}
+
+	it := ecs.IterateSorted(func(a, b *EntityWrap) bool {
+		return posX(a) < posX(b)
+	}, Pos{})
+
+	var xs []int
+	var ids []EntityID
+	for _, ew := range it {
+		xs = append(xs, posX(ew))
+		ids = append(ids, ew.GetEntity().ID())
+	}
+
+	assert.Equal(t, []int{1, 1, 3, 4, 5}, xs)
+	assert.Equal(t, []EntityID{2, 4, 5, 3, 1}, ids, "sort isn't stable")
+}
+
+func iteratorIDs(it EntityIterator) []EntityID {
+	var ids []EntityID
+	for _, ew := range it {
+		ids = append(ids, ew.GetEntity().ID())
+	}
+	return ids
+}
+
+func TestEntityIterator_SetOperations(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{})
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{})
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	dynPos := &DynamicUnit{}
+	_ = dynPos.SetComponent(&Velocity{})
+	_ = dynPos.SetComponent(&Pos{})
+	idDynPos, _ := ecs.AddEntity(dynPos)
+
+	withPos := ecs.Iterate(Pos{})
+	withVel := ecs.Iterate(Velocity{})
+
+	assert.Equal(t, []EntityID{idDynPos}, iteratorIDs(withPos.Intersect(withVel)))
+	assert.Equal(t, []EntityID{idUnit, idDynPos, idDyn}, iteratorIDs(withPos.Union(withVel)))
+	assert.Equal(t, []EntityID{idUnit}, iteratorIDs(withPos.Exclude(withVel)))
+	assert.Nil(t, withPos.Exclude(withPos))
+
+	dup := append(EntityIterator{}, withPos...)
+	dup = append(dup, withPos...)
+	assert.Equal(t, []EntityID{idUnit, idDynPos}, iteratorIDs(dup.Union(nil)), "union isn't deduplicated")
+	assert.Equal(t, []EntityID{idUnit, idDynPos}, iteratorIDs(dup.Intersect(withPos)), "intersect isn't deduplicated")
+}
+
+func TestEntityIterator_Reverse(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 5; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	assert.Equal(t, []EntityID{5, 4, 3, 2, 1}, iteratorIDs(ecs.Iterate().Reverse()))
+	assert.Equal(t, []EntityID{2, 1}, iteratorIDs(ecs.Iterate().Limit(2).Reverse()))
+	assert.Nil(t, EntityIterator(nil).Reverse())
+}
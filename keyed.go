@@ -0,0 +1,102 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// keySeparator joins the name and the key of keyed components in
+// snapshots, e.g. "StatusEffect#poison". It can't be part of a type name.
+const keySeparator = "#"
+
+func keyedName(name string, key string) string {
+	return name + keySeparator + key
+}
+
+// splitKeyed splits a serialized name into the name and key
+// of a keyed component. If name isn't keyed false is returned.
+func splitKeyed(name string) (string, string, bool) {
+	i := strings.Index(name, keySeparator)
+	if i < 0 {
+		return name, "", false
+	}
+	return name[:i], name[i+len(keySeparator):], true
+}
+
+// Keys returns the keys of all keyed components of the type c sorted.
+// If c is a string it is used as name.
+func (ew *EntityWrap) Keys(c interface{}) []string {
+	ew.rlock()
+	defer ew.runlock()
+
+	ke, ok := ew.ent.(KeyedEntity)
+	if !ok {
+		return nil
+	}
+
+	name := ew.parent.componentNames([]interface{}{c})[0]
+
+	var keys []string
+	for _, kc := range ke.GetKeyedComponents() {
+		if kc.Name == name {
+			keys = append(keys, kc.Key)
+		}
+	}
+
+	return keys
+}
+
+// ViewKeyed calls fn for every keyed component of the requested type
+// with the key and a pointer to the component. If fn returns a error
+// the iteration stops and the error is returned.
+//
+// For example you want to tick all status effects:
+//    ew.ViewKeyed(func(key string, s *StatusEffect) {
+//        s.Remaining -= 1
+//    })
+func (ew *EntityWrap) ViewKeyed(fn interface{}) error {
+	fnType := reflect.TypeOf(fn)
+	if fnType.Kind() != reflect.Func {
+		return ew.parent.misuse(fmt.Errorf("fn not function"))
+	}
+
+	if fnType.NumIn() != 2 || fnType.In(0).Kind() != reflect.String || fnType.In(1).Kind() != reflect.Ptr {
+		return ew.parent.misuse(fmt.Errorf("fn needs a key and a component pointer argument"))
+	}
+
+	name := ew.parent.names.typeName(fnType.In(1).Elem())
+
+	ew.rlock()
+	defer ew.runlock()
+
+	ke, ok := ew.ent.(KeyedEntity)
+	if !ok {
+		return ew.parent.misuse(fmt.Errorf("view keyed on entity without keyed components: %w", ErrNotFound))
+	}
+
+	if err := ew.parent.write(ew.ent.ID()); err != nil {
+		return err
+	}
+
+	ew.parent.enterView()
+	defer ew.parent.leaveView()
+
+	unlock := ew.parent.lockComponents(name)
+	defer unlock()
+
+	fnVal := reflect.ValueOf(fn)
+	for _, kc := range ke.GetKeyedComponents() {
+		if kc.Name != name {
+			continue
+		}
+
+		res := fnVal.Call([]reflect.Value{reflect.ValueOf(kc.Key), reflect.ValueOf(kc.Value)})
+		ew.parent.touch(ew.ent)
+		if err := callError(res); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package kinshi
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type StatusEffect struct {
+	Damage    int
+	Remaining int
+}
+
+func TestKeyedComponents(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(&StatusEffect{}))
+
+	ent := &DynamicUnit{Name: Name{Value: "Orc"}}
+	assert.NoError(t, ent.SetKeyed("poison", &StatusEffect{Damage: 2, Remaining: 3}))
+	assert.NoError(t, ent.SetKeyed("burn", &StatusEffect{Damage: 5, Remaining: 1}))
+
+	// Keyed components don't collide with the plain component.
+	_, err := ent.GetComponent("StatusEffect")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	_, err = ecs.AddEntity(ent)
+	assert.NoError(t, err)
+	_, err = ecs.AddEntity(&DynamicUnit{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, ecs.Count(StatusEffect{}))
+
+	ew := ecs.Access(ent)
+	assert.Equal(t, []string{"burn", "poison"}, ew.Keys(StatusEffect{}))
+	assert.Equal(t, []string{"Name", "StatusEffect#burn", "StatusEffect#poison"}, ew.Components())
+
+	damage := 0
+	assert.NoError(t, ew.ViewKeyed(func(key string, s *StatusEffect) {
+		damage += s.Damage
+		s.Remaining -= 1
+	}))
+	assert.Equal(t, 7, damage)
+
+	errStop := errors.New("stop")
+	calls := 0
+	assert.ErrorIs(t, ew.ViewKeyed(func(key string, s *StatusEffect) error {
+		calls++
+		return errStop
+	}), errStop)
+	assert.Equal(t, 1, calls)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.Contains(t, buf.String(), `"StatusEffect#poison"`)
+
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, loaded.RegisterComponent(&StatusEffect{}))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	c, err := loaded.MustGet(ent.ID()).GetEntity().(KeyedEntity).GetKeyed("StatusEffect", "poison")
+	assert.NoError(t, err)
+	assert.Equal(t, &StatusEffect{Damage: 2, Remaining: 2}, c)
+
+	clone := ecs.Clone()
+	assert.NoError(t, ent.RemoveKeyed(StatusEffect{}, "burn"))
+	assert.ErrorIs(t, ent.RemoveKeyed("StatusEffect", "burn"), ErrNotFound)
+	assert.Equal(t, []string{"poison"}, ew.Keys("StatusEffect"))
+	assert.Equal(t, []string{"burn", "poison"}, clone.MustGet(ent.ID()).Keys(StatusEffect{}))
+
+	assert.NoError(t, ent.RemoveKeyed(StatusEffect{}, "poison"))
+	assert.Equal(t, 0, ecs.Count(StatusEffect{}))
+}
+
+func TestKeyedComponents_Replication(t *testing.T) {
+	server := New()
+	client := New()
+	for _, ecs := range []*ECS{server, client} {
+		assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+		assert.NoError(t, ecs.RegisterComponent(&StatusEffect{}))
+	}
+
+	ent := &DynamicUnit{}
+	assert.NoError(t, ent.SetKeyed("poison", &StatusEffect{Damage: 2}))
+	_, err := server.AddEntity(ent)
+	assert.NoError(t, err)
+
+	r := NewReplicator(server)
+	payload, err := r.Tick()
+	assert.NoError(t, err)
+	assert.NoError(t, client.ApplyReplication(payload))
+	assert.Equal(t, []string{"poison"}, client.MustGet(ent.ID()).Keys(StatusEffect{}))
+
+	assert.NoError(t, ent.RemoveKeyed(StatusEffect{}, "poison"))
+	assert.NoError(t, ent.SetKeyed("burn", &StatusEffect{Damage: 5}))
+
+	payload, err = r.Tick()
+	assert.NoError(t, err)
+	assert.NoError(t, client.ApplyReplication(payload))
+	assert.Equal(t, []string{"burn"}, client.MustGet(ent.ID()).Keys(StatusEffect{}))
+}
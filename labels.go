@@ -0,0 +1,47 @@
+package kinshi
+
+import (
+	"context"
+	"runtime/pprof"
+)
+
+// WithPprofLabels tags the worker go routines that are spawned by
+// queries with the pprof label "kinshi_query" containing the queried
+// types (e.g. "Iterate(Pos, Velocity)"), so that CPU profiles attribute
+// the time to specific queries instead of anonymous worker functions.
+func WithPprofLabels() Option {
+	return func(ecs *ECS) {
+		ecs.pprofLabels = true
+	}
+}
+
+// queryLabels returns the label context for the workers of
+// a query or nil if labels are disabled.
+func (ecs *ECS) queryLabels(fn string, types []interface{}) context.Context {
+	if !ecs.pprofLabels {
+		return nil
+	}
+	return pprof.WithLabels(context.Background(), pprof.Labels("kinshi_query", ecs.queryName(fn, types)))
+}
+
+// setLabels sets the labels of the current go routine. This
+// is only used at the start of short-lived worker go routines.
+func setLabels(ctx context.Context) {
+	if ctx != nil {
+		pprof.SetGoroutineLabels(ctx)
+	}
+}
+
+// RunSystem runs fn with the pprof label "kinshi_system" set to the
+// given name. Go routines that are started by fn inherit the label,
+// only the query workers replace it with their "kinshi_query" label.
+//
+// For example:
+//    kinshi.RunSystem("movement", func() {
+//        SystemMovement(ecs)
+//    })
+func RunSystem(name string, fn func()) {
+	pprof.Do(context.Background(), pprof.Labels("kinshi_system", name), func(context.Context) {
+		fn()
+	})
+}
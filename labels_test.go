@@ -0,0 +1,30 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime/pprof"
+	"testing"
+)
+
+func TestWithPprofLabels(t *testing.T) {
+	ecs := New(WithPprofLabels())
+
+	ctx := ecs.queryLabels("Iterate", []interface{}{Pos{}, Velocity{}})
+	if assert.NotNil(t, ctx) {
+		label, ok := pprof.Label(ctx, "kinshi_query")
+		assert.True(t, ok)
+		assert.Equal(t, "Iterate(Pos, Velocity)", label)
+	}
+
+	assert.Nil(t, New().queryLabels("Iterate", nil), "labels are enabled by default")
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	var count int
+	RunSystem("test", func() {
+		count = ecs.Iterate(Pos{}).Count() + ecs.IterateSpecific(Unit{}).Count()
+	})
+	assert.Equal(t, 20, count)
+}
@@ -0,0 +1,74 @@
+package kinshi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// WithInterpolation keeps the values of the previous tick of the given
+// component types (or names), so that EntityWrap.Lerp can interpolate
+// between them. It's a shorthand for WithHistory with a size of 2, so
+// RecordHistory needs to be called at the end of every tick.
+func WithInterpolation(types ...interface{}) Option {
+	return WithHistory(2, types...)
+}
+
+// Lerp returns the component c interpolated between its values of the
+// two last recorded ticks, where a alpha of 0 results in the value of the
+// previous tick and 1 in the value of the last tick. Numeric fields,
+// also in nested structs and arrays, are interpolated linearly, all
+// other fields are taken from the last tick. If only a single tick has
+// been recorded so far its value is returned. This allows render code
+// that runs at a different rate than a fixed timestep simulation to
+// interpolate smoothly.
+//
+// For example:
+//    alpha := accumulator / timestep
+//    if p, err := ew.Lerp(Pos{}, alpha); err == nil {
+//        draw(p.(Pos))
+//    }
+func (ew *EntityWrap) Lerp(c interface{}, alpha float64) (interface{}, error) {
+	last, err := ew.History(c, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	prev, err := ew.History(c, 1)
+	if err != nil {
+		return last, nil
+	}
+
+	if reflect.TypeOf(prev) != reflect.TypeOf(last) {
+		return nil, fmt.Errorf("component '%s' changed type", TypeName(c))
+	}
+
+	return lerpValue(reflect.ValueOf(prev), reflect.ValueOf(last), alpha).Interface(), nil
+}
+
+// lerpValue interpolates linearly between a and b.
+func lerpValue(a, b reflect.Value, alpha float64) reflect.Value {
+	res := reflect.New(b.Type()).Elem()
+	res.Set(b)
+
+	switch b.Kind() {
+	case reflect.Float32, reflect.Float64:
+		res.SetFloat(a.Float() + (b.Float()-a.Float())*alpha)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		res.SetInt(a.Int() + int64(math.Round(float64(b.Int()-a.Int())*alpha)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		res.SetUint(uint64(math.Round(float64(a.Uint()) + (float64(b.Uint())-float64(a.Uint()))*alpha)))
+	case reflect.Struct:
+		for i := 0; i < b.NumField(); i++ {
+			if res.Field(i).CanSet() {
+				res.Field(i).Set(lerpValue(a.Field(i), b.Field(i), alpha))
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < b.Len(); i++ {
+			res.Index(i).Set(lerpValue(a.Index(i), b.Index(i), alpha))
+		}
+	}
+
+	return res
+}
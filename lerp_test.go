@@ -0,0 +1,54 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type Transform struct {
+	Pos      Velocity
+	Rotation [2]float32
+	Layer    uint8
+	Sprite   string
+}
+
+type Actor struct {
+	BaseEntity
+	Transform
+}
+
+func TestEntityWrap_Lerp(t *testing.T) {
+	ecs := New(WithInterpolation(Transform{}))
+	assert.NoError(t, ecs.RegisterEntity(&Actor{}))
+
+	id, _ := ecs.AddEntity(&Actor{Transform: Transform{Sprite: "idle"}})
+	ew := ecs.MustGet(id)
+
+	ecs.RecordHistory()
+	single, err := ew.Lerp(Transform{}, 0.5)
+	assert.NoError(t, err)
+	assert.Equal(t, Transform{Sprite: "idle"}, single, "single tick wasn't returned")
+
+	assert.NoError(t, ew.Set(Transform{
+		Pos:      Velocity{X: 10, Y: -4},
+		Rotation: [2]float32{1, 2},
+		Layer:    4,
+		Sprite:   "walk",
+	}))
+	ecs.RecordHistory()
+
+	res, err := ew.Lerp(Transform{}, 0.25)
+	assert.NoError(t, err)
+	assert.Equal(t, Transform{
+		Pos:      Velocity{X: 2.5, Y: -1},
+		Rotation: [2]float32{0.25, 0.5},
+		Layer:    1,
+		Sprite:   "walk",
+	}, res)
+
+	res, _ = ew.Lerp(Transform{}, 0)
+	assert.Equal(t, Transform{Sprite: "walk"}, res)
+
+	_, err = ew.Lerp(Pos{}, 0.5)
+	assert.Error(t, err, "lerp on untracked component")
+}
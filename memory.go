@@ -0,0 +1,103 @@
+package kinshi
+
+import (
+	"unsafe"
+)
+
+// MemoryStats describes the memory that is held by the entity storage.
+type MemoryStats struct {
+	// Entities is the number of stored entities.
+	Entities int
+	// Capacity is the number of entities the storage can
+	// hold without growing.
+	Capacity int
+	// StorageBytes is the size of the entity storage
+	// including the unused capacity.
+	StorageBytes uint64
+	// UnusedBytes is the part of StorageBytes that is held by
+	// unused capacity and can be released by Compact.
+	UnusedBytes uint64
+	// EntityBytes is a rough estimate of the size of the entities
+	// and their dynamic components. Memory referenced by components
+	// (slices, maps, ...) isn't counted.
+	EntityBytes uint64
+	// ReadViewBytes is the size of the currently published read view.
+	ReadViewBytes uint64
+}
+
+// MemoryStats reports the memory that is held by the entity storage. Like
+// in Stats the sizes are derived from the counts of the entities and
+// components, so the entities aren't inspected.
+func (ecs *ECS) MemoryStats() MemoryStats {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	entrySize := uint64(unsafe.Sizeof(entityEntry{}))
+
+	stats := MemoryStats{
+		Entities:     len(ecs.entities),
+		Capacity:     cap(ecs.entities),
+		StorageBytes: uint64(cap(ecs.entities)) * entrySize,
+		UnusedBytes:  uint64(cap(ecs.entities)-len(ecs.entities)) * entrySize,
+	}
+
+	for typeName, count := range ecs.typeCounts {
+		if meta, ok := ecs.metaCache[typeName]; ok {
+			stats.EntityBytes += uint64(count) * uint64(meta.t.Size())
+		}
+	}
+
+	_, dynamicBytes, keyedBytes := ecs.dynCounts.read()
+	stats.EntityBytes += dynamicBytes + keyedBytes
+
+	if rv, _ := ecs.published.Load().(*readView); rv != nil {
+		stats.ReadViewBytes = uint64(cap(rv.entries))*entrySize +
+			uint64(cap(rv.ids))*uint64(unsafe.Sizeof(EntityID(0))) +
+			uint64(cap(rv.metas))*uint64(unsafe.Sizeof(typeMeta{}))
+	}
+
+	return stats
+}
+
+// Compact releases the memory that is held by the storage after many
+// entities have been removed (e.g. at the end of a level). The backing
+// array of the entities and the internal maps never shrink on their own.
+func (ecs *ECS) Compact() {
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	entities := make([]entityEntry, len(ecs.entities))
+	copy(entities, ecs.entities)
+	ecs.entities = entities
+
+	typeCounts := make(map[string]int, len(ecs.typeCounts))
+	for k, v := range ecs.typeCounts {
+		typeCounts[k] = v
+	}
+	ecs.typeCounts = typeCounts
+
+	netIDs := make(map[NetID]EntityID, len(ecs.netIDs))
+	entityNetIDs := make(map[EntityID]NetID, len(ecs.entityNetIDs))
+	for k, v := range ecs.netIDs {
+		netIDs[k] = v
+		entityNetIDs[v] = k
+	}
+	ecs.netIDs = netIDs
+	ecs.entityNetIDs = entityNetIDs
+
+	uuids := make(map[UUID]EntityID, len(ecs.uuids))
+	entityUUIDs := make(map[EntityID]UUID, len(ecs.entityUUIDs))
+	for k, v := range ecs.uuids {
+		uuids[k] = v
+		entityUUIDs[v] = k
+	}
+	ecs.uuids = uuids
+	ecs.entityUUIDs = entityUUIDs
+
+	scenes := make(map[SceneID][]EntityID, len(ecs.scenes))
+	for k, v := range ecs.scenes {
+		scenes[k] = append([]EntityID(nil), v...)
+	}
+	ecs.scenes = scenes
+}
@@ -0,0 +1,46 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Compact(t *testing.T) {
+	ecs := New()
+
+	var ids []EntityID
+	for i := 0; i < 1000; i++ {
+		id, _ := ecs.AddEntity(&Unit{})
+		ids = append(ids, id)
+	}
+	_, _ = ecs.AssignNetID(ids[999])
+
+	for i := 0; i < 990; i++ {
+		_ = ecs.RemoveByID(ids[i])
+	}
+
+	before := ecs.MemoryStats()
+	assert.Equal(t, 10, before.Entities)
+	assert.True(t, before.Capacity >= 1000)
+	assert.NotZero(t, before.UnusedBytes)
+	assert.NotZero(t, before.EntityBytes)
+
+	ecs.Compact()
+
+	after := ecs.MemoryStats()
+	assert.Equal(t, 10, after.Entities)
+	assert.Equal(t, 10, after.Capacity)
+	assert.Zero(t, after.UnusedBytes)
+	assert.Equal(t, before.EntityBytes, after.EntityBytes)
+	assert.True(t, after.StorageBytes < before.StorageBytes)
+
+	assert.Equal(t, 10, ecs.Iterate(Pos{}).Count())
+	assert.NotZero(t, ecs.MemoryStats().ReadViewBytes)
+	assert.Equal(t, 10, ecs.CountType(Unit{}))
+
+	netID, ok := ecs.NetID(ids[999])
+	assert.True(t, ok)
+	id, ok := ecs.LocalID(netID)
+	assert.True(t, ok)
+	assert.Equal(t, ids[999], id)
+}
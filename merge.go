@@ -0,0 +1,163 @@
+package kinshi
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// Merge moves all entities of other into the ECS. Entities keep their
+// id if it is still free, otherwise a new id will be assigned. The returned
+// map contains the old to new id mapping of every moved entity. After the
+// merge other is empty but keeps its registered types.
+//
+// This is useful for stitching together chunks that have been
+// generated in parallel in separate ECS instances.
+//
+// Network ids and UUIDs are carried over if they aren't already in use.
+// If other uses another namer, see WithTypeNamer, the types of the moved
+// entities are cached again by the names of the ECS.
+//
+// If a id can't be assigned or a entity conflicts with a Unique index of
+// the ECS, nothing is moved and both worlds stay unchanged. Scenes of
+// other are dropped, see LoadScene.
+//
+// Important: EntityIDs that are stored inside of components are
+// not remapped. Use the returned mapping to fix them up.
+func (ecs *ECS) Merge(other *ECS) (map[EntityID]EntityID, error) {
+	if other == ecs {
+		return nil, ecs.misuse(fmt.Errorf("can't merge ECS with itself"))
+	}
+
+	ecs.checkMutation()
+
+	// Both are locked in the order of their addresses, so that merges in
+	// both directions at the same time don't deadlock.
+	first, second := ecs, other
+	if uintptr(unsafe.Pointer(other)) < uintptr(unsafe.Pointer(ecs)) {
+		first, second = other, ecs
+	}
+
+	first.lock()
+	defer first.Unlock()
+
+	second.lock()
+	defer second.Unlock()
+
+	renamed := ecs.names != other.names
+
+	// The ids are assigned and the conflicts are checked before anything
+	// is moved, so that a failed merge leaves both worlds unchanged.
+	ids := make([]EntityID, len(other.entities))
+	ents := make([]Entity, len(other.entities))
+	taken := make(map[EntityID]struct{}, len(other.entities))
+	for i := range other.entities {
+		ent := other.entities[i].Ent
+		if renamed {
+			if err := ecs.cacheType(ent); err != nil {
+				return nil, err
+			}
+		}
+
+		id := ent.ID()
+		for {
+			_, _, found := ecs.findEntity(id)
+			_, used := taken[id]
+			if !found && !used {
+				break
+			}
+			id = ecs.nextId()
+		}
+
+		ids[i] = id
+		ents[i] = ent
+		taken[id] = struct{}{}
+	}
+
+	for k, v := range other.compMetaCache {
+		if renamed {
+			k = ecs.names.typeName(v)
+		}
+		if err := ecs.checkName(k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ecs.checkNames(ents...); err != nil {
+		return nil, err
+	}
+
+	if err := ecs.checkUniqueAll(ents); err != nil {
+		return nil, err
+	}
+
+	if !renamed {
+		for k, v := range other.metaCache {
+			if _, ok := ecs.metaCache[k]; !ok {
+				ecs.metaCache[k] = v
+			}
+		}
+	}
+
+	for k, v := range other.compMetaCache {
+		if renamed {
+			k = ecs.names.typeName(v)
+		}
+		if _, ok := ecs.compMetaCache[k]; !ok {
+			_ = ecs.claimName(k, v)
+			ecs.compMetaCache[k] = v
+		}
+	}
+
+	for k, v := range other.aliases {
+		if _, ok := ecs.aliases[k]; !ok {
+			if ecs.aliases == nil {
+				ecs.aliases = map[string]string{}
+			}
+			ecs.aliases[k] = v
+		}
+	}
+
+	mapping := make(map[EntityID]EntityID, len(other.entities))
+	for i := range other.entities {
+		entry := other.entities[i]
+		oldID := entry.Ent.ID()
+
+		if renamed {
+			entry.TypeName = ecs.names.getTypeName(entry.Ent)
+		}
+		other.preserve(oldID)
+		entry.Ent.SetID(ids[i])
+
+		if err := ecs.insertEntity(entry); err != nil {
+			return mapping, err
+		}
+
+		mapping[oldID] = entry.Ent.ID()
+
+		if netID, ok := other.entityNetIDs[oldID]; ok {
+			if _, ok := ecs.netIDs[netID]; !ok {
+				ecs.bindNetID(entry.Ent.ID(), netID)
+			}
+		}
+
+		if uuid, ok := other.entityUUIDs[oldID]; ok {
+			if _, ok := ecs.uuids[uuid]; !ok {
+				ecs.bindUUID(entry.Ent.ID(), uuid)
+			}
+		}
+
+		ecs.setUnknown(entry.Ent.ID(), other.getUnknown(oldID))
+	}
+
+	other.entities = []entityEntry{}
+	other.scenes = map[SceneID][]EntityID{}
+	other.typeCounts = map[string]int{}
+	other.netIDs = map[NetID]EntityID{}
+	other.entityNetIDs = map[EntityID]NetID{}
+	other.uuids = map[UUID]EntityID{}
+	other.entityUUIDs = map[EntityID]UUID{}
+	other.unknown = nil
+	other.invalidateIndexes()
+
+	return mapping, nil
+}
@@ -0,0 +1,142 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestECS_Merge(t *testing.T) {
+	a := New()
+	b := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = a.AddEntity(&Unit{Name: Name{Value: "a"}})
+	}
+
+	for i := 0; i < 15; i++ {
+		_, _ = b.AddEntity(&Unit{Name: Name{Value: "b"}})
+	}
+
+	mapping, err := a.Merge(b)
+	if !assert.NoError(t, err, "merge failed") {
+		return
+	}
+
+	assert.Len(t, mapping, 15, "mapping has wrong length")
+	assert.Equal(t, 25, a.IterateSpecific(Unit{}).Count(), "entity count doesn't match")
+	assert.Equal(t, 0, b.IterateSpecific(Unit{}).Count(), "merged ECS isn't empty")
+
+	seen := map[EntityID]struct{}{}
+	for _, newID := range mapping {
+		_, ok := seen[newID]
+		assert.False(t, ok, "new id assigned twice")
+		seen[newID] = struct{}{}
+
+		assert.NoError(t, a.MustGet(newID).View(func(n *Name) {
+			assert.Equal(t, "b", n.Value, "mapping points to wrong entity")
+		}), "failed while view")
+	}
+
+	for i := 1; i < len(a.entities); i++ {
+		assert.Less(t, uint64(a.entities[i-1].Ent.ID()), uint64(a.entities[i].Ent.ID()), "entities aren't sorted")
+	}
+
+	_, err = a.Merge(a)
+	assert.Error(t, err, "merging with itself should fail")
+}
+
+func TestECS_MergeBothWays(t *testing.T) {
+	a := New()
+	b := New()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		wg := sync.WaitGroup{}
+		for i := 0; i < 100; i++ {
+			_, _ = a.AddEntity(&Unit{})
+			_, _ = b.AddEntity(&Unit{})
+
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := a.Merge(b)
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := b.Merge(a)
+				assert.NoError(t, err)
+			}()
+			wg.Wait()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("merges in both directions deadlocked")
+	}
+
+	assert.Equal(t, 200, a.Len()+b.Len())
+}
+
+func TestECS_MergeTypeNamer(t *testing.T) {
+	ecs := New(WithQualifiedNames())
+
+	other := New()
+	_, _ = other.AddEntity(&Unit{Pos: Pos{X: 1}})
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{X: 1})
+	_, _ = other.AddEntity(dyn)
+
+	_, err := ecs.Merge(other)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, ecs.CountType(Unit{}))
+	assert.Equal(t, 1, ecs.Count("github.com/BigJk/kinshi.Velocity"))
+	assert.Equal(t, 1, ecs.Count(Pos{}))
+	assert.Empty(t, ecs.Iterate("Pos"))
+}
+
+func TestECS_MergeConflict(t *testing.T) {
+	a := New()
+	assert.NoError(t, a.Index(Name{}, "Value", Unique()))
+	_, _ = a.AddEntity(&Unit{Name: Name{Value: "A"}})
+
+	b := New()
+	assert.NoError(t, b.Index(Name{}, "Value", Unique()))
+	first, _ := b.AddEntity(&Unit{Name: Name{Value: "B"}})
+	_, _ = b.AddEntity(&Unit{Name: Name{Value: "A"}})
+
+	// Nothing is moved if a entity conflicts.
+	mapping, err := a.Merge(b)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Nil(t, mapping)
+	assert.Equal(t, 1, a.Len())
+	assert.Equal(t, 2, b.Len())
+	assert.Equal(t, first, b.MustGet(first).GetEntity().ID())
+	assert.Equal(t, "B", b.MustGet(first).GetEntity().(*Unit).Name.Value)
+
+	// The moved entities also need to differ from each other.
+	d := New()
+	_, _ = d.AddEntity(&Unit{Name: Name{Value: "C"}})
+	_, _ = d.AddEntity(&Unit{Name: Name{Value: "C"}})
+	_, err = a.Merge(d)
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Equal(t, 1, a.Len())
+	assert.Equal(t, 2, d.Len())
+
+	// The merged ECS can be used again.
+	c := New()
+	assert.NoError(t, c.Index(Name{}, "Value", Unique()))
+	_, err = c.Merge(b)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, b.Len())
+	_, err = b.AddEntity(&Unit{Name: Name{Value: "A"}})
+	assert.NoError(t, err)
+	assert.Len(t, b.GetByIndex(Name{}, "Value", "A"), 1)
+}
@@ -0,0 +1,173 @@
+// Package metrics exposes the state of a kinshi.ECS as Prometheus
+// metrics. The metrics are written in the Prometheus text exposition
+// format, so the Exporter can be scraped directly without pulling the
+// Prometheus client library into your dependencies.
+//
+// Query latencies are only available if the ECS was created with
+// kinshi.WithProfiler().
+package metrics
+
+import (
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type systemStat struct {
+	runs  uint64
+	total time.Duration
+	last  time.Duration
+}
+
+// Exporter collects metrics of a ECS and the systems that run on it.
+type Exporter struct {
+	sync.Mutex
+	ecs     *kinshi.ECS
+	systems map[string]*systemStat
+}
+
+// New creates a new exporter for the ECS.
+func New(ecs *kinshi.ECS) *Exporter {
+	return &Exporter{
+		ecs:     ecs,
+		systems: map[string]*systemStat{},
+	}
+}
+
+// ObserveSystem records a single run of the named system.
+func (e *Exporter) ObserveSystem(name string, d time.Duration) {
+	e.Lock()
+	defer e.Unlock()
+
+	s, ok := e.systems[name]
+	if !ok {
+		s = &systemStat{}
+		e.systems[name] = s
+	}
+
+	s.runs += 1
+	s.total += d
+	s.last = d
+}
+
+// TimeSystem runs fn and records its run time under the system name.
+//
+// For example:
+//    exporter.TimeSystem("movement", func() {
+//        SystemMovement(ecs)
+//    })
+func (e *Exporter) TimeSystem(name string, fn func()) {
+	start := time.Now()
+	fn()
+	e.ObserveSystem(name, time.Since(start))
+}
+
+// ServeHTTP implements http.Handler and serves the metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := e.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+type metricWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (mw *metricWriter) header(name string, kind string, help string) {
+	mw.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (mw *metricWriter) value(name string, label string, labelValue string, v float64) {
+	if label == "" {
+		mw.printf("%s %v\n", name, v)
+	} else {
+		mw.printf("%s{%s=\"%s\"} %v\n", name, label, labelEscaper.Replace(labelValue), v)
+	}
+}
+
+func (mw *metricWriter) printf(format string, a ...interface{}) {
+	if mw.err == nil {
+		_, mw.err = fmt.Fprintf(mw.w, format, a...)
+	}
+}
+
+func (mw *metricWriter) counts(name string, label string, help string, counts map[string]int) {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	mw.header(name, "gauge", help)
+	for _, k := range keys {
+		mw.value(name, label, k, float64(counts[k]))
+	}
+}
+
+// Write writes all metrics in the Prometheus text format to w.
+func (e *Exporter) Write(w io.Writer) error {
+	mw := &metricWriter{w: w}
+	stats := e.ecs.Stats()
+
+	mw.header("kinshi_entities", "gauge", "Number of entities.")
+	mw.value("kinshi_entities", "", "", float64(stats.Entities))
+	mw.counts("kinshi_entities_by_type", "type", "Number of entities per entity type.", stats.EntitiesByType)
+	mw.counts("kinshi_components", "component", "Number of components per component type.", stats.Components)
+	mw.header("kinshi_memory_approx_bytes", "gauge", "Approximate memory used by the entity storage.")
+	mw.value("kinshi_memory_approx_bytes", "", "", float64(stats.ApproxMemory))
+	mw.header("kinshi_snapshot_size_bytes", "gauge", "Size of the last snapshot written by Marshal.")
+	mw.value("kinshi_snapshot_size_bytes", "", "", float64(stats.SnapshotSize))
+
+	if queries := e.ecs.QueryStats(); queries != nil {
+		mw.header("kinshi_query_calls_total", "counter", "Number of executed queries.")
+		for _, qs := range queries {
+			mw.value("kinshi_query_calls_total", "query", qs.Query, float64(qs.Calls))
+		}
+		mw.header("kinshi_query_duration_seconds_total", "counter", "Total time spent in queries.")
+		for _, qs := range queries {
+			mw.value("kinshi_query_duration_seconds_total", "query", qs.Query, qs.Duration.Seconds())
+		}
+		mw.header("kinshi_query_scanned_total", "counter", "Number of entities scanned by queries.")
+		for _, qs := range queries {
+			mw.value("kinshi_query_scanned_total", "query", qs.Query, float64(qs.Scanned))
+		}
+		mw.header("kinshi_query_matched_total", "counter", "Number of entities matched by queries.")
+		for _, qs := range queries {
+			mw.value("kinshi_query_matched_total", "query", qs.Query, float64(qs.Matched))
+		}
+	}
+
+	e.Lock()
+	names := make([]string, 0, len(e.systems))
+	for name := range e.systems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		mw.header("kinshi_system_runs_total", "counter", "Number of system runs.")
+		for _, name := range names {
+			mw.value("kinshi_system_runs_total", "system", name, float64(e.systems[name].runs))
+		}
+		mw.header("kinshi_system_duration_seconds_total", "counter", "Total run time of systems.")
+		for _, name := range names {
+			mw.value("kinshi_system_duration_seconds_total", "system", name, e.systems[name].total.Seconds())
+		}
+		mw.header("kinshi_system_last_duration_seconds", "gauge", "Run time of the last system run.")
+		for _, name := range names {
+			mw.value("kinshi_system_last_duration_seconds", "system", name, e.systems[name].last.Seconds())
+		}
+	}
+	e.Unlock()
+
+	return mw.err
+}
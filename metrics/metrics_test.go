@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"bytes"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Unit struct {
+	kinshi.BaseEntity
+	Pos
+}
+
+func TestExporter(t *testing.T) {
+	ecs := kinshi.New(kinshi.WithProfiler())
+	for i := 0; i < 3; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+	ecs.Iterate(Pos{})
+	_ = ecs.Marshal(ioutil.Discard)
+
+	exp := New(ecs)
+	exp.ObserveSystem("movement", time.Second)
+	exp.TimeSystem(`weird "name"`, func() {})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, exp.Write(buf))
+
+	out := buf.String()
+	assert.Contains(t, out, "# TYPE kinshi_entities gauge\nkinshi_entities 3\n")
+	assert.Contains(t, out, "kinshi_entities_by_type{type=\"Unit\"} 3\n")
+	assert.Contains(t, out, "kinshi_components{component=\"Pos\"} 3\n")
+	assert.Contains(t, out, "kinshi_query_calls_total{query=\"Iterate(Pos)\"} 1\n")
+	assert.Contains(t, out, "kinshi_system_runs_total{system=\"movement\"} 1\n")
+	assert.Contains(t, out, "kinshi_system_duration_seconds_total{system=\"movement\"} 1\n")
+	assert.Contains(t, out, "kinshi_system_runs_total{system=\"weird \\\"name\\\"\"} 1\n")
+	assert.NotContains(t, out, "kinshi_snapshot_size_bytes 0\n")
+}
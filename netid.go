@@ -0,0 +1,140 @@
+package kinshi
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// NetID is a stable global id of a entity that is independent of
+// the local EntityID. It can be used to reference the same logical
+// entity across different ECS instances, like a server and its clients.
+type NetID uint64
+
+const (
+	NetIDNone = NetID(0)
+)
+
+func (ecs *ECS) bindNetID(id EntityID, netID NetID) {
+	ecs.netIDs[netID] = id
+	ecs.entityNetIDs[id] = netID
+}
+
+func (ecs *ECS) unbindNetID(id EntityID) {
+	if netID, ok := ecs.entityNetIDs[id]; ok {
+		delete(ecs.netIDs, netID)
+		delete(ecs.entityNetIDs, id)
+	}
+}
+
+func randomNetID() (NetID, error) {
+	var buf [8]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return NetIDNone, err
+	}
+	return NetID(binary.LittleEndian.Uint64(buf[:])), nil
+}
+
+// AssignNetID assigns a random network id to the entity. If the
+// entity already has a network id it will be returned instead.
+func (ecs *ECS) AssignNetID(id EntityID) (NetID, error) {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	if _, _, ok := ecs.findEntity(id); !ok {
+		return NetIDNone, ErrNotFound
+	}
+
+	if netID, ok := ecs.entityNetIDs[id]; ok {
+		return netID, nil
+	}
+
+	netID, err := ecs.assignNetID(id)
+	if err == nil {
+		// The network id is part of the state that is
+		// compared and sent, e.g. by a Replicator.
+		ecs.markDirty(id)
+	}
+	return netID, err
+}
+
+// assignNetID implements AssignNetID. The caller needs to hold the
+// write lock and to make sure that the entity exists.
+func (ecs *ECS) assignNetID(id EntityID) (NetID, error) {
+	if netID, ok := ecs.entityNetIDs[id]; ok {
+		return netID, nil
+	}
+
+	for {
+		netID, err := randomNetID()
+		if err != nil {
+			return NetIDNone, err
+		}
+
+		if _, ok := ecs.netIDs[netID]; netID == NetIDNone || ok {
+			continue
+		}
+
+		ecs.bindNetID(id, netID)
+		return netID, nil
+	}
+}
+
+// SetNetID binds a known network id to the entity. This is used on the
+// receiving side to link a local entity to the one of the sender.
+func (ecs *ECS) SetNetID(id EntityID, netID NetID) error {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	if netID == NetIDNone {
+		return ErrNoID
+	}
+
+	if _, _, ok := ecs.findEntity(id); !ok {
+		return ErrNotFound
+	}
+
+	if other, ok := ecs.netIDs[netID]; ok && other != id {
+		return ErrAlreadyExists
+	}
+
+	if current, ok := ecs.entityNetIDs[id]; ok && current == netID {
+		return nil
+	}
+
+	ecs.unbindNetID(id)
+	ecs.bindNetID(id, netID)
+	ecs.markDirty(id)
+
+	return nil
+}
+
+// NetID returns the network id of the entity.
+func (ecs *ECS) NetID(id EntityID) (NetID, bool) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	netID, ok := ecs.entityNetIDs[id]
+	return netID, ok
+}
+
+// LocalID returns the local EntityID that belongs to the network id.
+func (ecs *ECS) LocalID(netID NetID) (EntityID, bool) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	id, ok := ecs.netIDs[netID]
+	return id, ok
+}
+
+// GetByNetID fetches a Entity by its network id.
+func (ecs *ECS) GetByNetID(netID NetID) (*EntityWrap, error) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	if id, ok := ecs.netIDs[netID]; ok {
+		if v, _, ok := ecs.findEntity(id); ok {
+			return &EntityWrap{parent: ecs, ent: v.Ent}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
@@ -0,0 +1,81 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_NetID(t *testing.T) {
+	ecs := New()
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "net"}})
+	netID, err := ecs.AssignNetID(id)
+	if !assert.NoError(t, err, "couldn't assign net id") {
+		return
+	}
+
+	again, _ := ecs.AssignNetID(id)
+	assert.Equal(t, netID, again, "net id changed on second assign")
+
+	ew, err := ecs.GetByNetID(netID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, id, ew.GetEntity().ID())
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf), "couldn't marshal ECS")
+
+	loaded := New()
+	loaded.RegisterEntity(&Unit{})
+	assert.NoError(t, loaded.Unmarshal(buf), "couldn't unmarshal ECS")
+
+	localID, ok := loaded.LocalID(netID)
+	assert.True(t, ok, "net id wasn't preserved")
+	assert.Equal(t, id, localID)
+
+	client := New()
+	client.RegisterEntity(&Unit{})
+	payload, _ := NewReplicator(ecs).Tick()
+	assert.NoError(t, client.ApplyReplication(payload))
+	_, err = client.GetByNetID(netID)
+	assert.NoError(t, err, "net id wasn't replicated")
+
+	other, _ := ecs.AddEntity(&Unit{})
+	assert.Equal(t, ErrAlreadyExists, ecs.SetNetID(other, netID), "net id was bound twice")
+
+	_ = ecs.RemoveEntity(ew.GetEntity())
+	_, err = ecs.GetByNetID(netID)
+	assert.Equal(t, ErrNotFound, err, "net id wasn't released on removal")
+}
+
+func TestECS_NetIDReplication(t *testing.T) {
+	server := New()
+	client := New()
+	client.RegisterEntity(&Unit{})
+
+	id, _ := server.AddEntity(&Unit{Name: Name{Value: "net"}})
+	rep := NewReplicator(server)
+	payload, _ := rep.Tick()
+	assert.NoError(t, client.ApplyReplication(payload))
+
+	// Binding a new network id marks the entity, so the
+	// receiving side is relinked with the next tick.
+	assert.NoError(t, server.SetNetID(id, 42))
+	payload, err := rep.Tick()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Len(t, payload.Destroyed, 1)
+	assert.Len(t, payload.Created, 1)
+	assert.NoError(t, client.ApplyReplication(payload))
+
+	ew, err := client.GetByNetID(42)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "net", ew.GetEntity().(*Unit).Name.Value)
+	}
+	assert.Equal(t, 1, client.Len())
+
+	payload, _ = rep.Tick()
+	assert.True(t, payload.Empty(), "unchanged network id was sent again")
+}
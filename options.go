@@ -0,0 +1,174 @@
+package kinshi
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+)
+
+// Option configures a ECS on creation.
+type Option func(ecs *ECS)
+
+// afterOptions runs fn once all options that are passed to New are
+// applied, so that options which derive the names of components use the
+// namer of WithTypeNamer regardless of the order of the options. If the
+// ECS is already created fn runs right away.
+func (ecs *ECS) afterOptions(fn func()) {
+	if ecs.pending != nil {
+		ecs.pending = append(ecs.pending, fn)
+		return
+	}
+	fn()
+}
+
+// WithStrict enables the strict mode. In strict mode misuse that would
+// otherwise silently be ignored or only be reported by a easy to drop
+// error results in a panic, so that mistakes surface during development.
+// This includes:
+//   - adding non-pointer entities or entities with a already used id
+//   - views that request missing components
+//   - queries with types that can't be components (e.g. ints)
+//   - mutating the ECS while a View is running, which would dead lock
+//   - unknown entity types or undecodable components in Unmarshal
+//
+// Views are tracked per go routine, so other go routines can mutate the
+// ECS while a view is running. Tracking them is slow though, so strict
+// mode is meant for development and tests.
+func WithStrict() Option {
+	return func(ecs *ECS) {
+		ecs.strict = true
+	}
+}
+
+// WithCapacity preallocates the storage for n entities, so that large
+// worlds don't repeatedly grow it while they are generated.
+func WithCapacity(n int) Option {
+	return func(ecs *ECS) {
+		if n <= 0 {
+			return
+		}
+
+		ecs.entities = make([]entityEntry, 0, n)
+	}
+}
+
+// WithRoutines sets the number of go routines that are used to
+// parallelize searches. See SetRoutineCount.
+func WithRoutines(n int) Option {
+	return func(ecs *ECS) {
+		if n > 0 {
+			ecs.routines = n
+		}
+	}
+}
+
+// WithParallelThreshold sets the number of entities below which
+// searches aren't parallelized. See SetParallelThreshold.
+func WithParallelThreshold(n int) Option {
+	return func(ecs *ECS) {
+		ecs.parThreshold = n
+	}
+}
+
+// misuse returns err, but panics with it in strict mode.
+func (ecs *ECS) misuse(err error) error {
+	if ecs.strict && err != nil {
+		panic(fmt.Sprintf("kinshi: %s", err))
+	}
+	return err
+}
+
+// goroutineID returns the id of the calling go routine. Go doesn't expose
+// it, so it's parsed from the header of the stack trace, which is why
+// views are only tracked in strict mode.
+func goroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// enterView records in strict mode that the calling go routine runs a
+// view. The views are tracked per go routine, because only a mutation
+// from inside the view dead locks, while mutations from other go
+// routines just wait for the view.
+func (ecs *ECS) enterView() {
+	if !ecs.strict {
+		return
+	}
+
+	id := goroutineID()
+
+	ecs.viewsMtx.Lock()
+	defer ecs.viewsMtx.Unlock()
+
+	if ecs.views == nil {
+		ecs.views = map[uint64]int{}
+	}
+	ecs.views[id] += 1
+}
+
+func (ecs *ECS) leaveView() {
+	if !ecs.strict {
+		return
+	}
+
+	id := goroutineID()
+
+	ecs.viewsMtx.Lock()
+	defer ecs.viewsMtx.Unlock()
+
+	if ecs.views[id] <= 1 {
+		delete(ecs.views, id)
+	} else {
+		ecs.views[id] -= 1
+	}
+}
+
+// checkMutation panics in strict mode if the calling go
+// routine is currently running a View.
+func (ecs *ECS) checkMutation() {
+	if !ecs.strict {
+		return
+	}
+
+	id := goroutineID()
+
+	ecs.viewsMtx.Lock()
+	running := ecs.views[id] > 0
+	ecs.viewsMtx.Unlock()
+
+	if running {
+		panic("kinshi: ECS mutated while a view is running, use a CommandBuffer instead")
+	}
+}
+
+// checkQueryTypes panics in strict mode if one of the queried types
+// isn't a component type or a component name.
+func (ecs *ECS) checkQueryTypes(types ...interface{}) {
+	if !ecs.strict {
+		return
+	}
+
+	for i := range types {
+		if _, ok := types[i].(string); ok {
+			continue
+		}
+
+		t := reflect.TypeOf(types[i])
+		if t != nil && t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+
+		if t == nil || !(isComponentType(t) || t.Kind() == reflect.Interface && t.Name() != "") {
+			panic(fmt.Sprintf("kinshi: query type '%v' isn't a component", reflect.TypeOf(types[i])))
+		}
+	}
+}
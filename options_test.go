@@ -0,0 +1,84 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestWithStrict(t *testing.T) {
+	ecs := New(WithStrict())
+
+	id, err := ecs.AddEntity(&Unit{})
+	assert.NoError(t, err)
+
+	assert.Panics(t, func() {
+		_, _ = ecs.AddEntity(&Unit{BaseEntity: BaseEntity{id: id}})
+	}, "adding existing id didn't panic")
+
+	assert.Panics(t, func() {
+		_ = ecs.MustGet(id).View(func(v *Velocity) {})
+	}, "view on missing component didn't panic")
+
+	assert.Panics(t, func() {
+		ecs.Iterate(5)
+	}, "query with int didn't panic")
+
+	assert.Panics(t, func() {
+		_ = ecs.MustGet(id).View(func(p *Pos) {
+			_, _ = ecs.AddEntity(&Unit{})
+		})
+	}, "mutation inside view didn't panic")
+
+	// Other go routines can mutate the ECS while a
+	// view is running, they just wait until it's done
+	done := make(chan error)
+	_ = ecs.MustGet(id).View(func(p *Pos) {
+		go func() {
+			_, err := ecs.AddEntity(&Unit{})
+			done <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+	})
+	assert.NoError(t, <-done)
+
+	// Mutations through a command buffer are fine
+	cb := ecs.NewCommandBuffer()
+	assert.NotPanics(t, func() {
+		_ = ecs.MustGet(id).View(func(p *Pos) {
+			_, _ = cb.AddEntity(&Unit{})
+		})
+	})
+	assert.NoError(t, cb.Flush())
+
+	buf := bytes.NewBufferString(`[{"ID": 1, "Type": "Unknown", "Components": {}}]`)
+	assert.Error(t, ecs.Unmarshal(buf), "unknown type didn't fail")
+
+	// Without strict mode the same misuse is silent
+	lax := New()
+	id, _ = lax.AddEntity(&Unit{})
+	assert.NotPanics(t, func() {
+		_ = lax.MustGet(id).View(func(v *Velocity) {})
+		lax.Iterate(5)
+	})
+	buf = bytes.NewBufferString(`[{"ID": 1, "Type": "Unknown", "Components": {}}]`)
+	assert.NoError(t, lax.Unmarshal(buf))
+}
+
+func TestWithCapacity(t *testing.T) {
+	ecs := New(WithCapacity(1000), WithRoutines(4), WithParallelThreshold(0))
+	assert.Equal(t, 1000, cap(ecs.entities))
+	assert.Equal(t, 4, ecs.routines)
+	assert.Equal(t, 0, ecs.parThreshold)
+
+	for i := 0; i < 1000; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+	assert.Equal(t, 1000, cap(ecs.entities), "storage was grown")
+	assert.Equal(t, 1000, ecs.Iterate(Pos{}).Count())
+	ecs.Close()
+
+	ecs = New(WithCapacity(-1), WithRoutines(0))
+	assert.Equal(t, 1, ecs.routines)
+}
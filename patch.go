@@ -0,0 +1,339 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// patchOperation is a single operation of a RFC 6902 JSON Patch.
+type patchOperation struct {
+	Op    string
+	Path  string
+	From  string
+	Value json.RawMessage
+}
+
+// PatchEntity applies a patch to the serialized form of the entity, see
+// MarshalEntity, and writes the result back like UnmarshalEntity. The
+// patch is either a JSON Patch (RFC 6902), which is a array of operations,
+// or a JSON Merge Patch (RFC 7386), which is a object. Nothing is changed
+// if the patch fails or the result can't be decoded. If the entity was
+// changed while the patch was applied a error wrapping ErrVersion is
+// returned and the patch can be retried.
+//
+// For example you want to move a entity and remove a component:
+//    err := ecs.PatchEntity(id, []byte(`[
+//        {"op": "replace", "path": "/Components/Pos/X", "value": 10},
+//        {"op": "remove", "path": "/Components/Poisoned"}
+//    ]`))
+//
+// Or the same move as merge patch:
+//    err := ecs.PatchEntity(id, []byte(`{"Components": {"Pos": {"X": 10}}}`))
+func (ecs *ECS) PatchEntity(id EntityID, patch []byte) error {
+	data, err := ecs.MarshalEntity(id)
+	if err != nil {
+		return err
+	}
+
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	trimmed := bytes.TrimSpace(patch)
+	switch {
+	case len(trimmed) > 0 && trimmed[0] == '[':
+		var ops []patchOperation
+		if err := json.Unmarshal(trimmed, &ops); err != nil {
+			return fmt.Errorf("patch of entity %d: %w", id, err)
+		}
+
+		for i := range ops {
+			if doc, err = applyPatchOperation(doc, ops[i]); err != nil {
+				return fmt.Errorf("patch of entity %d: operation %d: %w", id, i, err)
+			}
+		}
+	case len(trimmed) > 0 && trimmed[0] == '{':
+		var merge interface{}
+		if err := json.Unmarshal(trimmed, &merge); err != nil {
+			return fmt.Errorf("patch of entity %d: %w", id, err)
+		}
+		doc = mergePatch(doc, merge)
+	default:
+		return fmt.Errorf("patch of entity %d: patch is neither a array nor a object", id)
+	}
+
+	obj, ok := doc.(map[string]interface{})
+	if !ok || !reflect.DeepEqual(obj["ID"], float64(id)) {
+		return fmt.Errorf("patch of entity %d: the id can't be changed", id)
+	}
+
+	// The version of the original data makes sure that changes in
+	// between aren't overwritten.
+	var original struct{ Version uint64 }
+	_ = json.Unmarshal(data, &original)
+	obj["Version"] = original.Version
+
+	if data, err = json.Marshal(obj); err != nil {
+		return err
+	}
+
+	_, err = ecs.UnmarshalEntity(data)
+	return err
+}
+
+// mergePatch applies a RFC 7386 merge patch to doc.
+func mergePatch(doc interface{}, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	d, ok := doc.(map[string]interface{})
+	if !ok {
+		d = map[string]interface{}{}
+	}
+
+	for k, v := range p {
+		if v == nil {
+			delete(d, k)
+			continue
+		}
+		d[k] = mergePatch(d[k], v)
+	}
+
+	return d
+}
+
+// splitPointer splits a RFC 6901 JSON Pointer into its unescaped tokens.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid path '%s'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[i])
+	}
+	return tokens, nil
+}
+
+// arrayIndex parses the token as index into a array of length n. If end
+// is true the index n, or "-", which appends, is allowed.
+func arrayIndex(token string, n int, end bool) (int, error) {
+	if token == "-" && end {
+		return n, nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > n || i == n && !end || len(token) > 1 && token[0] == '0' {
+		return 0, fmt.Errorf("invalid index '%s'", token)
+	}
+	return i, nil
+}
+
+// pointerGet returns the value at the path.
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			val, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("'%s': %w", token, ErrNotFound)
+			}
+			doc = val
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[i]
+		default:
+			return nil, fmt.Errorf("'%s': %w", token, ErrNotFound)
+		}
+	}
+	return doc, nil
+}
+
+// pointerUpdate calls fn with the container of the last token of path
+// and replaces it with the result.
+func pointerUpdate(doc interface{}, path []string, fn func(container interface{}, token string) (interface{}, error)) (interface{}, error) {
+	if len(path) == 1 {
+		return fn(doc, path[0])
+	}
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("'%s': %w", path[0], ErrNotFound)
+		}
+
+		updated, err := pointerUpdate(child, path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[path[0]] = updated
+		return v, nil
+	case []interface{}:
+		i, err := arrayIndex(path[0], len(v), false)
+		if err != nil {
+			return nil, err
+		}
+
+		updated, err := pointerUpdate(v[i], path[1:], fn)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = updated
+		return v, nil
+	}
+
+	return nil, fmt.Errorf("'%s': %w", path[0], ErrNotFound)
+}
+
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	return pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			v[token] = value
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), true)
+			if err != nil {
+				return nil, err
+			}
+
+			v = append(v, nil)
+			copy(v[i+1:], v[i:])
+			v[i] = value
+			return v, nil
+		}
+		return nil, fmt.Errorf("'%s': %w", token, ErrNotFound)
+	})
+}
+
+func pointerRemove(doc interface{}, path []string) (interface{}, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("the whole document can't be removed")
+	}
+
+	return pointerUpdate(doc, path, func(container interface{}, token string) (interface{}, error) {
+		switch v := container.(type) {
+		case map[string]interface{}:
+			if _, ok := v[token]; !ok {
+				return nil, fmt.Errorf("'%s': %w", token, ErrNotFound)
+			}
+			delete(v, token)
+			return v, nil
+		case []interface{}:
+			i, err := arrayIndex(token, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			return append(v[:i], v[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("'%s': %w", token, ErrNotFound)
+	})
+}
+
+// deepCopyJSON copies a generic JSON value, so that copied values don't
+// share maps and slices with the original.
+func deepCopyJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(v))
+		for k := range v {
+			c[k] = deepCopyJSON(v[k])
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(v))
+		for i := range v {
+			c[i] = deepCopyJSON(v[i])
+		}
+		return c
+	}
+	return v
+}
+
+func applyPatchOperation(doc interface{}, op patchOperation) (interface{}, error) {
+	path, err := splitPointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch op.Op {
+	case "add", "replace", "test":
+		if len(op.Value) == 0 {
+			return nil, fmt.Errorf("%s needs a value", op.Op)
+		}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, err
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return pointerAdd(doc, path, value)
+	case "remove":
+		return pointerRemove(doc, path)
+	case "replace":
+		if _, err := pointerGet(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		if doc, err = pointerRemove(doc, path); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+	case "move", "copy":
+		from, err := splitPointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+
+		value, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+
+		if op.Op == "move" {
+			if strings.HasPrefix(op.Path+"/", op.From+"/") && op.Path != op.From {
+				return nil, fmt.Errorf("'%s' can't be moved into itself", op.From)
+			}
+			if doc, err = pointerRemove(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			value = deepCopyJSON(value)
+		}
+		return pointerAdd(doc, path, value)
+	case "test":
+		current, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, fmt.Errorf("test of '%s' failed", op.Path)
+		}
+		return doc, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation '%s'", op.Op)
+}
@@ -0,0 +1,101 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestPatchEntity(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Pos{}))
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+
+	id, _ := ecs.AddEntity(&Unit{Health: Health{Value: 5, Max: 10}, Name: Name{Value: "Gandalf"}})
+
+	// JSON Patch
+	assert.NoError(t, ecs.PatchEntity(id, []byte(`[
+		{"op": "test", "path": "/Components/Name/Value", "value": "Gandalf"},
+		{"op": "replace", "path": "/Components/Name/Value", "value": "Saruman"},
+		{"op": "copy", "from": "/Components/Health/Max", "path": "/Components/Health/Value"}
+	]`)))
+	unit := ecs.MustGet(id).GetEntity().(*Unit)
+	assert.Equal(t, "Saruman", unit.Name.Value)
+	assert.Equal(t, 10, unit.Health.Value)
+
+	// A failing test doesn't change anything.
+	assert.Error(t, ecs.PatchEntity(id, []byte(`[
+		{"op": "replace", "path": "/Components/Name/Value", "value": "Radagast"},
+		{"op": "test", "path": "/Components/Health/Value", "value": 1}
+	]`)))
+	assert.Equal(t, "Saruman", ecs.MustGet(id).GetEntity().(*Unit).Name.Value)
+
+	// JSON Merge Patch
+	assert.NoError(t, ecs.PatchEntity(id, []byte(`{"Components": {"Health": {"Value": 3}}}`)))
+	unit = ecs.MustGet(id).GetEntity().(*Unit)
+	assert.Equal(t, 3, unit.Health.Value)
+	assert.Equal(t, 10, unit.Health.Max)
+	assert.Equal(t, "Saruman", unit.Name.Value)
+
+	// Dynamic components can be added, moved and removed.
+	dyn, _ := ecs.AddEntity(&DynamicUnit{})
+	assert.NoError(t, ecs.PatchEntity(dyn, []byte(`[{"op": "add", "path": "/Components", "value": {"Pos": {"X": 2}}}]`)))
+	assert.True(t, ecs.MustGet(dyn).Has(Pos{}))
+	assert.NoError(t, ecs.PatchEntity(dyn, []byte(`[{"op": "move", "from": "/Components/Pos", "path": "/Components/Velocity"}]`)))
+	assert.False(t, ecs.MustGet(dyn).Has(Pos{}))
+	assert.True(t, ecs.MustGet(dyn).Has(Velocity{}))
+	assert.NoError(t, ecs.MustGet(dyn).View(func(vel *Velocity) {
+		assert.Equal(t, 2.0, vel.X)
+	}))
+	assert.NoError(t, ecs.PatchEntity(dyn, []byte(`{"Components": {"Velocity": null}}`)))
+	assert.False(t, ecs.MustGet(dyn).Has(Velocity{}))
+
+	assert.Error(t, ecs.PatchEntity(id, []byte(`{"ID": 42}`)))
+	assert.Error(t, ecs.PatchEntity(id, []byte(`[{"op": "remove", "path": "/Components/Missing"}]`)))
+	assert.Error(t, ecs.PatchEntity(id, []byte(`[{"op": "jump", "path": "/ID"}]`)))
+	assert.Error(t, ecs.PatchEntity(id, []byte(`[{"op": "replace", "path": "/Components/Health/Value", "value": "low"}]`)))
+	assert.Error(t, ecs.PatchEntity(id, []byte(`"Saruman"`)))
+	assert.ErrorIs(t, ecs.PatchEntity(42, []byte(`{}`)), ErrNotFound)
+	assert.Equal(t, 2, ecs.Count())
+}
+
+func TestApplyPatchOperation(t *testing.T) {
+	doc := func() interface{} {
+		return map[string]interface{}{
+			"a/b":  1.0,
+			"m~n":  2.0,
+			"list": []interface{}{"x", "y"},
+		}
+	}
+
+	res, err := applyPatchOperation(doc(), patchOperation{Op: "add", Path: "/list/1", Value: []byte(`"z"`)})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"x", "z", "y"}, res.(map[string]interface{})["list"])
+
+	res, err = applyPatchOperation(doc(), patchOperation{Op: "add", Path: "/list/-", Value: []byte(`"z"`)})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"x", "y", "z"}, res.(map[string]interface{})["list"])
+
+	res, err = applyPatchOperation(doc(), patchOperation{Op: "remove", Path: "/list/0"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"y"}, res.(map[string]interface{})["list"])
+
+	res, err = applyPatchOperation(doc(), patchOperation{Op: "remove", Path: "/a~1b"})
+	assert.NoError(t, err)
+	assert.NotContains(t, res, "a/b")
+
+	_, err = applyPatchOperation(doc(), patchOperation{Op: "test", Path: "/m~0n", Value: []byte(`2`)})
+	assert.NoError(t, err)
+
+	_, err = applyPatchOperation(doc(), patchOperation{Op: "remove", Path: "/list/2"})
+	assert.Error(t, err)
+	_, err = applyPatchOperation(doc(), patchOperation{Op: "add", Path: "/list/01", Value: []byte(`"z"`)})
+	assert.Error(t, err)
+	_, err = applyPatchOperation(doc(), patchOperation{Op: "add", Path: "list", Value: []byte(`"z"`)})
+	assert.Error(t, err)
+	_, err = applyPatchOperation(doc(), patchOperation{Op: "move", From: "/list", Path: "/list/0"})
+	assert.Error(t, err)
+	_, err = applyPatchOperation(doc(), patchOperation{Op: "replace", Path: "/missing", Value: []byte(`1`)})
+	assert.Error(t, err)
+}
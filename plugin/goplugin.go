@@ -0,0 +1,66 @@
+package plugin
+
+import (
+	"fmt"
+	goplugin "plugin"
+)
+
+// GoSystem is a system that is loaded from a Go plugin. Go plugins are
+// only supported on some platforms, see the documentation of the plugin
+// package of the standard library.
+//
+// The plugin needs to export the ABI version and a run function that only
+// use types of the standard library, so it doesn't need to be built with
+// the same version of kinshi:
+//    var KinshiABIVersion = 1
+//
+//    func Run(call func(op string, req []byte) ([]byte, error)) error {
+//        _, err := call("remove", []byte(`{"id": 1}`))
+//        return err
+//    }
+type GoSystem struct {
+	name string
+	host *Host
+	run  func(call func(op string, req []byte) ([]byte, error)) error
+}
+
+// LoadGoPlugin opens the Go plugin at path and checks its ABI version.
+func LoadGoPlugin(host *Host, name string, path string) (*GoSystem, error) {
+	p, err := goplugin.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("system '%s': %w", name, err)
+	}
+
+	version, err := p.Lookup("KinshiABIVersion")
+	if err != nil {
+		return nil, fmt.Errorf("system '%s': %w", name, err)
+	}
+	if v, ok := version.(*int); !ok || *v != ABIVersion {
+		return nil, fmt.Errorf("system '%s': unsupported abi version", name)
+	}
+
+	run, err := p.Lookup("Run")
+	if err != nil {
+		return nil, fmt.Errorf("system '%s': %w", name, err)
+	}
+
+	fn, ok := run.(func(call func(op string, req []byte) ([]byte, error)) error)
+	if !ok {
+		return nil, fmt.Errorf("system '%s': Run has the wrong signature", name)
+	}
+
+	return &GoSystem{name: name, host: host, run: fn}, nil
+}
+
+// Name implements System.
+func (sys *GoSystem) Name() string {
+	return sys.name
+}
+
+// Run implements System.
+func (sys *GoSystem) Run() error {
+	if err := sys.run(sys.host.Call); err != nil {
+		return fmt.Errorf("system '%s': %w", sys.name, err)
+	}
+	return nil
+}
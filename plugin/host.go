@@ -0,0 +1,167 @@
+// Package plugin hosts systems that are compiled to WebAssembly or loaded
+// as Go plugins, so that mods can add behavior to a game built on kinshi
+// without access to its source.
+//
+// Plugins talk to the ECS through a small ABI that only passes strings
+// and JSON, so it stays stable when the game or kinshi change and
+// plugins don't need to be compiled against the same version. A call
+// names a operation and carries a JSON request. The response is a JSON
+// object, which holds a "error" field if the operation failed.
+//
+// The operations are:
+//    query   {"components": ["Pos"]}                      -> {"ids": [1, 2]}
+//    select  {"query": "SELECT Pos WHERE Pos.X > 0"}      -> {"ids": [1]}
+//    get     {"id": 1, "component": "Pos"}                -> {"value": {"X": 1, "Y": 2}}
+//    set     {"id": 1, "component": "Pos", "value": {}}   -> {}
+//    spawn   {"type": "Unit", "components": {"Pos": {}}}  -> {"id": 3}
+//    remove  {"id": 1}                                    -> {}
+//
+// The value of get is null if the entity doesn't have the component.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+)
+
+// ABIVersion is the version of the ABI that plugins are checked against.
+const ABIVersion = 1
+
+// System is a system that is provided by a plugin.
+type System interface {
+	// Name returns the name of the system.
+	Name() string
+	// Run runs the system once, usually once per tick.
+	Run() error
+}
+
+// Host executes the operations of the ABI on a ECS.
+type Host struct {
+	ecs *kinshi.ECS
+}
+
+// NewHost creates a new host for the ECS.
+func NewHost(ecs *kinshi.ECS) *Host {
+	return &Host{ecs: ecs}
+}
+
+type request struct {
+	ID         kinshi.EntityID
+	Components json.RawMessage
+	Component  string
+	Query      string
+	Type       string
+	Value      json.RawMessage
+}
+
+// Call executes the operation op with the JSON request req and returns
+// the JSON response. Errors are returned as error and not in the response.
+func (h *Host) Call(op string, req []byte) ([]byte, error) {
+	var r request
+	if err := json.Unmarshal(req, &r); err != nil {
+		return nil, fmt.Errorf("%s: invalid request: %w", op, err)
+	}
+
+	res, err := h.call(op, r)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if res == nil {
+		res = struct{}{}
+	}
+	return json.Marshal(res)
+}
+
+// response is the JSON response of Call. Errors are included for the
+// runtimes that can only pass the response.
+func (h *Host) response(op string, req []byte) []byte {
+	res, err := h.Call(op, req)
+	if err != nil {
+		res, _ = json.Marshal(map[string]string{"error": err.Error()})
+	}
+	return res
+}
+
+func idsOf(it kinshi.EntityIterator) interface{} {
+	ids := make([]kinshi.EntityID, len(it))
+	for i := range it {
+		ids[i] = it[i].GetEntity().ID()
+	}
+	return map[string]interface{}{"ids": ids}
+}
+
+func (h *Host) call(op string, r request) (interface{}, error) {
+	switch op {
+	case "query":
+		var names []string
+		if err := unmarshalOptional(r.Components, &names); err != nil {
+			return nil, err
+		}
+
+		types := make([]interface{}, len(names))
+		for i := range names {
+			types[i] = names[i]
+		}
+		return idsOf(h.ecs.Iterate(types...)), nil
+	case "select":
+		it, err := h.ecs.QueryString(r.Query)
+		if err != nil {
+			return nil, err
+		}
+		return idsOf(it), nil
+	case "get":
+		ew, err := h.ecs.Get(r.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		names := ew.Components()
+		values := ew.ComponentValues()
+		for i := range names {
+			if names[i] == r.Component {
+				return map[string]interface{}{"value": values[i]}, nil
+			}
+		}
+		return map[string]interface{}{"value": nil}, nil
+	case "set":
+		ew, err := h.ecs.Get(r.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var value interface{}
+		if err := unmarshalOptional(r.Value, &value); err != nil {
+			return nil, err
+		}
+		return nil, ew.Decode(r.Component, value)
+	case "spawn":
+		var components map[string]interface{}
+		if err := unmarshalOptional(r.Components, &components); err != nil {
+			return nil, err
+		}
+
+		ent, err := h.ecs.NewEntity(r.Type, components)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := h.ecs.AddEntity(ent)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"id": id}, nil
+	case "remove":
+		return nil, h.ecs.RemoveByID(r.ID)
+	}
+
+	return nil, fmt.Errorf("unknown operation")
+}
+
+func unmarshalOptional(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,56 @@
+package plugin
+
+import (
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Unit struct {
+	kinshi.BaseEntity
+	Pos
+}
+
+func TestHost(t *testing.T) {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	host := NewHost(ecs)
+
+	call := func(op string, req string) string {
+		res, err := host.Call(op, []byte(req))
+		assert.NoError(t, err, op)
+		return string(res)
+	}
+
+	assert.Equal(t, `{"id":1}`, call("spawn", `{"type": "Unit", "components": {"Pos": {"X": 1, "Y": 2}}}`))
+	assert.Equal(t, `{"id":2}`, call("spawn", `{"type": "Unit"}`))
+	assert.Equal(t, `{"ids":[1,2]}`, call("query", `{"components": ["Pos"]}`))
+	assert.Equal(t, `{"value":{"X":1,"Y":2}}`, call("get", `{"id": 1, "component": "Pos"}`))
+	assert.Equal(t, `{"value":null}`, call("get", `{"id": 1, "component": "Velocity"}`))
+	assert.Equal(t, `{}`, call("set", `{"id": 2, "component": "Pos", "value": {"X": 5}}`))
+	assert.Equal(t, `{"ids":[2]}`, call("select", `{"query": "SELECT Pos WHERE Pos.X > 1"}`))
+	assert.Equal(t, `{}`, call("remove", `{"id": 1}`))
+	assert.Equal(t, `{"ids":[2]}`, call("query", `{}`))
+
+	for op, req := range map[string]string{
+		"remove":  `{"id": 1}`,
+		"get":     `{"id": 1, "component": "Pos"}`,
+		"set":     `{"id": 2, "component": "Pos", "value": {"X": "left"}}`,
+		"spawn":   `{"type": "Missing"}`,
+		"select":  `{"query": "SELECT"}`,
+		"query":   `{"components": 1}`,
+		"unknown": `{}`,
+	} {
+		_, err := host.Call(op, []byte(req))
+		assert.Error(t, err, op)
+	}
+
+	_, err := host.Call("query", []byte(`not json`))
+	assert.Error(t, err)
+	assert.Contains(t, string(host.response("unknown", []byte(`{}`))), `"error"`)
+}
@@ -0,0 +1,13 @@
+;; Source of remove.wasm, a system that removes the entity with the id 1.
+(module
+  (import "kinshi" "call" (func $call (param i32 i32 i32 i32) (result i32)))
+  (import "kinshi" "response" (func $response (param i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "remove")
+  (data (i32.const 16) "{\"id\":1}")
+  (func (export "kinshi_abi_version") (result i32)
+    i32.const 1)
+  (func (export "run") (result i32)
+    (drop (call $call (i32.const 0) (i32.const 6) (i32.const 16) (i32.const 8)))
+    (call $response (i32.const 64))
+    i32.const 0))
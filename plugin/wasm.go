@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"sync"
+)
+
+// WASMSystem is a system that is compiled to WebAssembly.
+//
+// The module imports the functions of the ABI from the module "kinshi":
+//    call(op_ptr, op_len, req_ptr, req_len i32) i32
+//        executes the operation and returns the length of the response
+//    response(ptr i32)
+//        copies the response of the last call to ptr
+//
+// And exports:
+//    memory                    the memory that the pointers refer to
+//    kinshi_abi_version() i32  the ABI version the module was built for
+//    run() i32                 runs the system, a result other than 0 is a error
+type WASMSystem struct {
+	mtx      sync.Mutex
+	name     string
+	host     *Host
+	runtime  wazero.Runtime
+	module   api.Module
+	run      api.Function
+	response []byte
+}
+
+// LoadWASM instantiates the WebAssembly module and checks its ABI
+// version. The system needs to be closed to free the runtime.
+//
+// For example:
+//    sys, err := plugin.LoadWASM(ctx, plugin.NewHost(ecs), "poison", wasm)
+//    // Each tick
+//    err = sys.Run()
+func LoadWASM(ctx context.Context, host *Host, name string, binary []byte) (*WASMSystem, error) {
+	sys := &WASMSystem{
+		name:    name,
+		host:    host,
+		runtime: wazero.NewRuntime(ctx),
+	}
+
+	_, err := sys.runtime.NewHostModuleBuilder("kinshi").
+		NewFunctionBuilder().WithFunc(sys.call).Export("call").
+		NewFunctionBuilder().WithFunc(sys.copyResponse).Export("response").
+		Instantiate(ctx)
+	if err != nil {
+		_ = sys.runtime.Close(ctx)
+		return nil, fmt.Errorf("system '%s': %w", name, err)
+	}
+
+	if err := sys.instantiate(ctx, binary); err != nil {
+		_ = sys.runtime.Close(ctx)
+		return nil, fmt.Errorf("system '%s': %w", name, err)
+	}
+
+	return sys, nil
+}
+
+func (sys *WASMSystem) instantiate(ctx context.Context, binary []byte) error {
+	module, err := sys.runtime.InstantiateWithConfig(ctx, binary, wazero.NewModuleConfig().WithName(sys.name))
+	if err != nil {
+		return err
+	}
+	sys.module = module
+
+	version := module.ExportedFunction("kinshi_abi_version")
+	if version == nil {
+		return fmt.Errorf("missing export 'kinshi_abi_version'")
+	}
+
+	res, err := version.Call(ctx)
+	if err != nil {
+		return err
+	}
+	if len(res) != 1 || uint32(res[0]) != ABIVersion {
+		return fmt.Errorf("unsupported abi version %v", res)
+	}
+
+	sys.run = module.ExportedFunction("run")
+	if sys.run == nil {
+		return fmt.Errorf("missing export 'run'")
+	}
+
+	return nil
+}
+
+func (sys *WASMSystem) call(ctx context.Context, m api.Module, opPtr, opLen, reqPtr, reqLen uint32) uint32 {
+	op, ok := m.Memory().Read(opPtr, opLen)
+	if !ok {
+		panic(fmt.Errorf("operation out of memory range"))
+	}
+
+	req, ok := m.Memory().Read(reqPtr, reqLen)
+	if !ok {
+		panic(fmt.Errorf("request out of memory range"))
+	}
+
+	sys.response = sys.host.response(string(op), req)
+	return uint32(len(sys.response))
+}
+
+func (sys *WASMSystem) copyResponse(ctx context.Context, m api.Module, ptr uint32) {
+	if !m.Memory().Write(ptr, sys.response) {
+		panic(fmt.Errorf("response out of memory range"))
+	}
+}
+
+// Name implements System.
+func (sys *WASMSystem) Name() string {
+	return sys.name
+}
+
+// Run implements System.
+func (sys *WASMSystem) Run() error {
+	sys.mtx.Lock()
+	defer sys.mtx.Unlock()
+
+	res, err := sys.run.Call(context.Background())
+	if err != nil {
+		return fmt.Errorf("system '%s': %w", sys.name, err)
+	}
+
+	if len(res) == 1 && uint32(res[0]) != 0 {
+		return fmt.Errorf("system '%s': run returned %d", sys.name, int32(res[0]))
+	}
+
+	return nil
+}
+
+// Close frees the runtime of the system.
+func (sys *WASMSystem) Close(ctx context.Context) error {
+	return sys.runtime.Close(ctx)
+}
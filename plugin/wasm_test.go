@@ -0,0 +1,41 @@
+package plugin
+
+import (
+	"context"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"testing"
+)
+
+func TestLoadWASM(t *testing.T) {
+	binary, err := ioutil.ReadFile("testdata/remove.wasm")
+	assert.NoError(t, err)
+
+	ecs := kinshi.New()
+	_, _ = ecs.AddEntity(&Unit{})
+	_, _ = ecs.AddEntity(&Unit{})
+
+	ctx := context.Background()
+	sys, err := LoadWASM(ctx, NewHost(ecs), "remove", binary)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer sys.Close(ctx)
+
+	assert.Equal(t, "remove", sys.Name())
+	assert.NoError(t, sys.Run())
+	assert.Equal(t, 1, ecs.Iterate().Count())
+
+	// The response of the second run holds the error.
+	assert.NoError(t, sys.Run())
+	assert.Contains(t, string(sys.response), "not found")
+
+	_, err = LoadWASM(ctx, NewHost(ecs), "broken", []byte("not wasm"))
+	assert.Error(t, err)
+}
+
+func TestLoadGoPlugin(t *testing.T) {
+	_, err := LoadGoPlugin(NewHost(kinshi.New()), "missing", "testdata/missing.so")
+	assert.Error(t, err)
+}
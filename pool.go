@@ -0,0 +1,161 @@
+package kinshi
+
+import (
+	"context"
+	"runtime/pprof"
+	"sync"
+)
+
+// wrapPool recycles the wraps of released iterators.
+var wrapPool = sync.Pool{
+	New: func() interface{} {
+		return &EntityWrap{}
+	},
+}
+
+// wrap returns a wrap for the entity that is
+// taken from the pool if one is available.
+func (ecs *ECS) wrap(ent Entity) *EntityWrap {
+	ew := wrapPool.Get().(*EntityWrap)
+	ew.parent = ecs
+	ew.ent = ent
+	return ew
+}
+
+// Release hands the wraps of the iterator back to a pool, so that the
+// following queries can reuse them instead of allocating new ones. This
+// reduces the garbage that is produced by queries that run every frame.
+//
+// For example:
+//    it := ecs.Iterate(Pos{})
+//    for _, ew := range it {
+//        // Work with the EntityWrap
+//    }
+//    it.Release()
+//
+// Important: Neither the iterator nor its wraps must be used after they
+// have been released. Iterators that have been combined by Union and the
+// other set operations share their wraps, so only one of them should be
+// released.
+func (it EntityIterator) Release() {
+	for i := range it {
+		if it[i] == nil || it[i].parent == nil {
+			continue
+		}
+
+		*it[i] = EntityWrap{}
+		wrapPool.Put(it[i])
+		it[i] = nil
+	}
+}
+
+// workerPool is a set of long-lived go routines that run the workers of
+// parallel scans, so that queries don't need to spawn new go routines on
+// every call. The pool grows on demand up to the largest routine count
+// that has been requested.
+type workerPool struct {
+	mtx     sync.Mutex
+	jobs    chan func()
+	size    int
+	closing bool
+	active  sync.WaitGroup
+}
+
+// acquire returns the jobs channel of the pool, grown to at least n go
+// routines, and marks a run as active. While the pool is closing no
+// channel is returned and the caller has to run inline.
+func (p *workerPool) acquire(n int) chan func() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closing {
+		return nil
+	}
+
+	if p.jobs == nil {
+		p.jobs = make(chan func())
+	}
+
+	for ; p.size < n; p.size++ {
+		go func(jobs chan func()) {
+			// Don't inherit the labels of the go routine
+			// that happened to grow the pool.
+			pprof.SetGoroutineLabels(context.Background())
+
+			for job := range jobs {
+				job()
+			}
+		}(p.jobs)
+	}
+
+	p.active.Add(1)
+	return p.jobs
+}
+
+// run calls fn n times with the index of the call on
+// the pool and waits until all calls have finished.
+// If the pool is being closed the calls run inline.
+func (p *workerPool) run(n int, labels context.Context, fn func(w int)) {
+	jobs := p.acquire(n)
+	if jobs == nil {
+		for w := 0; w < n; w++ {
+			fn(w)
+		}
+		return
+	}
+	defer p.active.Done()
+
+	wg := sync.WaitGroup{}
+	wg.Add(n)
+
+	for w := 0; w < n; w++ {
+		w := w
+		jobs <- func() {
+			defer wg.Done()
+
+			if labels != nil {
+				setLabels(labels)
+				defer pprof.SetGoroutineLabels(context.Background())
+			}
+
+			fn(w)
+		}
+	}
+
+	wg.Wait()
+}
+
+// close stops all go routines of the pool once the running scans have
+// finished. The pool can still be used afterwards and will grow again.
+func (p *workerPool) close() {
+	p.mtx.Lock()
+	if p.closing {
+		p.mtx.Unlock()
+		return
+	}
+	p.closing = true
+	p.mtx.Unlock()
+
+	// Scans that start now run inline, so waiting
+	// for the active ones can't deadlock.
+	p.active.Wait()
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.jobs != nil {
+		close(p.jobs)
+		p.jobs = nil
+		p.size = 0
+	}
+	p.closing = false
+}
+
+// Close stops the go routines that are used to parallelize searches.
+// The ECS can still be used afterwards and will start them again if
+// needed. Close should be called once a ECS with a routine count above
+// one isn't needed anymore, otherwise the go routines are leaked. Scans
+// that run while the ECS is closed finish first or run inline.
+func (ecs *ECS) Close() {
+	ecs.pool.close()
+}
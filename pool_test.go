@@ -0,0 +1,145 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestECS_WorkerPool(t *testing.T) {
+	ecs := New()
+	ecs.SetRoutineCount(8)
+	ecs.SetParallelThreshold(0)
+
+	for i := 0; i < 100; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	assert.Equal(t, 100, ecs.Iterate(Pos{}).Count())
+	assert.Equal(t, 8, ecs.pool.size)
+	started := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, 100, ecs.Iterate(Pos{}).Count())
+	}
+	assert.Equal(t, 8, ecs.pool.size, "workers aren't reused")
+	assert.True(t, runtime.NumGoroutine() <= started, "workers aren't reused")
+
+	ecs.Close()
+	assert.Equal(t, 0, ecs.pool.size)
+	for i := 0; i < 100 && runtime.NumGoroutine() > started-8; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, runtime.NumGoroutine() <= started-8, "workers weren't stopped")
+
+	assert.Equal(t, 100, ecs.Iterate(Pos{}).Count(), "pool can't be used after close")
+	ecs.Close()
+}
+
+func TestECS_CloseDuringIterate(t *testing.T) {
+	ecs := New()
+	ecs.SetRoutineCount(4)
+	ecs.SetParallelThreshold(0)
+
+	for i := 0; i < 100; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				assert.Equal(t, 100, ecs.Iterate(Pos{}).Count())
+			}
+		}()
+	}
+
+	for i := 0; i < 1000; i++ {
+		ecs.Close()
+	}
+	close(done)
+	wg.Wait()
+	ecs.Close()
+}
+
+func TestWorkerPool_CloseWhileRunning(t *testing.T) {
+	p := &workerPool{}
+	block := make(chan struct{})
+	started := make(chan struct{})
+
+	// The first run occupies the only worker, so the second one waits
+	// to hand over its job while the pool is closed.
+	wg := sync.WaitGroup{}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		p.run(1, nil, func(w int) {
+			close(started)
+			<-block
+		})
+	}()
+	<-started
+
+	ran := false
+	go func() {
+		defer wg.Done()
+		p.run(1, nil, func(w int) {
+			ran = true
+		})
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		p.close()
+		close(closed)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	close(block)
+	wg.Wait()
+	<-closed
+
+	assert.True(t, ran)
+	assert.Equal(t, 0, p.size)
+}
+
+func TestEntityIterator_Release(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	it := ecs.Iterate(Pos{})
+	wraps := append([]*EntityWrap{}, it...)
+	it.Release()
+
+	for i := range it {
+		assert.Nil(t, it[i])
+		assert.Nil(t, wraps[i].parent, "released wrap wasn't reset")
+	}
+
+	// Releasing again or releasing a combined iterator is safe.
+	it.Release()
+	other := ecs.Iterate(Pos{})
+	union := other.Union(nil)
+	union.Release()
+	other.Release()
+
+	it = ecs.Iterate(Pos{})
+	assert.Equal(t, []EntityID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, iteratorIDs(it))
+	for _, ew := range it {
+		assert.True(t, ew.Valid())
+	}
+	it.Release()
+}
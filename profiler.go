@@ -0,0 +1,113 @@
+package kinshi
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueryStat contains the recorded statistics of a single query.
+type QueryStat struct {
+	// Query describes the query, e.g. "Iterate(Pos, Velocity)".
+	Query string
+	// Calls is the number of times the query was executed.
+	Calls uint64
+	// Scanned is the total number of entities that were checked.
+	Scanned uint64
+	// Matched is the total number of entities that matched.
+	Matched uint64
+	// Duration is the total time spent in the query.
+	Duration time.Duration
+}
+
+// AvgDuration returns the average time spent per call.
+func (qs QueryStat) AvgDuration() time.Duration {
+	if qs.Calls == 0 {
+		return 0
+	}
+	return qs.Duration / time.Duration(qs.Calls)
+}
+
+// MatchRate returns the fraction of scanned entities that matched.
+func (qs QueryStat) MatchRate() float64 {
+	if qs.Scanned == 0 {
+		return 0
+	}
+	return float64(qs.Matched) / float64(qs.Scanned)
+}
+
+type profiler struct {
+	sync.Mutex
+	stats map[string]*QueryStat
+}
+
+func (p *profiler) record(query string, scanned int, matched int, d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	qs, ok := p.stats[query]
+	if !ok {
+		qs = &QueryStat{Query: query}
+		p.stats[query] = qs
+	}
+
+	qs.Calls += 1
+	qs.Scanned += uint64(scanned)
+	qs.Matched += uint64(matched)
+	qs.Duration += d
+}
+
+func (ecs *ECS) queryName(fn string, types []interface{}) string {
+	return fn + "(" + strings.Join(ecs.names.componentNames(types), ", ") + ")"
+}
+
+// WithProfiler enables the query profiler. The profiler records call
+// counts, scanned and matched entities and the time spent of every query,
+// which can be fetched with QueryStats. This adds some overhead to every
+// query, so it should only be enabled while looking for slow queries.
+func WithProfiler() Option {
+	return func(ecs *ECS) {
+		ecs.profiler = &profiler{
+			stats: map[string]*QueryStat{},
+		}
+	}
+}
+
+// QueryStats returns the statistics of all recorded queries sorted
+// by the total time spent, so that the most expensive queries come
+// first. If the profiler isn't enabled nil is returned.
+func (ecs *ECS) QueryStats() []QueryStat {
+	if ecs.profiler == nil {
+		return nil
+	}
+
+	ecs.profiler.Lock()
+	defer ecs.profiler.Unlock()
+
+	stats := make([]QueryStat, 0, len(ecs.profiler.stats))
+	for _, qs := range ecs.profiler.stats {
+		stats = append(stats, *qs)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Duration == stats[j].Duration {
+			return stats[i].Query < stats[j].Query
+		}
+		return stats[i].Duration > stats[j].Duration
+	})
+
+	return stats
+}
+
+// ResetQueryStats clears all recorded query statistics.
+func (ecs *ECS) ResetQueryStats() {
+	if ecs.profiler == nil {
+		return
+	}
+
+	ecs.profiler.Lock()
+	defer ecs.profiler.Unlock()
+
+	ecs.profiler.stats = map[string]*QueryStat{}
+}
@@ -0,0 +1,45 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWithProfiler(t *testing.T) {
+	ecs := New(WithProfiler())
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+		_, _ = ecs.AddEntity(&DynamicUnit{})
+	}
+
+	for i := 0; i < 3; i++ {
+		ecs.Iterate(Pos{}, Name{})
+	}
+	ecs.IterateSpecific(DynamicUnit{})
+
+	stats := ecs.QueryStats()
+	if !assert.Len(t, stats, 2) {
+		return
+	}
+
+	byName := map[string]QueryStat{}
+	for _, qs := range stats {
+		byName[qs.Query] = qs
+	}
+
+	iter := byName["Iterate(Pos, Name)"]
+	assert.Equal(t, uint64(3), iter.Calls)
+	assert.Equal(t, uint64(60), iter.Scanned)
+	assert.Equal(t, uint64(30), iter.Matched)
+	assert.Equal(t, 0.5, iter.MatchRate())
+
+	spec := byName["IterateSpecific(DynamicUnit)"]
+	assert.Equal(t, uint64(1), spec.Calls)
+	assert.Equal(t, uint64(10), spec.Matched)
+
+	ecs.ResetQueryStats()
+	assert.Len(t, ecs.QueryStats(), 0)
+
+	assert.Nil(t, New().QueryStats(), "profiler is enabled by default")
+}
@@ -0,0 +1,71 @@
+package protobuf
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"strings"
+)
+
+// protoType returns the name of the type of the field in a .proto file.
+func protoType(pkg string, f *descriptorpb.FieldDescriptorProto) string {
+	if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+		return strings.TrimPrefix(f.GetTypeName(), "."+pkg+".")
+	}
+	return strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+}
+
+func (c *Codec) writeMessage(b *strings.Builder, pkg string, msg *descriptorpb.DescriptorProto) {
+	fmt.Fprintf(b, "message %s {\n", msg.GetName())
+
+	entries := map[string]*descriptorpb.DescriptorProto{}
+	for _, nested := range msg.NestedType {
+		entries["."+pkg+"."+msg.GetName()+"."+nested.GetName()] = nested
+	}
+
+	for _, f := range msg.Field {
+		b.WriteString("  ")
+
+		isJSON := c.json[protoreflect.FullName(pkg+"."+msg.GetName()+"."+f.GetName())]
+		if entry, ok := entries[f.GetTypeName()]; ok && entry.GetOptions().GetMapEntry() {
+			fmt.Fprintf(b, "map<%s, %s>", protoType(pkg, entry.Field[0]), protoType(pkg, entry.Field[1]))
+			isJSON = c.json[protoreflect.FullName(strings.TrimPrefix(f.GetTypeName(), ".")+".value")]
+		} else {
+			if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+				b.WriteString("repeated ")
+			}
+			b.WriteString(protoType(pkg, f))
+		}
+
+		fmt.Fprintf(b, " %s = %d", f.GetName(), f.GetNumber())
+		if f.GetJsonName() != f.GetName() {
+			fmt.Fprintf(b, " [json_name = %q]", f.GetJsonName())
+		}
+		b.WriteString(";")
+		if isJSON {
+			b.WriteString(" // JSON")
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("}\n")
+}
+
+// Proto returns the generated messages as .proto file, so that bindings
+// for other languages can be generated with protoc. Values that are
+// marked with a JSON comment are bytes containing their JSON form.
+func (c *Codec) Proto() string {
+	b := &strings.Builder{}
+	pkg := c.fileProto.GetPackage()
+
+	b.WriteString("// Code generated by kinshi/protobuf. DO NOT EDIT.\n\n")
+	b.WriteString("syntax = \"proto3\";\n\n")
+	fmt.Fprintf(b, "package %s;\n", pkg)
+
+	for _, msg := range c.fileProto.MessageType {
+		b.WriteString("\n")
+		c.writeMessage(b, pkg, msg)
+	}
+
+	return b.String()
+}
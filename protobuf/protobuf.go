@@ -0,0 +1,511 @@
+// Package protobuf encodes snapshots and replication payloads of a
+// kinshi.ECS as protocol buffers, which are compact and can be read from
+// other languages. The messages are generated from the registered
+// components, Proto returns them as .proto file so that clients in other
+// languages can generate their bindings with protoc.
+//
+// The messages follow the JSON form of the components. Struct fields are
+// numbered in order of declaration, which can be changed with a proto
+// struct tag:
+//    type Health struct {
+//        Value int `proto:"1"`
+//        Max   int `proto:"2"`
+//    }
+//
+// The field numbers of the components in the Components message are
+// derived from their name, so components can be added and removed
+// without breaking old saves. Values that can't be represented, like
+// nested lists or interfaces, are stored as bytes containing JSON.
+package protobuf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"io/ioutil"
+	"strconv"
+)
+
+// Option configures a Codec.
+type Option func(o *options)
+
+type options struct {
+	pkg     string
+	numbers map[string]int32
+}
+
+// WithPackage sets the protobuf package of the messages,
+// which is kinshi.v1 by default.
+func WithPackage(pkg string) Option {
+	return func(o *options) {
+		o.pkg = pkg
+	}
+}
+
+// ComponentNumber sets the field number of the component in the
+// Components message, e.g. to resolve a collision of the numbers that
+// are derived from the names.
+func ComponentNumber(name string, number int32) Option {
+	return func(o *options) {
+		o.numbers[name] = number
+	}
+}
+
+// Codec encodes and decodes the data of a ECS as protobuf.
+type Codec struct {
+	ecs        *kinshi.ECS
+	file       protoreflect.FileDescriptor
+	fileProto  *descriptorpb.FileDescriptorProto
+	snapshot   protoreflect.MessageDescriptor
+	payload    protoreflect.MessageDescriptor
+	components protoreflect.MessageDescriptor
+	json       map[protoreflect.FullName]bool
+	wrappers   map[protoreflect.FullName]bool
+}
+
+// New generates the messages for the components that are registered in
+// the ECS, see kinshi.ECS.RegisteredComponents. Components that are
+// registered later aren't known to the codec and are stored as JSON.
+func New(ecs *kinshi.ECS, opts ...Option) (*Codec, error) {
+	o := &options{
+		pkg:     "kinshi.v1",
+		numbers: map[string]int32{},
+	}
+	for i := range opts {
+		opts[i](o)
+	}
+
+	b := newSchemaBuilder(o.pkg)
+	if err := b.build(ecs.RegisteredComponents(), o.numbers); err != nil {
+		return nil, err
+	}
+
+	file, err := protodesc.NewFile(b.file, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Codec{
+		ecs:        ecs,
+		file:       file,
+		fileProto:  b.file,
+		snapshot:   file.Messages().ByName("Snapshot"),
+		payload:    file.Messages().ByName("ReplicationPayload"),
+		components: file.Messages().ByName("Components"),
+		json:       map[protoreflect.FullName]bool{},
+		wrappers:   map[protoreflect.FullName]bool{},
+	}
+
+	for name := range b.json {
+		c.json[protoreflect.FullName(name)] = true
+	}
+	for name := range b.wrappers {
+		c.wrappers[protoreflect.FullName(name)] = true
+	}
+
+	return c, nil
+}
+
+// File returns the descriptor of the generated messages.
+func (c *Codec) File() protoreflect.FileDescriptor {
+	return c.file
+}
+
+// Marshal writes all entities of the ECS as Snapshot message to w.
+func (c *Codec) Marshal(w io.Writer) error {
+	buf := &bytes.Buffer{}
+	if err := c.ecs.Marshal(buf); err != nil {
+		return err
+	}
+
+	var entities []interface{}
+	if err := decodeJSON(buf.Bytes(), &entities); err != nil {
+		return err
+	}
+
+	for i := range entities {
+		c.splitComponents(entities[i])
+	}
+
+	msg := dynamicpb.NewMessage(c.snapshot)
+	if err := c.fill(msg, map[string]interface{}{"Entities": entities}); err != nil {
+		return err
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// Unmarshal reads a Snapshot message that was written by Marshal and
+// replaces the entities of the ECS like kinshi.ECS.Unmarshal.
+func (c *Codec) Unmarshal(r io.Reader) error {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	msg := dynamicpb.NewMessage(c.snapshot)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return err
+	}
+
+	entities, _ := c.generic(msg)["Entities"].([]interface{})
+	for i := range entities {
+		c.joinComponents(entities[i])
+	}
+
+	if entities == nil {
+		entities = []interface{}{}
+	}
+
+	data, err = json.Marshal(entities)
+	if err != nil {
+		return err
+	}
+
+	return c.ecs.Unmarshal(bytes.NewReader(data))
+}
+
+// MarshalPayload encodes the payload of a kinshi.Replicator as
+// ReplicationPayload message.
+func (c *Codec) MarshalPayload(payload kinshi.ReplicationPayload) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic map[string]interface{}
+	if err := decodeJSON(data, &generic); err != nil {
+		return nil, err
+	}
+
+	for _, key := range []string{"Created", "Updated"} {
+		entities, _ := generic[key].([]interface{})
+		for i := range entities {
+			c.splitComponents(entities[i])
+		}
+	}
+
+	msg := dynamicpb.NewMessage(c.payload)
+	if err := c.fill(msg, generic); err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(msg)
+}
+
+// UnmarshalPayload decodes a payload that was encoded by MarshalPayload,
+// which can be applied with kinshi.ECS.ApplyReplication.
+func (c *Codec) UnmarshalPayload(data []byte) (kinshi.ReplicationPayload, error) {
+	var payload kinshi.ReplicationPayload
+
+	msg := dynamicpb.NewMessage(c.payload)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return payload, err
+	}
+
+	generic := c.generic(msg)
+	for _, key := range []string{"Created", "Updated"} {
+		entities, _ := generic[key].([]interface{})
+		for i := range entities {
+			c.joinComponents(entities[i])
+		}
+	}
+
+	data, err := json.Marshal(generic)
+	if err != nil {
+		return payload, err
+	}
+
+	err = json.Unmarshal(data, &payload)
+	return payload, err
+}
+
+// decodeJSON decodes the data keeping numbers exact.
+func decodeJSON(data []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// splitComponents moves the components of the generic entity that
+// aren't part of the Components message to OtherComponents and wraps
+// the values of components that aren't structs.
+func (c *Codec) splitComponents(v interface{}) {
+	ent, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	comps, ok := ent["Components"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	other := map[string]interface{}{}
+	for name, val := range comps {
+		fd := c.components.Fields().ByJSONName(name)
+		switch {
+		case fd == nil:
+			other[name] = val
+			delete(comps, name)
+		case c.wrappers[fd.Message().FullName()]:
+			comps[name] = map[string]interface{}{"value": val}
+		}
+	}
+
+	if len(other) > 0 {
+		ent["OtherComponents"] = other
+	}
+}
+
+// joinComponents reverts splitComponents.
+func (c *Codec) joinComponents(v interface{}) {
+	ent, ok := v.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	comps, ok := ent["Components"].(map[string]interface{})
+	if !ok {
+		comps = map[string]interface{}{}
+		ent["Components"] = comps
+	}
+
+	for name, val := range comps {
+		if fd := c.components.Fields().ByJSONName(name); fd != nil && c.wrappers[fd.Message().FullName()] {
+			wrapped, _ := val.(map[string]interface{})
+			comps[name] = wrapped["value"]
+		}
+	}
+
+	other, _ := ent["OtherComponents"].(map[string]interface{})
+	for name, val := range other {
+		comps[name] = val
+	}
+	delete(ent, "OtherComponents")
+}
+
+// fill sets the fields of m from the generic JSON data v.
+func (c *Codec) fill(m protoreflect.Message, v map[string]interface{}) error {
+	fields := m.Descriptor().Fields()
+
+	for key, val := range v {
+		fd := fields.ByJSONName(key)
+		if fd == nil {
+			return fmt.Errorf("%s has no field '%s'", m.Descriptor().FullName(), key)
+		}
+
+		if val == nil {
+			continue
+		}
+
+		if err := c.fillField(m, fd, val); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+func (c *Codec) fillField(m protoreflect.Message, fd protoreflect.FieldDescriptor, val interface{}) error {
+	switch {
+	case fd.IsMap():
+		entries, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object but got %T", val)
+		}
+
+		mm := m.Mutable(fd).Map()
+		for k, e := range entries {
+			key, err := c.scalar(fd.MapKey(), k)
+			if err != nil {
+				return err
+			}
+
+			if fd.MapValue().Message() != nil {
+				obj, ok := e.(map[string]interface{})
+				if !ok && e != nil {
+					return fmt.Errorf("expected object but got %T", e)
+				}
+				if err := c.fill(mm.Mutable(key.MapKey()).Message(), obj); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if e == nil {
+				continue
+			}
+
+			value, err := c.scalar(fd.MapValue(), e)
+			if err != nil {
+				return err
+			}
+			mm.Set(key.MapKey(), value)
+		}
+	case fd.IsList():
+		elems, ok := val.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected array but got %T", val)
+		}
+
+		list := m.Mutable(fd).List()
+		for _, e := range elems {
+			if fd.Message() != nil {
+				obj, ok := e.(map[string]interface{})
+				if !ok && e != nil {
+					return fmt.Errorf("expected object but got %T", e)
+				}
+
+				el := list.NewElement()
+				if err := c.fill(el.Message(), obj); err != nil {
+					return err
+				}
+				list.Append(el)
+				continue
+			}
+
+			value, err := c.scalar(fd, e)
+			if err != nil {
+				return err
+			}
+			list.Append(value)
+		}
+	case fd.Message() != nil:
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object but got %T", val)
+		}
+		return c.fill(m.Mutable(fd).Message(), obj)
+	default:
+		value, err := c.scalar(fd, val)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, value)
+	}
+
+	return nil
+}
+
+// scalar converts a generic JSON value to the value of the field.
+func (c *Codec) scalar(fd protoreflect.FieldDescriptor, val interface{}) (protoreflect.Value, error) {
+	if c.json[fd.FullName()] {
+		data, err := json.Marshal(val)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(data), nil
+	}
+
+	// Map keys are always strings in JSON.
+	if s, ok := val.(string); ok && fd.Kind() != protoreflect.StringKind && fd.Kind() != protoreflect.BytesKind {
+		val = json.Number(s)
+	}
+
+	var err error
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if b, ok := val.(bool); ok {
+			return protoreflect.ValueOfBool(b), nil
+		}
+	case protoreflect.StringKind:
+		if s, ok := val.(string); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.BytesKind:
+		if s, ok := val.(string); ok {
+			var data []byte
+			if data, err = base64.StdEncoding.DecodeString(s); err == nil {
+				return protoreflect.ValueOfBytes(data), nil
+			}
+		}
+	case protoreflect.Sint32Kind, protoreflect.Sint64Kind:
+		if n, ok := val.(json.Number); ok {
+			var i int64
+			if i, err = strconv.ParseInt(string(n), 10, 64); err == nil {
+				if fd.Kind() == protoreflect.Sint32Kind {
+					return protoreflect.ValueOfInt32(int32(i)), nil
+				}
+				return protoreflect.ValueOfInt64(i), nil
+			}
+		}
+	case protoreflect.Uint32Kind, protoreflect.Uint64Kind:
+		if n, ok := val.(json.Number); ok {
+			var u uint64
+			if u, err = strconv.ParseUint(string(n), 10, 64); err == nil {
+				if fd.Kind() == protoreflect.Uint32Kind {
+					return protoreflect.ValueOfUint32(uint32(u)), nil
+				}
+				return protoreflect.ValueOfUint64(u), nil
+			}
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		if n, ok := val.(json.Number); ok {
+			var f float64
+			if f, err = n.Float64(); err == nil {
+				if fd.Kind() == protoreflect.FloatKind {
+					return protoreflect.ValueOfFloat32(float32(f)), nil
+				}
+				return protoreflect.ValueOfFloat64(f), nil
+			}
+		}
+	}
+
+	if err != nil {
+		return protoreflect.Value{}, err
+	}
+	return protoreflect.Value{}, fmt.Errorf("can't use %T as %s", val, fd.Kind())
+}
+
+// generic converts the message to generic JSON data.
+func (c *Codec) generic(m protoreflect.Message) map[string]interface{} {
+	res := map[string]interface{}{}
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			entries := map[string]interface{}{}
+			v.Map().Range(func(k protoreflect.MapKey, e protoreflect.Value) bool {
+				entries[k.String()] = c.genericValue(fd.MapValue(), e)
+				return true
+			})
+			res[fd.JSONName()] = entries
+		case fd.IsList():
+			elems := make([]interface{}, v.List().Len())
+			for i := range elems {
+				elems[i] = c.genericValue(fd, v.List().Get(i))
+			}
+			res[fd.JSONName()] = elems
+		default:
+			res[fd.JSONName()] = c.genericValue(fd, v)
+		}
+		return true
+	})
+	return res
+}
+
+func (c *Codec) genericValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	if fd.Message() != nil {
+		return c.generic(v.Message())
+	}
+
+	if c.json[fd.FullName()] {
+		return json.RawMessage(v.Bytes())
+	}
+
+	return v.Interface()
+}
@@ -0,0 +1,168 @@
+package protobuf
+
+import (
+	"bytes"
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"strings"
+	"testing"
+)
+
+type Armor struct {
+	Value int `proto:"2"`
+	Max   int `proto:"1"`
+}
+
+type Energy float32
+
+type Item struct {
+	Name  string `json:"name"`
+	Count uint8
+}
+
+type Bag struct {
+	Items  []Item
+	Prices map[string]float64
+	Slots  map[int]*Item
+	Grid   [][]int
+	Icon   []byte
+	Any    interface{}
+	Owner  kinshi.UUID
+	Next   *Bag
+	hidden int
+}
+
+type Soldier struct {
+	kinshi.BaseEntity
+	testutil.Pos
+	Armor
+	testutil.Hostile
+}
+
+type Trader struct {
+	kinshi.BaseDynamicEntity
+}
+
+// types are registered in addition to the shared fixtures.
+var types = []interface{}{&Soldier{}, &Trader{}, Bag{}, Energy(0)}
+
+func TestCodec_Proto(t *testing.T) {
+	c, err := New(testutil.NewECS(t, types...), WithPackage("game.v2"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	src := c.Proto()
+	assert.Contains(t, src, "package game.v2;")
+	assert.Contains(t, src, "message Armor {\n  sint64 Value = 2;\n  sint64 Max = 1;\n}")
+	assert.Contains(t, src, "message Energy {\n  float value = 1;\n}")
+	assert.Contains(t, src, "  repeated Item Items = 1;\n  map<string, double> Prices = 2;\n  map<sint64, Item> Slots = 3;\n  bytes Grid = 4; // JSON\n  bytes Icon = 5;\n  bytes Any = 6; // JSON\n  string Owner = 7;\n  Bag Next = 8;\n")
+	assert.Contains(t, src, "  string name = 1;\n  uint32 Count = 2;\n")
+	assert.Contains(t, src, "  map<string, bytes> other_components = 15 [json_name = \"OtherComponents\"]; // JSON\n")
+	assert.Contains(t, src, "  Pos Pos = ")
+	assert.Equal(t, protoreflect.Name("Snapshot"), c.File().Messages().Get(0).Name())
+}
+
+func TestCodec_Snapshot(t *testing.T) {
+	src := testutil.NewECS(t, types...)
+	_, _ = src.AddEntity(&Soldier{Pos: testutil.Pos{X: -1, Y: 2}, Armor: Armor{Value: 3, Max: 10}})
+
+	trader := &Trader{}
+	assert.NoError(t, trader.SetComponent(&Bag{
+		Items:  []Item{{Name: "sword", Count: 1}},
+		Prices: map[string]float64{"sword": 2.5},
+		Slots:  map[int]*Item{3: {Name: "shield"}},
+		Grid:   [][]int{{1, 2}, {3}},
+		Any:    "anything",
+		Next:   &Bag{Items: []Item{{Name: "coin", Count: 200}}},
+	}))
+	e := Energy(0.5)
+	assert.NoError(t, trader.SetComponent(&e))
+	assert.NoError(t, trader.SetComponent(&testutil.Pos{X: 1 << 40}))
+	assert.NoError(t, trader.SetKeyed("backpack", &Bag{Prices: map[string]float64{"coin": 1}}))
+	id, _ := src.AddEntity(trader)
+	_, _ = src.AssignNetID(id)
+	_, _ = src.AssignUUID(id)
+
+	c, err := New(src)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, c.Marshal(buf))
+
+	jsonBuf := &bytes.Buffer{}
+	assert.NoError(t, src.Marshal(jsonBuf))
+	assert.Less(t, buf.Len(), jsonBuf.Len()/2)
+
+	dst := testutil.NewECS(t, types...)
+	dc, err := New(dst)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, dc.Unmarshal(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, src.Len(), dst.Len())
+
+	for _, ew := range src.Iterate() {
+		expected, _ := src.MarshalEntity(ew.GetEntity().ID())
+		actual, err := dst.MarshalEntity(ew.GetEntity().ID())
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expected), string(actual))
+	}
+}
+
+func TestCodec_Payload(t *testing.T) {
+	ecs := testutil.NewECS(t, types...)
+	id, _ := ecs.AddEntity(&Soldier{Pos: testutil.Pos{X: 4, Y: 1}})
+	removed, _ := ecs.AddEntity(&Soldier{})
+
+	c, err := New(ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	replica := testutil.NewECS(t, types...)
+	r := kinshi.NewReplicator(ecs)
+	apply := func() {
+		payload, err := r.Tick()
+		assert.NoError(t, err)
+
+		data, err := c.MarshalPayload(payload)
+		assert.NoError(t, err)
+
+		decoded, err := c.UnmarshalPayload(data)
+		assert.NoError(t, err)
+		assert.NoError(t, replica.ApplyReplication(decoded))
+	}
+
+	apply()
+	assert.Equal(t, 2, replica.Len())
+
+	// Zero values are omitted but the components are still replaced.
+	assert.NoError(t, ecs.MustGet(id).Set(testutil.Pos{X: 5}))
+	_ = ecs.RemoveByID(removed)
+	apply()
+
+	assert.Equal(t, 1, replica.Len())
+	assert.Equal(t, testutil.Pos{X: 5}, replica.MustGet(id).GetEntity().(*Soldier).Pos)
+
+	_, err = c.UnmarshalPayload([]byte{0xff})
+	assert.Error(t, err)
+}
+
+func TestNew_Conflicts(t *testing.T) {
+	ecs := testutil.NewECS(t, types...)
+
+	name := ""
+	for n := range ecs.RegisteredComponents() {
+		name = n
+		break
+	}
+
+	_, err := New(ecs, ComponentNumber(name, 1), ComponentNumber("Pos", 1), ComponentNumber("Bag", 1))
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "number 1"))
+}
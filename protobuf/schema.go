@@ -0,0 +1,475 @@
+package protobuf
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// maxComponentNumber caps the field numbers of the components, so that
+// their tags fit into 3 bytes.
+const maxComponentNumber = 1 << 18
+
+// componentNumber returns the stable field number of the component in
+// the Components message. It only depends on the name, so that adding
+// or removing components doesn't change the numbers of the others.
+func componentNumber(name string) int32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+
+	n := int32(h.Sum32()%(maxComponentNumber-1)) + 1
+	if n >= 19000 && n <= 19999 {
+		// Reserved for the implementation of protobuf.
+		n += 1000
+	}
+	return n
+}
+
+// identifier converts s into a valid protobuf identifier.
+func identifier(s string) string {
+	b := strings.Builder{}
+	for i, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune('_')
+		}
+	}
+	return b.String()
+}
+
+// camelCase converts a field name like other_components into the form
+// OtherComponents that protoc uses for the names of map entries.
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := range parts {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// schemaBuilder builds the messages of the file descriptor.
+type schemaBuilder struct {
+	pkg      string
+	file     *descriptorpb.FileDescriptorProto
+	messages map[reflect.Type]string
+	names    map[string]bool
+	json     map[string]bool
+	wrappers map[string]bool
+}
+
+func newSchemaBuilder(pkg string) *schemaBuilder {
+	return &schemaBuilder{
+		pkg: pkg,
+		file: &descriptorpb.FileDescriptorProto{
+			Name:    proto.String(strings.Replace(pkg, ".", "/", -1) + "/kinshi.proto"),
+			Package: proto.String(pkg),
+			Syntax:  proto.String("proto3"),
+		},
+		messages: map[reflect.Type]string{},
+		names:    map[string]bool{},
+		json:     map[string]bool{},
+		wrappers: map[string]bool{},
+	}
+}
+
+// fullName returns the full name of the message or field.
+func (b *schemaBuilder) fullName(names ...string) string {
+	return b.pkg + "." + strings.Join(names, ".")
+}
+
+// reserve claims the name of a top level message.
+func (b *schemaBuilder) reserve(name string) error {
+	if b.names[name] {
+		return fmt.Errorf("message '%s' exists twice, rename one of the types: %w", name, kinshi.ErrTypeConflict)
+	}
+	b.names[name] = true
+	return nil
+}
+
+func (b *schemaBuilder) addMessage(msg *descriptorpb.DescriptorProto) {
+	b.file.MessageType = append(b.file.MessageType, msg)
+}
+
+func field(name string, number int32, jsonName string, t descriptorpb.FieldDescriptorProto_Type) *descriptorpb.FieldDescriptorProto {
+	return &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		Number:   proto.Int32(number),
+		JsonName: proto.String(jsonName),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:     t.Enum(),
+	}
+}
+
+func repeated(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
+
+func (b *schemaBuilder) messageField(name string, number int32, jsonName string, msg string) *descriptorpb.FieldDescriptorProto {
+	f := field(name, number, jsonName, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE)
+	f.TypeName = proto.String("." + b.fullName(msg))
+	return f
+}
+
+// jsonField returns a bytes field that holds the JSON form of the value,
+// for values that can't be represented as protobuf.
+func (b *schemaBuilder) jsonField(parent string, name string, number int32, jsonName string) *descriptorpb.FieldDescriptorProto {
+	b.json[b.fullName(parent, name)] = true
+	return field(name, number, jsonName, descriptorpb.FieldDescriptorProto_TYPE_BYTES)
+}
+
+// scalarType returns the protobuf type of t if it's a scalar.
+func scalarType(t reflect.Type) (descriptorpb.FieldDescriptorProto_Type, bool) {
+	if t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType) {
+		return 0, false
+	}
+
+	if t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType) {
+		return descriptorpb.FieldDescriptorProto_TYPE_STRING, true
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return descriptorpb.FieldDescriptorProto_TYPE_BOOL, true
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return descriptorpb.FieldDescriptorProto_TYPE_SINT32, true
+	case reflect.Int, reflect.Int64:
+		return descriptorpb.FieldDescriptorProto_TYPE_SINT64, true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return descriptorpb.FieldDescriptorProto_TYPE_UINT32, true
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return descriptorpb.FieldDescriptorProto_TYPE_UINT64, true
+	case reflect.Float32:
+		return descriptorpb.FieldDescriptorProto_TYPE_FLOAT, true
+	case reflect.Float64:
+		return descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, true
+	case reflect.String:
+		return descriptorpb.FieldDescriptorProto_TYPE_STRING, true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return descriptorpb.FieldDescriptorProto_TYPE_BYTES, true
+		}
+	}
+	return 0, false
+}
+
+// isStruct checks if t is encoded as a protobuf message.
+func isStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	_, scalar := scalarType(t)
+	return t.Kind() == reflect.Struct && !scalar &&
+		!t.Implements(jsonMarshalerType) && !reflect.PtrTo(t).Implements(jsonMarshalerType)
+}
+
+// valueField returns the field for a value of type t.
+func (b *schemaBuilder) valueField(parent *descriptorpb.DescriptorProto, parentName string, name string, number int32, jsonName string, t reflect.Type) (*descriptorpb.FieldDescriptorProto, error) {
+	if t.Kind() == reflect.Ptr && t.Elem().Kind() != reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if st, ok := scalarType(t); ok {
+		return field(name, number, jsonName, st), nil
+	}
+
+	if isStruct(t) {
+		msg, err := b.message(t, parentName+"_"+name)
+		if err != nil {
+			return nil, err
+		}
+		return b.messageField(name, number, jsonName, msg), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		elem := t.Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+
+		// Nested lists can't be represented.
+		if _, ok := scalarType(elem); !ok && !isStruct(elem) {
+			break
+		}
+
+		f, err := b.valueField(parent, parentName, name, number, jsonName, elem)
+		if err != nil {
+			return nil, err
+		}
+		return repeated(f), nil
+	case reflect.Map:
+		key, ok := scalarType(t.Key())
+		if !ok || key == descriptorpb.FieldDescriptorProto_TYPE_BYTES ||
+			key == descriptorpb.FieldDescriptorProto_TYPE_FLOAT || key == descriptorpb.FieldDescriptorProto_TYPE_DOUBLE {
+			break
+		}
+
+		elem := t.Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if _, ok := scalarType(elem); !ok && !isStruct(elem) {
+			break
+		}
+
+		entryName := camelCase(name) + "Entry"
+		entry := &descriptorpb.DescriptorProto{
+			Name:    proto.String(entryName),
+			Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+		}
+
+		entry.Field = append(entry.Field, field("key", 1, "key", key))
+
+		value, err := b.valueField(entry, parentName+"."+entryName, "value", 2, "value", elem)
+		if err != nil {
+			return nil, err
+		}
+		entry.Field = append(entry.Field, value)
+		parent.NestedType = append(parent.NestedType, entry)
+
+		return repeated(b.messageField(name, number, jsonName, parentName+"."+entryName)), nil
+	}
+
+	return b.jsonField(parentName, name, number, jsonName), nil
+}
+
+// message returns the name of the message of the struct type t and
+// builds it if needed. Anonymous structs are named by fallback.
+func (b *schemaBuilder) message(t reflect.Type, fallback string) (string, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if name, ok := b.messages[t]; ok {
+		return name, nil
+	}
+
+	name := identifier(fallback)
+	if t.Name() != "" {
+		name = identifier(kinshi.TypeName(reflect.New(t).Elem().Interface()))
+	}
+
+	if err := b.reserve(name); err != nil {
+		return "", err
+	}
+	b.messages[t] = name
+
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(name)}
+	b.addMessage(msg)
+
+	numbers := map[int32]string{}
+	next := int32(1)
+	if err := b.structFields(msg, name, t, numbers, &next); err != nil {
+		return "", err
+	}
+
+	return name, nil
+}
+
+// structFields adds the fields of t to msg following the rules of
+// encoding/json. The fields are numbered in order of declaration, the
+// number can be set with a proto struct tag, e.g. `proto:"3"`.
+func (b *schemaBuilder) structFields(msg *descriptorpb.DescriptorProto, name string, t reflect.Type, numbers map[int32]string, next *int32) error {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		jsonName := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// Embedded structs without name are flattened like in encoding/json.
+		if f.Anonymous && jsonName == "" && ft.Kind() == reflect.Struct {
+			if err := b.structFields(msg, name, ft, numbers, next); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if jsonName == "" {
+			jsonName = f.Name
+		}
+
+		number := *next
+		if n := f.Tag.Get("proto"); n != "" {
+			parsed, err := strconv.ParseInt(n, 10, 32)
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid proto tag '%s' of %s.%s", n, t, f.Name)
+			}
+			number = int32(parsed)
+		}
+
+		if other, ok := numbers[number]; ok {
+			return fmt.Errorf("fields '%s' and '%s' of %s have the number %d", other, jsonName, t, number)
+		}
+		numbers[number] = jsonName
+		*next = number + 1
+
+		var fd *descriptorpb.FieldDescriptorProto
+		var err error
+		if strings.Contains(tag, ",string") {
+			fd = field(identifier(jsonName), number, jsonName, descriptorpb.FieldDescriptorProto_TYPE_STRING)
+		} else if fd, err = b.valueField(msg, name, identifier(jsonName), number, jsonName, f.Type); err != nil {
+			return err
+		}
+		msg.Field = append(msg.Field, fd)
+	}
+
+	return nil
+}
+
+// component builds the message of a component. Components that aren't
+// structs, like `type Energy int`, are wrapped into a message with a
+// single value field.
+func (b *schemaBuilder) component(name string, t reflect.Type) (string, error) {
+	if isStruct(t) {
+		return b.message(t, identifier(name))
+	}
+
+	msgName := identifier(name)
+	if err := b.reserve(msgName); err != nil {
+		return "", err
+	}
+
+	msg := &descriptorpb.DescriptorProto{Name: proto.String(msgName)}
+	b.addMessage(msg)
+
+	fd, err := b.valueField(msg, msgName, "value", 1, "value", t)
+	if err != nil {
+		return "", err
+	}
+	msg.Field = append(msg.Field, fd)
+	b.wrappers[b.fullName(msgName)] = true
+
+	return msgName, nil
+}
+
+// build creates the messages of the snapshots, replication payloads and
+// the given components.
+func (b *schemaBuilder) build(components map[string]reflect.Type, numbers map[string]int32) error {
+	for _, name := range []string{"Snapshot", "Entity", "Components", "ReplicationPayload", "ReplicatedEntity"} {
+		_ = b.reserve(name)
+	}
+
+	b.addMessage(&descriptorpb.DescriptorProto{
+		Name: proto.String("Snapshot"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			repeated(b.messageField("entities", 1, "Entities", "Entity")),
+		},
+	})
+
+	entity := &descriptorpb.DescriptorProto{
+		Name: proto.String("Entity"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("id", 1, "ID", descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			field("net_id", 2, "NetID", descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			field("uuid", 3, "UUID", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			field("version", 4, "Version", descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			field("type", 5, "Type", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			b.messageField("components", 6, "Components", "Components"),
+			repeated(field("tags", 7, "Tags", descriptorpb.FieldDescriptorProto_TYPE_STRING)),
+		},
+	}
+	b.addMessage(entity)
+
+	comps := &descriptorpb.DescriptorProto{Name: proto.String("Components")}
+	b.addMessage(comps)
+
+	b.addMessage(&descriptorpb.DescriptorProto{
+		Name: proto.String("ReplicationPayload"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			repeated(b.messageField("created", 1, "Created", "ReplicatedEntity")),
+			repeated(b.messageField("updated", 2, "Updated", "ReplicatedEntity")),
+			repeated(b.messageField("destroyed", 3, "Destroyed", "ReplicatedEntity")),
+		},
+	})
+
+	replicated := &descriptorpb.DescriptorProto{
+		Name: proto.String("ReplicatedEntity"),
+		Field: []*descriptorpb.FieldDescriptorProto{
+			field("id", 1, "ID", descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			field("net_id", 2, "NetID", descriptorpb.FieldDescriptorProto_TYPE_UINT64),
+			field("type", 3, "Type", descriptorpb.FieldDescriptorProto_TYPE_STRING),
+			b.messageField("components", 4, "Components", "Components"),
+			repeated(field("removed_components", 5, "RemovedComponents", descriptorpb.FieldDescriptorProto_TYPE_STRING)),
+		},
+	}
+	b.addMessage(replicated)
+
+	// Components that aren't known, like keyed components, are kept
+	// in their JSON form.
+	for _, msg := range []*descriptorpb.DescriptorProto{entity, replicated} {
+		other, err := b.valueField(msg, msg.GetName(), "other_components", 15, "OtherComponents", reflect.TypeOf(map[string][]byte{}))
+		if err != nil {
+			return err
+		}
+		msg.Field = append(msg.Field, other)
+		b.json[b.fullName(msg.GetName(), "OtherComponentsEntry", "value")] = true
+	}
+
+	names := make([]string, 0, len(components))
+	for name := range components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	used := map[int32]string{}
+	for _, name := range names {
+		msg, err := b.component(name, components[name])
+		if err != nil {
+			return err
+		}
+
+		number, ok := numbers[name]
+		if !ok {
+			number = componentNumber(name)
+		}
+
+		if other, ok := used[number]; ok {
+			return fmt.Errorf("components '%s' and '%s' have the number %d, set one with ComponentNumber", other, name, number)
+		}
+		used[number] = name
+
+		comps.Field = append(comps.Field, b.messageField(identifier(name), number, name, msg))
+	}
+
+	sort.Slice(comps.Field, func(i, j int) bool {
+		return comps.Field[i].GetNumber() < comps.Field[j].GetNumber()
+	})
+
+	return nil
+}
@@ -0,0 +1,366 @@
+package kinshi
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// nextMatch returns the first entity with a id greater than after that
+// contains all the named components or nil if there is none. Because the
+// entities are sorted by id a scan can be resumed even if entities have
+// been added or removed in between.
+func (ecs *ECS) nextMatch(after EntityID, names []string) Entity {
+	rv := ecs.reads()
+
+	idx, ok := rv.find(after)
+	if ok {
+		idx++
+	}
+
+	for ; idx < len(rv.entries); idx++ {
+		if rv.has(idx, names) {
+			return rv.entries[idx].Ent
+		}
+	}
+
+	return nil
+}
+
+// Find returns the first entity that contains all the given types and
+// satisfies pred. The search stops at the first match, so in contrast
+// to Iterate no wrapper is created for the remaining entities. If no
+// entity matches ErrNotFound is returned.
+//
+// The lock isn't held while pred is called, so pred is free to use
+// View and the other methods of the EntityWrap.
+//
+// For example you want to find the unit on a specific tile:
+//    ew, err := ecs.Find(func(ew *kinshi.EntityWrap) bool {
+//        var found bool
+//        _ = ew.View(func(p *Pos) {
+//            found = p.X == x && p.Y == y
+//        })
+//        return found
+//    }, Pos{})
+func (ecs *ECS) Find(pred func(ew *EntityWrap) bool, types ...interface{}) (*EntityWrap, error) {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	names := ecs.componentNames(types)
+
+	last := EntityNone
+	for {
+		ent := ecs.nextMatch(last, names)
+		if ent == nil {
+			return nil, ErrNotFound
+		}
+
+		// The cursor is taken before pred runs, because pred may remove
+		// the entity, which resets its id.
+		last = ent.ID()
+
+		ew := &EntityWrap{parent: ecs, ent: ent}
+		if pred(ew) {
+			return ew, nil
+		}
+	}
+}
+
+// Count returns the number of entities that contain all the given types.
+// In contrast to Iterate(...).Count() no wrappers are allocated.
+func (ecs *ECS) Count(types ...interface{}) int {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	rv := ecs.reads()
+
+	count := 0
+
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("Count", types), len(rv.entries), count, time.Since(start))
+		}()
+	}
+
+	names := ecs.componentNames(types)
+	for i := range rv.entries {
+		if rv.has(i, names) {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Exists checks if at least one entity contains all the given types. The
+// search stops at the first match.
+//
+// For example:
+//    if !ecs.Exists(Enemy{}, Health{}) {
+//        // All enemies are dead
+//    }
+func (ecs *ECS) Exists(types ...interface{}) bool {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	return ecs.nextMatch(EntityNone, ecs.componentNames(types)) != nil
+}
+
+// IterateCtx works like Iterate but checks ctx between chunks of the
+// scan. If ctx is cancelled the scan is aborted and the error of ctx
+// is returned.
+//
+// For example you want to abort long scans of a request handler:
+//    it, err := ecs.IterateCtx(r.Context(), Pos{})
+func (ecs *ECS) IterateCtx(ctx context.Context, types ...interface{}) (EntityIterator, error) {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rv := ecs.reads()
+
+	var foundEnts []*EntityWrap
+
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("IterateCtx", types), len(rv.entries), len(foundEnts), time.Since(start))
+		}()
+	}
+
+	names := ecs.componentNames(types)
+	foundEnts, err := ecs.scan(ctx, ecs.queryLabels("IterateCtx", types), rv, rv.routines, func(i int) bool {
+		return rv.has(i, names)
+	})
+
+	return foundEnts, err
+}
+
+// Each calls fn for every entity that contains all the given types. In
+// contrast to Iterate no wrapper is allocated per entity, a single wrap is
+// reused for all calls and the lock is held during the whole iteration.
+// If fn returns a error the iteration stops and the error is returned.
+//
+// For example:
+//    err := ecs.Each(func(ew *kinshi.EntityWrap) error {
+//        return ew.View(func(p *Pos, v *Velocity) {
+//            p.X += v.X
+//        })
+//    }, Pos{}, Velocity{})
+//
+// Important: The wrap is only valid during the call of fn and must not
+// be stored. Like in View, fn must not add or remove entities.
+func (ecs *ECS) Each(fn func(ew *EntityWrap) error, types ...interface{}) error {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	ecs.enterView()
+	defer ecs.leaveView()
+
+	matched := 0
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("Each", types), len(ecs.entities), matched, time.Since(start))
+		}()
+	}
+
+	names := ecs.componentNames(types)
+	ew := &EntityWrap{parent: ecs, held: true}
+
+	for i := range ecs.entities {
+		if !ecs.hasComponents(&ecs.entities[i], names) {
+			continue
+		}
+		matched++
+
+		ew.ent = ecs.entities[i].Ent
+		if err := fn(ew); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Sample returns up to n uniformly random entities that contain all the
+// given types. The entities are picked by reservoir sampling during the
+// scan, so only n wrappers are allocated. If rng is nil the global source
+// of math/rand is used. The returned entities are in no particular order.
+//
+// For example you want to pick a random target:
+//    targets := ecs.Sample(1, rng, Health{}, Hostile{})
+func (ecs *ECS) Sample(n int, rng *rand.Rand, types ...interface{}) EntityIterator {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	if n <= 0 {
+		return nil
+	}
+
+	intn := rand.Intn
+	if rng != nil {
+		intn = rng.Intn
+	}
+
+	rv := ecs.reads()
+
+	var sample EntityIterator
+
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("Sample", types), len(rv.entries), len(sample), time.Since(start))
+		}()
+	}
+
+	names := ecs.componentNames(types)
+
+	seen := 0
+	for i := range rv.entries {
+		if !rv.has(i, names) {
+			continue
+		}
+		seen++
+
+		if len(sample) < n {
+			sample = append(sample, ecs.wrap(rv.entries[i].Ent))
+		} else if j := intn(seen); j < n {
+			sample[j].ent = rv.entries[i].Ent
+		}
+	}
+
+	return sample
+}
+
+// Query is a configurable query that is created with ECS.Query. In contrast
+// to a plain Iterate the options of a query are applied while scanning,
+// so a limited query stops as soon as enough entities have been found.
+type Query struct {
+	ecs      *ECS
+	types    []interface{}
+	limit    int
+	offset   int
+	reverse  bool
+	routines int
+}
+
+// Query creates a query for entities that contain all the given types.
+//
+// For example you want to process at most 100 entities per frame:
+//    it := ecs.Query(Pos{}, Velocity{}).Offset(done).Limit(100).Iterate()
+func (ecs *ECS) Query(types ...interface{}) *Query {
+	return &Query{
+		ecs:   ecs,
+		types: types,
+		limit: -1,
+	}
+}
+
+// Limit sets the maximum number of returned entities.
+func (q *Query) Limit(n int) *Query {
+	if n < 0 {
+		n = 0
+	}
+	q.limit = n
+	return q
+}
+
+// Offset skips the first n matched entities.
+func (q *Query) Offset(n int) *Query {
+	if n < 0 {
+		n = 0
+	}
+	q.offset = n
+	return q
+}
+
+// Reverse scans the entities in descending id order. Combined with
+// Limit this returns the most recently added entities.
+func (q *Query) Reverse() *Query {
+	q.reverse = true
+	return q
+}
+
+// Parallel overrides the number of go routines that are used to scan
+// the entities for this query. Queries that are limited are always
+// scanned by a single go routine, so they can stop early.
+func (q *Query) Parallel(n int) *Query {
+	q.routines = n
+	return q
+}
+
+// Iterate runs the query and returns a iterator that can be range'd over.
+// The entities are ordered by id, or descending if Reverse was set.
+func (q *Query) Iterate() EntityIterator {
+	ecs := q.ecs
+
+	ecs.checkQueryTypes(q.types...)
+	ecs.countIterate()
+
+	rv := ecs.reads()
+
+	var foundEnts []*EntityWrap
+	scanned := len(rv.entries)
+
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("Query", q.types), scanned, len(foundEnts), time.Since(start))
+		}()
+	}
+
+	names := q.ecs.componentNames(q.types)
+
+	routines := rv.routines
+	if q.routines > 0 {
+		routines = q.routines
+	}
+
+	// Without a limit the whole world has to be scanned anyway,
+	// so the scan can be done in parallel.
+	if q.limit < 0 {
+		foundEnts, _ = ecs.scan(context.Background(), ecs.queryLabels("Query", q.types), rv, routines, func(i int) bool {
+			return rv.has(i, names)
+		})
+		if q.reverse {
+			EntityIterator(foundEnts).Reverse()
+		}
+		foundEnts = EntityIterator(foundEnts).Offset(q.offset)
+		return foundEnts
+	}
+
+	skipped := 0
+	for n := range rv.entries {
+		if len(foundEnts) >= q.limit {
+			scanned = n
+			break
+		}
+
+		i := n
+		if q.reverse {
+			i = len(rv.entries) - 1 - n
+		}
+
+		if !rv.has(i, names) {
+			continue
+		}
+
+		if skipped < q.offset {
+			skipped++
+			continue
+		}
+
+		foundEnts = append(foundEnts, ecs.wrap(rv.entries[i].Ent))
+	}
+
+	return foundEnts
+}
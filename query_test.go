@@ -0,0 +1,288 @@
+package kinshi
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+)
+
+func TestECS_Find(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: i, Y: i * 2}})
+	}
+	_, _ = ecs.AddEntity(&DynamicUnit{})
+
+	calls := 0
+	ew, err := ecs.Find(func(ew *EntityWrap) bool {
+		calls++
+		var found bool
+		_ = ew.View(func(p *Pos) {
+			found = p.X == 3 && p.Y == 6
+		})
+		return found
+	}, Pos{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, EntityID(4), ew.GetEntity().ID())
+	}
+	assert.Equal(t, 4, calls, "find didn't stop at the first match")
+
+	_, err = ecs.Find(func(ew *EntityWrap) bool {
+		return true
+	}, Velocity{})
+	assert.Equal(t, ErrNotFound, err)
+
+	ew, err = ecs.Find(func(ew *EntityWrap) bool {
+		// Removing entities while searching is fine.
+		_ = ecs.RemoveEntity(ew.GetEntity())
+		return false
+	})
+	assert.Equal(t, ErrNotFound, err)
+	assert.Nil(t, ew)
+	assert.Equal(t, 0, ecs.Len())
+}
+
+func TestECS_FindRemove(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	// Every entity is visited once, even if pred removes it or
+	// replaces it by a new one.
+	var visited []EntityID
+	_, err := ecs.Find(func(ew *EntityWrap) bool {
+		id := ew.GetEntity().ID()
+		visited = append(visited, id)
+
+		switch id % 3 {
+		case 0:
+			assert.NoError(t, ecs.RemoveEntity(ew.GetEntity()))
+		case 1:
+			assert.NoError(t, ecs.RemoveEntity(ew.GetEntity()))
+			_, _ = ecs.AddEntity(&DynamicUnit{})
+		}
+		return false
+	}, Pos{})
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, []EntityID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, visited)
+	assert.Equal(t, 3+4, ecs.Len())
+}
+
+func TestECS_Count(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 5; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{})
+	_, _ = ecs.AddEntity(dyn)
+
+	assert.Equal(t, 6, ecs.Count())
+	assert.Equal(t, 6, ecs.Count(Name{}))
+	assert.Equal(t, 5, ecs.Count(Pos{}, Health{}))
+	assert.Equal(t, 1, ecs.Count(Velocity{}))
+	assert.Equal(t, 1, ecs.Count("Velocity", Name{}))
+	assert.Equal(t, 0, ecs.Count(Velocity{}, Pos{}))
+
+	for _, types := range [][]interface{}{{}, {Pos{}}, {Velocity{}, Name{}}} {
+		assert.Equal(t, ecs.Iterate(types...).Count(), ecs.Count(types...))
+	}
+}
+
+func TestECS_Exists(t *testing.T) {
+	ecs := New()
+
+	assert.False(t, ecs.Exists())
+
+	_, _ = ecs.AddEntity(&Unit{})
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{})
+	_, _ = ecs.AddEntity(dyn)
+
+	assert.True(t, ecs.Exists())
+	assert.True(t, ecs.Exists(Pos{}, Health{}))
+	assert.True(t, ecs.Exists(Velocity{}, Name{}))
+	assert.False(t, ecs.Exists(Velocity{}, Pos{}))
+
+	_ = ecs.RemoveEntity(dyn)
+	assert.False(t, ecs.Exists(Velocity{}))
+}
+
+func TestQuery_LimitOffset(t *testing.T) {
+	ecs := New(WithProfiler())
+	ecs.SetRoutineCount(4)
+
+	for i := 0; i < 20; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	assert.Equal(t, []EntityID{1, 2, 3}, iteratorIDs(ecs.Query(Pos{}).Limit(3).Iterate()))
+	assert.Equal(t, []EntityID{6, 7, 8}, iteratorIDs(ecs.Query(Pos{}).Offset(5).Limit(3).Iterate()))
+	assert.Equal(t, []EntityID{19, 20}, iteratorIDs(ecs.Query(Pos{}).Offset(18).Iterate()))
+	assert.Nil(t, ecs.Query(Pos{}).Offset(20).Iterate())
+	assert.Nil(t, ecs.Query(Pos{}).Limit(0).Iterate())
+	assert.Nil(t, ecs.Query(Velocity{}).Limit(3).Iterate())
+
+	for _, qs := range ecs.QueryStats() {
+		if qs.Query == "Query(Pos)" {
+			assert.Equal(t, uint64(3+8+20+20+0), qs.Scanned, "limited query didn't stop early")
+		}
+	}
+
+	it := ecs.Iterate(Pos{})
+	assert.Equal(t, iteratorIDs(ecs.Query(Pos{}).Offset(5).Limit(3).Iterate()), iteratorIDs(it.Offset(5).Limit(3)))
+	assert.Nil(t, it.Offset(100))
+	assert.Len(t, it.Limit(100), 20)
+}
+
+func TestQuery_Reverse(t *testing.T) {
+	ecs := New()
+	ecs.SetRoutineCount(4)
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	assert.Equal(t, []EntityID{10, 9, 8}, iteratorIDs(ecs.Query(Pos{}).Reverse().Limit(3).Iterate()))
+	assert.Equal(t, []EntityID{8, 7}, iteratorIDs(ecs.Query(Pos{}).Reverse().Offset(2).Limit(2).Iterate()))
+	assert.Equal(t, []EntityID{2, 1}, iteratorIDs(ecs.Query(Pos{}).Reverse().Offset(8).Iterate()))
+	assert.Equal(t, iteratorIDs(ecs.Query().Reverse().Iterate()), iteratorIDs(ecs.Iterate().Reverse()))
+}
+
+func TestECS_Sample(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	rng := rand.New(rand.NewSource(1))
+
+	assert.Nil(t, ecs.Sample(0, rng, Pos{}))
+	assert.Nil(t, ecs.Sample(3, rng, Velocity{}))
+	assert.Len(t, ecs.Sample(20, rng, Pos{}), 10)
+
+	hits := map[EntityID]int{}
+	for i := 0; i < 1000; i++ {
+		sample := ecs.Sample(3, rng, Pos{})
+		if !assert.Len(t, sample, 3) {
+			return
+		}
+
+		ids := map[EntityID]struct{}{}
+		for _, ew := range sample {
+			ids[ew.GetEntity().ID()] = struct{}{}
+			hits[ew.GetEntity().ID()]++
+		}
+		assert.Len(t, ids, 3, "sample contains duplicates")
+	}
+
+	for id := EntityID(1); id <= 10; id++ {
+		assert.InDelta(t, 300, hits[id], 100, "sample isn't uniform")
+	}
+}
+
+// cancelAfter is a context that reports to be cancelled
+// after Err has been called n times.
+type cancelAfter struct {
+	context.Context
+	n int32
+}
+
+func (c *cancelAfter) Err() error {
+	if atomic.AddInt32(&c.n, -1) < 0 {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestECS_IterateCtx(t *testing.T) {
+	ecs := New()
+	ecs.SetRoutineCount(2)
+
+	for i := 0; i < scanChunk*4; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	it, err := ecs.IterateCtx(context.Background(), Pos{})
+	assert.NoError(t, err)
+	assert.Equal(t, iteratorIDs(ecs.Iterate(Pos{})), iteratorIDs(it))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	it, err = ecs.IterateCtx(ctx, Pos{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, it)
+
+	// Cancelled after the first chunks of the scan.
+	it, err = ecs.IterateCtx(&cancelAfter{Context: context.Background(), n: 3}, Pos{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, it)
+}
+
+func TestQuery_Parallel(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 100; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+		_, _ = ecs.AddEntity(&DynamicUnit{})
+	}
+
+	expected := iteratorIDs(ecs.Iterate(Pos{}))
+	for _, threshold := range []int{0, DefaultParallelThreshold} {
+		ecs.SetParallelThreshold(threshold)
+		for _, n := range []int{0, 1, 3, 8, 500} {
+			assert.Equal(t, expected, iteratorIDs(ecs.Query(Pos{}).Parallel(n).Iterate()), "parallel %d, threshold %d", n, threshold)
+		}
+	}
+	assert.Equal(t, expected[:5], iteratorIDs(ecs.Query(Pos{}).Parallel(8).Limit(5).Iterate()))
+}
+
+func TestECS_Each(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: i}})
+	}
+	_, _ = ecs.AddEntity(&DynamicUnit{})
+
+	sum := 0
+	assert.NoError(t, ecs.Each(func(ew *EntityWrap) error {
+		return ew.View(func(p *Pos) {
+			sum += p.X
+			p.X = 0
+		})
+	}, Pos{}))
+	assert.Equal(t, 45, sum)
+	_ = ecs.UpdateAll(func(p *Pos) {
+		assert.Equal(t, 0, p.X, "change in each got lost")
+	})
+
+	errStop := errors.New("stop")
+	calls := 0
+	assert.Equal(t, errStop, ecs.Each(func(ew *EntityWrap) error {
+		calls++
+		return errStop
+	}))
+	assert.Equal(t, 1, calls)
+
+	each := func() {
+		_ = ecs.Each(func(ew *EntityWrap) error {
+			_ = ew.GetEntity().(*Unit).Pos.X
+			return nil
+		}, Pos{})
+	}
+	small := testing.AllocsPerRun(10, each)
+	for i := 0; i < 1000; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+	assert.Equal(t, small, testing.AllocsPerRun(10, each), "each allocates per entity")
+}
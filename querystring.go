@@ -0,0 +1,102 @@
+package kinshi
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parsedQuery is the result of parsing a query string.
+type parsedQuery struct {
+	types []interface{}
+	where interface{}
+	limit int
+}
+
+// parseQuery parses a query string of the form:
+//    SELECT <components> | * [WHERE <expression>] [LIMIT <n>]
+func parseQuery(src string) (parsedQuery, error) {
+	q := parsedQuery{where: "true", limit: -1}
+
+	tokens, err := tokenizeExpr(src)
+	if err != nil {
+		return q, err
+	}
+
+	p := &exprParser{tokens: tokens}
+	keyword := func(word string) bool {
+		t := p.peek()
+		if t.kind == 'i' && strings.EqualFold(t.text, word) {
+			p.pos++
+			return true
+		}
+		return false
+	}
+
+	if !keyword("SELECT") {
+		return q, p.errorf("missing SELECT")
+	}
+
+	if _, ok := p.accept("*"); !ok {
+		for {
+			t := p.next()
+			if t.kind != 'i' {
+				return q, fmt.Errorf("missing component name at %d", t.pos)
+			}
+			q.types = append(q.types, t.text)
+
+			if _, ok := p.accept(","); !ok {
+				break
+			}
+		}
+	}
+
+	if keyword("WHERE") {
+		start := p.peek().pos
+		if _, err := p.parseOr(); err != nil {
+			return q, err
+		}
+		q.where = src[start:p.peek().pos]
+	}
+
+	if keyword("LIMIT") {
+		t := p.next()
+		n, ok := t.value.(float64)
+		if t.kind != 'n' || !ok || n < 0 || n != float64(int(n)) {
+			return q, fmt.Errorf("invalid limit at %d", t.pos)
+		}
+		q.limit = int(n)
+	}
+
+	if p.peek().kind != 0 {
+		return q, p.errorf("unexpected '%s'", p.peek().text)
+	}
+
+	return q, nil
+}
+
+// QueryString runs a query that is given as text, which is useful for
+// tooling, consoles or scripting layers where the queries don't come from
+// Go code. The query selects the entities that contain all the listed
+// components or all entities for *. The optional WHERE clause takes a
+// expression like IterateWhere and LIMIT caps the number of results.
+// The keywords are case insensitive and the entities are ordered by id.
+//
+// For example:
+//    it, err := ecs.QueryString("SELECT Pos, Health WHERE Health.Value < 10 AND tag('enemy')")
+func (ecs *ECS) QueryString(query string) (EntityIterator, error) {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("query '%s': %w", query, err)
+	}
+
+	it, err := ecs.IterateWhere(q.where, q.types...)
+	if err != nil {
+		return nil, fmt.Errorf("query '%s': %w", query, err)
+	}
+
+	if q.limit >= 0 {
+		it = it.Limit(q.limit)
+	}
+
+	return it, nil
+}
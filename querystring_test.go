@@ -0,0 +1,61 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type Enemy struct{}
+
+type Orc struct {
+	BaseEntity
+	Enemy
+	Pos
+	Health
+}
+
+func TestQueryString(t *testing.T) {
+	ecs := New()
+
+	for i := 1; i <= 4; i++ {
+		_, _ = ecs.AddEntity(&Orc{Health: Health{Value: i * 5}})
+		_, _ = ecs.AddEntity(&Unit{Health: Health{Value: i * 5}})
+	}
+	_, _ = ecs.AddEntity(&DynamicUnit{})
+
+	it, err := ecs.QueryString("SELECT Pos, Health WHERE Health.Value < 10 AND tag('enemy')")
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{1}, iteratorIDs(it))
+
+	it, err = ecs.QueryString("select Health where not tag('Enemy') limit 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{2, 4}, iteratorIDs(it))
+
+	it, err = ecs.QueryString("SELECT * LIMIT 0")
+	assert.NoError(t, err)
+	assert.Empty(t, it)
+
+	it, err = ecs.QueryString("SELECT *")
+	assert.NoError(t, err)
+	assert.Len(t, it, 9)
+
+	// Components that aren't tags don't match.
+	it, err = ecs.QueryString("SELECT * WHERE tag('health')")
+	assert.NoError(t, err)
+	assert.Empty(t, it)
+
+	for _, query := range []string{
+		"",
+		"Health",
+		"SELECT",
+		"SELECT Health,",
+		"SELECT Health WHERE",
+		"SELECT Health WHERE Health.Value <",
+		"SELECT Health LIMIT -1",
+		"SELECT Health LIMIT 1.5",
+		"SELECT Health ORDER BY Health.Value",
+	} {
+		_, err := ecs.QueryString(query)
+		assert.Error(t, err, query)
+	}
+}
@@ -0,0 +1,72 @@
+package kinshi
+
+import (
+	"sort"
+)
+
+// readView is a immutable copy of the entity storage. It's published
+// after the first read that follows a mutation, so that the read-only
+// queries don't need to acquire the lock. Because the view is rebuilt
+// lazily a burst of mutations only results in a single copy.
+type readView struct {
+	ids       []EntityID
+	entries   []entityEntry
+	metas     []typeMeta
+	routines  int
+	threshold int
+}
+
+// find returns the index of the entity with the given id or
+// the index at which it would be inserted if it's missing.
+func (rv *readView) find(id EntityID) (int, bool) {
+	idx := sort.Search(len(rv.ids), func(i int) bool {
+		return rv.ids[i] >= id
+	})
+	return idx, idx < len(rv.ids) && rv.ids[idx] == id
+}
+
+// has checks if the entity at index i contains
+// all the components with the given names.
+func (rv *readView) has(i int, names []string) bool {
+	return matchComponents(rv.metas[i], rv.entries[i].Ent, names)
+}
+
+// reads returns the current read view and builds
+// it if the storage was mutated since the last read.
+func (ecs *ECS) reads() *readView {
+	if rv, _ := ecs.published.Load().(*readView); rv != nil {
+		return rv
+	}
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	// Another reader could have been faster.
+	if rv, _ := ecs.published.Load().(*readView); rv != nil {
+		return rv
+	}
+
+	rv := &readView{
+		ids:       make([]EntityID, len(ecs.entities)),
+		entries:   make([]entityEntry, len(ecs.entities)),
+		metas:     make([]typeMeta, len(ecs.entities)),
+		routines:  ecs.routines,
+		threshold: ecs.parThreshold,
+	}
+
+	copy(rv.entries, ecs.entities)
+	for i := range ecs.entities {
+		rv.ids[i] = ecs.entities[i].Ent.ID()
+		rv.metas[i] = ecs.metaCache[ecs.entities[i].TypeName]
+	}
+
+	ecs.published.Store(rv)
+
+	return rv
+}
+
+// invalidateReads drops the read view. It's called whenever the
+// write lock is acquired, so every mutation results in a new view.
+func (ecs *ECS) invalidateReads() {
+	ecs.published.Store((*readView)(nil))
+}
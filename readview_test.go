@@ -0,0 +1,80 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestECS_LockFreeReads(t *testing.T) {
+	ecs := New()
+
+	id, _ := ecs.AddEntity(&Unit{})
+	_, _ = ecs.AddEntity(&DynamicUnit{})
+
+	// Warm up the read view, afterwards reads don't touch the lock.
+	assert.Equal(t, 2, ecs.Iterate().Count())
+
+	ecs.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_, err := ecs.Get(id)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, ecs.Iterate(Pos{}).Count())
+		assert.Equal(t, 1, ecs.IterateSpecific(DynamicUnit{}).Count())
+		assert.Equal(t, 2, ecs.Count(Name{}))
+		assert.True(t, ecs.Exists(Health{}))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("reads were blocked by the lock")
+	}
+	ecs.Unlock()
+	<-done
+
+	// Mutations publish a new view.
+	idNew, _ := ecs.AddEntity(&Unit{})
+	assert.Equal(t, 2, ecs.Count(Pos{}))
+	_, err := ecs.Get(idNew)
+	assert.NoError(t, err)
+
+	_ = ecs.RemoveByID(id)
+	_, err = ecs.Get(id)
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, []EntityID{idNew}, iteratorIDs(ecs.Iterate(Pos{})))
+}
+
+func TestECS_LockFreeReadsConcurrent(t *testing.T) {
+	ecs := New()
+	ecs.SetRoutineCount(4)
+
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				it := ecs.Iterate(Pos{})
+				for j := 1; j < len(it); j++ {
+					assert.True(t, it[j-1].GetEntity().ID() < it[j].GetEntity().ID())
+				}
+				_ = ecs.Count(Name{})
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		id, _ := ecs.AddEntity(&Unit{})
+		if i%3 == 0 {
+			_ = ecs.RemoveByID(id)
+		}
+	}
+
+	wg.Wait()
+	assert.Equal(t, 133, ecs.Count(Pos{}))
+}
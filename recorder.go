@@ -0,0 +1,260 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+)
+
+// CommandHandler executes a high-level command, like spawning a prefab,
+// with the recorded arguments.
+type CommandHandler func(ecs *ECS, args json.RawMessage) error
+
+// RecordedCommand is a single command of a Recorder.
+type RecordedCommand struct {
+	Tick uint64
+	Name string
+	Args json.RawMessage `json:",omitempty"`
+}
+
+// Recorder records high-level commands together with the tick in which
+// they were executed. The recording can be encoded as JSON and later be
+// replayed against a fresh world with the same registered types and
+// handlers, for example for demo playback or automated regression
+// scenarios.
+//
+// For example:
+//    rec := kinshi.NewRecorder()
+//    rec.Handle("spawn_goblin", func(ecs *kinshi.ECS, args json.RawMessage) error {
+//        var pos Pos
+//        if err := json.Unmarshal(args, &pos); err != nil {
+//            return err
+//        }
+//        _, err := ecs.AddEntity(&Goblin{Pos: pos})
+//        return err
+//    })
+//
+//    // In the game loop
+//    rec.Exec(world, "spawn_goblin", Pos{X: 10, Y: 5})
+//    rec.Advance()
+//
+// Besides the custom commands the same mutations as in a CommandBuffer
+// can be recorded with AddEntity, RemoveEntity, SetComponent and
+// RemoveComponent.
+type Recorder struct {
+	mtx      sync.Mutex
+	handlers map[string]CommandHandler
+	tick     uint64
+	Commands []RecordedCommand
+}
+
+// NewRecorder creates a new empty recorder at tick 0.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		handlers: map[string]CommandHandler{},
+	}
+}
+
+// Handle registers the handler of the command with the given name. The
+// handler is called without holding any lock, so it can use the ECS
+// like any other code. Handlers need to be deterministic for the replay
+// to reproduce the recorded world.
+func (r *Recorder) Handle(name string, fn CommandHandler) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.handlers[name] = fn
+}
+
+// Tick returns the current tick.
+func (r *Recorder) Tick() uint64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.tick
+}
+
+// SetTick sets the current tick, e.g. to the frame counter of the
+// game. The tick must not go backwards while recording.
+func (r *Recorder) SetTick(tick uint64) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.tick = tick
+}
+
+// Advance increments the current tick and returns it.
+func (r *Recorder) Advance() uint64 {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.tick++
+	return r.tick
+}
+
+func (r *Recorder) handler(name string) (CommandHandler, bool) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	fn, ok := r.handlers[name]
+	return fn, ok
+}
+
+func (r *Recorder) record(name string, args json.RawMessage) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.Commands = append(r.Commands, RecordedCommand{
+		Tick: r.tick,
+		Name: name,
+		Args: args,
+	})
+}
+
+// Exec executes the command with the given name and records it at the
+// current tick. The arguments are encoded as JSON and handed to the
+// handler in encoded form, so that a replay calls it with the same
+// values. Commands whose handler fails aren't recorded.
+func (r *Recorder) Exec(ecs *ECS, name string, args interface{}) error {
+	fn, ok := r.handler(name)
+	if !ok {
+		return fmt.Errorf("command '%s': %w", name, ErrNotFound)
+	}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(ecs, data); err != nil {
+		return err
+	}
+
+	r.record(name, data)
+	return nil
+}
+
+// apply applies a recorded mutation of the same form as
+// used by CommandBuffer and records it on success.
+func (r *Recorder) apply(ecs *ECS, cmd Command) error {
+	ecs.checkMutation()
+	ecs.lock()
+	err := ecs.applyCommand(&cmd)
+	ecs.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.capture(ecs.names); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+
+	r.record(string(cmd.Type), data)
+	return nil
+}
+
+// AddEntity adds the entity and records it.
+func (r *Recorder) AddEntity(ecs *ECS, ent Entity) (EntityID, error) {
+	cb := ecs.NewCommandBuffer()
+	id, err := cb.AddEntity(ent)
+	if err != nil {
+		return EntityNone, err
+	}
+	return id, r.apply(ecs, cb.commands[0])
+}
+
+// RemoveEntity removes the entity with the given id and records it.
+func (r *Recorder) RemoveEntity(ecs *ECS, id EntityID) error {
+	cb := ecs.NewCommandBuffer()
+	cb.RemoveEntity(id)
+	return r.apply(ecs, cb.commands[0])
+}
+
+// SetComponent overwrites (or adds in case of a dynamic entity)
+// the component of the entity with a copy of c and records it.
+func (r *Recorder) SetComponent(ecs *ECS, id EntityID, c interface{}) error {
+	cb := ecs.NewCommandBuffer()
+	cb.SetComponent(id, c)
+	return r.apply(ecs, cb.commands[0])
+}
+
+// RemoveComponent removes a dynamic component and records it. If
+// c is a string the component will be removed by name.
+func (r *Recorder) RemoveComponent(ecs *ECS, id EntityID, c interface{}) error {
+	cb := ecs.NewCommandBuffer()
+	cb.RemoveComponent(id, c)
+	return r.apply(ecs, cb.commands[0])
+}
+
+// replayCommand executes a single recorded command.
+func (r *Recorder) replayCommand(ecs *ECS, rc RecordedCommand) error {
+	switch CommandType(rc.Name) {
+	case CommandAddEntity, CommandRemoveEntity, CommandSetComponent, CommandRemoveComponent:
+		var cmd Command
+		if err := json.Unmarshal(rc.Args, &cmd); err != nil {
+			return err
+		}
+
+		ecs.checkMutation()
+		ecs.lock()
+		defer ecs.Unlock()
+
+		return ecs.applyCommand(&cmd)
+	}
+
+	fn, ok := r.handler(rc.Name)
+	if !ok {
+		return fmt.Errorf("command '%s': %w", rc.Name, ErrNotFound)
+	}
+	return fn(ecs, rc.Args)
+}
+
+// commands returns a copy of the recorded commands of the ticks
+// in the range [from, to).
+func (r *Recorder) commands(from, to uint64) []RecordedCommand {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	start := sort.Search(len(r.Commands), func(i int) bool {
+		return r.Commands[i].Tick >= from
+	})
+	end := sort.Search(len(r.Commands), func(i int) bool {
+		return r.Commands[i].Tick >= to
+	})
+
+	return append([]RecordedCommand(nil), r.Commands[start:end]...)
+}
+
+// ReplayTick executes all recorded commands of the given tick in the
+// order they were recorded in. Calling it for every tick of the game
+// loop plays the recording back in real time.
+func (r *Recorder) ReplayTick(ecs *ECS, tick uint64) error {
+	for i, rc := range r.commands(tick, tick+1) {
+		if err := r.replayCommand(ecs, rc); err != nil {
+			return fmt.Errorf("command %d at tick %d: %w", i, tick, err)
+		}
+	}
+
+	return nil
+}
+
+// Replay executes all recorded commands in order. Replaying against a
+// fresh world with the same registered types and handlers reproduces
+// the recorded world, including the entity ids.
+func (r *Recorder) Replay(ecs *ECS) error {
+	for i, rc := range r.commands(0, math.MaxUint64) {
+		if err := r.replayCommand(ecs, rc); err != nil {
+			return fmt.Errorf("command %d at tick %d: %w", i, rc.Tick, err)
+		}
+	}
+
+	return nil
+}
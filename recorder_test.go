@@ -0,0 +1,82 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestRecorder() *Recorder {
+	rec := NewRecorder()
+	rec.Handle("spawn_unit", func(ecs *ECS, args json.RawMessage) error {
+		var pos Pos
+		if err := json.Unmarshal(args, &pos); err != nil {
+			return err
+		}
+		_, err := ecs.AddEntity(&Unit{Pos: pos, Health: Health{Value: 100}})
+		return err
+	})
+	return rec
+}
+
+func TestRecorder(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+
+	rec := newTestRecorder()
+	assert.NoError(t, rec.Exec(ecs, "spawn_unit", Pos{X: 1, Y: 2}))
+	assert.NoError(t, rec.Exec(ecs, "spawn_unit", Pos{X: 3, Y: 4}))
+	assert.Error(t, rec.Exec(ecs, "unknown", nil))
+
+	rec.Advance()
+	dyn, err := rec.AddEntity(ecs, &DynamicUnit{Name: Name{Value: "dyn"}})
+	assert.NoError(t, err)
+	assert.NoError(t, rec.SetComponent(ecs, dyn, Velocity{X: 2}))
+
+	rec.Advance()
+	first, _ := ecs.Iterate(Pos{}).First()
+	assert.NoError(t, rec.RemoveEntity(ecs, first.GetEntity().ID()))
+	assert.NoError(t, rec.RemoveComponent(ecs, dyn, Velocity{}))
+
+	assert.Len(t, rec.Commands, 6)
+	assert.Equal(t, uint64(2), rec.Tick())
+
+	data, err := json.Marshal(rec)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	replay := newTestRecorder()
+	if !assert.NoError(t, json.Unmarshal(data, replay)) {
+		return
+	}
+
+	replayed := New()
+	assert.NoError(t, replayed.RegisterEntity(&Unit{}))
+	assert.NoError(t, replayed.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, replayed.RegisterComponent(Velocity{}))
+
+	assert.NoError(t, replay.ReplayTick(replayed, 0))
+	assert.Equal(t, 2, replayed.Iterate().Count(), "tick 0 wasn't replayed")
+
+	assert.NoError(t, replay.ReplayTick(replayed, 1))
+	assert.Equal(t, 3, replayed.Iterate().Count(), "tick 1 wasn't replayed")
+	assert.True(t, replayed.MustGet(dyn).Has(Velocity{}))
+
+	assert.NoError(t, replay.ReplayTick(replayed, 2))
+
+	sum := ecs.Checksum()
+	replayedSum := replayed.Checksum()
+	assert.Equal(t, sum, replayedSum, "replayed world differs")
+
+	fresh := New()
+	assert.NoError(t, fresh.RegisterEntity(&Unit{}))
+	assert.NoError(t, fresh.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, fresh.RegisterComponent(Velocity{}))
+	assert.NoError(t, replay.Replay(fresh))
+
+	freshSum := fresh.Checksum()
+	assert.Equal(t, sum, freshSum, "replayed world differs")
+}
@@ -3,25 +3,564 @@ package kinshi
 import (
 	"fmt"
 	"reflect"
+	"sort"
+	"strings"
+	"sync"
 )
 
-func getTypeName(s interface{}) string {
-	t := reflect.TypeOf(s)
+// TypeNamer derives the names by which entity and component types are
+// identified in the registry, in queries and in snapshots. The type that
+// is passed is never a pointer.
+type TypeNamer interface {
+	TypeName(t reflect.Type) string
+}
+
+// TypeNamerFunc is a function that implements TypeNamer.
+type TypeNamerFunc func(t reflect.Type) string
+
+// TypeName calls fn(t).
+func (fn TypeNamerFunc) TypeName(t reflect.Type) string {
+	return fn(t)
+}
+
+var (
+	// BareNames identifies types by their name without the package
+	// path (e.g. "Pos"). This is the default. Internally a name stays
+	// bound to the first type that used it in a ECS, so registering or
+	// setting a type of another package with the same name fails with
+	// ErrTypeConflict and queries for it don't match the first type. Use
+	// QualifiedNames to use both.
+	BareNames TypeNamer = TypeNamerFunc(func(t reflect.Type) string {
+		return t.Name()
+	})
+
+	// QualifiedNames identifies types by their name including the package
+	// path (e.g. "github.com/user/game.Pos"), so that types of the same
+	// name from different packages don't collide.
+	QualifiedNames TypeNamer = TypeNamerFunc(func(t reflect.Type) string {
+		if t.PkgPath() == "" {
+			return t.Name()
+		}
+		return t.PkgPath() + "." + t.Name()
+	})
+)
+
+// typeNames derives the names of types with a TypeNamer and interns
+// them, so that the namer only runs once per type instead of on every
+// query. Everything that is derived from the names is cached alongside.
+// Every ECS holds its own, so worlds can use different namers.
+type typeNames struct {
+	namer   TypeNamer
+	names   sync.Map
+	statics sync.Map
+	views   sync.Map
+}
+
+func newTypeNames(namer TypeNamer) *typeNames {
+	return &typeNames{namer: namer}
+}
+
+// defaultNames derives bare names. It's used by worlds without a custom
+// namer and by dynamic entities that aren't stored in a ECS.
+var defaultNames = newTypeNames(BareNames)
+
+// WithTypeNamer replaces how the names of types are derived for this ECS.
+// A custom namer can for example keep the old name of a renamed type, so
+// that existing saves can still be loaded:
+//    ecs := kinshi.New(kinshi.WithTypeNamer(kinshi.TypeNamerFunc(func(t reflect.Type) string {
+//        if t == reflect.TypeOf(Position{}) {
+//            return "Pos"
+//        }
+//        return t.Name()
+//    })))
+//
+// Snapshots that were written with bare names can still be loaded as long
+// as the bare names of the registered types are unique.
+func WithTypeNamer(n TypeNamer) Option {
+	return func(ecs *ECS) {
+		ecs.names = newTypeNames(n)
+	}
+}
+
+// WithQualifiedNames is a shortcut for WithTypeNamer(QualifiedNames).
+func WithQualifiedNames() Option {
+	return WithTypeNamer(QualifiedNames)
+}
+
+// typeName returns the name by which the type is identified.
+func (tn *typeNames) typeName(t reflect.Type) string {
 	if t.Kind() == reflect.Ptr {
-		return t.Elem().Name()
+		t = t.Elem()
+	}
+
+	if name, ok := tn.names.Load(t); ok {
+		return name.(string)
+	}
+
+	name := tn.namer.TypeName(t)
+	tn.names.Store(t, name)
+	return name
+}
+
+// bareName strips the package path of a qualified name.
+func bareName(name string) string {
+	return name[strings.LastIndex(name, ".")+1:]
+}
+
+func (tn *typeNames) getTypeName(s interface{}) string {
+	return tn.typeName(reflect.TypeOf(s))
+}
+
+// TypeName returns the bare name of the type of the entity or component
+// v. Worlds that use WithTypeNamer can name types differently, use
+// ECS.TypeName for the name by which a specific ECS identifies a type.
+func TypeName(v interface{}) string {
+	return defaultNames.getTypeName(v)
+}
+
+// TypeName returns the name by which the ECS identifies the type of
+// the entity or component v, e.g. in queries or in snapshots.
+func (ecs *ECS) TypeName(v interface{}) string {
+	return ecs.names.getTypeName(v)
+}
+
+// componentKind describes how a static component is held by its field.
+type componentKind int
+
+const (
+	// componentValue is a plain struct field that is always present.
+	componentValue componentKind = iota
+	// componentPtr is a pointer to a component. A nil pointer means that the
+	// component is missing, so it can be allocated lazily or be shared
+	// between entities.
+	componentPtr
+	// componentInterface is a named interface that holds one of the
+	// registered concrete component types.
+	componentInterface
+)
+
+// staticComponent is a struct field that holds a component.
+type staticComponent struct {
+	name  string
+	index int
+	kind  componentKind
+	names *typeNames
+}
+
+// ptr returns a pointer to the component that is held by the field of
+// val. Pointer fields are returned as they are, for interface fields a
+// pointer to the interface is returned. If the field is nil the
+// component is missing and false is returned.
+func (sc staticComponent) ptr(val reflect.Value) (interface{}, bool) {
+	field := val.Field(sc.index)
+	switch sc.kind {
+	case componentPtr:
+		if field.IsNil() {
+			return nil, false
+		}
+		return field.Interface(), true
+	case componentInterface:
+		if field.IsNil() {
+			return nil, false
+		}
+	}
+	return field.Addr().Interface(), true
+}
+
+// value returns the serializable value of the component that is held
+// by the field of val. The value of interface fields is wrapped together
+// with the name of its concrete type.
+func (sc staticComponent) value(val reflect.Value) (interface{}, bool) {
+	field := val.Field(sc.index)
+	switch sc.kind {
+	case componentPtr:
+		if field.IsNil() {
+			return nil, false
+		}
+		return sc.names.encodeValue(field.Elem()), true
+	case componentInterface:
+		if field.IsNil() {
+			return nil, false
+		}
+		return interfaceComponent{
+			Type:  sc.names.typeName(field.Elem().Type()),
+			Value: sc.names.encodeValue(field.Elem()),
+		}, true
+	}
+	return sc.names.encodeValue(field), true
+}
+
+// interfaceComponent is the serialized form of a component
+// that is held by a interface field.
+type interfaceComponent struct {
+	Type  string
+	Value interface{}
+}
+
+// componentTag is the value of the `kinshi` struct tag that marks a field
+// of a defined non-struct type as static component:
+//    type Miner struct {
+//        kinshi.BaseEntity
+//        Energy `kinshi:"component"`
+//    }
+// Without the tag such fields are plain data, so fields like a EntityID,
+// a time.Duration or a enum don't turn into components by accident.
+const componentTag = "component"
+
+// isComponentType checks if values of the type can be components. Besides
+// structs this includes defined types like `type Energy int` or
+// `type Inventory []Item`.
+func isComponentType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Struct:
+		return true
+	case reflect.Ptr, reflect.Interface, reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		return false
+	}
+	return t.Name() != "" && t.PkgPath() != ""
+}
+
+// staticComponents returns the static components of the entity struct
+// type t in field order. Exported struct fields, exported pointers to
+// structs and exported fields of named interface types are components.
+// Exported fields of other component types and pointers to them need
+// to be marked with the component tag. Base entities, unexported fields,
+// which can't be accessed even if they are embedded, and all other
+// fields are skipped.
+func (tn *typeNames) staticComponents(t reflect.Type) []staticComponent {
+	if comps, ok := tn.statics.Load(t); ok {
+		return comps.([]staticComponent)
+	}
+
+	var comps []staticComponent
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if isBaseField(field.Name) {
+			continue
+		}
+
+		sc := staticComponent{
+			name:  tn.typeName(field.Type),
+			index: i,
+			names: tn,
+		}
+
+		exported := field.PkgPath == ""
+		tagged := exported && field.Tag.Get("kinshi") == componentTag
+		switch {
+		case exported && field.Type.Kind() == reflect.Struct, tagged && isComponentType(field.Type):
+			sc.kind = componentValue
+		case exported && field.Type.Kind() == reflect.Ptr && field.Type.Elem().Kind() == reflect.Struct,
+			tagged && field.Type.Kind() == reflect.Ptr && isComponentType(field.Type.Elem()):
+			sc.kind = componentPtr
+		case exported && field.Type.Kind() == reflect.Interface && field.Type.PkgPath() != "":
+			sc.kind = componentInterface
+		default:
+			continue
+		}
+
+		comps = append(comps, sc)
+	}
+
+	tn.statics.Store(t, comps)
+	return comps
+}
+
+// staticField returns the field that holds the component with the
+// given name. Bare names are accepted for qualified types, so that
+// snapshots from before the switch to qualified names can be read.
+func (tn *typeNames) staticField(t reflect.Type, name string) (staticComponent, bool) {
+	comps := tn.staticComponents(t)
+	for i := range comps {
+		if comps[i].name == name {
+			return comps[i], true
+		}
+	}
+
+	if strings.Contains(name, ".") {
+		return staticComponent{}, false
+	}
+
+	found := -1
+	for i := range comps {
+		if bareName(comps[i].name) == name {
+			if found >= 0 {
+				return staticComponent{}, false
+			}
+			found = i
+		}
+	}
+
+	if found < 0 {
+		return staticComponent{}, false
+	}
+	return comps[found], true
+}
+
+// interfaceField returns the interface field of the entity type t
+// that can hold values of the type ct.
+func (tn *typeNames) interfaceField(t reflect.Type, ct reflect.Type) (staticComponent, bool) {
+	for _, sc := range tn.staticComponents(t) {
+		if sc.kind == componentInterface && ct.AssignableTo(t.Field(sc.index).Type) {
+			return sc, true
+		}
 	}
-	return t.Name()
+	return staticComponent{}, false
+}
+
+// componentNames returns the names of the given components. Strings
+// are treated as names already.
+func (tn *typeNames) componentNames(types []interface{}) []string {
+	names := make([]string, len(types))
+	for i := range types {
+		if name, ok := types[i].(string); ok {
+			names[i] = name
+		} else {
+			names[i] = tn.getTypeName(types[i])
+		}
+	}
+	return names
+}
+
+// isBaseField checks if the field name belongs to one of the
+// embedded base entities, which aren't components.
+func isBaseField(name string) bool {
+	return name == "BaseEntity" || name == "BaseDynamicEntity"
 }
 
-func fetchPtrOfType(s interface{}, typeName string) (interface{}, error) {
+func (tn *typeNames) fetchPtrOfType(s interface{}, typeName string) (interface{}, error) {
 	if reflect.TypeOf(s).Elem().Kind() != reflect.Struct {
 		return nil, fmt.Errorf("target wasn't a struct")
 	}
 
-	foundVal := reflect.ValueOf(s).Elem().FieldByName(typeName)
-	if !foundVal.IsValid() {
+	sc, ok := tn.staticField(reflect.TypeOf(s).Elem(), typeName)
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	ptr, ok := sc.ptr(reflect.ValueOf(s).Elem())
+	if !ok {
 		return nil, ErrNotFound
 	}
 
-	return foundVal.Addr().Interface(), nil
+	return ptr, nil
+}
+
+// fetchComponent returns a pointer to the static or dynamic
+// component with the given name.
+func (tn *typeNames) fetchComponent(ent Entity, name string) (interface{}, error) {
+	ptr, err := tn.fetchPtrOfType(ent, name)
+	if err == nil {
+		return ptr, nil
+	}
+
+	if dyn, ok := ent.(DynamicEntity); ok {
+		return dyn.GetComponent(name)
+	}
+
+	return nil, err
+}
+
+// callError extracts the error of a user supplied function
+// that optionally returns a single error.
+func callError(res []reflect.Value) error {
+	if len(res) == 1 {
+		if err, ok := res[0].Interface().(error); ok && err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// viewSignature returns the names of the components that are
+// requested by the arguments of the view function type. The names
+// are cached by the function type.
+func (tn *typeNames) viewSignature(fnType reflect.Type) []string {
+	if names, ok := tn.views.Load(fnType); ok {
+		return names.([]string)
+	}
+
+	names := make([]string, fnType.NumIn())
+	for i := range names {
+		names[i] = tn.typeName(fnType.In(i).Elem())
+	}
+
+	tn.views.Store(fnType, names)
+	return names
+}
+
+// callArgs recycles the argument slices of view calls.
+var callArgs = sync.Pool{
+	New: func() interface{} {
+		args := make([]reflect.Value, 0, 8)
+		return &args
+	},
+}
+
+func getCallArgs() *[]reflect.Value {
+	return callArgs.Get().(*[]reflect.Value)
+}
+
+func putCallArgs(args *[]reflect.Value) {
+	for i := range *args {
+		(*args)[i] = reflect.Value{}
+	}
+	*args = (*args)[:0]
+	callArgs.Put(args)
+}
+
+// deepCopy returns a deep copy of v. Unexported struct fields
+// can't be set by reflection and are therefore copied shallow.
+func deepCopy(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type().Elem())
+		c.Elem().Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopy(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if c.Field(i).CanSet() {
+				c.Field(i).Set(deepCopy(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Array:
+		c := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopy(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return reflect.Zero(v.Type())
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return c
+	}
+
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// setComponentValue overwrites the component of the entity with the
+// value of c. Static components are set directly, nil pointer fields are
+// allocated and interface fields are set to c. Otherwise a copy of c is
+// set as dynamic component.
+func (tn *typeNames) setComponentValue(ent Entity, c interface{}) error {
+	val := reflect.ValueOf(c)
+	if val.Kind() == reflect.Ptr {
+		val = val.Elem()
+	}
+
+	t := reflect.TypeOf(ent).Elem()
+	entVal := reflect.ValueOf(ent).Elem()
+
+	if sc, ok := tn.staticField(t, tn.typeName(val.Type())); ok {
+		field := entVal.Field(sc.index)
+		switch {
+		case sc.kind == componentValue && field.Type() == val.Type():
+			field.Set(val)
+			return nil
+		case sc.kind == componentPtr && field.Type().Elem() == val.Type():
+			if field.IsNil() {
+				field.Set(reflect.New(val.Type()))
+			}
+			field.Elem().Set(val)
+			return nil
+		}
+	}
+
+	if sc, ok := tn.interfaceField(t, reflect.TypeOf(c)); ok {
+		entVal.Field(sc.index).Set(reflect.ValueOf(c))
+		return nil
+	}
+
+	if dyn, ok := ent.(DynamicEntity); ok {
+		ptr := reflect.New(val.Type())
+		ptr.Elem().Set(val)
+		return dyn.SetComponent(ptr.Interface())
+	}
+
+	return ErrNotFound
+}
+
+type namedComponent struct {
+	Name    string
+	Value   interface{}
+	Dynamic bool
+}
+
+// collectComponents returns all static components in field order
+// followed by the dynamic components sorted by name and the keyed
+// components. Static components are returned as pointer into the entity.
+func (tn *typeNames) collectComponents(ent Entity) []namedComponent {
+	var comps []namedComponent
+
+	val := reflect.ValueOf(ent).Elem()
+	for _, sc := range tn.staticComponents(val.Type()) {
+		ptr, ok := sc.ptr(val)
+		if !ok {
+			continue
+		}
+
+		comps = append(comps, namedComponent{
+			Name:  sc.name,
+			Value: ptr,
+		})
+	}
+
+	if dyn, ok := ent.(DynamicEntity); ok {
+		var dynComps []namedComponent
+		for _, c := range dyn.GetComponents() {
+			dynComps = append(dynComps, namedComponent{
+				Name:    tn.getTypeName(c),
+				Value:   c,
+				Dynamic: true,
+			})
+		}
+
+		sort.Slice(dynComps, func(i, j int) bool {
+			return dynComps[i].Name < dynComps[j].Name
+		})
+
+		comps = append(comps, dynComps...)
+	}
+
+	if ke, ok := ent.(KeyedEntity); ok {
+		for _, kc := range ke.GetKeyedComponents() {
+			comps = append(comps, namedComponent{
+				Name:    keyedName(kc.Name, kc.Key),
+				Value:   kc.Value,
+				Dynamic: true,
+			})
+		}
+	}
+
+	return comps
 }
@@ -0,0 +1,380 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestECS_TypeConflict(t *testing.T) {
+	type Pos struct {
+		Other string
+	}
+
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.ErrorIs(t, ecs.RegisterComponent(Pos{}), ErrTypeConflict)
+
+	type Unit struct {
+		BaseEntity
+	}
+	_, err := ecs.AddEntity(&Unit{})
+	assert.ErrorIs(t, err, ErrTypeConflict)
+	assert.Equal(t, 0, ecs.Len())
+}
+
+func TestWithQualifiedNames(t *testing.T) {
+	bare := New()
+	_, _ = bare.AddEntity(&Unit{Name: Name{Value: "bare"}})
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{X: 1})
+	_, _ = bare.AddEntity(dyn)
+
+	snapshot := &bytes.Buffer{}
+	assert.NoError(t, bare.Marshal(snapshot))
+
+	ecs := New(WithQualifiedNames())
+	assert.Equal(t, "github.com/BigJk/kinshi.Pos", ecs.TypeName(Pos{}))
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+
+	// Snapshots with bare names can still be read.
+	assert.NoError(t, ecs.Unmarshal(bytes.NewBuffer(snapshot.Bytes())))
+	assert.Equal(t, 2, ecs.Count(Name{}))
+	assert.Equal(t, 1, ecs.Count(Velocity{}))
+	assert.Equal(t, 1, ecs.Count("github.com/BigJk/kinshi.Velocity"))
+	assert.Equal(t, 1, ecs.CountType(Unit{}))
+
+	_ = ecs.UpdateAll(func(n *Name, p *Pos) {
+		assert.Equal(t, "bare", n.Value)
+	})
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.True(t, strings.Contains(buf.String(), `"github.com/BigJk/kinshi.Pos"`), "components aren't qualified")
+	assert.True(t, strings.Contains(buf.String(), `"github.com/BigJk/kinshi.Unit"`), "entities aren't qualified")
+
+	// The other world still uses bare names.
+	assert.Equal(t, "Pos", bare.TypeName(&Pos{}))
+	assert.Equal(t, "Pos", TypeName(&Pos{}))
+	assert.Equal(t, 1, bare.Count("Velocity"))
+}
+
+func TestTypeNameConflict(t *testing.T) {
+	// A type of the same bare name, as if it was declared in another
+	// package.
+	type Pos struct {
+		Z int
+	}
+
+	ecs := New()
+	dyn := &DynamicUnit{}
+	assert.NoError(t, dyn.SetComponent(&Pos{Z: 1}))
+	assert.ErrorIs(t, dyn.SetComponent(&kinshiPos{}), ErrTypeConflict)
+	id, err := ecs.AddEntity(dyn)
+	assert.NoError(t, err)
+
+	// The name is taken by the local type now.
+	assert.ErrorIs(t, ecs.RegisterComponent(kinshiPos{}), ErrTypeConflict)
+	assert.ErrorIs(t, ecs.MustGet(id).Set(kinshiPos{}), ErrTypeConflict)
+	_, err = ecs.AddEntity(&Unit{})
+	assert.ErrorIs(t, err, ErrTypeConflict)
+
+	other := &DynamicUnit{}
+	assert.NoError(t, other.SetComponent(&kinshiPos{X: 1}))
+	_, err = ecs.AddEntity(other)
+	assert.ErrorIs(t, err, ErrTypeConflict)
+	assert.Equal(t, 1, ecs.Len())
+
+	// Queries by type don't match the other type of the same name.
+	assert.Equal(t, 1, ecs.Count(Pos{}))
+	assert.Equal(t, 0, ecs.Count(kinshiPos{}))
+	assert.False(t, ecs.MustGet(id).Has(kinshiPos{}))
+	assert.Equal(t, ErrNotFound, dyn.HasComponent(kinshiPos{}))
+	assert.Equal(t, ErrNotFound, dyn.RemoveComponent(kinshiPos{}))
+	assert.NoError(t, dyn.HasComponent(Pos{}))
+}
+
+// kinshiPos is the Pos of the fixtures, which is shadowed inside of
+// TestTypeNameConflict.
+type kinshiPos = Pos
+
+func TestWithQualifiedNames_Dynamic(t *testing.T) {
+	dyn := &DynamicUnit{}
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 1}))
+	assert.NoError(t, dyn.SetKeyed("a", &Health{Value: 1}))
+	assert.NoError(t, dyn.SetComponent(&Dead{}))
+	assert.NoError(t, dyn.HasComponent("Velocity"))
+
+	// The components are renamed once the entity is added.
+	ecs := New(WithQualifiedNames())
+	id, _ := ecs.AddEntity(dyn)
+	assert.NoError(t, dyn.HasComponent("github.com/BigJk/kinshi.Velocity"))
+	assert.NoError(t, dyn.HasComponent("github.com/BigJk/kinshi.Dead"))
+	assert.Equal(t, 1, ecs.Count(Velocity{}, Health{}, Dead{}))
+
+	assert.NoError(t, ecs.MustGet(id).Set(Pos{}))
+	assert.NoError(t, dyn.HasComponent("github.com/BigJk/kinshi.Pos"))
+	assert.Equal(t, ErrNotFound, dyn.HasComponent("Pos"))
+}
+
+type Position struct {
+	X int
+	Y int
+}
+
+type RenamedUnit struct {
+	BaseEntity
+	Position
+}
+
+func TestWithTypeNamer(t *testing.T) {
+	old := New()
+	_, _ = old.AddEntity(&Unit{Pos: Pos{X: 1, Y: 2}})
+
+	snapshot := &bytes.Buffer{}
+	assert.NoError(t, old.Marshal(snapshot))
+
+	// Renamed types keep their old names.
+	ecs := New(WithTypeNamer(TypeNamerFunc(func(t reflect.Type) string {
+		switch t {
+		case reflect.TypeOf(Position{}):
+			return "Pos"
+		case reflect.TypeOf(RenamedUnit{}):
+			return "Unit"
+		}
+		return t.Name()
+	})))
+	assert.NoError(t, ecs.RegisterEntity(&RenamedUnit{}))
+	assert.NoError(t, ecs.Unmarshal(snapshot))
+
+	assert.Equal(t, 1, ecs.Count("Pos"))
+	assert.NoError(t, ecs.UpdateAll(func(p *Position) {
+		assert.Equal(t, Position{X: 1, Y: 2}, *p)
+	}))
+}
+
+func TestTypeNameCache(t *testing.T) {
+	calls := 0
+	ecs := New(WithTypeNamer(TypeNamerFunc(func(t reflect.Type) string {
+		calls++
+		return t.Name()
+	})))
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "Pos", ecs.TypeName(Pos{}))
+		assert.Equal(t, "Pos", ecs.TypeName(&Pos{}))
+	}
+	assert.Equal(t, 1, calls, "name wasn't interned")
+
+	// Each ECS caches the names of its own namer.
+	qualified := New(WithQualifiedNames())
+	assert.Equal(t, "github.com/BigJk/kinshi.Pos", qualified.TypeName(Pos{}))
+	assert.Equal(t, "Pos", ecs.TypeName(Pos{}))
+}
+
+func TestWithTypeNamer_OptionOrder(t *testing.T) {
+	ecs := New(WithDoubleBuffering(Pos{}), WithHistory(2, Pos{}), WithQualifiedNames())
+
+	_, ok := ecs.buffers.types["github.com/BigJk/kinshi.Pos"]
+	assert.True(t, ok, "buffered component isn't qualified")
+	_, ok = ecs.history.types["github.com/BigJk/kinshi.Pos"]
+	assert.True(t, ok, "tracked component isn't qualified")
+}
+
+type Weapon interface {
+	Damage() int
+}
+
+type Sword struct {
+	Sharpness int
+}
+
+func (s Sword) Damage() int {
+	return s.Sharpness * 2
+}
+
+type Armed struct {
+	BaseEntity
+	Pos
+	Health *Health
+	Weapon Weapon
+}
+
+func TestPointerComponents(t *testing.T) {
+	ecs := New()
+
+	shared := &Health{Value: 10, Max: 10}
+	a := &Armed{Health: shared}
+	b := &Armed{Health: shared}
+	c := &Armed{}
+
+	for _, ent := range []Entity{a, b, c} {
+		_, err := ecs.AddEntity(ent)
+		assert.NoError(t, err)
+	}
+
+	assert.Equal(t, 2, ecs.Count(Health{}))
+	assert.Equal(t, 3, ecs.Count(Pos{}))
+
+	// Changes to a shared component are seen by all entities.
+	assert.NoError(t, ecs.Access(a).View(func(h *Health) {
+		h.Value -= 3
+	}))
+	assert.Equal(t, 7, b.Health.Value)
+
+	// A nil pointer is a missing component.
+	assert.False(t, ecs.Access(c).Has(Health{}))
+	assert.Error(t, ecs.Access(c).View(func(h *Health) {}))
+
+	// Set allocates missing components.
+	assert.NoError(t, ecs.Access(c).Set(Health{Value: 1, Max: 5}))
+	assert.Equal(t, &Health{Value: 1, Max: 5}, c.Health)
+	assert.Equal(t, 3, ecs.Count(Health{}))
+	assert.Equal(t, []string{"Pos", "Health"}, ecs.Access(c).Components())
+}
+
+func TestInterfaceComponents(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Armed{}))
+
+	armed := &Armed{}
+	_, err := ecs.AddEntity(armed)
+	assert.NoError(t, err)
+	_, err = ecs.AddEntity(&Armed{})
+	assert.NoError(t, err)
+
+	assert.Equal(t, 0, ecs.Count(TypeName((*Weapon)(nil))))
+	assert.NoError(t, ecs.Access(armed).Set(Sword{Sharpness: 4}))
+	assert.Equal(t, 1, ecs.Count("Weapon"))
+
+	damage := 0
+	assert.NoError(t, ecs.Access(armed).View(func(w *Weapon) {
+		damage = (*w).Damage()
+	}))
+	assert.Equal(t, 8, damage)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.Contains(t, buf.String(), `"Type": "Sword"`)
+
+	// The concrete type needs to be registered.
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.RegisterEntity(&Armed{}))
+	assert.ErrorIs(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())), ErrNotFound)
+
+	loaded = New()
+	assert.NoError(t, loaded.RegisterEntity(&Armed{}))
+	assert.NoError(t, loaded.RegisterComponent(&Sword{}))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	ew, err := loaded.Get(armed.ID())
+	assert.NoError(t, err)
+	assert.Equal(t, Sword{Sharpness: 4}, ew.GetEntity().(*Armed).Weapon)
+	assert.Nil(t, ew.GetEntity().(*Armed).Health)
+	assert.Equal(t, 1, loaded.Count("Weapon"))
+}
+
+type Energy int
+
+type Item struct {
+	Name  string
+	Count int
+}
+
+type Inventory []Item
+
+type Miner struct {
+	BaseEntity
+	Energy    `kinshi:"component"`
+	Inventory `kinshi:"component"`
+}
+
+func TestNamedComponents(t *testing.T) {
+	ecs := New(WithStrict())
+	assert.NoError(t, ecs.RegisterEntity(&Miner{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Energy(0)))
+
+	_, err := ecs.AddEntity(&Miner{Energy: 10, Inventory: Inventory{{Name: "Ore", Count: 2}}})
+	assert.NoError(t, err)
+
+	dyn := &DynamicUnit{}
+	energy := Energy(3)
+	assert.NoError(t, dyn.SetComponent(&energy))
+	_, err = ecs.AddEntity(dyn)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, ecs.Count(Energy(0)))
+	assert.Equal(t, 1, ecs.Count(Energy(0), Inventory{}))
+
+	for _, ew := range ecs.Iterate(Energy(0)) {
+		assert.NoError(t, ew.View(func(e *Energy) {
+			*e += 1
+		}))
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.RegisterEntity(&Miner{}))
+	assert.NoError(t, loaded.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, loaded.RegisterComponent(Energy(0)))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	miner := loaded.Iterate(Inventory{})[0].GetEntity().(*Miner)
+	assert.Equal(t, Energy(11), miner.Energy)
+	assert.Equal(t, Inventory{{Name: "Ore", Count: 2}}, miner.Inventory)
+
+	c, err := loaded.Iterate(Name{})[0].GetEntity().(*DynamicUnit).GetComponent("Energy")
+	assert.NoError(t, err)
+	assert.Equal(t, Energy(4), *c.(*Energy))
+}
+
+type Cooldown struct {
+	BaseEntity
+	Pos
+	Target   EntityID
+	Duration time.Duration
+	Energy
+}
+
+func TestNamedComponents_OptIn(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Cooldown{}))
+
+	_, err := ecs.AddEntity(&Cooldown{Duration: time.Second, Energy: 5})
+	assert.NoError(t, err)
+
+	// Only the struct is a component, the other fields are plain data.
+	assert.Equal(t, 1, ecs.Count(Pos{}))
+	assert.Equal(t, 0, ecs.Count(time.Duration(0)))
+	assert.Equal(t, 0, ecs.Count(Energy(0)))
+
+	comps := defaultNames.staticComponents(reflect.TypeOf(Cooldown{}))
+	if assert.Len(t, comps, 1) {
+		assert.Equal(t, "Pos", comps[0].name)
+	}
+
+	ew := ecs.Iterate(Pos{})[0]
+	assert.Error(t, ew.View(func(d *time.Duration) {}))
+}
+
+func BenchmarkComponentNames(b *testing.B) {
+	types := []interface{}{Pos{}, Velocity{}, Health{}}
+
+	for name, n := range map[string]TypeNamer{"Bare": BareNames, "Qualified": QualifiedNames} {
+		names := newTypeNames(n)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = names.componentNames(types)
+			}
+		})
+	}
+}
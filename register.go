@@ -0,0 +1,186 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var entityType = reflect.TypeOf((*Entity)(nil)).Elem()
+
+// Register registers entity types and component types in a single call.
+// Entities need to be passed as pointer. Besides the entity type itself
+// the dynamic components that are set on a passed entity and the concrete
+// types that are held by interfaces inside of the components are
+// registered as well, so a prototype of each entity is enough to prepare
+// a ECS for Unmarshal.
+//
+// For example:
+//    err := ecs.Register(&Unit{}, &Player{}, Velocity{}, Burning{})
+func (ecs *ECS) Register(types ...interface{}) error {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	for i := range types {
+		if err := ecs.register(types[i]); err != nil {
+			return ecs.misuse(err)
+		}
+	}
+
+	return nil
+}
+
+func (ecs *ECS) register(v interface{}) error {
+	if v == nil {
+		return fmt.Errorf("can't register nil")
+	}
+
+	t := reflect.TypeOf(v)
+	if t.Kind() != reflect.Ptr && reflect.PtrTo(t).Implements(entityType) {
+		return fmt.Errorf("entity '%s' needs to be passed as pointer", ecs.names.typeName(t))
+	}
+
+	ent, ok := v.(Entity)
+	if !ok {
+		return ecs.registerComponent(reflect.ValueOf(v))
+	}
+
+	if err := ecs.cacheType(ent); err != nil {
+		return err
+	}
+
+	val := reflect.ValueOf(ent).Elem()
+	for _, sc := range ecs.names.staticComponents(val.Type()) {
+		if err := ecs.registerNested(val.Field(sc.index)); err != nil {
+			return err
+		}
+	}
+
+	if dyn, ok := ent.(DynamicEntity); ok {
+		for _, c := range dyn.GetComponents() {
+			if err := ecs.registerComponent(reflect.ValueOf(c)); err != nil {
+				return err
+			}
+		}
+	}
+
+	if ke, ok := ent.(KeyedEntity); ok {
+		for _, kc := range ke.GetKeyedComponents() {
+			if err := ecs.registerComponent(reflect.ValueOf(kc.Value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// registerComponent caches the type of the component and
+// the concrete types of the interfaces inside of it.
+func (ecs *ECS) registerComponent(v reflect.Value) error {
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if !isComponentType(t) {
+		return fmt.Errorf("'%s' can't be a component", t)
+	}
+
+	if err := ecs.cacheComponent(ecs.names.typeName(t), t); err != nil {
+		return err
+	}
+
+	return ecs.registerNested(v)
+}
+
+// registerNested registers the concrete types of all
+// non-nil interfaces that are contained in v.
+func (ecs *ECS) registerNested(v reflect.Value) error {
+	if !v.IsValid() || !hasInterface(v.Type()) {
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+
+		ct := v.Elem().Type()
+		if ct.Kind() == reflect.Ptr {
+			ct = ct.Elem()
+		}
+
+		if ct.Name() == "" || ct.PkgPath() == "" {
+			return ecs.registerNested(v.Elem())
+		}
+		return ecs.registerComponent(v.Elem())
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return ecs.registerNested(v.Elem())
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := ecs.registerNested(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := ecs.registerNested(iter.Value()); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if err := ecs.registerNested(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// RegisteredComponents returns the types of all known components by
+// their name. This includes the components of registered entities and
+// the components that were recorded from dynamic entities, so it can be
+// used to generate schemas or bindings for other languages.
+func (ecs *ECS) RegisteredComponents() map[string]reflect.Type {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	return ecs.registeredComponents()
+}
+
+// registeredComponents implements RegisteredComponents.
+// The caller needs to hold the lock.
+func (ecs *ECS) registeredComponents() map[string]reflect.Type {
+	components := map[string]reflect.Type{}
+	ecs.autoTypes.Range(func(k, v interface{}) bool {
+		components[k.(string)] = v.(reflect.Type)
+		return true
+	})
+	for name, t := range ecs.compMetaCache {
+		components[name] = t
+	}
+
+	return components
+}
+
+// componentRecorder is implemented by BaseDynamicEntity. While the
+// entity is stored in the ECS it records the types of the components
+// that are set on it.
+type componentRecorder interface {
+	setOwner(owner *ECS)
+}
+
+// attach links the entity to the registry of automatically recorded
+// component types and to the trackers of the ECS.
+func (ecs *ECS) attach(ent Entity) {
+	if rec, ok := ent.(componentRecorder); ok {
+		rec.setOwner(ecs)
+	}
+}
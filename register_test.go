@@ -0,0 +1,97 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+)
+
+func TestECS_Register(t *testing.T) {
+	src := New()
+
+	armed := &Armed{Weapon: Sword{Sharpness: 2}}
+	chest := &Chest{Bag: Bag{Worn: []Equipment{&Ring{Magic: 3}}, Extra: Helmet{Armor: 1}}}
+	dyn := &DynamicUnit{}
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 1}))
+	assert.NoError(t, dyn.SetKeyed("poison", &StatusEffect{Damage: 2}))
+
+	for _, ent := range []Entity{armed, chest, dyn} {
+		_, err := src.AddEntity(ent)
+		assert.NoError(t, err)
+	}
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, src.Marshal(buf))
+
+	// A single prototype of each entity is enough.
+	protoDyn := &DynamicUnit{}
+	assert.NoError(t, protoDyn.SetComponent(&Velocity{}))
+	assert.NoError(t, protoDyn.SetKeyed("", &StatusEffect{}))
+
+	loaded := New(WithStrict())
+	assert.NoError(t, loaded.Register(
+		&Armed{Weapon: Sword{}},
+		&Chest{Bag: Bag{Worn: []Equipment{&Ring{}}, Extra: Helmet{}}},
+		protoDyn,
+		Energy(0),
+	))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	assert.Equal(t, armed.Weapon, loaded.MustGet(armed.ID()).GetEntity().(*Armed).Weapon)
+	assert.Equal(t, chest.Bag, loaded.MustGet(chest.ID()).GetEntity().(*Chest).Bag)
+	assert.Equal(t, []string{"poison"}, loaded.MustGet(dyn.ID()).Keys(StatusEffect{}))
+	assert.True(t, loaded.MustGet(dyn.ID()).Has(Velocity{}))
+}
+
+func TestECS_Register_Errors(t *testing.T) {
+	ecs := New()
+	assert.Error(t, ecs.Register(Unit{}))
+	assert.Error(t, ecs.Register(42))
+	assert.Error(t, ecs.Register(nil))
+}
+
+func TestECS_AutoRegisterDynamic(t *testing.T) {
+	ecs := New(WithStrict())
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+
+	added := &DynamicUnit{}
+	assert.NoError(t, added.SetComponent(&Velocity{X: 1}))
+	_, err := ecs.AddEntity(added)
+	assert.NoError(t, err)
+
+	// Components set after the entity was added are recorded too.
+	assert.NoError(t, added.SetComponent(&Health{Value: 2}))
+	assert.NoError(t, added.SetKeyed("poison", &StatusEffect{Damage: 3}))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.NoError(t, ecs.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	loaded := ecs.MustGet(added.ID())
+	assert.True(t, loaded.HasAll(Velocity{}, Health{}))
+	assert.Equal(t, []string{"poison"}, loaded.Keys(StatusEffect{}))
+
+	// Removed entities don't record anymore.
+	assert.NoError(t, ecs.RemoveEntity(loaded.GetEntity()))
+	assert.NoError(t, loaded.GetEntity().(*DynamicUnit).SetComponent(&Pos{}))
+	_, ok := ecs.lookupComponent("Pos")
+	assert.False(t, ok)
+}
+
+func TestECS_RegisteredComponents(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.Register(&Unit{}, &DynamicUnit{}, Velocity{}))
+
+	added := &DynamicUnit{}
+	_, _ = ecs.AddEntity(added)
+	assert.NoError(t, added.SetComponent(&Dead{}))
+
+	assert.Equal(t, map[string]reflect.Type{
+		"Health":   reflect.TypeOf(Health{}),
+		"Pos":      reflect.TypeOf(Pos{}),
+		"Name":     reflect.TypeOf(Name{}),
+		"Velocity": reflect.TypeOf(Velocity{}),
+		"Dead":     reflect.TypeOf(Dead{}),
+	}, ecs.RegisteredComponents())
+}
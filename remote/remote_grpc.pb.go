@@ -0,0 +1,257 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: remote.proto
+
+package remote
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	ECS_Query_FullMethodName        = "/kinshi.remote.v1.ECS/Query"
+	ECS_Get_FullMethodName          = "/kinshi.remote.v1.ECS/Get"
+	ECS_Spawn_FullMethodName        = "/kinshi.remote.v1.ECS/Spawn"
+	ECS_SetComponent_FullMethodName = "/kinshi.remote.v1.ECS/SetComponent"
+	ECS_Remove_FullMethodName       = "/kinshi.remote.v1.ECS/Remove"
+)
+
+// ECSClient is the client API for ECS service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ECSClient interface {
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Entity, error)
+	Spawn(ctx context.Context, in *SpawnRequest, opts ...grpc.CallOption) (*Entity, error)
+	SetComponent(ctx context.Context, in *SetComponentRequest, opts ...grpc.CallOption) (*Entity, error)
+	Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error)
+}
+
+type eCSClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewECSClient(cc grpc.ClientConnInterface) ECSClient {
+	return &eCSClient{cc}
+}
+
+func (c *eCSClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := c.cc.Invoke(ctx, ECS_Query_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCSClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*Entity, error) {
+	out := new(Entity)
+	err := c.cc.Invoke(ctx, ECS_Get_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCSClient) Spawn(ctx context.Context, in *SpawnRequest, opts ...grpc.CallOption) (*Entity, error) {
+	out := new(Entity)
+	err := c.cc.Invoke(ctx, ECS_Spawn_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCSClient) SetComponent(ctx context.Context, in *SetComponentRequest, opts ...grpc.CallOption) (*Entity, error) {
+	out := new(Entity)
+	err := c.cc.Invoke(ctx, ECS_SetComponent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *eCSClient) Remove(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*RemoveResponse, error) {
+	out := new(RemoveResponse)
+	err := c.cc.Invoke(ctx, ECS_Remove_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ECSServer is the server API for ECS service.
+// All implementations must embed UnimplementedECSServer
+// for forward compatibility
+type ECSServer interface {
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
+	Get(context.Context, *GetRequest) (*Entity, error)
+	Spawn(context.Context, *SpawnRequest) (*Entity, error)
+	SetComponent(context.Context, *SetComponentRequest) (*Entity, error)
+	Remove(context.Context, *RemoveRequest) (*RemoveResponse, error)
+	mustEmbedUnimplementedECSServer()
+}
+
+// UnimplementedECSServer must be embedded to have forward compatible implementations.
+type UnimplementedECSServer struct {
+}
+
+func (UnimplementedECSServer) Query(context.Context, *QueryRequest) (*QueryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedECSServer) Get(context.Context, *GetRequest) (*Entity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedECSServer) Spawn(context.Context, *SpawnRequest) (*Entity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Spawn not implemented")
+}
+func (UnimplementedECSServer) SetComponent(context.Context, *SetComponentRequest) (*Entity, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetComponent not implemented")
+}
+func (UnimplementedECSServer) Remove(context.Context, *RemoveRequest) (*RemoveResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedECSServer) mustEmbedUnimplementedECSServer() {}
+
+// UnsafeECSServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ECSServer will
+// result in compilation errors.
+type UnsafeECSServer interface {
+	mustEmbedUnimplementedECSServer()
+}
+
+func RegisterECSServer(s grpc.ServiceRegistrar, srv ECSServer) {
+	s.RegisterService(&ECS_ServiceDesc, srv)
+}
+
+func _ECS_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ECSServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ECS_Query_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ECSServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ECS_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ECSServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ECS_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ECSServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ECS_Spawn_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SpawnRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ECSServer).Spawn(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ECS_Spawn_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ECSServer).Spawn(ctx, req.(*SpawnRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ECS_SetComponent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetComponentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ECSServer).SetComponent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ECS_SetComponent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ECSServer).SetComponent(ctx, req.(*SetComponentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ECS_Remove_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ECSServer).Remove(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ECS_Remove_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ECSServer).Remove(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// ECS_ServiceDesc is the grpc.ServiceDesc for ECS service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var ECS_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "kinshi.remote.v1.ECS",
+	HandlerType: (*ECSServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Query",
+			Handler:    _ECS_Query_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _ECS_Get_Handler,
+		},
+		{
+			MethodName: "Spawn",
+			Handler:    _ECS_Spawn_Handler,
+		},
+		{
+			MethodName: "SetComponent",
+			Handler:    _ECS_SetComponent_Handler,
+		},
+		{
+			MethodName: "Remove",
+			Handler:    _ECS_Remove_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "remote.proto",
+}
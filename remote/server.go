@@ -0,0 +1,221 @@
+// Package remote provides a gRPC service that exposes a running
+// kinshi.ECS, so that external tools and companion services can query
+// a live headless simulation. Writes are disabled by default and need to
+// be allowed with AllowWrites. The service is defined in remote.proto.
+//
+// For example:
+//    lis, _ := net.Listen("tcp", ":9000")
+//    s := grpc.NewServer()
+//    remote.RegisterECSServer(s, remote.NewServer(ecs))
+//    s.Serve(lis)
+package remote
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative remote.proto
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/inspect"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Option configures a Server.
+type Option func(s *Server)
+
+// AllowWrites enables the methods that modify the ECS. If check isn't
+// nil it's called before each write with the full method name, e.g.
+// "/kinshi.remote.v1.ECS/Remove", and the write is rejected with the
+// returned error. This can be used to authenticate the caller.
+func AllowWrites(check func(ctx context.Context, method string) error) Option {
+	return func(s *Server) {
+		s.writes = true
+		s.check = check
+	}
+}
+
+// Server implements the ECS service for a ECS.
+type Server struct {
+	UnimplementedECSServer
+
+	ecs    *kinshi.ECS
+	writes bool
+	check  func(ctx context.Context, method string) error
+}
+
+// NewServer creates a new read only server for the ECS.
+func NewServer(ecs *kinshi.ECS, opts ...Option) *Server {
+	s := &Server{ecs: ecs}
+	for i := range opts {
+		opts[i](s)
+	}
+	return s
+}
+
+// statusError converts the errors of the ECS into gRPC status errors.
+func statusError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, kinshi.ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, kinshi.ErrAlreadyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, kinshi.ErrVersion):
+		return status.Error(codes.Aborted, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+func (s *Server) allowWrite(ctx context.Context, method string) error {
+	if !s.writes {
+		return status.Error(codes.PermissionDenied, "writes are disabled")
+	}
+
+	if s.check != nil {
+		if err := s.check(ctx, "/kinshi.remote.v1.ECS/"+method); err != nil {
+			if _, ok := status.FromError(err); ok {
+				return err
+			}
+			return status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func collect(ew *kinshi.EntityWrap) (*Entity, error) {
+	ent, err := inspect.Collect(ew)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	res := &Entity{
+		Id:         uint64(ent.ID),
+		Type:       ent.Type,
+		Components: make(map[string][]byte, len(ent.Components)),
+	}
+	for _, c := range ent.Components {
+		res.Components[c.Name] = c.Value.(json.RawMessage)
+	}
+
+	return res, nil
+}
+
+// Query implements ECSServer.
+func (s *Server) Query(ctx context.Context, req *QueryRequest) (*QueryResponse, error) {
+	types := make([]interface{}, len(req.Components))
+	for i := range req.Components {
+		types[i] = req.Components[i]
+	}
+
+	var it kinshi.EntityIterator
+	var err error
+
+	switch {
+	case req.Query != "":
+		it, err = s.ecs.QueryString(req.Query)
+	case req.Where != "":
+		it, err = s.ecs.IterateWhere(req.Where, types...)
+	default:
+		it = s.ecs.Iterate(types...)
+	}
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	if req.Limit > 0 {
+		it = it.Limit(int(req.Limit))
+	}
+
+	res := &QueryResponse{Entities: make([]*Entity, 0, len(it))}
+	for _, ew := range it {
+		if req.IdsOnly {
+			res.Entities = append(res.Entities, &Entity{Id: uint64(ew.GetEntity().ID()), Type: ew.TypeName(ew.GetEntity())})
+			continue
+		}
+
+		ent, err := collect(ew)
+		if err != nil {
+			return nil, err
+		}
+		res.Entities = append(res.Entities, ent)
+	}
+
+	return res, nil
+}
+
+// Get implements ECSServer.
+func (s *Server) Get(ctx context.Context, req *GetRequest) (*Entity, error) {
+	ew, err := s.ecs.Get(kinshi.EntityID(req.Id))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return collect(ew)
+}
+
+// Spawn implements ECSServer.
+func (s *Server) Spawn(ctx context.Context, req *SpawnRequest) (*Entity, error) {
+	if err := s.allowWrite(ctx, "Spawn"); err != nil {
+		return nil, err
+	}
+
+	components := make(map[string]interface{}, len(req.Components))
+	for name, data := range req.Components {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "component '%s': %s", name, err)
+		}
+		components[name] = v
+	}
+
+	ent, err := s.ecs.NewEntity(req.Type, components)
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	if _, err := s.ecs.AddEntity(ent); err != nil {
+		return nil, statusError(err)
+	}
+
+	return collect(s.ecs.Access(ent))
+}
+
+// SetComponent implements ECSServer.
+func (s *Server) SetComponent(ctx context.Context, req *SetComponentRequest) (*Entity, error) {
+	if err := s.allowWrite(ctx, "SetComponent"); err != nil {
+		return nil, err
+	}
+
+	ew, err := s.ecs.Get(kinshi.EntityID(req.Id))
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(req.Value, &v); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "component '%s': %s", req.Component, err)
+	}
+
+	if err := ew.Decode(req.Component, v); err != nil {
+		return nil, statusError(err)
+	}
+
+	return collect(ew)
+}
+
+// Remove implements ECSServer.
+func (s *Server) Remove(ctx context.Context, req *RemoveRequest) (*RemoveResponse, error) {
+	if err := s.allowWrite(ctx, "Remove"); err != nil {
+		return nil, err
+	}
+
+	if err := s.ecs.RemoveByID(kinshi.EntityID(req.Id)); err != nil {
+		return nil, statusError(err)
+	}
+
+	return &RemoveResponse{}, nil
+}
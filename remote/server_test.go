@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Unit struct {
+	kinshi.BaseEntity
+	Pos
+}
+
+func dial(t *testing.T, ecs *kinshi.ECS, opts ...Option) (ECSClient, func()) {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	RegisterECSServer(s, NewServer(ecs, opts...))
+	go func() {
+		_ = s.Serve(lis)
+	}()
+
+	conn, err := grpc.Dial("bufnet", grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+
+	return NewECSClient(conn), func() {
+		_ = conn.Close()
+		s.Stop()
+	}
+}
+
+func TestServer(t *testing.T) {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	for i := 0; i < 3; i++ {
+		_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: i}})
+	}
+
+	client, stop := dial(t, ecs, AllowWrites(nil))
+	defer stop()
+	ctx := context.Background()
+
+	res, err := client.Query(ctx, &QueryRequest{Components: []string{"Pos"}, Where: "Pos.X > 0"})
+	assert.NoError(t, err)
+	if assert.Len(t, res.Entities, 2) {
+		assert.Equal(t, uint64(2), res.Entities[0].Id)
+		assert.Equal(t, "Unit", res.Entities[0].Type)
+		assert.JSONEq(t, `{"X": 1, "Y": 0}`, string(res.Entities[0].Components["Pos"]))
+	}
+
+	res, err = client.Query(ctx, &QueryRequest{Query: "SELECT * LIMIT 2", IdsOnly: true})
+	assert.NoError(t, err)
+	if assert.Len(t, res.Entities, 2) {
+		assert.Empty(t, res.Entities[1].Components)
+	}
+
+	res, err = client.Query(ctx, &QueryRequest{Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, res.Entities, 1)
+
+	ent, err := client.Spawn(ctx, &SpawnRequest{Type: "Unit", Components: map[string][]byte{"Pos": []byte(`{"X": 7}`)}})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), ent.Id)
+
+	ent, err = client.SetComponent(ctx, &SetComponentRequest{Id: 4, Component: "Pos", Value: []byte(`{"Y": 3}`)})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"X": 0, "Y": 3}`, string(ent.Components["Pos"]))
+
+	_, err = client.Remove(ctx, &RemoveRequest{Id: 4})
+	assert.NoError(t, err)
+
+	_, err = client.Get(ctx, &GetRequest{Id: 4})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.Query(ctx, &QueryRequest{Query: "SELECT"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.SetComponent(ctx, &SetComponentRequest{Id: 1, Component: "Pos", Value: []byte(`{`)})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestServer_Writes(t *testing.T) {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	_, _ = ecs.AddEntity(&Unit{})
+	ctx := context.Background()
+
+	client, stop := dial(t, ecs)
+	_, err := client.Remove(ctx, &RemoveRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.Spawn(ctx, &SpawnRequest{Type: "Unit"})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	stop()
+
+	var methods []string
+	client, stop = dial(t, ecs, AllowWrites(func(ctx context.Context, method string) error {
+		methods = append(methods, method)
+		if method == "/kinshi.remote.v1.ECS/Remove" {
+			return errors.New("no removes")
+		}
+		return nil
+	}))
+	defer stop()
+
+	_, err = client.Remove(ctx, &RemoveRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	_, err = client.SetComponent(ctx, &SetComponentRequest{Id: 1, Component: "Pos", Value: []byte(`{"X": 1}`)})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/kinshi.remote.v1.ECS/Remove", "/kinshi.remote.v1.ECS/SetComponent"}, methods)
+	assert.Equal(t, 1, ecs.Count())
+}
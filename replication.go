@@ -0,0 +1,351 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// ReplicatedEntity contains the components of a created or
+// updated entity in their JSON encoded form.
+type ReplicatedEntity struct {
+	ID                EntityID
+	NetID             NetID `json:",omitempty"`
+	Type              string
+	Components        map[string]json.RawMessage
+	RemovedComponents []string `json:",omitempty"`
+}
+
+// ReplicationPayload contains all changes of a world since the last
+// payload was created. The destroyed entities only contain their ids.
+type ReplicationPayload struct {
+	Created   []ReplicatedEntity `json:",omitempty"`
+	Updated   []ReplicatedEntity `json:",omitempty"`
+	Destroyed []ReplicatedEntity `json:",omitempty"`
+}
+
+// Empty returns true if the payload doesn't contain any changes.
+func (p ReplicationPayload) Empty() bool {
+	return len(p.Created) == 0 && len(p.Updated) == 0 && len(p.Destroyed) == 0
+}
+
+// Replicator tracks the state of a ECS that has been sent to a
+// client and produces payloads containing only the changes since
+// the last tick. Use one Replicator per client, the first payload
+// of a new Replicator contains all entities as created.
+//
+// Only the entities that have been added, removed or written to since
+// the last tick are encoded and compared, so the cost of a tick depends
+// on the number of changes instead of the size of the world. Entities
+// are identified by their network id, so entities that don't have one
+// get a network id assigned when they are sent for the first time. Components
+// that are changed outside of the ECS, e.g. by holding on to component
+// pointers, aren't noticed. A Replicator isn't safe for concurrent use
+// and should be closed once the client is gone.
+type Replicator struct {
+	ecs     *ECS
+	changes changeTracker
+	last    map[EntityID]rawEntity
+}
+
+// NewReplicator creates a new Replicator for the given ECS.
+func NewReplicator(ecs *ECS) *Replicator {
+	r := &Replicator{
+		ecs:  ecs,
+		last: map[EntityID]rawEntity{},
+	}
+	ecs.track(&r.changes)
+	return r
+}
+
+// Close stops the tracking of changes. The Replicator must not be
+// used afterwards.
+func (r *Replicator) Close() {
+	r.ecs.untrack(&r.changes)
+}
+
+// collect encodes the entities that changed since the last tick and
+// returns the ids of the destroyed ones.
+func (r *Replicator) collect() (map[EntityID]rawEntity, []EntityID, error) {
+	ecs := r.ecs
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	var firstErr error
+	changed := map[EntityID]rawEntity{}
+	var destroyed []EntityID
+
+	r.changes.sync(ecs, func(id EntityID, ent Entity) bool {
+		entry, _, ok := ecs.findEntity(id)
+		if !ok {
+			return false
+		}
+
+		re, err := ecs.rawEntity(entry)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		changed[id] = re
+		return true
+	}, func(id EntityID) {
+		destroyed = append(destroyed, id)
+	})
+
+	if firstErr != nil {
+		// The changes are lost for the tracker, so
+		// the next tick needs to compare everything.
+		r.changes.reset()
+		return nil, nil, firstErr
+	}
+
+	return changed, destroyed, nil
+}
+
+// link assigns network ids to the changed entities that don't have one
+// yet, so that the receiving side can link them to its own entities.
+// The entities are sent along with their new network id, so they aren't
+// marked as changed. Entities that were removed in the meantime are
+// dropped, they are neither created nor destroyed on the receiving side.
+func (r *Replicator) link(changed map[EntityID]rawEntity) error {
+	var missing []EntityID
+	for id, re := range changed {
+		if re.NetID == NetIDNone {
+			missing = append(missing, id)
+		}
+	}
+
+	if len(missing) == 0 {
+		return nil
+	}
+
+	ecs := r.ecs
+	ecs.lock()
+	defer ecs.Unlock()
+
+	for _, id := range missing {
+		if _, _, ok := ecs.findEntity(id); !ok {
+			delete(changed, id)
+			continue
+		}
+
+		netID, err := ecs.assignNetID(id)
+		if err != nil {
+			return err
+		}
+
+		re := changed[id]
+		re.NetID = netID
+		changed[id] = re
+	}
+
+	return nil
+}
+
+// Tick compares the changed entities with their state of the last tick
+// and returns the created, updated and destroyed entities. Updated
+// entities only contain the changed components.
+func (r *Replicator) Tick() (ReplicationPayload, error) {
+	changed, destroyed, err := r.collect()
+	if err != nil {
+		return ReplicationPayload{}, err
+	}
+
+	if err := r.link(changed); err != nil {
+		r.changes.reset()
+		return ReplicationPayload{}, err
+	}
+
+	last := make(map[EntityID]rawEntity, len(changed))
+	for id := range changed {
+		if re, ok := r.last[id]; ok {
+			last[id] = re
+		}
+	}
+
+	diff := diffRaw(last, changed)
+	payload := ReplicationPayload{}
+
+	// The receiving side can't find a entity whose network id
+	// changed, so it's destroyed and created again.
+	relinked := map[EntityID]bool{}
+	for id, re := range changed {
+		if prev, ok := last[id]; ok && prev.NetID != re.NetID {
+			relinked[id] = true
+			payload.Destroyed = append(payload.Destroyed, ReplicatedEntity{ID: prev.ID, NetID: prev.NetID})
+			payload.Created = append(payload.Created, newReplicatedEntity(re))
+		}
+	}
+
+	for _, id := range diff.Added {
+		payload.Created = append(payload.Created, newReplicatedEntity(changed[id]))
+	}
+
+	for _, ed := range diff.Changed {
+		re := changed[ed.ID]
+
+		if relinked[ed.ID] {
+			continue
+		}
+
+		if ed.TypeChanged {
+			payload.Created = append(payload.Created, newReplicatedEntity(re))
+			continue
+		}
+
+		update := ReplicatedEntity{
+			ID:                re.ID,
+			NetID:             re.NetID,
+			Type:              re.Type,
+			Components:        map[string]json.RawMessage{},
+			RemovedComponents: ed.RemovedComponents,
+		}
+
+		for _, name := range ed.AddedComponents {
+			update.Components[name] = re.Components[name]
+		}
+
+		for _, name := range ed.ChangedComponents {
+			update.Components[name] = re.Components[name]
+		}
+
+		payload.Updated = append(payload.Updated, update)
+	}
+
+	sort.Slice(payload.Created, func(i, j int) bool { return payload.Created[i].ID < payload.Created[j].ID })
+
+	for _, id := range destroyed {
+		if prev, ok := r.last[id]; ok {
+			payload.Destroyed = append(payload.Destroyed, ReplicatedEntity{ID: prev.ID, NetID: prev.NetID})
+			delete(r.last, id)
+		}
+	}
+	sort.Slice(payload.Destroyed, func(i, j int) bool { return payload.Destroyed[i].ID < payload.Destroyed[j].ID })
+
+	for id, re := range changed {
+		r.last[id] = re
+	}
+
+	return payload, nil
+}
+
+// ApplyReplication applies a payload that was created by a Replicator
+// to the ECS. The entities of the sending side are linked to the local
+// entities by their network id, so the local ids are independent of the
+// ids of the sender and local entities that weren't replicated are left
+// alone. Created entities get a new local id, unless a entity with the
+// network id exists. That happens if the type of the entity changed, in
+// which case the local entity is removed and replaced, but keeps its id.
+//
+// Important: Just like Unmarshal the entity types and dynamic components
+// need to be registered before. Components that reference other entities
+// by their EntityID still hold the ids of the sending side.
+func (ecs *ECS) ApplyReplication(payload ReplicationPayload) error {
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	for i := range payload.Destroyed {
+		if id, ok := ecs.netIDs[payload.Destroyed[i].NetID]; ok {
+			if _, idx, ok := ecs.findEntity(id); ok {
+				ecs.removeAt(idx)
+			}
+		}
+	}
+
+	for i := range payload.Created {
+		netID := payload.Created[i].NetID
+		if netID == NetIDNone {
+			return fmt.Errorf("created entity %d: %w", payload.Created[i].ID, ErrNoID)
+		}
+
+		se, err := payload.Created[i].decode()
+		if err != nil {
+			return err
+		}
+
+		ent, ok, err := ecs.deserializeEntity(&se)
+		if !ok || (err != nil && ecs.strict) {
+			return err
+		}
+
+		id, linked := ecs.netIDs[netID]
+		if linked {
+			if _, idx, ok := ecs.findEntity(id); ok {
+				ecs.removeAt(idx)
+			}
+		} else {
+			id = ecs.nextId()
+		}
+		ent.Ent.SetID(id)
+
+		if err := ecs.insertEntity(ent); err != nil {
+			return err
+		}
+		ecs.bindNetID(id, netID)
+	}
+
+	for i := range payload.Updated {
+		se, err := payload.Updated[i].decode()
+		if err != nil {
+			return err
+		}
+
+		id, ok := ecs.netIDs[payload.Updated[i].NetID]
+		if !ok {
+			return fmt.Errorf("updated entity %d: %w", payload.Updated[i].ID, ErrNotFound)
+		}
+
+		entry, _, ok := ecs.findEntity(id)
+		if !ok {
+			return fmt.Errorf("updated entity %d: %w", payload.Updated[i].ID, ErrNotFound)
+		}
+		ecs.preserve(id)
+
+		for comp, val := range se.Components {
+			if err := ecs.touched(entry.Ent, ecs.decodeComponent(entry.Ent, comp, val)); err != nil {
+				return err
+			}
+		}
+
+		if dyn, ok := entry.Ent.(DynamicEntity); ok {
+			for _, comp := range payload.Updated[i].RemovedComponents {
+				if name, key, keyed := splitKeyed(comp); keyed {
+					if ke, ok := dyn.(KeyedEntity); ok {
+						_ = ecs.touched(entry.Ent, ke.RemoveKeyed(name, key))
+					}
+					continue
+				}
+				_ = ecs.touched(entry.Ent, dyn.RemoveComponent(comp))
+			}
+		}
+	}
+
+	return nil
+}
+
+func newReplicatedEntity(re rawEntity) ReplicatedEntity {
+	return ReplicatedEntity{
+		ID:         re.ID,
+		NetID:      re.NetID,
+		Type:       re.Type,
+		Components: re.Components,
+	}
+}
+
+func (re ReplicatedEntity) decode() (serializedEntity, error) {
+	se := serializedEntity{
+		ID:         re.ID,
+		Type:       re.Type,
+		Components: make(map[string]interface{}, len(re.Components)),
+	}
+
+	for name, data := range re.Components {
+		var val interface{}
+		if err := json.Unmarshal(data, &val); err != nil {
+			return se, err
+		}
+		se.Components[name] = val
+	}
+
+	return se, nil
+}
@@ -0,0 +1,180 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestReplicator(t *testing.T) {
+	server := New()
+	server.RegisterComponent(Velocity{})
+
+	client := New()
+	client.RegisterEntity(&Unit{})
+	client.RegisterEntity(&DynamicUnit{})
+	client.RegisterComponent(Velocity{})
+
+	rep := NewReplicator(server)
+
+	// Sends the payload through JSON like a real network layer would
+	replicate := func() ReplicationPayload {
+		payload, err := rep.Tick()
+		assert.NoError(t, err, "couldn't create payload")
+
+		data, err := json.Marshal(payload)
+		assert.NoError(t, err, "couldn't encode payload")
+
+		var received ReplicationPayload
+		assert.NoError(t, json.Unmarshal(data, &received), "couldn't decode payload")
+		assert.NoError(t, client.ApplyReplication(received), "couldn't apply payload")
+
+		diff, err := Diff(server, client)
+		assert.NoError(t, err)
+		assert.True(t, diff.Empty(), "worlds out of sync")
+
+		return payload
+	}
+
+	idUnit, _ := server.AddEntity(&Unit{Name: Name{Value: "unit"}})
+	dyn := &DynamicUnit{Name: Name{Value: "dyn"}}
+	_ = dyn.SetComponent(&Velocity{X: 1})
+	idDyn, _ := server.AddEntity(dyn)
+
+	payload := replicate()
+	assert.Len(t, payload.Created, 2)
+
+	payload = replicate()
+	assert.True(t, payload.Empty(), "payload without changes isn't empty")
+
+	_ = server.MustGet(idUnit).View(func(p *Pos) {
+		p.X = 10
+	})
+	_ = dyn.RemoveComponent(Velocity{})
+
+	payload = replicate()
+	if assert.Len(t, payload.Updated, 2) {
+		assert.Len(t, payload.Updated[0].Components, 1, "unchanged components were sent")
+		assert.Equal(t, []string{"Velocity"}, payload.Updated[1].RemovedComponents)
+	}
+
+	_ = server.RemoveEntity(dyn)
+
+	payload = replicate()
+	if assert.Len(t, payload.Destroyed, 1) {
+		assert.Equal(t, idDyn, payload.Destroyed[0].ID)
+	}
+}
+
+func TestReplicator_Dirty(t *testing.T) {
+	ecs := New()
+
+	rep := NewReplicator(ecs)
+	defer rep.Close()
+
+	var ids []EntityID
+	for i := 0; i < 10; i++ {
+		id, _ := ecs.AddEntity(&Unit{Pos: Pos{X: i}})
+		ids = append(ids, id)
+	}
+
+	payload, err := rep.Tick()
+	assert.NoError(t, err)
+	assert.Len(t, payload.Created, 10)
+
+	// Only the written entity should be encoded.
+	_ = ecs.MustGet(ids[3]).Set(Pos{X: 100})
+
+	changed, destroyed, err := rep.collect()
+	assert.NoError(t, err)
+	assert.Len(t, changed, 1)
+	assert.Empty(t, destroyed)
+
+	// Clear resets the tracker, so all entities are destroyed.
+	ecs.Clear(false)
+
+	payload, err = rep.Tick()
+	assert.NoError(t, err)
+	assert.Len(t, payload.Destroyed, 10)
+	assert.Empty(t, payload.Created)
+}
+
+func TestECS_ApplyReplicationTouch(t *testing.T) {
+	server := New()
+
+	client := New(WithSpatialIndex(Pos{}, NewHashGrid(8)))
+	client.RegisterEntity(&Unit{})
+	assert.NoError(t, client.Index(Name{}, "Value"))
+
+	rep := NewReplicator(server)
+	replicate := func() {
+		payload, err := rep.Tick()
+		assert.NoError(t, err)
+		assert.NoError(t, client.ApplyReplication(payload))
+	}
+
+	id, _ := server.AddEntity(&Unit{Name: Name{Value: "old"}})
+	replicate()
+	assert.Equal(t, 1, client.GetByIndex(Name{}, "Value", "old").Count())
+	assert.Equal(t, 1, client.IterateNear(0, 0, 1).Count())
+	version := client.MustGet(id).Version()
+
+	assert.NoError(t, server.MustGet(id).Set(Name{Value: "new"}))
+	assert.NoError(t, server.MustGet(id).Set(Pos{X: 50, Y: 50}))
+	replicate()
+
+	assert.Greater(t, client.MustGet(id).Version(), version, "version didn't change")
+	assert.Equal(t, 0, client.GetByIndex(Name{}, "Value", "old").Count())
+	assert.Equal(t, 1, client.GetByIndex(Name{}, "Value", "new").Count())
+	assert.Equal(t, 0, client.IterateNear(0, 0, 1).Count())
+	assert.Equal(t, 1, client.IterateNear(50, 50, 1).Count())
+}
+
+func TestECS_ApplyReplicationLocalIDs(t *testing.T) {
+	server := New()
+
+	client := New()
+	client.RegisterEntity(&Unit{})
+	ownID, _ := client.AddEntity(&Unit{Name: Name{Value: "own"}})
+
+	var removed []string
+	client.OnRemove(func(ent Entity) {
+		removed = append(removed, ent.(*Unit).Name.Value)
+	})
+
+	rep := NewReplicator(server)
+	replicate := func() {
+		payload, err := rep.Tick()
+		assert.NoError(t, err)
+		assert.NoError(t, client.ApplyReplication(payload))
+	}
+
+	id, _ := server.AddEntity(&Unit{Name: Name{Value: "remote"}})
+	replicate()
+
+	netID, ok := server.NetID(id)
+	if !assert.True(t, ok, "net id wasn't assigned") {
+		return
+	}
+
+	local, err := client.GetByNetID(netID)
+	if !assert.NoError(t, err) {
+		return
+	}
+	localID := local.GetEntity().ID()
+	assert.NotEqual(t, ownID, localID, "sender id was used locally")
+	assert.Equal(t, "own", client.MustGet(ownID).GetEntity().(*Unit).Name.Value, "local entity was replaced")
+
+	assert.NoError(t, server.MustGet(id).Set(Name{Value: "changed"}))
+	replicate()
+	assert.Equal(t, "changed", client.MustGet(localID).GetEntity().(*Unit).Name.Value)
+
+	assert.NoError(t, server.RemoveByID(id))
+	replicate()
+	assert.Equal(t, []string{"changed"}, removed)
+	assert.Equal(t, 1, client.Len())
+
+	assert.ErrorIs(t, client.ApplyReplication(ReplicationPayload{
+		Created: []ReplicatedEntity{{ID: 5, Type: "Unit"}},
+	}), ErrNoID)
+}
@@ -0,0 +1,63 @@
+package kinshi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UnmarshalFailure is a entity or a component of a entity that couldn't
+// be decoded.
+type UnmarshalFailure struct {
+	ID   EntityID
+	Type string
+	// Component is empty if the whole entity couldn't be decoded,
+	// e.g. because its type isn't registered.
+	Component string
+	Err       error
+}
+
+// UnmarshalReport lists everything that UnmarshalWithReport couldn't
+// decode, sorted by entity id and component name.
+type UnmarshalReport struct {
+	// Loaded is the number of loaded entities.
+	Loaded   int
+	Failures []UnmarshalFailure
+}
+
+// Empty returns true if everything was decoded.
+func (r UnmarshalReport) Empty() bool {
+	return len(r.Failures) == 0
+}
+
+// Err returns a error that lists all failures or nil if there are none.
+func (r UnmarshalReport) Err() error {
+	if r.Empty() {
+		return nil
+	}
+
+	msgs := make([]string, len(r.Failures))
+	for i := range r.Failures {
+		msgs[i] = r.Failures[i].Err.Error()
+	}
+	return fmt.Errorf("%d failures: %s", len(r.Failures), strings.Join(msgs, "; "))
+}
+
+// UnmarshalWithReport works like Unmarshal but lists the entities and
+// components that couldn't be decoded, like unknown entity types, fields
+// of the wrong type or dynamic components that aren't registered, instead
+// of silently skipping them. Everything else is loaded anyway. The error
+// is only set if the snapshot itself couldn't be read or, in strict mode,
+// for the first failure.
+//
+// For example you want to warn about a partially loaded save:
+//    report, err := ecs.UnmarshalWithReport(f)
+//    // ...
+//    for _, f := range report.Failures {
+//        log.Printf("entity %d (%s): %s", f.ID, f.Component, f.Err)
+//    }
+func (ecs *ECS) UnmarshalWithReport(reader io.Reader) (UnmarshalReport, error) {
+	var report UnmarshalReport
+	err := ecs.unmarshal(reader, nil, &report)
+	return report, err
+}
@@ -0,0 +1,65 @@
+package kinshi
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestECS_UnmarshalWithReport(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.Register(&Unit{}, &DynamicUnit{}, Velocity{}))
+
+	snapshot := `[
+		{"ID": 1, "Type": "Unit", "Components": {"Health": {"Value": 5}}},
+		{"ID": 2, "Type": "Missing", "Components": {}},
+		{"ID": 3, "Type": "Unit", "Components": {"Health": {"Value": "full"}, "Pos": {"X": []}}},
+		{"ID": 4, "Type": "DynamicUnit", "Components": {"Velocity": {"X": 1}, "Poisoned": {"Damage": 2}}}
+	]`
+
+	report, err := ecs.UnmarshalWithReport(strings.NewReader(snapshot))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, 3, report.Loaded)
+	assert.Equal(t, 3, ecs.Len())
+	assert.False(t, report.Empty())
+
+	if assert.Len(t, report.Failures, 4) {
+		got := make([]UnmarshalFailure, len(report.Failures))
+		for i, f := range report.Failures {
+			got[i] = UnmarshalFailure{ID: f.ID, Type: f.Type, Component: f.Component}
+		}
+		assert.Equal(t, []UnmarshalFailure{
+			{ID: 2, Type: "Missing"},
+			{ID: 3, Type: "Unit", Component: "Health"},
+			{ID: 3, Type: "Unit", Component: "Pos"},
+			{ID: 4, Type: "DynamicUnit", Component: "Poisoned"},
+		}, got)
+
+		assert.True(t, errors.Is(report.Failures[0].Err, ErrNotFound))
+		assert.True(t, errors.Is(report.Failures[3].Err, ErrNotFound))
+	}
+
+	assert.Contains(t, report.Err().Error(), "4 failures")
+	assert.Contains(t, report.Err().Error(), "component 'Poisoned' of entity 4")
+
+	// Everything that could be decoded is loaded.
+	assert.Equal(t, 5, ecs.MustGet(1).GetEntity().(*Unit).Health.Value)
+	assert.True(t, ecs.MustGet(4).Has(Velocity{}))
+
+	report, err = ecs.UnmarshalWithReport(strings.NewReader(`[{"ID": 1, "Type": "Unit"}]`))
+	assert.NoError(t, err)
+	assert.True(t, report.Empty())
+	assert.NoError(t, report.Err())
+
+	_, err = ecs.UnmarshalWithReport(strings.NewReader(`[{"ID": 1`))
+	assert.Error(t, err)
+
+	strict := New(WithStrict())
+	assert.NoError(t, strict.Register(&Unit{}))
+	_, err = strict.UnmarshalWithReport(strings.NewReader(snapshot))
+	assert.Error(t, err)
+}
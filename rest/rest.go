@@ -0,0 +1,339 @@
+// Package rest provides a http.Handler with a JSON API to create, read,
+// update and delete the entities and components of a running kinshi.ECS,
+// e.g. for a browser based level editor. Entities are in the same form
+// as in the snapshots of kinshi.ECS.Marshal. The API allows to modify
+// the whole world, so it shouldn't be exposed publicly.
+//
+// The routes are:
+//    GET    /entities                          list entities, see below
+//    POST   /entities                          add a entity
+//    GET    /entities/{id}                     get a entity
+//    PUT    /entities/{id}                     replace a entity
+//    PATCH  /entities/{id}                     patch a entity, see kinshi.ECS.PatchEntity
+//    DELETE /entities/{id}                     remove a entity
+//    GET    /entities/{id}/components/{name}   get a component
+//    PUT    /entities/{id}/components/{name}   set a component
+//    DELETE /entities/{id}/components/{name}   remove a dynamic component
+//
+// The list can be filtered with the query parameters components (comma
+// separated), where (a expression, see kinshi.ECS.IterateWhere), query
+// (see kinshi.ECS.QueryString) and limit.
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Handler serves the API.
+type Handler struct {
+	// AllowOrigin is sent as Access-Control-Allow-Origin header if it
+	// isn't empty, so that editors on other origins can use the API.
+	AllowOrigin string
+
+	ecs *kinshi.ECS
+	mux *http.ServeMux
+}
+
+// New creates a new API for the given ECS. The handler can be
+// mounted under a prefix with http.StripPrefix.
+func New(ecs *kinshi.ECS) *Handler {
+	h := &Handler{
+		ecs: ecs,
+		mux: http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/entities", h.handleEntities)
+	h.mux.HandleFunc("/entities/", h.handleEntity)
+
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.AllowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", h.AllowOrigin)
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// statusOf returns the http status for the error of the ECS.
+func statusOf(err error) int {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.Is(err, kinshi.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, kinshi.ErrAlreadyExists), errors.Is(err, kinshi.ErrVersion):
+		return http.StatusConflict
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return http.StatusBadRequest
+	}
+	return http.StatusUnprocessableEntity
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	http.Error(w, err.Error(), statusOf(err))
+}
+
+func writeJSON(w http.ResponseWriter, status int, data []byte) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(data)
+}
+
+func (h *Handler) list(r *http.Request) (kinshi.EntityIterator, error) {
+	q := r.URL.Query()
+
+	var types []interface{}
+	for _, c := range strings.Split(q.Get("components"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			types = append(types, c)
+		}
+	}
+
+	var it kinshi.EntityIterator
+	var err error
+
+	switch {
+	case q.Get("query") != "":
+		it, err = h.ecs.QueryString(q.Get("query"))
+	case q.Get("where") != "":
+		it, err = h.ecs.IterateWhere(q.Get("where"), types...)
+	default:
+		it = h.ecs.Iterate(types...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Get("limit") != "" {
+		limit, err := strconv.Atoi(q.Get("limit"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit: %w", err)
+		}
+		it = it.Limit(limit)
+	}
+
+	return it, nil
+}
+
+func (h *Handler) handleEntities(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		it, err := h.list(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ents := make([]json.RawMessage, 0, len(it))
+		for _, ew := range it {
+			data, err := h.ecs.MarshalEntity(ew.GetEntity().ID())
+			if err != nil {
+				// Removed since the query.
+				continue
+			}
+			ents = append(ents, data)
+		}
+
+		data, _ := json.Marshal(ents)
+		writeJSON(w, http.StatusOK, data)
+	case http.MethodPost:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var ent struct {
+			ID kinshi.EntityID
+		}
+		if err := json.Unmarshal(body, &ent); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if _, err := h.ecs.Get(ent.ID); ent.ID != kinshi.EntityNone && err == nil {
+			http.Error(w, fmt.Sprintf("entity %d: %s", ent.ID, kinshi.ErrAlreadyExists), http.StatusConflict)
+			return
+		}
+
+		h.writeBack(w, http.StatusCreated, body)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// writeBack unmarshals the entity and responds with its new state.
+func (h *Handler) writeBack(w http.ResponseWriter, status int, body []byte) {
+	id, err := h.ecs.UnmarshalEntity(body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	data, err := h.ecs.MarshalEntity(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, status, data)
+}
+
+func (h *Handler) handleEntity(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/entities/"), "/")
+
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid id '%s'", parts[0]), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		h.serveEntity(w, r, kinshi.EntityID(id))
+	case len(parts) == 3 && parts[1] == "components" && parts[2] != "":
+		h.serveComponent(w, r, kinshi.EntityID(id), parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) serveEntity(w http.ResponseWriter, r *http.Request, id kinshi.EntityID) {
+	switch r.Method {
+	case http.MethodGet:
+		data, err := h.ecs.MarshalEntity(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, data)
+	case http.MethodPut:
+		var ent map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&ent); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if _, err := h.ecs.Get(id); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		// The id of the path wins over the one of the body.
+		for k := range ent {
+			if strings.EqualFold(k, "ID") {
+				delete(ent, k)
+			}
+		}
+		ent["ID"] = id
+		body, _ := json.Marshal(ent)
+		h.writeBack(w, http.StatusOK, body)
+	case http.MethodPatch:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := h.ecs.PatchEntity(id, body); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		data, err := h.ecs.MarshalEntity(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, data)
+	case http.MethodDelete:
+		if err := h.ecs.RemoveByID(id); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) serveComponent(w http.ResponseWriter, r *http.Request, id kinshi.EntityID, name string) {
+	ew, err := h.ecs.Get(id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		data, err := h.ecs.MarshalEntity(id)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+
+		var ent struct {
+			Components map[string]json.RawMessage
+			Tags       []string
+		}
+		if err := json.Unmarshal(data, &ent); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if c, ok := ent.Components[name]; ok {
+			writeJSON(w, http.StatusOK, c)
+			return
+		}
+
+		for _, tag := range ent.Tags {
+			if tag == name {
+				writeJSON(w, http.StatusOK, []byte("{}"))
+				return
+			}
+		}
+
+		http.Error(w, fmt.Sprintf("component '%s': %s", name, kinshi.ErrNotFound), http.StatusNotFound)
+	case http.MethodPut:
+		var value interface{}
+		if err := json.NewDecoder(r.Body).Decode(&value); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		if err := ew.Decode(name, value); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		h.serveComponent(w, &http.Request{Method: http.MethodGet}, id, name)
+	case http.MethodDelete:
+		cb := h.ecs.NewCommandBuffer()
+		cb.RemoveComponent(id, name)
+		if err := cb.Flush(); err != nil {
+			writeError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,119 @@
+package rest
+
+import (
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Hostile struct{}
+
+type Unit struct {
+	kinshi.BaseDynamicEntity
+	Pos
+}
+
+func TestHandler(t *testing.T) {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterComponent(Hostile{}))
+
+	for i := 0; i < 3; i++ {
+		_, _ = ecs.AddEntity(&Unit{Pos: Pos{X: i}})
+	}
+
+	srv := httptest.NewServer(New(ecs))
+	defer srv.Close()
+
+	do := func(method string, path string, body string) (int, string) {
+		req, _ := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if !assert.NoError(t, err) {
+			return 0, ""
+		}
+		defer resp.Body.Close()
+
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	status, body := do("GET", "/entities?where=Pos.X>0&limit=1", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `[{"ID": 2, "Type": "Unit", "Components": {"Pos": {"X": 1, "Y": 0}}}]`, body)
+
+	status, body = do("POST", "/entities", `{"Type": "Unit", "Components": {"Pos": {"X": 7}}, "Tags": ["Hostile"]}`)
+	assert.Equal(t, http.StatusCreated, status)
+	assert.JSONEq(t, `{"ID": 4, "Type": "Unit", "Components": {"Pos": {"X": 7, "Y": 0}}, "Tags": ["Hostile"]}`, body)
+
+	status, body = do("GET", "/entities?query=SELECT%20Hostile", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"ID":4`)
+
+	status, body = do("GET", "/entities/4/components/Hostile", "")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "{}", body)
+
+	status, body = do("PUT", "/entities/4/components/Pos", `{"Y": 2}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"X": 0, "Y": 2}`, body)
+
+	status, _ = do("DELETE", "/entities/4/components/Hostile", "")
+	assert.Equal(t, http.StatusNoContent, status)
+	assert.False(t, ecs.MustGet(4).Has(Hostile{}))
+
+	status, body = do("PUT", "/entities/1", `{"id": 3, "Type": "Unit", "Components": {"Pos": {"Y": 9}}}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, body, `"ID":1`)
+	assert.Equal(t, Pos{Y: 9}, ecs.MustGet(1).GetEntity().(*Unit).Pos)
+
+	status, body = do("PATCH", "/entities/1", `[{"op": "replace", "path": "/Components/Pos/X", "value": 4}]`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.JSONEq(t, `{"ID": 1, "Type": "Unit", "Version": 2, "Components": {"Pos": {"X": 4, "Y": 9}}}`, body)
+
+	status, _ = do("DELETE", "/entities/1", "")
+	assert.Equal(t, http.StatusNoContent, status)
+
+	for _, c := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"GET", "/entities/1", "", http.StatusNotFound},
+		{"GET", "/entities/abc", "", http.StatusBadRequest},
+		{"GET", "/entities/2/other", "", http.StatusNotFound},
+		{"GET", "/entities/2/components/Hostile", "", http.StatusNotFound},
+		{"GET", "/entities?limit=x", "", http.StatusBadRequest},
+		{"GET", "/entities?query=SELECT", "", http.StatusBadRequest},
+		{"POST", "/entities", `{"ID": 2, "Type": "Unit"}`, http.StatusConflict},
+		{"POST", "/entities", `{"Type": "Missing"}`, http.StatusNotFound},
+		{"POST", "/entities", `{`, http.StatusBadRequest},
+		{"PUT", "/entities/1", `{"Type": "Unit"}`, http.StatusNotFound},
+		{"PUT", "/entities/2/components/Pos", `{"X": "left"}`, http.StatusUnprocessableEntity},
+		{"DELETE", "/entities/2/components/Velocity", "", http.StatusNotFound},
+		{"PATCH", "/entities/1", `{}`, http.StatusNotFound},
+		{"PATCH", "/entities/2", `{`, http.StatusBadRequest},
+		{"PATCH", "/entities/2", `[{"op": "test", "path": "/Type", "value": "Orc"}]`, http.StatusUnprocessableEntity},
+		{"PATCH", "/entities", "", http.StatusMethodNotAllowed},
+	} {
+		status, _ := do(c.method, c.path, c.body)
+		assert.Equal(t, c.status, status, c.method+" "+c.path)
+	}
+}
+
+func TestHandler_AllowOrigin(t *testing.T) {
+	h := New(kinshi.New())
+	h.AllowOrigin = "*"
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/entities", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,295 @@
+package kinshi
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// saveMagic starts every save file written by Save.
+const saveMagic = "KNSV"
+
+// saveFormat is the version of the save file format. Version 1 has no
+// checksum.
+const saveFormat = 2
+
+// saveHeaderSize is the size of the header: the magic, the format, the
+// flags, the length of the payload and its CRC-32C.
+const saveHeaderSize = 4 + 1 + 1 + 8 + 4
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// The flags in the header of a save file.
+const (
+	saveEncrypted = 1 << iota
+	saveIncrement
+)
+
+// SaveOption configures Save and Load.
+type SaveOption func(o *saveOptions)
+
+type saveOptions struct {
+	key      []byte
+	progress func(p Progress)
+}
+
+// Progress describes the progress of Save or Load. Save counts the
+// written Entities of Total and the written Bytes of the snapshot. Load
+// counts the decoded Bytes of TotalBytes first, Entities and Total are
+// known once all bytes have been decoded and count the added entities.
+type Progress struct {
+	Entities   int
+	Total      int
+	Bytes      int64
+	TotalBytes int64
+}
+
+// Done returns the progress as fraction between 0 and 1. For Load the
+// decoding and the adding of the entities count as half each.
+func (p Progress) Done() float64 {
+	switch {
+	case p.TotalBytes > 0 && p.Total > 0:
+		return 0.5 + float64(p.Entities)/float64(p.Total)/2
+	case p.TotalBytes > 0:
+		return float64(p.Bytes) / float64(p.TotalBytes) / 2
+	case p.Total > 0:
+		return float64(p.Entities) / float64(p.Total)
+	}
+	return 0
+}
+
+// WithEncryption encrypts the save file with AES-GCM, so that it can't be
+// read or edited without the key. The key needs to be 16, 24 or 32 bytes
+// long to select AES-128, AES-192 or AES-256. Load needs the same key.
+func WithEncryption(key []byte) SaveOption {
+	return func(o *saveOptions) {
+		o.key = key
+	}
+}
+
+// WithProgress calls fn with the progress of Save and Load, e.g. to show
+// a progress bar on a loading screen. fn is called while the ECS is
+// locked, so it must not use the ECS.
+func WithProgress(fn func(p Progress)) SaveOption {
+	return func(o *saveOptions) {
+		o.progress = fn
+	}
+}
+
+func newSaveOptions(opts []SaveOption) saveOptions {
+	var o saveOptions
+	for i := range opts {
+		opts[i](&o)
+	}
+	return o
+}
+
+func (o saveOptions) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(o.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Save writes a snapshot like Marshal as save file. In contrast to the
+// plain snapshot the save file starts with a small header that contains
+// a checksum of the payload and it can be encrypted, see WithEncryption.
+func (ecs *ECS) Save(w io.Writer, opts ...SaveOption) error {
+	o := newSaveOptions(opts)
+
+	var progress func(entities int, total int, bytes int64)
+	if o.progress != nil {
+		progress = func(entities int, total int, bytes int64) {
+			o.progress(Progress{Entities: entities, Total: total, Bytes: bytes})
+		}
+	}
+
+	var payload bytes.Buffer
+	if err := ecs.marshal(&payload, progress); err != nil {
+		return err
+	}
+
+	_, err := writeSave(w, 0, payload.Bytes(), o)
+	return err
+}
+
+// writeSave writes the header and the payload of a save file and returns
+// the checksum of the stored payload.
+func writeSave(w io.Writer, flags byte, payload []byte, o saveOptions) (uint32, error) {
+	header := make([]byte, saveHeaderSize)
+	copy(header, saveMagic)
+	header[4] = saveFormat
+	header[5] = flags
+
+	if o.key != nil {
+		header[5] |= saveEncrypted
+
+		aead, err := o.aead()
+		if err != nil {
+			return 0, err
+		}
+
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return 0, err
+		}
+
+		// The start of the header is authenticated as well.
+		payload = aead.Seal(nonce, nonce, payload, header[:6])
+	}
+
+	crc := crc32.Checksum(payload, crcTable)
+	binary.LittleEndian.PutUint64(header[6:], uint64(len(payload)))
+	binary.LittleEndian.PutUint32(header[14:], crc)
+
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+	_, err := w.Write(payload)
+	return crc, err
+}
+
+// Load reads a save file written by Save and loads the entities like
+// Unmarshal. If the file is truncated or damaged a error wrapping
+// ErrCorrupt is returned and the ECS isn't changed, so that e.g. a backup
+// can be loaded instead. Encrypted save files need the key they were
+// saved with, otherwise a error wrapping ErrDecrypt is returned.
+func (ecs *ECS) Load(r io.Reader, opts ...SaveOption) error {
+	o := newSaveOptions(opts)
+
+	flags, payload, _, err := readSave(r, o)
+	if err != nil {
+		return err
+	}
+
+	if flags&saveIncrement != 0 {
+		return fmt.Errorf("save file is a increment, see LoadIncremental")
+	}
+
+	var progress func(entities int, total int, bytes int64)
+	if o.progress != nil {
+		progress = func(entities int, total int, bytes int64) {
+			if total > 0 {
+				// Decoding is done.
+				bytes = int64(len(payload))
+			}
+			o.progress(Progress{Entities: entities, Total: total, Bytes: bytes, TotalBytes: int64(len(payload))})
+		}
+	}
+
+	return corrupted(ecs.unmarshal(bytes.NewReader(payload), progress, nil))
+}
+
+// readSave reads a save file and returns the flags of the header, the
+// decrypted payload and the checksum of the stored payload.
+func readSave(r io.Reader, o saveOptions) (byte, []byte, uint32, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+
+	if len(data) < 6 || string(data[:4]) != saveMagic {
+		return 0, nil, 0, fmt.Errorf("not a save file")
+	}
+
+	var header, payload []byte
+	switch data[4] {
+	case 1:
+		header, payload = data[:6], data[6:]
+	case saveFormat:
+		if len(data) < saveHeaderSize {
+			return 0, nil, 0, fmt.Errorf("save file is truncated: %w", ErrCorrupt)
+		}
+
+		header, payload = data[:saveHeaderSize], data[saveHeaderSize:]
+		if binary.LittleEndian.Uint64(header[6:]) != uint64(len(payload)) {
+			return 0, nil, 0, fmt.Errorf("save file has %d of %d bytes: %w", len(payload), binary.LittleEndian.Uint64(header[6:]), ErrCorrupt)
+		}
+	default:
+		return 0, nil, 0, fmt.Errorf("unsupported save format %d", data[4])
+	}
+
+	crc := crc32.Checksum(payload, crcTable)
+	if len(header) == saveHeaderSize && crc != binary.LittleEndian.Uint32(header[14:]) {
+		return 0, nil, 0, fmt.Errorf("checksum mismatch: %w", ErrCorrupt)
+	}
+
+	if header[5]&saveEncrypted != 0 {
+		if o.key == nil {
+			return 0, nil, 0, fmt.Errorf("save file is encrypted: %w", ErrDecrypt)
+		}
+
+		aead, err := o.aead()
+		if err != nil {
+			return 0, nil, 0, err
+		}
+
+		if len(payload) < aead.NonceSize() {
+			return 0, nil, 0, fmt.Errorf("save file is truncated: %w", ErrDecrypt)
+		}
+
+		nonce := payload[:aead.NonceSize()]
+		if payload, err = aead.Open(nil, nonce, payload[aead.NonceSize():], header[:6]); err != nil {
+			return 0, nil, 0, fmt.Errorf("wrong key or modified save file: %w", ErrDecrypt)
+		}
+	}
+
+	return header[5], payload, crc, nil
+}
+
+// corrupted marks errors of the JSON decoding as ErrCorrupt.
+func corrupted(err error) error {
+	var syntaxErr *json.SyntaxError
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.As(err, &syntaxErr) {
+		return fmt.Errorf("%v: %w", err, ErrCorrupt)
+	}
+	return err
+}
+
+// SaveFile writes a save file like Save. The file is first written next
+// to the path and then renamed, so a crash while saving doesn't destroy
+// the previous save.
+func (ecs *ECS) SaveFile(path string, opts ...SaveOption) error {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if err := ecs.Save(f, opts...); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// LoadFile reads a save file written by SaveFile, see Load.
+func (ecs *ECS) LoadFile(path string, opts ...SaveOption) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ecs.Load(f, opts...)
+}
@@ -0,0 +1,184 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestECS_Save(t *testing.T) {
+	ecs := New()
+	for i := 0; i < 5; i++ {
+		_, _ = ecs.AddEntity(&Unit{Name: Name{Value: "secret"}, Health: Health{Value: i}})
+	}
+
+	var want bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&want))
+
+	load := func(data []byte, opts ...SaveOption) (*ECS, error) {
+		other := New()
+		_ = other.RegisterEntity(&Unit{})
+		return other, other.Load(bytes.NewReader(data), opts...)
+	}
+
+	var plain bytes.Buffer
+	assert.NoError(t, ecs.Save(&plain))
+	assert.Contains(t, plain.String(), "secret")
+
+	other, err := load(plain.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 5, other.Len())
+
+	key := bytes.Repeat([]byte{7}, 32)
+
+	var enc bytes.Buffer
+	assert.NoError(t, ecs.Save(&enc, WithEncryption(key)))
+	assert.NotContains(t, enc.String(), "secret")
+
+	other, err = load(enc.Bytes(), WithEncryption(key))
+	if assert.NoError(t, err) {
+		var got bytes.Buffer
+		assert.NoError(t, other.Marshal(&got))
+		assert.JSONEq(t, want.String(), got.String())
+	}
+
+	_, err = load(enc.Bytes())
+	assert.True(t, errors.Is(err, ErrDecrypt))
+
+	_, err = load(enc.Bytes(), WithEncryption(bytes.Repeat([]byte{8}, 32)))
+	assert.True(t, errors.Is(err, ErrDecrypt))
+
+	tampered := append([]byte{}, enc.Bytes()...)
+	tampered[len(tampered)/2] ^= 1
+	_, err = load(tampered, WithEncryption(key))
+	assert.True(t, errors.Is(err, ErrCorrupt))
+
+	// The header is authenticated, the encryption can't be stripped.
+	tampered = append([]byte{}, enc.Bytes()...)
+	tampered[5] = 0
+	_, err = load(tampered, WithEncryption(key))
+	assert.Error(t, err)
+
+	assert.Error(t, ecs.Save(&enc, WithEncryption([]byte("short"))))
+
+	_, err = load(want.Bytes())
+	assert.Error(t, err)
+}
+
+func TestECS_LoadCorrupt(t *testing.T) {
+	ecs := New()
+	for i := 0; i < 5; i++ {
+		_, _ = ecs.AddEntity(&Unit{Health: Health{Value: i}})
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, ecs.Save(&buf))
+	data := buf.Bytes()
+
+	other := New()
+	_ = other.RegisterEntity(&Unit{})
+	_, _ = other.AddEntity(&Unit{})
+
+	for _, c := range [][]byte{
+		data[:10],
+		data[:len(data)-1],
+		append(append([]byte{}, data...), '\n'),
+		func() []byte {
+			d := append([]byte{}, data...)
+			d[len(d)-5] ^= 1
+			return d
+		}(),
+	} {
+		err := other.Load(bytes.NewReader(c))
+		assert.True(t, errors.Is(err, ErrCorrupt), err)
+	}
+	assert.Equal(t, 1, other.Len())
+
+	// A valid checksum over a broken payload.
+	var plain bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&plain))
+	payload := plain.Bytes()[:plain.Len()/2]
+	header := make([]byte, saveHeaderSize)
+	copy(header, data[:6])
+	binary.LittleEndian.PutUint64(header[6:], uint64(len(payload)))
+	binary.LittleEndian.PutUint32(header[14:], crc32.Checksum(payload, crcTable))
+	err := other.Load(bytes.NewReader(append(header, payload...)))
+	assert.True(t, errors.Is(err, ErrCorrupt), err)
+
+	// Version 1 save files have no checksum.
+	assert.NoError(t, other.Load(bytes.NewReader(append([]byte{'K', 'N', 'S', 'V', 1, 0}, plain.Bytes()...))))
+	assert.Equal(t, 5, other.Len())
+}
+
+func TestECS_SaveFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kinshi")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	ecs := New()
+	_, _ = ecs.AddEntity(&Unit{Health: Health{Value: 3}})
+
+	path := filepath.Join(dir, "world.sav")
+	assert.NoError(t, ecs.SaveFile(path))
+	assert.NoError(t, ecs.SaveFile(path))
+
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(t, files, 1)
+
+	other := New()
+	_ = other.RegisterEntity(&Unit{})
+	assert.NoError(t, other.LoadFile(path))
+	assert.Equal(t, 3, other.MustGet(1).GetEntity().(*Unit).Health.Value)
+
+	assert.Error(t, other.LoadFile(filepath.Join(dir, "missing.sav")))
+}
+
+func TestWithProgress(t *testing.T) {
+	ecs := New()
+	for i := 0; i < 20; i++ {
+		_, _ = ecs.AddEntity(&Unit{Health: Health{Value: i}})
+	}
+
+	var saved []Progress
+	var buf bytes.Buffer
+	assert.NoError(t, ecs.Save(&buf, WithProgress(func(p Progress) {
+		saved = append(saved, p)
+	})))
+
+	if assert.Len(t, saved, 20) {
+		assert.Equal(t, Progress{Entities: 1, Total: 20, Bytes: saved[0].Bytes}, saved[0])
+		assert.Equal(t, 20, saved[19].Entities)
+		assert.Equal(t, 1.0, saved[19].Done())
+		assert.True(t, saved[0].Bytes > 0 && saved[0].Bytes < saved[1].Bytes)
+	}
+
+	other := New()
+	_ = other.RegisterEntity(&Unit{})
+
+	var loaded []Progress
+	assert.NoError(t, other.Load(&buf, WithProgress(func(p Progress) {
+		loaded = append(loaded, p)
+	})))
+
+	if assert.Len(t, loaded, 40) {
+		last := 0.0
+		for _, p := range loaded {
+			assert.True(t, p.Done() >= last, "progress goes backwards")
+			last = p.Done()
+		}
+		assert.Equal(t, 0, loaded[19].Total)
+		assert.Equal(t, 20, loaded[20].Total)
+		assert.Equal(t, loaded[39].TotalBytes, loaded[39].Bytes)
+		assert.Equal(t, 1.0, loaded[39].Done())
+	}
+
+	assert.Equal(t, 0.0, Progress{}.Done())
+}
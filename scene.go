@@ -0,0 +1,107 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SceneID is the handle of a loaded scene.
+type SceneID uint64
+
+// LoadScene reads a JSON encoded snapshot (as written by Marshal) and
+// adds all contained entities to the ECS without touching the already
+// present entities. All loaded entities get new ids assigned. The returned
+// SceneID can be used to unload exactly the entities the scene introduced.
+// If a entity of the scene conflicts with a Unique index no entity is
+// added.
+//
+// Important: Just like Unmarshal the entity types and dynamic components
+// need to be registered before. EntityIDs that are stored inside of
+// components are not remapped.
+func (ecs *ECS) LoadScene(reader io.Reader) (SceneID, error) {
+	var ses []serializedEntity
+
+	dec := json.NewDecoder(reader)
+	if err := dec.Decode(&ses); err != nil {
+		return 0, err
+	}
+
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	// All entities are decoded and checked before the first one is added,
+	// so that a scene is either loaded completely or not at all.
+	var entries []entityEntry
+	var ents []Entity
+	for i := range ses {
+		ent, ok, err := ecs.deserializeEntity(&ses[i])
+		if err != nil && ecs.strict {
+			return 0, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		ent.Ent.SetID(ecs.nextId())
+		entries = append(entries, ent)
+		ents = append(ents, ent.Ent)
+	}
+
+	if err := ecs.checkUniqueAll(ents); err != nil {
+		return 0, err
+	}
+
+	var ids []EntityID
+	for i := range entries {
+		if err := ecs.insertEntity(entries[i]); err != nil {
+			return 0, err
+		}
+
+		ids = append(ids, entries[i].Ent.ID())
+	}
+
+	ecs.sceneCounter += 1
+	id := SceneID(ecs.sceneCounter)
+	ecs.scenes[id] = ids
+
+	return id, nil
+}
+
+// UnloadScene removes all entities that were introduced by the scene.
+// Entities of the scene that have already been removed are skipped.
+func (ecs *ECS) UnloadScene(id SceneID) error {
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	ids, ok := ecs.scenes[id]
+	if !ok {
+		return ErrNotFound
+	}
+
+	for i := range ids {
+		if _, idx, ok := ecs.findEntity(ids[i]); ok {
+			ecs.removeAt(idx)
+		}
+	}
+
+	delete(ecs.scenes, id)
+
+	return nil
+}
+
+// SceneEntities returns the ids of all entities that were
+// introduced by the scene.
+func (ecs *ECS) SceneEntities(id SceneID) ([]EntityID, error) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	ids, ok := ecs.scenes[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]EntityID{}, ids...), nil
+}
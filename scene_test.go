@@ -0,0 +1,65 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Scene(t *testing.T) {
+	prefab := New()
+	for i := 0; i < 5; i++ {
+		_, _ = prefab.AddEntity(&Unit{Name: Name{Value: "scene"}})
+	}
+
+	buf := &bytes.Buffer{}
+	if !assert.NoError(t, prefab.Marshal(buf), "couldn't marshal ECS") {
+		return
+	}
+
+	ecs := New()
+	ecs.RegisterEntity(&Unit{})
+	idOwn, _ := ecs.AddEntity(&Unit{Name: Name{Value: "own"}})
+
+	scene, err := ecs.LoadScene(bytes.NewBuffer(buf.Bytes()))
+	if !assert.NoError(t, err, "couldn't load scene") {
+		return
+	}
+
+	second, err := ecs.LoadScene(bytes.NewBuffer(buf.Bytes()))
+	if !assert.NoError(t, err, "couldn't load scene") {
+		return
+	}
+
+	assert.Equal(t, 11, ecs.IterateSpecific(Unit{}).Count(), "entity count doesn't match")
+
+	ids, err := ecs.SceneEntities(scene)
+	assert.NoError(t, err)
+	assert.Len(t, ids, 5, "scene entity count doesn't match")
+
+	assert.NoError(t, ecs.UnloadScene(scene), "couldn't unload scene")
+	assert.Equal(t, 6, ecs.IterateSpecific(Unit{}).Count(), "entity count doesn't match")
+	assert.Len(t, ecs.IterateID(ids...), 0, "scene entities weren't removed")
+	assert.Len(t, ecs.IterateID(idOwn), 1, "own entity was removed")
+
+	assert.NoError(t, ecs.UnloadScene(second), "couldn't unload scene")
+	assert.Equal(t, ErrNotFound, ecs.UnloadScene(second), "scene was unloaded twice")
+	assert.Equal(t, 1, ecs.IterateSpecific(Unit{}).Count(), "entity count doesn't match")
+}
+
+func TestECS_SceneConflict(t *testing.T) {
+	ecs := New()
+	ecs.RegisterEntity(&Unit{})
+	assert.NoError(t, ecs.Index(Name{}, "Value", Unique()))
+	_, _ = ecs.AddEntity(&Unit{Name: Name{Value: "taken"}})
+
+	scene := `[
+		{"Type": "Unit", "Components": {"Name": {"Value": "free"}}},
+		{"Type": "Unit", "Components": {"Name": {"Value": "taken"}}}
+	]`
+	id, err := ecs.LoadScene(bytes.NewBufferString(scene))
+	assert.ErrorIs(t, err, ErrAlreadyExists)
+	assert.Equal(t, SceneID(0), id)
+	assert.Equal(t, 1, ecs.Len(), "entities of the failed scene were added")
+	assert.Empty(t, ecs.GetByIndex(Name{}, "Value", "free"))
+}
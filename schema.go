@@ -0,0 +1,236 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	dynamicEntityType = reflect.TypeOf((*DynamicEntity)(nil)).Elem()
+	keyedEntityType   = reflect.TypeOf((*KeyedEntity)(nil)).Elem()
+)
+
+// schemaObject is a JSON Schema.
+type schemaObject map[string]interface{}
+
+// schemaBuilder collects the definitions of named struct types, so that
+// they are only defined once and recursive types are possible.
+type schemaBuilder struct {
+	defs  schemaObject
+	names *typeNames
+}
+
+// schemaRef returns a reference to the definition with the given name.
+func schemaRef(name string) schemaObject {
+	return schemaObject{"$ref": "#/$defs/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(name)}
+}
+
+// schemaOf returns the schema of the JSON form of values of type t.
+func (b *schemaBuilder) schemaOf(t reflect.Type) schemaObject {
+	switch {
+	case t == timeType:
+		return schemaObject{"type": "string", "format": "date-time"}
+	case t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType):
+		// The form is unknown.
+		return schemaObject{}
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return schemaObject{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return schemaObject{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return schemaObject{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return schemaObject{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return schemaObject{"type": "number"}
+	case reflect.String:
+		return schemaObject{"type": "string"}
+	case reflect.Interface:
+		// Interfaces are wrapped together with the name of their
+		// concrete type, see encodeValue.
+		return schemaObject{
+			"type": []string{"object", "null"},
+			"properties": schemaObject{
+				"Type":  schemaObject{"type": "string"},
+				"Value": schemaObject{},
+			},
+		}
+	case reflect.Ptr:
+		return schemaObject{"anyOf": []schemaObject{b.schemaOf(t.Elem()), {"type": "null"}}}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return schemaObject{"type": []string{"string", "null"}, "contentEncoding": "base64"}
+		}
+		return schemaObject{"type": []string{"array", "null"}, "items": b.schemaOf(t.Elem())}
+	case reflect.Array:
+		return schemaObject{"type": "array", "items": b.schemaOf(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return schemaObject{"type": []string{"object", "null"}, "additionalProperties": b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+
+		name := b.names.typeName(t)
+		if _, ok := b.defs[name]; !ok {
+			// Reserve the name before the fields are visited
+			// to stop the recursion of recursive types.
+			b.defs[name] = schemaObject{}
+			b.defs[name] = b.structSchema(t)
+		}
+		return schemaRef(name)
+	}
+
+	// Channels and functions can't be encoded.
+	return schemaObject{"not": schemaObject{}}
+}
+
+// structSchema returns the schema of a struct following the field
+// rules of encoding/json.
+func (b *schemaBuilder) structSchema(t reflect.Type) schemaObject {
+	props := schemaObject{}
+	b.structFields(t, props)
+	return schemaObject{"type": "object", "properties": props}
+}
+
+func (b *schemaBuilder) structFields(t reflect.Type, props schemaObject) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		// Embedded structs without name are flattened.
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			b.structFields(ft, props)
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		if strings.Contains(tag, ",string") {
+			props[name] = schemaObject{"type": "string"}
+			continue
+		}
+		props[name] = b.schemaOf(f.Type)
+	}
+}
+
+// entitySchema returns the schema of the serialized form of the given
+// entity type, see MarshalEntity.
+func (b *schemaBuilder) entitySchema(name string, t reflect.Type, components map[string]reflect.Type) schemaObject {
+	comps := schemaObject{}
+	var tags []string
+
+	dynamic := reflect.PtrTo(t).Implements(dynamicEntityType)
+	keyed := reflect.PtrTo(t).Implements(keyedEntityType)
+
+	if dynamic {
+		for cn, ct := range components {
+			if ct.Kind() == reflect.Struct && ct.Size() == 0 {
+				continue
+			}
+			comps[cn] = b.schemaOf(ct)
+		}
+	}
+
+	for _, sc := range b.names.staticComponents(t) {
+		ft := t.Field(sc.index).Type
+		if sc.kind == componentPtr {
+			ft = ft.Elem()
+		}
+
+		if ft.Kind() == reflect.Struct && ft.Size() == 0 {
+			tags = append(tags, sc.name)
+			continue
+		}
+
+		comps[sc.name] = b.schemaOf(ft)
+	}
+
+	componentsSchema := schemaObject{"type": []string{"object", "null"}, "properties": comps}
+	if !dynamic && !keyed {
+		componentsSchema["additionalProperties"] = false
+	}
+
+	tagItems := schemaObject{"type": "string"}
+	if !dynamic {
+		sort.Strings(tags)
+		tagItems["enum"] = append([]string{}, tags...)
+	}
+
+	return schemaObject{
+		"type": "object",
+		"properties": schemaObject{
+			"ID":         schemaObject{"type": "integer", "minimum": 0},
+			"NetID":      schemaObject{"type": "integer", "minimum": 0},
+			"UUID":       schemaObject{"type": "string", "format": "uuid"},
+			"Version":    schemaObject{"type": "integer", "minimum": 0},
+			"Type":       schemaObject{"const": name},
+			"Components": componentsSchema,
+			"Tags":       schemaObject{"type": "array", "items": tagItems},
+		},
+		"required": []string{"Type"},
+	}
+}
+
+// Schema returns a JSON Schema (draft 2020-12) of the snapshots written
+// by Marshal, which is generated from the registered entity types and
+// components. The schema of a single entity, e.g. of MarshalEntity, is
+// available as "#/$defs/entity" and the ones of the entity types as
+// "#/$defs/entity:<type>". Named structs that are used by components
+// are defined by their type name.
+//
+// The schema allows editors and external tools to validate prefab and
+// save files:
+//    _ = ioutil.WriteFile("snapshot.schema.json", ecs.Schema(), 0644)
+func (ecs *ECS) Schema() []byte {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	components := ecs.registeredComponents()
+	b := &schemaBuilder{defs: schemaObject{}, names: ecs.names}
+
+	names := make([]string, 0, len(ecs.metaCache))
+	for name := range ecs.metaCache {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	entities := make([]schemaObject, 0, len(names))
+	for _, name := range names {
+		b.defs["entity:"+name] = b.entitySchema(name, ecs.metaCache[name].t, components)
+		entities = append(entities, schemaRef("entity:"+name))
+	}
+	b.defs["entity"] = schemaObject{"oneOf": entities}
+
+	data, _ := json.MarshalIndent(schemaObject{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title":   "kinshi snapshot",
+		"type":    []string{"array", "null"},
+		"items":   schemaRef("entity"),
+		"$defs":   b.defs,
+	}, "", "\t")
+	return data
+}
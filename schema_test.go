@@ -0,0 +1,97 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type schemaNode struct {
+	Label    string `json:"label"`
+	Secret   string `json:"-"`
+	Count    int64  `json:",string"`
+	Children []schemaNode
+	hidden   int
+}
+
+type schemaEmbedded struct {
+	Inner int
+}
+
+type schemaComponent struct {
+	schemaEmbedded
+	Root    *schemaNode
+	Data    []byte
+	Weights map[string]float32
+	Fixed   [2]uint8
+	At      time.Time
+	ID      UUID
+	Any     interface{}
+}
+
+func TestECS_Schema(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.Register(&Unit{}, &DynamicUnit{}, Velocity{}, Hostile{}))
+
+	var schema map[string]interface{}
+	assert.NoError(t, json.Unmarshal(ecs.Schema(), &schema))
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#/$defs/entity"}, schema["items"])
+
+	defs := schema["$defs"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"oneOf": []interface{}{
+		map[string]interface{}{"$ref": "#/$defs/entity:DynamicUnit"},
+		map[string]interface{}{"$ref": "#/$defs/entity:Unit"},
+	}}, defs["entity"])
+
+	unit := defs["entity:Unit"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"const": "Unit"}, unit["Type"])
+
+	comps := unit["Components"].(map[string]interface{})
+	assert.Equal(t, false, comps["additionalProperties"])
+	assert.Equal(t, map[string]interface{}{"$ref": "#/$defs/Pos"}, comps["properties"].(map[string]interface{})["Pos"])
+	assert.Len(t, comps["properties"], 3)
+
+	assert.Equal(t, map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"X": map[string]interface{}{"type": "integer"},
+			"Y": map[string]interface{}{"type": "integer"},
+		},
+	}, defs["Pos"])
+
+	// Dynamic entities can have all components, tags are listed separately.
+	dyn := defs["entity:DynamicUnit"].(map[string]interface{})["properties"].(map[string]interface{})
+	dynComps := dyn["Components"].(map[string]interface{})
+	assert.NotContains(t, dynComps, "additionalProperties")
+	assert.Contains(t, dynComps["properties"], "Velocity")
+	assert.Contains(t, dynComps["properties"], "Name")
+	assert.NotContains(t, dynComps["properties"], "Hostile")
+}
+
+func TestSchemaOf(t *testing.T) {
+	b := &schemaBuilder{defs: schemaObject{}, names: defaultNames}
+	assert.Equal(t, schemaRef("schemaComponent"), b.schemaOf(reflect.TypeOf(schemaComponent{})))
+
+	props := b.defs["schemaComponent"].(schemaObject)["properties"].(schemaObject)
+	assert.Equal(t, schemaObject{"type": "integer"}, props["Inner"])
+	assert.Equal(t, schemaObject{"anyOf": []schemaObject{schemaRef("schemaNode"), {"type": "null"}}}, props["Root"])
+	assert.Equal(t, schemaObject{"type": []string{"string", "null"}, "contentEncoding": "base64"}, props["Data"])
+	assert.Equal(t, schemaObject{"type": []string{"object", "null"}, "additionalProperties": schemaObject{"type": "number"}}, props["Weights"])
+	assert.Equal(t, schemaObject{"type": "array", "items": schemaObject{"type": "integer", "minimum": 0}, "minItems": 2, "maxItems": 2}, props["Fixed"])
+	assert.Equal(t, schemaObject{"type": "string", "format": "date-time"}, props["At"])
+	assert.Equal(t, schemaObject{"type": "string"}, props["ID"])
+	assert.Contains(t, props["Any"].(schemaObject)["properties"], "Type")
+	assert.Len(t, props, 8)
+
+	// Recursive types reference their own definition.
+	node := b.defs["schemaNode"].(schemaObject)["properties"].(schemaObject)
+	assert.Equal(t, schemaObject{"type": []string{"array", "null"}, "items": schemaRef("schemaNode")}, node["Children"])
+	assert.Equal(t, schemaObject{"type": "string"}, node["label"])
+	assert.Equal(t, schemaObject{"type": "string"}, node["Count"])
+	assert.Len(t, node, 3)
+
+	assert.Equal(t, schemaObject{"$ref": "#/$defs/a~1b~0c"}, schemaRef("a/b~c"))
+}
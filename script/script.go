@@ -0,0 +1,298 @@
+// Package script exposes a kinshi.ECS to Lua scripts that run on
+// gopher-lua, so that game content can be scripted without recompiling.
+// Components are passed to the scripts as Lua tables that have the same
+// layout as the JSON form of the components.
+//
+// The functions are available in the "ecs" table:
+//    local id = ecs.spawn("Unit", { Pos = { X = 1, Y = 2 } })
+//    for _, id in ipairs(ecs.query("Pos", "Velocity")) do
+//        ecs.view(id, function(pos, vel)
+//            pos.X = pos.X + vel.X
+//        end, "Pos", "Velocity")
+//    end
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"github.com/yuin/gopher-lua"
+	"sort"
+)
+
+// Open makes the ECS available to the scripts of L as global table "ecs".
+//
+// The table contains the following functions:
+//    spawn(type [, components])     adds a entity of a registered type and returns its id
+//    remove(id)                     removes the entity
+//    query(component...)            returns the ids of the entities with all components
+//    select(query)                  returns the ids of the result of ECS.QueryString
+//    has(id, component)             checks if the entity has the component
+//    components(id)                 returns the names of the components of the entity
+//    get(id, component)             returns the component as table or nil if it's missing
+//    set(id, component, table)      sets the component, dynamic components are created
+//    view(id, fn, component...)     calls fn with the components and writes them back
+func Open(L *lua.LState, ecs *kinshi.ECS) {
+	L.SetGlobal("ecs", newModule(L, ecs))
+}
+
+// Loader returns a loader for L.PreloadModule, so that the scripts can
+// load the functions of Open with require instead of a global.
+//
+// For example:
+//    L.PreloadModule("kinshi", script.Loader(ecs))
+func Loader(ecs *kinshi.ECS) lua.LGFunction {
+	return func(L *lua.LState) int {
+		L.Push(newModule(L, ecs))
+		return 1
+	}
+}
+
+func newModule(L *lua.LState, ecs *kinshi.ECS) *lua.LTable {
+	m := &module{ecs: ecs}
+	return L.SetFuncs(L.NewTable(), map[string]lua.LGFunction{
+		"spawn":      m.spawn,
+		"remove":     m.remove,
+		"query":      m.query,
+		"select":     m.selectQuery,
+		"has":        m.has,
+		"components": m.components,
+		"get":        m.get,
+		"set":        m.set,
+		"view":       m.view,
+	})
+}
+
+type module struct {
+	ecs *kinshi.ECS
+}
+
+func (m *module) entity(L *lua.LState, n int) *kinshi.EntityWrap {
+	id := kinshi.EntityID(L.CheckInt64(n))
+
+	ew, err := m.ecs.Get(id)
+	if err != nil {
+		L.RaiseError("entity %d: %s", id, err)
+	}
+	return ew
+}
+
+func pushIDs(L *lua.LState, it kinshi.EntityIterator) int {
+	ids := L.CreateTable(len(it), 0)
+	for _, ew := range it {
+		ids.Append(lua.LNumber(ew.GetEntity().ID()))
+	}
+
+	L.Push(ids)
+	return 1
+}
+
+func (m *module) spawn(L *lua.LState) int {
+	typeName := L.CheckString(1)
+
+	components := map[string]interface{}{}
+	if tbl := L.OptTable(2, nil); tbl != nil {
+		tbl.ForEach(func(k lua.LValue, v lua.LValue) {
+			components[k.String()] = fromLua(v)
+		})
+	}
+
+	ent, err := m.ecs.NewEntity(typeName, components)
+	if err != nil {
+		L.RaiseError("spawn of '%s': %s", typeName, err)
+	}
+
+	id, err := m.ecs.AddEntity(ent)
+	if err != nil {
+		L.RaiseError("spawn of '%s': %s", typeName, err)
+	}
+
+	L.Push(lua.LNumber(id))
+	return 1
+}
+
+func (m *module) remove(L *lua.LState) int {
+	id := kinshi.EntityID(L.CheckInt64(1))
+	if err := m.ecs.RemoveByID(id); err != nil {
+		L.RaiseError("remove of entity %d: %s", id, err)
+	}
+	return 0
+}
+
+func (m *module) query(L *lua.LState) int {
+	types := make([]interface{}, L.GetTop())
+	for i := range types {
+		types[i] = L.CheckString(i + 1)
+	}
+
+	return pushIDs(L, m.ecs.Iterate(types...))
+}
+
+func (m *module) selectQuery(L *lua.LState) int {
+	it, err := m.ecs.QueryString(L.CheckString(1))
+	if err != nil {
+		L.RaiseError("%s", err)
+	}
+
+	return pushIDs(L, it)
+}
+
+func (m *module) has(L *lua.LState) int {
+	ew := m.entity(L, 1)
+	L.Push(lua.LBool(ew.Has(L.CheckString(2))))
+	return 1
+}
+
+func (m *module) components(L *lua.LState) int {
+	names := m.entity(L, 1).Components()
+
+	tbl := L.CreateTable(len(names), 0)
+	for i := range names {
+		tbl.Append(lua.LString(names[i]))
+	}
+
+	L.Push(tbl)
+	return 1
+}
+
+// component returns the named component of the entity converted to
+// a Lua value. ok is false if the entity doesn't have the component.
+func component(L *lua.LState, ew *kinshi.EntityWrap, name string) (lua.LValue, bool) {
+	names := ew.Components()
+	values := ew.ComponentValues()
+
+	for i := range names {
+		if names[i] != name {
+			continue
+		}
+
+		data, err := json.Marshal(values[i])
+		if err != nil {
+			L.RaiseError("component '%s': %s", name, err)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			L.RaiseError("component '%s': %s", name, err)
+		}
+
+		return toLua(L, v), true
+	}
+
+	return lua.LNil, false
+}
+
+func (m *module) get(L *lua.LState) int {
+	v, _ := component(L, m.entity(L, 1), L.CheckString(2))
+	L.Push(v)
+	return 1
+}
+
+func (m *module) set(L *lua.LState) int {
+	ew := m.entity(L, 1)
+	name := L.CheckString(2)
+
+	if err := ew.Decode(name, fromLua(L.CheckAny(3))); err != nil {
+		L.RaiseError("entity %d: %s", ew.GetEntity().ID(), err)
+	}
+	return 0
+}
+
+func (m *module) view(L *lua.LState) int {
+	ew := m.entity(L, 1)
+	fn := L.CheckFunction(2)
+
+	names := make([]string, L.GetTop()-2)
+	args := make([]lua.LValue, len(names))
+	for i := range names {
+		names[i] = L.CheckString(i + 3)
+
+		v, ok := component(L, ew, names[i])
+		if !ok {
+			L.RaiseError("view on missing component '%s' of entity %d", names[i], ew.GetEntity().ID())
+		}
+		args[i] = v
+	}
+
+	L.Push(fn)
+	for i := range args {
+		L.Push(args[i])
+	}
+	L.Call(len(args), 0)
+
+	for i := range names {
+		if err := ew.Decode(names[i], fromLua(args[i])); err != nil {
+			L.RaiseError("entity %d: %s", ew.GetEntity().ID(), err)
+		}
+	}
+	return 0
+}
+
+// toLua converts the generic data of encoding/json to Lua values.
+func toLua(L *lua.LState, v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	case string:
+		return lua.LString(v)
+	case []interface{}:
+		tbl := L.CreateTable(len(v), 0)
+		for i := range v {
+			tbl.Append(toLua(L, v[i]))
+		}
+		return tbl
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		tbl := L.CreateTable(0, len(v))
+		for _, k := range keys {
+			tbl.RawSetString(k, toLua(L, v[k]))
+		}
+		return tbl
+	}
+
+	return lua.LNil
+}
+
+// fromLua converts Lua values to generic data that can be decoded into
+// components. Tables with only the keys 1 to n are converted to slices,
+// all other tables to maps.
+func fromLua(v lua.LValue) interface{} {
+	switch v := v.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LNumber:
+		return float64(v)
+	case lua.LString:
+		return string(v)
+	case *lua.LTable:
+		n := v.MaxN()
+
+		count := 0
+		v.ForEach(func(lua.LValue, lua.LValue) {
+			count++
+		})
+
+		if n > 0 && count == n {
+			s := make([]interface{}, n)
+			for i := range s {
+				s[i] = fromLua(v.RawGetInt(i + 1))
+			}
+			return s
+		}
+
+		m := make(map[string]interface{}, count)
+		v.ForEach(func(k lua.LValue, val lua.LValue) {
+			m[fmt.Sprint(fromLua(k))] = fromLua(val)
+		})
+		return m
+	}
+
+	return nil
+}
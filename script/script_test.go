@@ -0,0 +1,91 @@
+package script
+
+import (
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"github.com/yuin/gopher-lua"
+	"testing"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Velocity struct {
+	X int
+	Y int
+}
+
+type Inventory struct {
+	Items []string
+}
+
+type Unit struct {
+	kinshi.BaseDynamicEntity
+	Pos
+}
+
+func TestOpen(t *testing.T) {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+	assert.NoError(t, ecs.RegisterComponent(Inventory{}))
+
+	L := lua.NewState()
+	defer L.Close()
+	Open(L, ecs)
+
+	assert.NoError(t, L.DoString(`
+		local a = ecs.spawn("Unit", { Pos = { X = 1, Y = 2 }, Velocity = { X = 3, Y = 4 } })
+		local b = ecs.spawn("Unit")
+		ecs.set(b, "Inventory", { Items = { "sword", "shield" } })
+
+		for _, id in ipairs(ecs.query("Pos", "Velocity")) do
+			ecs.view(id, function(pos, vel)
+				pos.X = pos.X + vel.X
+				pos.Y = pos.Y + vel.Y
+			end, "Pos", "Velocity")
+		end
+
+		assert(#ecs.query("Pos") == 2)
+		assert(#ecs.select("SELECT Pos WHERE Pos.X > 1") == 1)
+		assert(ecs.has(b, "Inventory") and not ecs.has(b, "Velocity"))
+		assert(ecs.get(b, "Velocity") == nil)
+		assert(ecs.get(b, "Inventory").Items[2] == "shield")
+		assert(#ecs.components(a) == 2)
+	`))
+
+	ew := ecs.MustGet(1)
+	assert.NoError(t, ew.View(func(p *Pos) {
+		assert.Equal(t, Pos{X: 4, Y: 6}, *p)
+	}))
+
+	inv, err := ecs.MustGet(2).GetEntity().(kinshi.DynamicEntity).GetComponent("Inventory")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"sword", "shield"}, inv.(*Inventory).Items)
+
+	assert.NoError(t, L.DoString(`ecs.remove(2)`))
+	assert.Equal(t, 1, len(ecs.Iterate()))
+}
+
+func TestOpen_Errors(t *testing.T) {
+	ecs := kinshi.New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+
+	L := lua.NewState()
+	defer L.Close()
+	L.PreloadModule("kinshi", Loader(ecs))
+
+	for _, src := range []string{
+		`require("kinshi").spawn("Missing")`,
+		`require("kinshi").spawn("Unit", { Velocity = { X = 1 } })`,
+		`require("kinshi").remove(42)`,
+		`require("kinshi").get(42, "Pos")`,
+		`require("kinshi").select("SELECT")`,
+		`local ecs = require("kinshi"); ecs.view(ecs.spawn("Unit"), function() end, "Velocity")`,
+		`local ecs = require("kinshi"); ecs.set(ecs.spawn("Unit"), "Pos", { X = "left" })`,
+	} {
+		assert.Error(t, L.DoString(src), src)
+	}
+}
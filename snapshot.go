@@ -0,0 +1,213 @@
+package kinshi
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Snapshot is a read-only state of a ECS that is created by ReadOnlySnapshot.
+// It can be read from other go routines without holding the lock of the
+// world, which is useful for rendering or saving in the background.
+type Snapshot struct {
+	mtx   sync.Mutex
+	world *ECS
+
+	// ids and entries are the entities at the time of the snapshot. An
+	// entry is shared with the world until it's copied.
+	ids     []EntityID
+	entries []entityEntry
+	copied  []bool
+	shared  int
+
+	// ecs holds the copies once all entries are copied.
+	ecs   *ECS
+	ready bool
+}
+
+// ReadOnlySnapshot creates a copy-on-write snapshot of the current state.
+// The snapshot shares the entities with the world, so taking it only
+// copies the list of entities and the bookkeeping of the world, like the
+// net ids and scenes, while the lock is held.
+//
+// A entity is copied into the snapshot before it's changed or removed by
+// the world for the first time. The first read of the snapshot copies
+// the remaining entities, so writers only pay for the entities they
+// change, and the snapshot stops to cost anything once all entities are
+// copied. The snapshot doesn't start any pooled go routines.
+//
+// The entities of the snapshot are read only. View, ViewSpecific, Set
+// and all other functions that change components return ErrReadOnly on
+// them, use Into to read components.
+//
+// Important: Changes through component pointers that were kept after a
+// View returned aren't seen by the world, so they aren't seen by the
+// snapshot either. ReadOnlySnapshot must not be called inside of a View
+// or Each, because it needs the write lock.
+//
+// For example you want to save without blocking the game loop:
+//    snap := ecs.ReadOnlySnapshot()
+//    go func() {
+//        _ = snap.Marshal(file)
+//    }()
+func (ecs *ECS) ReadOnlySnapshot() *Snapshot {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	s := &Snapshot{
+		world:   ecs,
+		ids:     make([]EntityID, len(ecs.entities)),
+		entries: make([]entityEntry, len(ecs.entities)),
+		copied:  make([]bool, len(ecs.entities)),
+		shared:  len(ecs.entities),
+		ecs:     ecs.cloneShell(),
+	}
+
+	// Snapshots are short-lived and can't be closed,
+	// so they shouldn't start any pooled go routines.
+	s.ecs.routines = 1
+	s.ecs.readOnly = true
+
+	copy(s.entries, ecs.entities)
+	for i := range ecs.entities {
+		s.ids[i] = ecs.entities[i].Ent.ID()
+	}
+
+	if s.shared > 0 {
+		ecs.addSnapshot(s)
+	}
+
+	return s
+}
+
+func (ecs *ECS) addSnapshot(s *Snapshot) {
+	ecs.snapshotsMtx.Lock()
+	defer ecs.snapshotsMtx.Unlock()
+
+	snaps, _ := ecs.snapshots.Load().([]*Snapshot)
+	ecs.snapshots.Store(append(append([]*Snapshot{}, snaps...), s))
+}
+
+func (ecs *ECS) removeSnapshot(s *Snapshot) {
+	ecs.snapshotsMtx.Lock()
+	defer ecs.snapshotsMtx.Unlock()
+
+	snaps, _ := ecs.snapshots.Load().([]*Snapshot)
+	kept := make([]*Snapshot, 0, len(snaps))
+	for i := range snaps {
+		if snaps[i] != s {
+			kept = append(kept, snaps[i])
+		}
+	}
+	ecs.snapshots.Store(kept)
+}
+
+// preserve copies the entity with the id into all snapshots that still
+// share it. It needs to be called before the entity is changed or removed.
+func (ecs *ECS) preserve(id EntityID) {
+	snaps, _ := ecs.snapshots.Load().([]*Snapshot)
+	for i := range snaps {
+		snaps[i].preserve(id)
+	}
+}
+
+// write prepares a change of the entity with the id, see preserve.
+// Changes of the entities of a snapshot are rejected.
+func (ecs *ECS) write(id EntityID) error {
+	if ecs.readOnly {
+		return fmt.Errorf("entity %d: %w", id, ErrReadOnly)
+	}
+	ecs.preserve(id)
+	return nil
+}
+
+func (s *Snapshot) preserve(id EntityID) {
+	idx := sort.Search(len(s.ids), func(i int) bool {
+		return s.ids[i] >= id
+	})
+	if idx < len(s.ids) && s.ids[idx] == id {
+		s.copyAt(idx)
+	}
+}
+
+// copyAt copies the entry at the index if it's still shared and
+// detaches the snapshot from the world once nothing is shared.
+func (s *Snapshot) copyAt(idx int) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.copied[idx] {
+		return
+	}
+
+	s.entries[idx].Ent = cloneEntity(s.entries[idx].Ent)
+	s.copied[idx] = true
+	s.shared -= 1
+
+	if s.shared == 0 {
+		s.world.removeSnapshot(s)
+	}
+}
+
+// state copies all entries that are still shared and returns the
+// ECS that holds the copies.
+func (s *Snapshot) state() *ECS {
+	s.mtx.Lock()
+	ready := s.ready
+	s.mtx.Unlock()
+
+	if ready {
+		return s.ecs
+	}
+
+	// The entries are copied one by one, so that a writer
+	// only waits for a single copy at a time.
+	for i := range s.entries {
+		s.copyAt(i)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if !s.ready {
+		for i := range s.entries {
+			s.ecs.attach(s.entries[i].Ent)
+		}
+		s.ecs.entities = s.entries
+		s.ready = true
+	}
+
+	return s.ecs
+}
+
+// Len returns the number of entities in the snapshot.
+func (s *Snapshot) Len() int {
+	return len(s.ids)
+}
+
+// TypeName works like ECS.TypeName.
+func (s *Snapshot) TypeName(v interface{}) string {
+	return s.ecs.TypeName(v)
+}
+
+// Get fetches a entity of the snapshot by id.
+func (s *Snapshot) Get(id EntityID) (*EntityWrap, error) {
+	return s.state().Get(id)
+}
+
+// Iterate works like ECS.Iterate on the entities of the snapshot.
+func (s *Snapshot) Iterate(types ...interface{}) EntityIterator {
+	return s.state().Iterate(types...)
+}
+
+// IterateSpecific works like ECS.IterateSpecific on
+// the entities of the snapshot.
+func (s *Snapshot) IterateSpecific(t interface{}) EntityIterator {
+	return s.state().IterateSpecific(t)
+}
+
+// Marshal writes the snapshot in the same format as ECS.Marshal.
+func (s *Snapshot) Marshal(writer io.Writer) error {
+	return s.state().Marshal(writer)
+}
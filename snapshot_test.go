@@ -0,0 +1,109 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestECS_ReadOnlySnapshot(t *testing.T) {
+	ecs := New()
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "unit"}})
+	_, _ = ecs.AddEntity(&Unit{})
+
+	snap := ecs.ReadOnlySnapshot()
+
+	before := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(before))
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := 0; i < 100; i++ {
+			buf := &bytes.Buffer{}
+			assert.NoError(t, snap.Marshal(buf))
+			assert.Equal(t, before.String(), buf.String())
+			assert.Equal(t, 2, snap.Iterate(Name{}).Count())
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		_ = ecs.UpdateAll(func(n *Name) {
+			n.Value = "changed"
+		})
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, 2, snap.Len())
+	assert.Equal(t, 102, ecs.Len())
+
+	ew, err := snap.Get(id)
+	if assert.NoError(t, err) {
+		var n Name
+		assert.NoError(t, ew.Into(&n))
+		assert.Equal(t, "unit", n.Value)
+	}
+	assert.Equal(t, 2, snap.IterateSpecific(Unit{}).Count())
+}
+
+func TestECS_ReadOnlySnapshotCopyOnWrite(t *testing.T) {
+	ecs := New()
+
+	changed, _ := ecs.AddEntity(&DynamicUnit{Name: Name{Value: "changed"}})
+	removed, _ := ecs.AddEntity(&DynamicUnit{Name: Name{Value: "removed"}})
+	ew, _ := ecs.Get(changed)
+	assert.NoError(t, ew.Set(Health{Value: 10}))
+
+	snap := ecs.ReadOnlySnapshot()
+
+	assert.NoError(t, ew.View(func(n *Name) {
+		n.Value = "other"
+	}))
+	assert.NoError(t, ew.Set(Health{Value: 5}))
+	assert.NoError(t, ecs.RemoveByID(removed))
+
+	ew, err := snap.Get(changed)
+	if assert.NoError(t, err) {
+		var n Name
+		var h Health
+		assert.NoError(t, ew.Into(&n))
+		assert.NoError(t, ew.Into(&h))
+		assert.Equal(t, "changed", n.Value)
+		assert.Equal(t, 10, h.Value)
+	}
+	assert.Equal(t, 2, snap.Iterate(Name{}).Count())
+
+	// All entities are copied by the first read, so the
+	// snapshot doesn't need to see later changes anymore.
+	snaps, _ := ecs.snapshots.Load().([]*Snapshot)
+	assert.Empty(t, snaps)
+}
+
+func TestECS_ReadOnlySnapshotWrites(t *testing.T) {
+	ecs := New()
+
+	id, _ := ecs.AddEntity(&DynamicUnit{Name: Name{Value: "unit"}})
+	snap := ecs.ReadOnlySnapshot()
+
+	ew, err := snap.Get(id)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.ErrorIs(t, ew.View(func(n *Name) {}), ErrReadOnly)
+	assert.ErrorIs(t, ew.ViewSpecific(func(u *DynamicUnit) {}), ErrReadOnly)
+	assert.ErrorIs(t, ew.Set(Name{Value: "other"}), ErrReadOnly)
+	assert.ErrorIs(t, ew.Decode("Name", map[string]interface{}{"Value": "other"}), ErrReadOnly)
+	assert.ErrorIs(t, ew.GetEntity().(DynamicEntity).SetComponent(&Health{}), ErrReadOnly)
+
+	var n Name
+	assert.NoError(t, ew.Into(&n))
+	assert.Equal(t, "unit", n.Value)
+	assert.False(t, ew.Has(Health{}))
+}
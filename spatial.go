@@ -0,0 +1,349 @@
+package kinshi
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Point is a position in the world. Two dimensional indexes ignore Z.
+type Point struct {
+	X, Y, Z float64
+}
+
+// Positioner is implemented by position components that don't
+// store their position in numeric X, Y (and Z) fields.
+type Positioner interface {
+	Position() Point
+}
+
+// SpatialIndex is a index of entity positions that accelerates spatial
+// queries, see WithSpatialIndex. The ECS serializes all calls, so
+// implementations don't need to be safe for concurrent use.
+type SpatialIndex interface {
+	// Update inserts the entity or moves it to the given position.
+	Update(id EntityID, p Point)
+	// Remove removes the entity from the index.
+	Remove(id EntityID)
+	// Query calls fn for all entities inside the box between min and
+	// max, bounds included.
+	Query(min, max Point, fn func(id EntityID, p Point))
+}
+
+// spatialState keeps the spatial index in sync with the entities.
+type spatialState struct {
+	mtx     sync.Mutex
+	name    string
+	index   SpatialIndex
+	changes changeTracker
+}
+
+// WithSpatialIndex tracks the positions of all entities that contain the
+// position component c in the index, which enables fast spatial queries
+// like IterateNear. HashGrid, Quadtree and Octree are provided as
+// indexes. The position is read from the numeric X, Y and Z
+// fields of the component or from its Position method if it implements
+// Positioner.
+//
+// The index is updated automatically on the next query after
+// entities have been added, removed or written to. Only the entities
+// that changed since the last query are updated, so a query doesn't look
+// at the whole world. The index isn't carried over by Clone.
+//
+// For example:
+//    ecs := kinshi.New(kinshi.WithSpatialIndex(Pos{}, kinshi.NewHashGrid(32)))
+func WithSpatialIndex(c interface{}, index SpatialIndex) Option {
+	return func(ecs *ECS) {
+		s := &spatialState{index: index}
+		ecs.afterOptions(func() {
+			s.name = ecs.names.componentNames([]interface{}{c})[0]
+		})
+		ecs.spatial = s
+		ecs.track(&s.changes)
+	}
+}
+
+// positionFields caches the indexes of the X, Y and Z
+// fields of position components by type.
+var positionFields sync.Map
+
+// positionOf reads the position of the entity from the component
+// with the given name.
+func (tn *typeNames) positionOf(ent Entity, name string) (Point, bool) {
+	ptr, err := tn.fetchComponent(ent, name)
+	if err != nil {
+		return Point{}, false
+	}
+
+	if p, ok := ptr.(Positioner); ok {
+		return p.Position(), true
+	}
+
+	val := reflect.ValueOf(ptr).Elem()
+	if val.Kind() != reflect.Struct {
+		return Point{}, false
+	}
+
+	var fields [3]int
+	if cached, ok := positionFields.Load(val.Type()); ok {
+		fields = cached.([3]int)
+	} else {
+		for i, name := range []string{"X", "Y", "Z"} {
+			fields[i] = -1
+			if f, ok := val.Type().FieldByName(name); ok && len(f.Index) == 1 && f.PkgPath == "" {
+				fields[i] = f.Index[0]
+			}
+		}
+		positionFields.Store(val.Type(), fields)
+	}
+
+	var coords [3]float64
+	for i := range fields {
+		if fields[i] < 0 {
+			continue
+		}
+
+		f := val.Field(fields[i])
+		switch f.Kind() {
+		case reflect.Float32, reflect.Float64:
+			coords[i] = f.Float()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			coords[i] = float64(f.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			coords[i] = float64(f.Uint())
+		default:
+			return Point{}, false
+		}
+	}
+
+	if fields[0] < 0 || fields[1] < 0 {
+		return Point{}, false
+	}
+
+	return Point{X: coords[0], Y: coords[1], Z: coords[2]}, true
+}
+
+// refresh updates the positions of all changed entities. The caller
+// needs to hold the lock of the ECS and the mutex of the state.
+func (s *spatialState) refresh(ecs *ECS) {
+	s.changes.sync(ecs, func(id EntityID, ent Entity) bool {
+		p, ok := ecs.names.positionOf(ent, s.name)
+		if ok {
+			s.index.Update(id, p)
+		}
+		return ok
+	}, s.index.Remove)
+}
+
+// UpdateSpatialIndex updates the positions of all entities in the
+// spatial index. This is only needed if position components have been
+// changed outside of the ECS, e.g. by holding on to component pointers.
+func (ecs *ECS) UpdateSpatialIndex() {
+	s := ecs.spatial
+	if s == nil {
+		return
+	}
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.changes.reset()
+	s.refresh(ecs)
+}
+
+// iterateSpatial returns all entities with the given components that
+// are inside the box and for which within returns true, ordered by id.
+func (ecs *ECS) iterateSpatial(query string, min, max Point, within func(p Point) bool, types []interface{}) EntityIterator {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	rv := ecs.reads()
+
+	s := ecs.spatial
+	if s == nil {
+		_ = ecs.misuse(fmt.Errorf("%s without spatial index: %w", query, ErrNotFound))
+		return nil
+	}
+
+	var foundEnts []*EntityWrap
+
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName(query, types), len(rv.entries), len(foundEnts), time.Since(start))
+		}()
+	}
+
+	var ids []EntityID
+
+	ecs.rlock()
+	s.mtx.Lock()
+	s.refresh(ecs)
+	s.index.Query(min, max, func(id EntityID, p Point) {
+		if within == nil || within(p) {
+			ids = append(ids, id)
+		}
+	})
+	s.mtx.Unlock()
+	ecs.RUnlock()
+
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	names := ecs.componentNames(types)
+	for i := range ids {
+		if idx, ok := rv.find(ids[i]); ok && rv.has(idx, names) {
+			foundEnts = append(foundEnts, ecs.wrap(rv.entries[idx].Ent))
+		}
+	}
+
+	return foundEnts
+}
+
+// IterateNear returns all entities with the given components whose
+// position is within the radius around x and y. It needs a spatial
+// index, see WithSpatialIndex. The Z coordinate is ignored.
+//
+// For example all entities that can be sensed by a enemy:
+//    for _, ew := range ecs.IterateNear(pos.X, pos.Y, 10, Health{}) {
+//        // Work with the EntityWrap
+//    }
+func (ecs *ECS) IterateNear(x, y, radius float64, types ...interface{}) EntityIterator {
+	return ecs.iterateCircle("IterateNear", x, y, radius, types)
+}
+
+// IterateInCircle works like IterateNear, but takes the center as Point.
+// The Z coordinate is ignored.
+func (ecs *ECS) IterateInCircle(center Point, radius float64, types ...interface{}) EntityIterator {
+	return ecs.iterateCircle("IterateInCircle", center.X, center.Y, radius, types)
+}
+
+func (ecs *ECS) iterateCircle(query string, x, y, radius float64, types []interface{}) EntityIterator {
+	min := Point{X: x - radius, Y: y - radius, Z: math.Inf(-1)}
+	max := Point{X: x + radius, Y: y + radius, Z: math.Inf(1)}
+
+	return ecs.iterateSpatial(query, min, max, func(p Point) bool {
+		dx, dy := p.X-x, p.Y-y
+		return dx*dx+dy*dy <= radius*radius
+	}, types)
+}
+
+// IterateInRect returns all entities with the given components whose
+// position is inside the box between min and max, bounds included. It
+// needs a spatial index, see WithSpatialIndex. The Z bounds are only
+// used by three dimensional indexes like the Octree.
+//
+// For example all enemies that are on screen:
+//    for _, ew := range ecs.IterateInRect(camera.Min, camera.Max, Enemy{}) {
+//        // Draw the enemy
+//    }
+func (ecs *ECS) IterateInRect(min, max Point, types ...interface{}) EntityIterator {
+	return ecs.iterateSpatial("IterateInRect", min, max, nil, types)
+}
+
+// gridCell is the coordinate of a cell of a HashGrid.
+type gridCell struct {
+	X, Y int64
+}
+
+// HashGrid is a SpatialIndex that sorts the entities into square cells
+// of a fixed size. It works best if the entities are evenly distributed
+// and the queried areas are in the range of the cell size. The Z
+// coordinate is ignored.
+type HashGrid struct {
+	cellSize float64
+	cells    map[gridCell]map[EntityID]struct{}
+	points   map[EntityID]Point
+}
+
+// NewHashGrid creates a new empty hash grid with the given cell size.
+func NewHashGrid(cellSize float64) *HashGrid {
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+
+	return &HashGrid{
+		cellSize: cellSize,
+		cells:    map[gridCell]map[EntityID]struct{}{},
+		points:   map[EntityID]Point{},
+	}
+}
+
+func (g *HashGrid) cell(p Point) gridCell {
+	return gridCell{
+		X: int64(math.Floor(p.X / g.cellSize)),
+		Y: int64(math.Floor(p.Y / g.cellSize)),
+	}
+}
+
+// Update implements SpatialIndex.
+func (g *HashGrid) Update(id EntityID, p Point) {
+	c := g.cell(p)
+
+	if old, ok := g.points[id]; ok {
+		if oc := g.cell(old); oc != c {
+			g.removeFromCell(oc, id)
+		}
+	}
+
+	cell, ok := g.cells[c]
+	if !ok {
+		cell = map[EntityID]struct{}{}
+		g.cells[c] = cell
+	}
+	cell[id] = struct{}{}
+	g.points[id] = p
+}
+
+// Remove implements SpatialIndex.
+func (g *HashGrid) Remove(id EntityID) {
+	if p, ok := g.points[id]; ok {
+		g.removeFromCell(g.cell(p), id)
+		delete(g.points, id)
+	}
+}
+
+func (g *HashGrid) removeFromCell(c gridCell, id EntityID) {
+	cell := g.cells[c]
+	delete(cell, id)
+	if len(cell) == 0 {
+		delete(g.cells, c)
+	}
+}
+
+// Query implements SpatialIndex. The Z bounds are ignored.
+func (g *HashGrid) Query(min, max Point, fn func(id EntityID, p Point)) {
+	inside := func(p Point) bool {
+		return p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y
+	}
+
+	minX, minY := math.Floor(min.X/g.cellSize), math.Floor(min.Y/g.cellSize)
+	maxX, maxY := math.Floor(max.X/g.cellSize), math.Floor(max.Y/g.cellSize)
+
+	// Checking all entities is cheaper than visiting mostly empty cells.
+	if (maxX-minX+1)*(maxY-minY+1) > float64(len(g.cells)) {
+		for id, p := range g.points {
+			if inside(p) {
+				fn(id, p)
+			}
+		}
+		return
+	}
+
+	for x := int64(minX); x <= int64(maxX); x++ {
+		for y := int64(minY); y <= int64(maxY); y++ {
+			for id := range g.cells[gridCell{X: x, Y: y}] {
+				if p := g.points[id]; inside(p) {
+					fn(id, p)
+				}
+			}
+		}
+	}
+}
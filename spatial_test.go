@@ -0,0 +1,101 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+type Marker struct{}
+
+type Tracker struct {
+	BaseDynamicEntity
+	Velocity
+}
+
+// bruteNear returns the ids of all trackers within the radius.
+func bruteNear(ecs *ECS, x, y, radius float64) []EntityID {
+	var ids []EntityID
+	for _, ew := range ecs.Iterate(Velocity{}) {
+		v := ew.GetEntity().(*Tracker).Velocity
+		if (v.X-x)*(v.X-x)+(v.Y-y)*(v.Y-y) <= radius*radius {
+			ids = append(ids, ew.GetEntity().ID())
+		}
+	}
+	return ids
+}
+
+func testSpatialIndex(t *testing.T, index SpatialIndex) {
+	ecs := New(WithSpatialIndex(Velocity{}, index))
+	assert.NoError(t, ecs.RegisterEntity(&Tracker{}))
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		_, _ = ecs.AddEntity(&Tracker{Velocity: Velocity{X: rnd.Float64() * 1000, Y: rnd.Float64() * 1000}})
+	}
+
+	for i := 0; i < 20; i++ {
+		x, y, r := rnd.Float64()*1000, rnd.Float64()*1000, rnd.Float64()*100
+		assert.Equal(t, bruteNear(ecs, x, y, r), iteratorIDs(ecs.IterateNear(x, y, r)))
+	}
+
+	// Moved entities are picked up on the next query.
+	ew, _ := ecs.Get(1)
+	assert.NoError(t, ew.View(func(v *Velocity) {
+		v.X, v.Y = 2000, 2000
+	}))
+	assert.Equal(t, []EntityID{1}, iteratorIDs(ecs.IterateNear(2000, 2000, 1)))
+
+	// Queries are combined with the component filter.
+	assert.Empty(t, ecs.IterateNear(2000, 2000, 1, Marker{}))
+	assert.NoError(t, ew.GetEntity().(DynamicEntity).SetComponent(&Marker{}))
+	assert.Equal(t, []EntityID{1}, iteratorIDs(ecs.IterateNear(2000, 2000, 1, Marker{})))
+
+	assert.NoError(t, ecs.RemoveByID(1))
+	assert.Empty(t, ecs.IterateNear(2000, 2000, 1))
+
+	// Changes through held pointers need a explicit update.
+	var held *Velocity
+	ew, _ = ecs.Get(2)
+	_ = ew.View(func(v *Velocity) {
+		held = v
+	})
+	assert.Empty(t, ecs.IterateNear(-500, -500, 1))
+	held.X, held.Y = -500, -500
+	assert.Empty(t, ecs.IterateNear(-500, -500, 1))
+	ecs.UpdateSpatialIndex()
+	assert.Equal(t, []EntityID{2}, iteratorIDs(ecs.IterateNear(-500, -500, 1)))
+}
+
+func TestHashGrid(t *testing.T) {
+	testSpatialIndex(t, NewHashGrid(50))
+}
+
+func TestIterateNear_NoIndex(t *testing.T) {
+	ecs := New()
+	assert.Empty(t, ecs.IterateNear(0, 0, 10))
+	assert.Panics(t, func() {
+		New(WithStrict()).IterateNear(0, 0, 10)
+	})
+}
+
+func TestIterateInRect(t *testing.T) {
+	ecs := New(WithSpatialIndex(Velocity{}, NewQuadtree(Point{}, Point{X: 100, Y: 100})))
+	assert.NoError(t, ecs.RegisterEntity(&Tracker{}))
+
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 10; y++ {
+			id, _ := ecs.AddEntity(&Tracker{Velocity: Velocity{X: float64(x * 10), Y: float64(y * 10)}})
+			if x%2 == 0 {
+				_ = ecs.MustGet(id).GetEntity().(DynamicEntity).SetComponent(&Marker{})
+			}
+		}
+	}
+
+	assert.Len(t, ecs.IterateInRect(Point{X: 0, Y: 0}, Point{X: 20, Y: 20}), 9)
+	assert.Len(t, ecs.IterateInRect(Point{X: 0, Y: 0}, Point{X: 20, Y: 20}, Marker{}), 6)
+	assert.Len(t, ecs.IterateInRect(Point{X: 200, Y: 200}, Point{X: 300, Y: 300}), 0)
+
+	assert.Len(t, ecs.IterateInCircle(Point{X: 50, Y: 50}, 10), 5)
+	assert.Len(t, ecs.IterateInCircle(Point{X: 50, Y: 50}, 10, Marker{}), 2)
+}
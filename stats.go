@@ -0,0 +1,241 @@
+package kinshi
+
+import (
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// Stats contains statistics about the current state of a ECS.
+type Stats struct {
+	// Entities is the total number of entities.
+	Entities int
+	// EntitiesByType counts the entities per entity type.
+	EntitiesByType map[string]int
+	// Components counts the static and dynamic components by name.
+	Components map[string]int
+	// DynamicEntities is the number of entities that are dynamic.
+	DynamicEntities int
+	// DynamicComponents counts only the dynamic components by name.
+	DynamicComponents map[string]int
+	// ApproxMemory is a rough estimate of the memory in bytes that
+	// is used by the entity storage, entities and dynamic components.
+	// Memory referenced by components (slices, maps, ...) isn't counted.
+	ApproxMemory uint64
+	// HighestID is the high-water mark of the id counter.
+	HighestID EntityID
+	// SnapshotSize is the size in bytes of the last snapshot
+	// written by Marshal.
+	SnapshotSize int64
+}
+
+// Stats collects statistics about the ECS. Entities and components are
+// counted as they are added and removed, so only the pointer and
+// interface components of the entities that changed since the last call
+// need to be inspected. Dynamic components are counted for entities that
+// embed BaseDynamicEntity.
+func (ecs *ECS) Stats() Stats {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	dynamic, dynamicBytes, _ := ecs.dynCounts.read()
+
+	stats := Stats{
+		Entities:          len(ecs.entities),
+		EntitiesByType:    map[string]int{},
+		Components:        map[string]int{},
+		DynamicComponents: dynamic,
+		ApproxMemory:      uint64(cap(ecs.entities))*uint64(unsafe.Sizeof(entityEntry{})) + dynamicBytes,
+		HighestID:         EntityID(atomic.LoadUint64(&ecs.idCounter)),
+		SnapshotSize:      atomic.LoadInt64(&ecs.snapshotSize),
+	}
+
+	for name, count := range dynamic {
+		stats.Components[name] += count
+	}
+
+	optional := false
+	for typeName, count := range ecs.typeCounts {
+		stats.EntitiesByType[typeName] = count
+
+		meta, ok := ecs.metaCache[typeName]
+		if !ok {
+			continue
+		}
+
+		stats.ApproxMemory += uint64(count) * uint64(meta.t.Size())
+		for field := range meta.fields {
+			stats.Components[field] += count
+		}
+
+		if reflect.PtrTo(meta.t).Implements(dynamicEntityType) {
+			stats.DynamicEntities += count
+		}
+		optional = optional || len(meta.optional) > 0
+	}
+
+	if optional {
+		for name, count := range ecs.optionalCounts().sync(ecs) {
+			stats.Components[name] += count
+		}
+	}
+
+	return stats
+}
+
+// componentCounts counts the dynamic components of the entities that are
+// stored in a ECS. The entities report every added and removed component
+// to their owner, so the ECS doesn't need to inspect them.
+type componentCounts struct {
+	mtx        sync.Mutex
+	names      map[string]int
+	bytes      int64
+	keyedBytes int64
+}
+
+// add adds delta components with the name and type.
+func (cc *componentCounts) add(name string, delta int, t reflect.Type) {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+
+	if cc.names == nil {
+		cc.names = map[string]int{}
+	}
+
+	cc.names[name] += delta
+	if cc.names[name] <= 0 {
+		delete(cc.names, name)
+	}
+	cc.bytes += int64(delta) * int64(t.Size())
+}
+
+// addKeyed adds delta keyed components of the type. Keyed components
+// are only counted by size, just like they aren't part of GetComponents.
+func (cc *componentCounts) addKeyed(delta int, t reflect.Type) {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+
+	cc.keyedBytes += int64(delta) * int64(t.Size())
+}
+
+func (cc *componentCounts) reset() {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+
+	cc.names = nil
+	cc.bytes = 0
+	cc.keyedBytes = 0
+}
+
+// read returns a copy of the counts by name and the size of
+// the plain and of the keyed components.
+func (cc *componentCounts) read() (map[string]int, uint64, uint64) {
+	cc.mtx.Lock()
+	defer cc.mtx.Unlock()
+
+	names := make(map[string]int, len(cc.names))
+	for k, v := range cc.names {
+		names[k] = v
+	}
+
+	return names, uint64(cc.bytes), uint64(cc.keyedBytes)
+}
+
+// optionalCounts counts the set pointer and interface components. They
+// can be set and cleared through View, so they can't be counted on
+// change. Instead the entities that changed since the last sync are
+// recounted.
+type optionalCounts struct {
+	mtx     sync.Mutex
+	changes changeTracker
+	set     map[EntityID][]string
+	counts  map[string]int
+}
+
+// optionalCounts returns the counts of the optional components. They
+// are only tracked once they are needed, so that worlds which are
+// never inspected don't pay for the tracking.
+func (ecs *ECS) optionalCounts() *optionalCounts {
+	ecs.optionalOnce.Do(func() {
+		ecs.optional = &optionalCounts{
+			set:    map[EntityID][]string{},
+			counts: map[string]int{},
+		}
+		ecs.track(&ecs.optional.changes)
+	})
+	return ecs.optional
+}
+
+// sync recounts the changed entities and returns a copy of the counts by
+// name. The caller needs to hold the lock of the ECS.
+func (oc *optionalCounts) sync(ecs *ECS) map[string]int {
+	oc.mtx.Lock()
+	defer oc.mtx.Unlock()
+
+	oc.changes.sync(ecs, func(id EntityID, ent Entity) bool {
+		oc.remove(id)
+
+		meta := ecs.metaCache[ecs.names.getTypeName(ent)]
+		val := reflect.ValueOf(ent).Elem()
+
+		var set []string
+		for name, idx := range meta.optional {
+			if !val.Field(idx).IsNil() {
+				set = append(set, name)
+			}
+		}
+		if len(set) == 0 {
+			return false
+		}
+
+		oc.set[id] = set
+		for i := range set {
+			oc.counts[set[i]] += 1
+		}
+		return true
+	}, oc.remove)
+
+	counts := make(map[string]int, len(oc.counts))
+	for k, v := range oc.counts {
+		counts[k] = v
+	}
+	return counts
+}
+
+func (oc *optionalCounts) remove(id EntityID) {
+	for _, name := range oc.set[id] {
+		if oc.counts[name] <= 1 {
+			delete(oc.counts, name)
+		} else {
+			oc.counts[name] -= 1
+		}
+	}
+	delete(oc.set, id)
+}
+
+// Len returns the number of entities.
+func (ecs *ECS) Len() int {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	return len(ecs.entities)
+}
+
+// CountType returns the number of entities of the given entity type
+// in O(1), without building a iterator. If t is a string it is used as
+// the name of the type.
+//
+// For example:
+//    players := ecs.CountType(Player{})
+func (ecs *ECS) CountType(t interface{}) int {
+	name, ok := t.(string)
+	if !ok {
+		name = ecs.names.getTypeName(t)
+	}
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	return ecs.typeCounts[name]
+}
@@ -0,0 +1,90 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"unsafe"
+)
+
+func TestECS_Stats(t *testing.T) {
+	ecs := New()
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+
+	for i := 0; i < 5; i++ {
+		dyn := &DynamicUnit{}
+		_ = dyn.SetComponent(&Velocity{})
+		_, _ = ecs.AddEntity(dyn)
+	}
+
+	stats := ecs.Stats()
+	assert.Equal(t, 15, stats.Entities)
+	assert.Equal(t, map[string]int{"Unit": 10, "DynamicUnit": 5}, stats.EntitiesByType)
+	assert.Equal(t, 5, stats.DynamicEntities)
+	assert.Equal(t, map[string]int{"Velocity": 5}, stats.DynamicComponents)
+	assert.Equal(t, map[string]int{"Health": 10, "Pos": 10, "Name": 15, "Velocity": 5}, stats.Components)
+	assert.Equal(t, EntityID(15), stats.HighestID)
+	assert.NotZero(t, stats.ApproxMemory)
+}
+
+func TestECS_StatsChanges(t *testing.T) {
+	ecs := New()
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Velocity{})
+	id, _ := ecs.AddEntity(dyn)
+	armed, _ := ecs.AddEntity(&Armed{})
+
+	stats := ecs.Stats()
+	assert.Equal(t, map[string]int{"Velocity": 1}, stats.DynamicComponents)
+	assert.Zero(t, stats.Components["Health"])
+
+	_ = dyn.SetComponent(&Pos{})
+	_ = dyn.SetComponent(&Velocity{X: 1})
+	_ = dyn.RemoveComponent(Velocity{})
+	_ = dyn.SetComponent(&Dead{})
+	_ = ecs.MustGet(armed).ViewSpecific(func(a *Armed) {
+		a.Health = &Health{}
+	})
+
+	stats = ecs.Stats()
+	assert.Equal(t, map[string]int{"Dead": 1, "Pos": 1}, stats.DynamicComponents)
+	assert.Equal(t, 1, stats.Components["Health"], "set pointer component wasn't counted")
+	assert.Equal(t, 2, stats.Components["Pos"])
+
+	_ = ecs.RemoveByID(id)
+	stats = ecs.Stats()
+	assert.Empty(t, stats.DynamicComponents)
+	assert.Equal(t, 0, stats.DynamicEntities)
+	assert.Equal(t, uint64(unsafe.Sizeof(Armed{})), ecs.MemoryStats().EntityBytes)
+}
+
+func TestECS_CountType(t *testing.T) {
+	ecs := New()
+	ecs.RegisterEntity(&Unit{})
+
+	for i := 0; i < 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{})
+	}
+	dynID, _ := ecs.AddEntity(&DynamicUnit{})
+
+	assert.Equal(t, 11, ecs.Len())
+	assert.Equal(t, 10, ecs.CountType(Unit{}))
+	assert.Equal(t, 10, ecs.CountType(&Unit{}))
+	assert.Equal(t, 1, ecs.CountType("DynamicUnit"))
+
+	_ = ecs.RemoveEntity(ecs.MustGet(dynID).GetEntity())
+	assert.Equal(t, 0, ecs.CountType(DynamicUnit{}))
+
+	buf := &bytes.Buffer{}
+	_ = ecs.Marshal(buf)
+	ecs.Clear(false)
+	assert.Equal(t, 0, ecs.CountType(Unit{}))
+
+	_ = ecs.Unmarshal(buf)
+	assert.Equal(t, 10, ecs.CountType(Unit{}), "counts weren't rebuilt on unmarshal")
+	assert.Equal(t, 10, ecs.Clone().CountType(Unit{}), "counts weren't cloned")
+}
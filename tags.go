@@ -0,0 +1,143 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// isTag checks if the component is a empty struct like Dead{} or
+// Hostile{}. Such tag components carry no data, only their presence
+// matters.
+func isTag(c interface{}) bool {
+	t := reflect.TypeOf(c)
+	if t == nil {
+		return false
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t.Size() == 0
+}
+
+// maxTags is the number of tag types that dynamic entities can store as
+// flags. Further tag types are stored like any other component.
+const maxTags = 64
+
+// tagRegistry assigns a bit to every tag type that is set on a dynamic
+// entity, so that the presence of the tags is stored in a single flag
+// set instead of a component value per entity.
+var tagRegistry = struct {
+	sync.RWMutex
+	bits  map[reflect.Type]uint
+	types []reflect.Type
+}{bits: map[reflect.Type]uint{}}
+
+// tagBit returns the bit of the tag c and assigns one if the type is
+// new. False is returned if c isn't a tag or all bits are taken.
+func tagBit(c interface{}) (uint, bool) {
+	if !isTag(c) {
+		return 0, false
+	}
+
+	t := reflect.TypeOf(c)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	tagRegistry.RLock()
+	bit, ok := tagRegistry.bits[t]
+	tagRegistry.RUnlock()
+	if ok {
+		return bit, true
+	}
+
+	tagRegistry.Lock()
+	defer tagRegistry.Unlock()
+
+	if bit, ok := tagRegistry.bits[t]; ok {
+		return bit, true
+	}
+
+	if len(tagRegistry.types) >= maxTags {
+		return 0, false
+	}
+
+	bit = uint(len(tagRegistry.types))
+	tagRegistry.bits[t] = bit
+	tagRegistry.types = append(tagRegistry.types, t)
+
+	return bit, true
+}
+
+// tagType returns the type of the tag with the given bit.
+func tagType(bit uint) reflect.Type {
+	tagRegistry.RLock()
+	defer tagRegistry.RUnlock()
+
+	return tagRegistry.types[bit]
+}
+
+// serializedEntityJSON is the JSON form of a serialized entity. Tag
+// components are written as a list of names instead of empty objects.
+type serializedEntityJSON struct {
+	ID         EntityID
+	NetID      NetID  `json:",omitempty"`
+	UUID       *UUID  `json:",omitempty"`
+	Version    uint64 `json:",omitempty"`
+	Type       string
+	Components map[string]interface{}
+	Tags       []string `json:",omitempty"`
+}
+
+func (se serializedEntity) MarshalJSON() ([]byte, error) {
+	out := serializedEntityJSON{
+		ID:         se.ID,
+		NetID:      se.NetID,
+		Version:    se.Version,
+		Type:       se.Type,
+		Components: make(map[string]interface{}, len(se.Components)),
+	}
+
+	if se.UUID != UUIDNone {
+		out.UUID = &se.UUID
+	}
+
+	for name, c := range se.Components {
+		if isTag(c) {
+			out.Tags = append(out.Tags, name)
+			continue
+		}
+		out.Components[name] = c
+	}
+	sort.Strings(out.Tags)
+
+	return json.Marshal(out)
+}
+
+func (se *serializedEntity) UnmarshalJSON(data []byte) error {
+	var in serializedEntityJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	se.ID = in.ID
+	se.NetID = in.NetID
+	if in.UUID != nil {
+		se.UUID = *in.UUID
+	}
+	se.Version = in.Version
+	se.Type = in.Type
+	se.Components = in.Components
+
+	if se.Components == nil {
+		se.Components = make(map[string]interface{}, len(in.Tags))
+	}
+
+	for _, name := range in.Tags {
+		se.Components[name] = map[string]interface{}{}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type Dead struct{}
+
+type Hostile struct{}
+
+type TaggedUnit struct {
+	BaseEntity
+	Pos
+	Hostile
+}
+
+func TestTags(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&TaggedUnit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(&Dead{}))
+
+	_, err := ecs.AddEntity(&TaggedUnit{Pos: Pos{X: 1}})
+	assert.NoError(t, err)
+
+	dyn := &DynamicUnit{Name: Name{Value: "Zombie"}}
+	assert.NoError(t, dyn.SetComponent(&Dead{}))
+	_, err = ecs.AddEntity(dyn)
+	assert.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+
+	var raw []map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &raw))
+	assert.Len(t, raw, 2)
+	assert.JSONEq(t, `["Hostile"]`, string(raw[0]["Tags"]))
+	assert.JSONEq(t, `{"Pos":{"X":1,"Y":0}}`, string(raw[0]["Components"]))
+	assert.JSONEq(t, `["Dead"]`, string(raw[1]["Tags"]))
+	assert.JSONEq(t, `{"Name":{"Value":"Zombie"}}`, string(raw[1]["Components"]))
+
+	diff, err := DiffSnapshot(ecs, bytes.NewReader(buf.Bytes()))
+	assert.NoError(t, err)
+	assert.True(t, diff.Empty())
+
+	loaded := New()
+	assert.NoError(t, loaded.RegisterEntity(&TaggedUnit{}))
+	assert.NoError(t, loaded.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, loaded.RegisterComponent(&Dead{}))
+	assert.NoError(t, loaded.Unmarshal(bytes.NewReader(buf.Bytes())))
+
+	assert.Equal(t, 1, loaded.Count(Hostile{}))
+	assert.Equal(t, 1, loaded.Count(Dead{}))
+	assert.Equal(t, 1, loaded.Count(Dead{}, Name{}))
+}
+
+func TestTags_LegacySnapshot(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(&Dead{}))
+
+	snapshot := `[{"ID":1,"Type":"DynamicUnit","Components":{"Dead":{},"Name":{"Value":"Zombie"}}}]`
+	assert.NoError(t, ecs.Unmarshal(bytes.NewBufferString(snapshot)))
+	assert.Equal(t, 1, ecs.Count(Dead{}, Name{}))
+}
+
+func TestTags_Flags(t *testing.T) {
+	dyn := &DynamicUnit{}
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 1}))
+	assert.NoError(t, dyn.SetComponent(&Dead{}))
+	assert.NoError(t, dyn.SetComponent(&Hostile{}))
+
+	// Tags are only stored as flags.
+	assert.Len(t, dyn.values, 1)
+	assert.NotZero(t, dyn.tags)
+
+	assert.NoError(t, dyn.HasComponent(Dead{}))
+	assert.NoError(t, dyn.HasComponent("Hostile"))
+
+	c, err := dyn.GetComponent("Dead")
+	assert.NoError(t, err)
+	assert.IsType(t, &Dead{}, c)
+
+	comps := dyn.GetComponents()
+	if assert.Len(t, comps, 3) {
+		assert.IsType(t, &Dead{}, comps[0])
+		assert.IsType(t, &Hostile{}, comps[1])
+		assert.IsType(t, &Velocity{}, comps[2])
+	}
+
+	ecs := New()
+	_, _ = ecs.AddEntity(dyn)
+	assert.Equal(t, 1, ecs.Count(Dead{}, Hostile{}, Velocity{}))
+
+	clone := ecs.Clone()
+	assert.Equal(t, 1, clone.Count(Dead{}, Hostile{}))
+
+	assert.NoError(t, dyn.RemoveComponent(Dead{}))
+	assert.Equal(t, ErrNotFound, dyn.HasComponent(Dead{}))
+	assert.Equal(t, ErrNotFound, dyn.RemoveComponent("Dead"))
+	assert.Equal(t, 0, ecs.Count(Dead{}))
+	assert.Equal(t, 1, clone.Count(Dead{}))
+}
@@ -0,0 +1,80 @@
+// Package toml loads prefabs of a kinshi.ECS from TOML, e.g. from the
+// configuration files of a game. Each entity is a table of the Entity
+// array and follows the JSON form of kinshi.ECS.MarshalEntity, so the
+// same components need to be registered and the same decode hooks apply:
+//    [[Entity]]
+//    Type = "Orc"
+//    Tags = ["Hostile"]
+//
+//    [Entity.Components.Name]
+//    Value = "Grunt"
+//
+//    [Entity.Components.Health]
+//    Value = 50
+//    Max = 50
+//
+// Short entities can also be written as inline tables, e.g.
+// Entity = [{ Type = "Orc" }]. Missing ids are fine, the ECS assigns them.
+package toml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"github.com/BurntSushi/toml"
+	"io"
+)
+
+// LoadScene reads the entities of the file and adds them to the ECS
+// like kinshi.ECS.LoadScene.
+func LoadScene(r io.Reader, ecs *kinshi.ECS) (kinshi.SceneID, error) {
+	var f map[string]interface{}
+	if _, err := toml.NewDecoder(r).Decode(&f); err != nil {
+		return 0, err
+	}
+
+	for k := range f {
+		if k != "Entity" {
+			return 0, fmt.Errorf("unknown key '%s'", k)
+		}
+	}
+
+	ents := f["Entity"]
+	switch list := ents.(type) {
+	case nil:
+		ents = []interface{}{}
+	case []map[string]interface{}:
+	case []interface{}:
+		// Inline arrays are decoded without their element type.
+		for i := range list {
+			if _, ok := list[i].(map[string]interface{}); !ok {
+				return 0, fmt.Errorf("entity %d isn't a table", i+1)
+			}
+		}
+	default:
+		return 0, fmt.Errorf("Entity isn't a array of tables")
+	}
+
+	data, err := json.Marshal(ents)
+	if err != nil {
+		return 0, err
+	}
+	return ecs.LoadScene(bytes.NewReader(data))
+}
+
+// UnmarshalEntity decodes a single entity, which is a table in the form
+// of kinshi.ECS.MarshalEntity without the Entity header, and adds it
+// like kinshi.ECS.UnmarshalEntity.
+func UnmarshalEntity(ecs *kinshi.ECS, data []byte) (kinshi.EntityID, error) {
+	var ent map[string]interface{}
+	if err := toml.Unmarshal(data, &ent); err != nil {
+		return kinshi.EntityNone, err
+	}
+
+	data, err := json.Marshal(ent)
+	if err != nil {
+		return kinshi.EntityNone, err
+	}
+	return ecs.UnmarshalEntity(data)
+}
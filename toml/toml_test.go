@@ -0,0 +1,159 @@
+package toml
+
+import (
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Item struct {
+	Name  string
+	Count int
+}
+
+type Inventory struct {
+	Items []Item
+	Grid  [][]int
+}
+
+type Spawn struct {
+	At time.Time
+}
+
+const prefab = `
+[[Entity]]
+Type = "Orc"
+Tags = ["Hostile"]
+
+[Entity.Components.Name]
+Value = "Grunt"
+
+[Entity.Components.Health]
+Value = 50
+Max = 50
+
+[Entity.Components.Loot]
+Chance = 0.5
+Items = { gold = 3 }
+
+[[Entity]]
+Type = "Orc"
+Components.Name.Value = "Peon"
+`
+
+func TestLoadScene(t *testing.T) {
+	ecs := testutil.NewECS(t, Inventory{}, Spawn{})
+	scene, err := LoadScene(strings.NewReader(prefab), ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids, _ := ecs.SceneEntities(scene)
+	assert.Len(t, ids, 2)
+
+	ew := ecs.MustGet(ids[0])
+	assert.Equal(t, testutil.Health{Value: 50, Max: 50}, ew.GetEntity().(*testutil.Orc).Health)
+	assert.True(t, ew.Has(testutil.Hostile{}))
+
+	var loot testutil.Loot
+	assert.NoError(t, ew.View(func(l *testutil.Loot) { loot = *l }))
+	assert.Equal(t, testutil.Loot{Chance: 0.5, Items: map[string]int{"gold": 3}}, loot)
+	assert.Equal(t, "Peon", ecs.MustGet(ids[1]).GetEntity().(*testutil.Orc).Name.Value)
+
+	scene, err = LoadScene(strings.NewReader(""), ecs)
+	assert.NoError(t, err)
+	ids, _ = ecs.SceneEntities(scene)
+	assert.Len(t, ids, 0)
+
+	_, err = LoadScene(strings.NewReader(`[[Entities]]`), ecs)
+	assert.Error(t, err)
+
+	_, err = LoadScene(strings.NewReader(`[[Entity]`), ecs)
+	assert.Error(t, err)
+}
+
+func TestLoadScene_Arrays(t *testing.T) {
+	ecs := testutil.NewECS(t, Inventory{}, Spawn{})
+	scene, err := LoadScene(strings.NewReader(`
+[[Entity]]
+Type = "Orc"
+
+[Entity.Components.Inventory]
+Grid = [[1, 2], [3]]
+
+[[Entity.Components.Inventory.Items]]
+Name = "sword"
+Count = 1
+
+[[Entity.Components.Inventory.Items]]
+Name = "coin"
+Count = 20
+
+[Entity.Components.Spawn]
+At = 1979-05-27T07:32:00Z
+`), ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids, _ := ecs.SceneEntities(scene)
+	if !assert.Len(t, ids, 1) {
+		return
+	}
+
+	ew := ecs.MustGet(ids[0])
+	assert.NoError(t, ew.View(func(inv *Inventory, spawn *Spawn) {
+		assert.Equal(t, Inventory{
+			Items: []Item{{Name: "sword", Count: 1}, {Name: "coin", Count: 20}},
+			Grid:  [][]int{{1, 2}, {3}},
+		}, *inv)
+		assert.True(t, time.Date(1979, 5, 27, 7, 32, 0, 0, time.UTC).Equal(spawn.At))
+	}))
+}
+
+func TestLoadScene_Inline(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	scene, err := LoadScene(strings.NewReader(`
+Entity = [
+  { Type = "Orc", Tags = ["Hostile"], Components = { Name = { Value = "Grunt" } } },
+  { Type = "Unit", Components = { Pos = { X = 1, Y = 2 } } },
+]
+`), ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids, _ := ecs.SceneEntities(scene)
+	if !assert.Len(t, ids, 2) {
+		return
+	}
+	assert.Equal(t, "Grunt", ecs.MustGet(ids[0]).GetEntity().(*testutil.Orc).Name.Value)
+	assert.True(t, ecs.MustGet(ids[0]).Has(testutil.Hostile{}))
+	assert.Equal(t, testutil.Pos{X: 1, Y: 2}, ecs.MustGet(ids[1]).GetEntity().(*testutil.Unit).Pos)
+
+	_, err = LoadScene(strings.NewReader(`Entity = [{ Type = "Orc" }, "Unit"]`), ecs)
+	assert.Error(t, err)
+
+	_, err = LoadScene(strings.NewReader(`Entity = "Orc"`), ecs)
+	assert.Error(t, err)
+}
+
+func TestUnmarshalEntity(t *testing.T) {
+	ecs := testutil.NewECS(t, Inventory{}, Spawn{})
+	id, err := UnmarshalEntity(ecs, []byte(`
+ID = 4
+Type = "Orc"
+
+[Components.Health]
+Value = 7
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, kinshi.EntityID(4), id)
+	assert.Equal(t, 7, ecs.MustGet(4).GetEntity().(*testutil.Orc).Health.Value)
+
+	_, err = UnmarshalEntity(ecs, []byte(`Type = "Troll"`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Tx queues the mutations of a transaction, see ECS.Transaction.
+type Tx struct {
+	buf *CommandBuffer
+}
+
+// AddEntity queues the insertion of the entity. The id of the
+// entity is reserved immediately so that it can already be referenced.
+func (tx *Tx) AddEntity(ent Entity) (EntityID, error) {
+	return tx.buf.AddEntity(ent)
+}
+
+// RemoveEntity queues the removal of the entity with the given id.
+func (tx *Tx) RemoveEntity(id EntityID) {
+	tx.buf.RemoveEntity(id)
+}
+
+// SetComponent queues overwriting (or adding in case of a dynamic
+// entity) the component of the entity with a copy of c.
+func (tx *Tx) SetComponent(id EntityID, c interface{}) {
+	tx.buf.SetComponent(id, c)
+}
+
+// RemoveComponent queues the removal of a dynamic component. If
+// c is a string the component will be removed by name.
+func (tx *Tx) RemoveComponent(id EntityID, c interface{}) {
+	tx.buf.RemoveComponent(id, c)
+}
+
+// Transaction calls fn to queue mutations and then applies all of them
+// at once. If fn returns a error nothing is applied. If one of the
+// mutations fails, the already applied ones are rolled back and the
+// error is returned, so that operations on multiple entities can't be
+// half applied.
+//
+// For example a trade between two inventories:
+//    err := ecs.Transaction(func(tx *kinshi.Tx) error {
+//        if buyerGold.Amount < price {
+//            return ErrNotEnoughGold
+//        }
+//        tx.SetComponent(buyer, Gold{Amount: buyerGold.Amount - price})
+//        tx.SetComponent(seller, Gold{Amount: sellerGold.Amount + price})
+//        tx.RemoveEntity(item)
+//        return nil
+//    })
+//
+// fn runs without holding the lock, so the ECS can be read inside of it.
+// The mutations are applied under the write lock, which means that other
+// go routines either see all or none of them. Removal hooks fire for
+// removed entities even if they are added back by a roll back.
+func (ecs *ECS) Transaction(fn func(tx *Tx) error) error {
+	tx := &Tx{buf: ecs.NewCommandBuffer()}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	commands := tx.buf.commands
+
+	ecs.checkMutation()
+	ecs.lock()
+	defer ecs.Unlock()
+
+	undo := make([]func(), 0, len(commands))
+	for i := range commands {
+		restore := ecs.captureCommand(&commands[i])
+
+		if err := ecs.applyCommand(&commands[i]); err != nil {
+			for j := len(undo) - 1; j >= 0; j-- {
+				undo[j]()
+			}
+			return fmt.Errorf("transaction command %d: %w", i, err)
+		}
+
+		undo = append(undo, restore)
+	}
+
+	if ecs.commandLog != nil {
+		for i := range commands {
+			if err := commands[i].capture(ecs.names); err != nil {
+				return err
+			}
+			ecs.commandLog.append(commands[i])
+		}
+	}
+
+	return nil
+}
+
+// captureCommand captures the state that the command is going to change
+// and returns a function that restores it. The caller needs to hold the
+// write lock.
+func (ecs *ECS) captureCommand(cmd *Command) func() {
+	switch cmd.Type {
+	case CommandAddEntity:
+		return func() {
+			if _, idx, ok := ecs.findEntity(cmd.ID); ok {
+				ecs.removeAt(idx)
+			}
+		}
+	case CommandRemoveEntity:
+		entry, _, ok := ecs.findEntity(cmd.ID)
+		if !ok {
+			return func() {}
+		}
+
+		removed := *entry
+		netID := ecs.entityNetIDs[cmd.ID]
+		uuid := ecs.entityUUIDs[cmd.ID]
+
+		return func() {
+			removed.Ent.SetID(cmd.ID)
+			_ = ecs.insertEntity(removed)
+
+			if netID != NetIDNone {
+				ecs.bindNetID(cmd.ID, netID)
+			}
+			if uuid != UUIDNone {
+				ecs.bindUUID(cmd.ID, uuid)
+			}
+		}
+	case CommandSetComponent, CommandRemoveComponent:
+		entry, _, ok := ecs.findEntity(cmd.ID)
+		if !ok {
+			return func() {}
+		}
+		ent := entry.Ent
+		restore := ecs.names.captureComponent(ent, cmd.Component, cmd.value)
+		return func() {
+			ecs.preserve(ent.ID())
+			restore()
+			ecs.touch(ent)
+		}
+	}
+
+	return func() {}
+}
+
+// captureComponent captures the component with the given name, or the
+// interface field c would be set to, and returns a function that restores
+// it. Components that are missing are removed again on restore.
+func (tn *typeNames) captureComponent(ent Entity, name string, c interface{}) func() {
+	t := reflect.TypeOf(ent).Elem()
+	val := reflect.ValueOf(ent).Elem()
+
+	sc, ok := tn.staticField(t, name)
+	if !ok && c != nil {
+		sc, ok = tn.interfaceField(t, reflect.TypeOf(c))
+	}
+
+	if ok {
+		field := val.Field(sc.index)
+
+		if sc.kind == componentPtr && !field.IsNil() {
+			target := field.Elem()
+			prev := deepCopy(target)
+			return func() {
+				target.Set(prev)
+			}
+		}
+
+		prev := deepCopy(field)
+		return func() {
+			field.Set(prev)
+		}
+	}
+
+	dyn, ok := ent.(DynamicEntity)
+	if !ok {
+		return func() {}
+	}
+
+	prev, err := dyn.GetComponent(name)
+	if err != nil {
+		return func() {
+			_ = dyn.RemoveComponent(name)
+		}
+	}
+
+	return func() {
+		_ = dyn.SetComponent(prev)
+	}
+}
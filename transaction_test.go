@@ -0,0 +1,100 @@
+package kinshi
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_Transaction(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+
+	buyer, _ := ecs.AddEntity(&Unit{Health: Health{Value: 10}})
+	seller, _ := ecs.AddEntity(&Unit{Health: Health{Value: 20}})
+
+	var added EntityID
+	assert.NoError(t, ecs.Transaction(func(tx *Tx) error {
+		tx.SetComponent(buyer, Health{Value: 5})
+		tx.SetComponent(seller, Health{Value: 25})
+		added, _ = tx.AddEntity(&Unit{})
+		return nil
+	}))
+
+	assert.Equal(t, 5, ecs.MustGet(buyer).GetEntity().(*Unit).Health.Value)
+	assert.Equal(t, 25, ecs.MustGet(seller).GetEntity().(*Unit).Health.Value)
+	assert.True(t, ecs.MustGet(added).Valid(), "entity wasn't added")
+
+	errAbort := errors.New("abort")
+	err := ecs.Transaction(func(tx *Tx) error {
+		tx.SetComponent(buyer, Health{Value: 0})
+		return errAbort
+	})
+	assert.True(t, errors.Is(err, errAbort))
+	assert.Equal(t, 5, ecs.MustGet(buyer).GetEntity().(*Unit).Health.Value, "aborted transaction was applied")
+}
+
+func TestECS_TransactionRollback(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+
+	unit, _ := ecs.AddEntity(&Unit{Health: Health{Value: 10}})
+	item, _ := ecs.AddEntity(&Unit{Name: Name{Value: "item"}})
+	dyn, _ := ecs.AddEntity(&DynamicUnit{})
+	uuid, _ := ecs.AssignUUID(item)
+
+	var added EntityID
+	err := ecs.Transaction(func(tx *Tx) error {
+		tx.SetComponent(unit, Health{Value: 0})
+		tx.RemoveEntity(item)
+		tx.SetComponent(dyn, Velocity{X: 1})
+		added, _ = tx.AddEntity(&Unit{})
+		tx.RemoveEntity(EntityID(1000))
+		return nil
+	})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	assert.Equal(t, 10, ecs.MustGet(unit).GetEntity().(*Unit).Health.Value, "component wasn't rolled back")
+	assert.Equal(t, "item", ecs.MustGet(item).GetEntity().(*Unit).Name.Value, "removed entity wasn't restored")
+	byUUID, err := ecs.GetByUUID(uuid)
+	assert.NoError(t, err, "uuid wasn't restored")
+	assert.Equal(t, item, byUUID.GetEntity().ID())
+	assert.False(t, ecs.MustGet(dyn).Has(Velocity{}), "dynamic component wasn't removed")
+	_, err = ecs.Get(added)
+	assert.Error(t, err, "added entity wasn't removed")
+	assert.Equal(t, 3, ecs.Iterate().Count())
+}
+
+func TestECS_TransactionTouch(t *testing.T) {
+	ecs := New(WithSpatialIndex(Pos{}, NewHashGrid(8)))
+	assert.NoError(t, ecs.Index(Name{}, "Value"))
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "old"}})
+	assert.Equal(t, 1, ecs.GetByIndex(Name{}, "Value", "old").Count())
+	assert.Equal(t, 1, ecs.IterateNear(0, 0, 1).Count())
+	version := ecs.MustGet(id).Version()
+
+	assert.NoError(t, ecs.Transaction(func(tx *Tx) error {
+		tx.SetComponent(id, Name{Value: "new"})
+		tx.SetComponent(id, Pos{X: 50, Y: 50})
+		return nil
+	}))
+
+	assert.Greater(t, ecs.MustGet(id).Version(), version, "version didn't change")
+	assert.Equal(t, 0, ecs.GetByIndex(Name{}, "Value", "old").Count())
+	assert.Equal(t, 1, ecs.GetByIndex(Name{}, "Value", "new").Count())
+	assert.Equal(t, 1, ecs.IterateNear(50, 50, 1).Count())
+
+	// A roll back is a write as well.
+	version = ecs.MustGet(id).Version()
+	assert.Error(t, ecs.Transaction(func(tx *Tx) error {
+		tx.SetComponent(id, Name{Value: "rolled back"})
+		tx.RemoveEntity(EntityID(1000))
+		return nil
+	}))
+	assert.Greater(t, ecs.MustGet(id).Version(), version, "version didn't change")
+	assert.Equal(t, 1, ecs.GetByIndex(Name{}, "Value", "new").Count())
+	assert.Equal(t, 0, ecs.GetByIndex(Name{}, "Value", "rolled back").Count())
+}
@@ -0,0 +1,210 @@
+package kinshi
+
+const (
+	treeNodeCapacity = 8
+	treeMaxDepth     = 16
+)
+
+type treeItem struct {
+	id EntityID
+	p  Point
+}
+
+type treeNode struct {
+	min, max Point
+	items    []treeItem
+	children []*treeNode
+}
+
+// tree is a region tree over 2 (quadtree) or 3 (octree) dimensions. Leaf
+// nodes are split once they hold more than treeNodeCapacity entities, so
+// dense areas are subdivided finer than sparse ones. Positions outside
+// of the bounds of the root are kept in a separate list.
+type tree struct {
+	dims    int
+	root    *treeNode
+	points  map[EntityID]Point
+	outside map[EntityID]Point
+}
+
+func newTree(dims int, min, max Point) tree {
+	return tree{
+		dims:    dims,
+		root:    &treeNode{min: min, max: max},
+		points:  map[EntityID]Point{},
+		outside: map[EntityID]Point{},
+	}
+}
+
+func (t *tree) contains(min, max, p Point) bool {
+	inside := p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y
+	if t.dims == 3 {
+		inside = inside && p.Z >= min.Z && p.Z <= max.Z
+	}
+	return inside
+}
+
+func (t *tree) overlaps(n *treeNode, min, max Point) bool {
+	overlap := n.min.X <= max.X && n.max.X >= min.X && n.min.Y <= max.Y && n.max.Y >= min.Y
+	if t.dims == 3 {
+		overlap = overlap && n.min.Z <= max.Z && n.max.Z >= min.Z
+	}
+	return overlap
+}
+
+// child returns the index of the child of n that contains p.
+func (t *tree) child(n *treeNode, p Point) int {
+	i := 0
+	if p.X >= (n.min.X+n.max.X)/2 {
+		i |= 1
+	}
+	if p.Y >= (n.min.Y+n.max.Y)/2 {
+		i |= 2
+	}
+	if t.dims == 3 && p.Z >= (n.min.Z+n.max.Z)/2 {
+		i |= 4
+	}
+	return i
+}
+
+func (t *tree) split(n *treeNode) {
+	mid := Point{X: (n.min.X + n.max.X) / 2, Y: (n.min.Y + n.max.Y) / 2, Z: (n.min.Z + n.max.Z) / 2}
+
+	n.children = make([]*treeNode, 1<<uint(t.dims))
+	for i := range n.children {
+		c := &treeNode{min: n.min, max: mid}
+		if i&1 != 0 {
+			c.min.X, c.max.X = mid.X, n.max.X
+		}
+		if i&2 != 0 {
+			c.min.Y, c.max.Y = mid.Y, n.max.Y
+		}
+		if i&4 != 0 {
+			c.min.Z, c.max.Z = mid.Z, n.max.Z
+		}
+		if t.dims == 2 {
+			c.min.Z, c.max.Z = n.min.Z, n.max.Z
+		}
+		n.children[i] = c
+	}
+
+	items := n.items
+	n.items = nil
+	for i := range items {
+		c := n.children[t.child(n, items[i].p)]
+		c.items = append(c.items, items[i])
+	}
+}
+
+func (t *tree) insert(it treeItem) {
+	if !t.contains(t.root.min, t.root.max, it.p) {
+		t.outside[it.id] = it.p
+		return
+	}
+
+	n := t.root
+	for depth := 0; ; depth++ {
+		if n.children == nil {
+			n.items = append(n.items, it)
+			if len(n.items) > treeNodeCapacity && depth < treeMaxDepth {
+				t.split(n)
+			}
+			return
+		}
+		n = n.children[t.child(n, it.p)]
+	}
+}
+
+func (t *tree) remove(id EntityID, p Point) {
+	if _, ok := t.outside[id]; ok {
+		delete(t.outside, id)
+		return
+	}
+
+	n := t.root
+	for n.children != nil {
+		n = n.children[t.child(n, p)]
+	}
+
+	for i := range n.items {
+		if n.items[i].id == id {
+			n.items[i] = n.items[len(n.items)-1]
+			n.items = n.items[:len(n.items)-1]
+			return
+		}
+	}
+}
+
+// Update implements SpatialIndex.
+func (t *tree) Update(id EntityID, p Point) {
+	if old, ok := t.points[id]; ok {
+		if old == p {
+			return
+		}
+		t.remove(id, old)
+	}
+
+	t.points[id] = p
+	t.insert(treeItem{id: id, p: p})
+}
+
+// Remove implements SpatialIndex.
+func (t *tree) Remove(id EntityID) {
+	if p, ok := t.points[id]; ok {
+		t.remove(id, p)
+		delete(t.points, id)
+	}
+}
+
+// Query implements SpatialIndex.
+func (t *tree) Query(min, max Point, fn func(id EntityID, p Point)) {
+	for id, p := range t.outside {
+		if t.contains(min, max, p) {
+			fn(id, p)
+		}
+	}
+
+	t.query(t.root, min, max, fn)
+}
+
+func (t *tree) query(n *treeNode, min, max Point, fn func(id EntityID, p Point)) {
+	if !t.overlaps(n, min, max) {
+		return
+	}
+
+	for i := range n.items {
+		if t.contains(min, max, n.items[i].p) {
+			fn(n.items[i].id, n.items[i].p)
+		}
+	}
+
+	for i := range n.children {
+		t.query(n.children[i], min, max, fn)
+	}
+}
+
+// Quadtree is a SpatialIndex that recursively subdivides the area between
+// min and max into four quadrants wherever many entities are close to each
+// other. Unlike the HashGrid it copes well with very uneven densities. The
+// Z coordinate is ignored.
+type Quadtree struct {
+	tree
+}
+
+// NewQuadtree creates a new empty quadtree over the area between min and
+// max. Entities outside of the area are supported, but not accelerated.
+func NewQuadtree(min, max Point) *Quadtree {
+	return &Quadtree{tree: newTree(2, min, max)}
+}
+
+// Octree is the three dimensional version of the Quadtree, which
+// subdivides the volume between min and max into eight octants.
+type Octree struct {
+	tree
+}
+
+// NewOctree creates a new empty octree over the volume between min and
+// max. Entities outside of the volume are supported, but not accelerated.
+func NewOctree(min, max Point) *Octree {
+	return &Octree{tree: newTree(3, min, max)}
+}
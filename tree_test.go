@@ -0,0 +1,52 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestQuadtree(t *testing.T) {
+	testSpatialIndex(t, NewQuadtree(Point{}, Point{X: 1000, Y: 1000}))
+}
+
+func TestOctree(t *testing.T) {
+	testSpatialIndex(t, NewOctree(Point{Z: -1}, Point{X: 1000, Y: 1000, Z: 1}))
+
+	tree := NewOctree(Point{}, Point{X: 100, Y: 100, Z: 100})
+
+	// Dense cluster next to sparse points
+	rnd := rand.New(rand.NewSource(1))
+	points := map[EntityID]Point{}
+	for i := 1; i <= 1000; i++ {
+		p := Point{X: rnd.Float64() * 100, Y: rnd.Float64() * 100, Z: rnd.Float64() * 100}
+		if i%2 == 0 {
+			p = Point{X: 50 + rnd.Float64(), Y: 50 + rnd.Float64(), Z: 50 + rnd.Float64()}
+		}
+		points[EntityID(i)] = p
+		tree.Update(EntityID(i), p)
+	}
+
+	for i := 1; i <= 1000; i += 3 {
+		tree.Remove(EntityID(i))
+		delete(points, EntityID(i))
+	}
+
+	min, max := Point{X: 49.5, Y: 49.5, Z: 49.5}, Point{X: 50.5, Y: 50.5, Z: 50.5}
+
+	var expected, found []EntityID
+	for id, p := range points {
+		if p.X >= min.X && p.X <= max.X && p.Y >= min.Y && p.Y <= max.Y && p.Z >= min.Z && p.Z <= max.Z {
+			expected = append(expected, id)
+		}
+	}
+	tree.Query(min, max, func(id EntityID, p Point) {
+		found = append(found, id)
+	})
+
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+	assert.NotEmpty(t, found)
+	assert.Equal(t, expected, found)
+}
@@ -0,0 +1,294 @@
+// Package tui provides a small terminal inspector that attaches to a
+// running kinshi.ECS. It is line based so that it works in any terminal
+// without putting it into raw mode, which keeps it usable next to the
+// output of a terminal game.
+package tui
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	"github.com/BigJk/kinshi/inspect"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	clearScreen = "\033[H\033[2J"
+	bold        = "\033[1m"
+	reset       = "\033[0m"
+)
+
+// UI is a terminal inspector for a ECS.
+type UI struct {
+	// FrameInterval is the refresh interval of the watch command.
+	FrameInterval time.Duration
+
+	ecs *kinshi.ECS
+	in  io.Reader
+	out io.Writer
+}
+
+// New creates a new terminal inspector that reads commands from
+// in and writes to out, usually os.Stdin and os.Stdout.
+func New(ecs *kinshi.ECS, in io.Reader, out io.Writer) *UI {
+	return &UI{
+		FrameInterval: time.Second / 30,
+		ecs:           ecs,
+		in:            in,
+		out:           out,
+	}
+}
+
+// Run starts the inspector and blocks until the quit
+// command is entered or the input is closed.
+func (ui *UI) Run() error {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		scanner := bufio.NewScanner(ui.in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+		errs <- scanner.Err()
+		close(lines)
+	}()
+
+	ui.printf("kinshi inspector, type 'help' for a list of commands\n")
+
+	for {
+		ui.printf("> ")
+
+		line, ok := <-lines
+		if !ok {
+			return <-errs
+		}
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+
+		var err error
+		switch args[0] {
+		case "help":
+			ui.help()
+		case "ls":
+			err = ui.list(args[1:])
+		case "show":
+			err = ui.show(args[1:])
+		case "set":
+			err = ui.set(args[1:])
+		case "stats":
+			ui.stats()
+		case "watch":
+			ui.watch(lines)
+		case "quit", "exit":
+			return nil
+		default:
+			err = fmt.Errorf("unknown command '%s'", args[0])
+		}
+
+		if err != nil {
+			ui.printf("error: %s\n", err)
+		}
+	}
+}
+
+func (ui *UI) printf(format string, a ...interface{}) {
+	_, _ = fmt.Fprintf(ui.out, format, a...)
+}
+
+func (ui *UI) help() {
+	ui.printf(`commands:
+  ls [Type] [+Component...]         list entities, optionally filtered
+  show <id>                         show all components of a entity
+  set <id> <Component[.Field]> <v>  set a component or a field, v is parsed as JSON
+  stats                             show entity and component counts
+  watch                             refresh the stats every frame until enter is pressed
+  quit                              leave the inspector
+`)
+}
+
+func (ui *UI) list(args []string) error {
+	var filter inspect.Filter
+	for _, a := range args {
+		if strings.HasPrefix(a, "+") {
+			filter.Components = append(filter.Components, strings.TrimPrefix(a, "+"))
+		} else {
+			filter.Type = a
+		}
+	}
+
+	ents, err := inspect.New(ui.ecs).Entities(filter)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range ents {
+		ui.printf("%8d  %-20s %s\n", e.ID, e.Type, strings.Join(e.Names(), ", "))
+	}
+	ui.printf("%d entities\n", len(ents))
+
+	return nil
+}
+
+func parseID(args []string) (kinshi.EntityID, error) {
+	if len(args) == 0 {
+		return kinshi.EntityNone, fmt.Errorf("missing entity id")
+	}
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		return kinshi.EntityNone, fmt.Errorf("invalid entity id '%s'", args[0])
+	}
+
+	return kinshi.EntityID(id), nil
+}
+
+func (ui *UI) show(args []string) error {
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+
+	ew, err := ui.ecs.Get(id)
+	if err != nil {
+		return err
+	}
+
+	ent, err := inspect.Collect(ew)
+	if err != nil {
+		return err
+	}
+
+	ui.printf("%s%s %d%s\n", bold, ent.Type, ent.ID, reset)
+	for _, c := range ent.Components {
+		data, _ := json.MarshalIndent(c.Value, "  ", "  ")
+		ui.printf("  %s: %s\n", c.Name, data)
+	}
+
+	return nil
+}
+
+// set changes a single field of a component, or the whole component if
+// no field is given. The value is applied through EntityWrap.Apply, so
+// the other fields keep their values and the change is seen like any
+// other write.
+func (ui *UI) set(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("usage: set <id> <Component[.Field]> <value>")
+	}
+
+	id, err := parseID(args)
+	if err != nil {
+		return err
+	}
+
+	name, field := args[1], ""
+	if i := strings.IndexByte(args[1], '.'); i >= 0 {
+		name, field = args[1][:i], args[1][i+1:]
+	}
+
+	raw := strings.Join(args[2:], " ")
+	var value interface{}
+	if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	ew, err := ui.ecs.Get(id)
+	if err != nil {
+		return err
+	}
+
+	if field != "" {
+		ok, err := hasField(ew, name, field)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("component '%s' has no field '%s'", name, field)
+		}
+		value = map[string]interface{}{field: value}
+	}
+
+	return ew.Apply(map[string]interface{}{name: value})
+}
+
+// hasField checks if the encoded component with the name has the field.
+func hasField(ew *kinshi.EntityWrap, name string, field string) (bool, error) {
+	names := ew.Components()
+	values := ew.ComponentValues()
+
+	for i := range names {
+		if i >= len(values) || names[i] != name {
+			continue
+		}
+
+		data, err := json.Marshal(values[i])
+		if err != nil {
+			return false, err
+		}
+
+		fields := map[string]json.RawMessage{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return false, nil
+		}
+
+		_, ok := fields[field]
+		return ok, nil
+	}
+
+	return false, fmt.Errorf("component '%s': %w", name, kinshi.ErrNotFound)
+}
+
+func (ui *UI) stats() {
+	stats := ui.ecs.Stats()
+
+	ui.printf("%sentities: %d%s (dynamic: %d, highest id: %d, ~%d KiB)\n", bold, stats.Entities, reset, stats.DynamicEntities, stats.HighestID, stats.ApproxMemory/1024)
+
+	printCounts := func(title string, counts map[string]int) {
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		ui.printf("%s:\n", title)
+		for _, name := range names {
+			ui.printf("  %-20s %8d\n", name, counts[name])
+		}
+	}
+
+	printCounts("types", stats.EntitiesByType)
+	printCounts("components", stats.Components)
+}
+
+// watch redraws the stats every frame until a line is entered.
+func (ui *UI) watch(lines <-chan string) {
+	ticker := time.NewTicker(ui.FrameInterval)
+	defer ticker.Stop()
+
+	for {
+		ui.printf(clearScreen)
+		ui.stats()
+		ui.printf("\npress enter to stop watching\n")
+
+		select {
+		case <-lines:
+			return
+		case <-ticker.C:
+		}
+	}
+}
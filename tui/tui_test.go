@@ -0,0 +1,63 @@
+package tui
+
+import (
+	"bytes"
+	"github.com/BigJk/kinshi"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+type Pos struct {
+	X int
+	Y int
+}
+
+type Energy int
+
+type Unit struct {
+	kinshi.BaseEntity
+	Pos
+	Energy Energy `kinshi:"component"`
+}
+
+func TestUI(t *testing.T) {
+	ecs := kinshi.New()
+	id, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1}})
+	_, _ = ecs.AddEntity(&Unit{})
+
+	out := &bytes.Buffer{}
+	in := strings.NewReader("ls +Pos\nshow 1\nset 1 Pos.X 42\nset 1 Pos.Z 1\nstats\nunknown\nquit\n")
+
+	assert.NoError(t, New(ecs, in, out).Run())
+	assert.Contains(t, out.String(), "2 entities")
+	assert.Contains(t, out.String(), "Unit 1")
+	assert.Contains(t, out.String(), "component 'Pos' has no field 'Z'")
+	assert.Contains(t, out.String(), "entities: 2")
+	assert.Contains(t, out.String(), "unknown command 'unknown'")
+
+	_ = ecs.MustGet(id).View(func(p *Pos) {
+		assert.Equal(t, 42, p.X, "field wasn't set")
+	})
+}
+
+func TestUI_Set(t *testing.T) {
+	ecs := kinshi.New()
+	id, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1, Y: 2}})
+	ew := ecs.MustGet(id)
+
+	version := ew.Version()
+	assert.NoError(t, New(ecs, strings.NewReader("show 1\nls\nquit\n"), &bytes.Buffer{}).Run())
+	assert.Equal(t, version, ew.Version(), "show changed the entity")
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, New(ecs, strings.NewReader("set 1 Energy 7\nset 1 Pos.Y 5\nquit\n"), out).Run())
+	assert.NotContains(t, out.String(), "error")
+	assert.Greater(t, ew.Version(), version, "set didn't change the entity")
+
+	var u Unit
+	assert.NoError(t, ew.Into(&u.Pos))
+	assert.NoError(t, ew.Into(&u.Energy))
+	assert.Equal(t, Pos{X: 1, Y: 5}, u.Pos)
+	assert.Equal(t, Energy(7), u.Energy)
+}
@@ -0,0 +1,173 @@
+package kinshi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// errUnknownComponent is returned by decodeComponent if the type of the
+// component isn't known, so it can be kept as raw JSON.
+var errUnknownComponent = fmt.Errorf("unknown component: %w", ErrNotFound)
+
+// unknownComponents collects the components of a entity whose types
+// aren't known while it's decoded. Once stored in the ECS the maps are
+// never modified, only replaced.
+type unknownComponents map[string]json.RawMessage
+
+func (uc *unknownComponents) add(comp string, val interface{}) {
+	data, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+
+	if *uc == nil {
+		*uc = unknownComponents{}
+	}
+	(*uc)[comp] = data
+}
+
+// getUnknown returns the kept components of the entity.
+func (ecs *ECS) getUnknown(id EntityID) unknownComponents {
+	ecs.unknownMtx.Lock()
+	defer ecs.unknownMtx.Unlock()
+
+	return ecs.unknown[id]
+}
+
+// setUnknown replaces the kept components of the entity.
+func (ecs *ECS) setUnknown(id EntityID, uc unknownComponents) {
+	ecs.unknownMtx.Lock()
+	defer ecs.unknownMtx.Unlock()
+
+	if len(uc) == 0 {
+		delete(ecs.unknown, id)
+		return
+	}
+
+	if ecs.unknown == nil {
+		ecs.unknown = map[EntityID]unknownComponents{}
+	}
+	ecs.unknown[id] = uc
+}
+
+// addUnknown adds the kept components of the entity to the serialized
+// form, unless the entity got a component of the same name since.
+func (ecs *ECS) addUnknown(se *serializedEntity) {
+	for comp, data := range ecs.getUnknown(se.ID) {
+		if _, ok := se.Components[comp]; ok {
+			continue
+		}
+
+		// Unknown tags are written as tags again.
+		if string(data) == "{}" {
+			se.Components[comp] = struct{}{}
+		} else {
+			se.Components[comp] = data
+		}
+	}
+}
+
+// RawComponent returns the JSON of a component whose type wasn't known
+// when the Entity was decoded, see Unmarshal. This way tools and mods can
+// read components of types that aren't compiled into the binary.
+//
+// For example:
+//    if data, ok := ew.RawComponent("ModArmor"); ok {
+//        var armor struct{ Value int }
+//        _ = json.Unmarshal(data, &armor)
+//    }
+func (ew *EntityWrap) RawComponent(name string) (json.RawMessage, bool) {
+	data, ok := ew.parent.getUnknown(ew.ent.ID())[name]
+	if !ok {
+		return nil, false
+	}
+	return append(json.RawMessage(nil), data...), true
+}
+
+// RawComponents returns the names of all components that are kept as raw
+// JSON, sorted by name.
+func (ew *EntityWrap) RawComponents() []string {
+	uc := ew.parent.getUnknown(ew.ent.ID())
+	names := make([]string, 0, len(uc))
+	for name := range uc {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetRawComponent sets the JSON of a component whose type isn't known,
+// which is written out by the next Marshal. If the type of the component
+// is known the data is decoded into it like Decode instead. A nil data
+// removes the raw component.
+//
+// For example a mod tool patches the armor of a entity:
+//    err := ew.SetRawComponent("ModArmor", json.RawMessage(`{"Value": 5}`))
+func (ew *EntityWrap) SetRawComponent(name string, data json.RawMessage) error {
+	id := ew.ent.ID()
+	if id == EntityNone {
+		return ErrNotFound
+	}
+
+	if err := ew.parent.write(id); err != nil {
+		return err
+	}
+
+	if data == nil {
+		ew.parent.patchUnknown(id, name, nil)
+		ew.parent.touch(ew.ent)
+		return nil
+	}
+
+	var val interface{}
+	if err := json.Unmarshal(data, &val); err != nil {
+		return fmt.Errorf("raw component '%s': %w", name, err)
+	}
+
+	err := ew.Decode(name, val)
+	if err == nil {
+		ew.parent.patchUnknown(id, name, nil)
+		return nil
+	}
+	if !errors.Is(err, errUnknownComponent) {
+		return err
+	}
+
+	ew.parent.patchUnknown(id, name, append(json.RawMessage(nil), data...))
+	ew.parent.touch(ew.ent)
+	return nil
+}
+
+// patchUnknown replaces or removes a single kept component of the entity.
+func (ecs *ECS) patchUnknown(id EntityID, name string, data json.RawMessage) {
+	ecs.unknownMtx.Lock()
+	defer ecs.unknownMtx.Unlock()
+
+	old := ecs.unknown[id]
+	if _, ok := old[name]; !ok && data == nil {
+		return
+	}
+
+	uc := make(unknownComponents, len(old)+1)
+	for k, v := range old {
+		uc[k] = v
+	}
+
+	if data == nil {
+		delete(uc, name)
+	} else {
+		uc[name] = data
+	}
+
+	if len(uc) == 0 {
+		delete(ecs.unknown, id)
+		return
+	}
+
+	if ecs.unknown == nil {
+		ecs.unknown = map[EntityID]unknownComponents{}
+	}
+	ecs.unknown[id] = uc
+}
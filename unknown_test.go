@@ -0,0 +1,120 @@
+package kinshi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestECS_UnknownComponents(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.Register(&Unit{}, &DynamicUnit{}, Velocity{}))
+
+	snapshot := `[
+		{"ID": 1, "Type": "Unit", "Components": {"Health": {"Value": 5}, "ModArmor": {"Value": 3}}},
+		{"ID": 2, "Type": "DynamicUnit", "Components": {"Velocity": {"X": 1}, "ModPoison": {"Damage": 2}}, "Tags": ["ModCursed"]}
+	]`
+	assert.NoError(t, ecs.Unmarshal(strings.NewReader(snapshot)))
+	assert.Equal(t, 2, ecs.Len())
+
+	var buf bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&buf))
+
+	var ses []serializedEntityJSON
+	if !assert.NoError(t, json.Unmarshal(buf.Bytes(), &ses)) || !assert.Len(t, ses, 2) {
+		return
+	}
+	assert.JSONEq(t, `{"Value": 3}`, toJSONString(t, ses[0].Components["ModArmor"]))
+	assert.JSONEq(t, `{"Damage": 2}`, toJSONString(t, ses[1].Components["ModPoison"]))
+	assert.Equal(t, []string{"ModCursed"}, ses[1].Tags)
+
+	// The kept components survive a further round trip.
+	other := ecs.Clone()
+	var again bytes.Buffer
+	assert.NoError(t, other.Marshal(&again))
+	assert.JSONEq(t, buf.String(), again.String())
+
+	// Removed entities drop their kept components.
+	assert.NoError(t, ecs.RemoveByID(2))
+	data, err := ecs.MarshalEntity(1)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "ModArmor")
+	assert.NotContains(t, ecs.unknown, EntityID(2))
+}
+
+func TestECS_UnknownComponentsEntity(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.Register(&Unit{}))
+
+	id, err := ecs.UnmarshalEntity([]byte(`{"Type": "Unit", "Components": {"Health": {"Value": 5}, "ModArmor": {"Value": 3}}}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, err := ecs.MarshalEntity(id)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"ModArmor":{"Value":3}`)
+
+	// Replacing the entity without the component drops it.
+	_, err = ecs.UnmarshalEntity([]byte(fmt.Sprintf(`{"ID": %d, "Type": "Unit", "Components": {"Health": {"Value": 6}}}`, id)))
+	assert.NoError(t, err)
+
+	data, err = ecs.MarshalEntity(id)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "ModArmor")
+
+	// Other errors still fail.
+	_, err = ecs.UnmarshalEntity([]byte(`{"Type": "Unit", "Components": {"Health": {"Value": "full"}}}`))
+	assert.Error(t, err)
+}
+
+func toJSONString(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestEntityWrap_RawComponent(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.Register(&Unit{}))
+
+	id, err := ecs.UnmarshalEntity([]byte(`{"Type": "Unit", "Components": {"ModArmor": {"Value": 3}}, "Tags": ["ModCursed"]}`))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ew, err := ecs.Get(id)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	data, ok := ew.RawComponent("ModArmor")
+	assert.True(t, ok)
+	assert.JSONEq(t, `{"Value": 3}`, string(data))
+	assert.Equal(t, []string{"ModArmor", "ModCursed"}, ew.RawComponents())
+
+	_, ok = ew.RawComponent("Health")
+	assert.False(t, ok)
+
+	// Patching unknown components keeps them raw.
+	version := ew.Version()
+	assert.NoError(t, ew.SetRawComponent("ModArmor", json.RawMessage(`{"Value": 5}`)))
+	assert.NoError(t, ew.SetRawComponent("ModCursed", nil))
+	assert.Greater(t, ew.Version(), version)
+
+	data, err = ecs.MarshalEntity(id)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"ModArmor":{"Value":5}`)
+	assert.NotContains(t, string(data), "ModCursed")
+
+	// Known components are decoded.
+	assert.NoError(t, ew.SetRawComponent("Health", json.RawMessage(`{"Value": 7}`)))
+	assert.NoError(t, ew.View(func(h *Health) {
+		assert.Equal(t, 7, h.Value)
+	}))
+
+	assert.Error(t, ew.SetRawComponent("ModArmor", json.RawMessage(`{`)))
+}
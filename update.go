@@ -0,0 +1,88 @@
+package kinshi
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// UpdateAll calls fn for every entity that contains all the components
+// that fn takes as arguments and all the additionally given types. This
+// works like Iterate followed by a View on every found entity, but only
+// takes the lock once and doesn't allocate a EntityWrap per entity.
+//
+// If fn returns a error the update stops and the error is returned.
+// fn is called from the calling go routine, one entity after another.
+//
+// For example you want to move all entities containing
+// a Pos{} and Velocity{} component:
+//    ecs.UpdateAll(func(p *Pos, v *Velocity) {
+//        p.X += v.X
+//        p.Y += v.Y
+//    })
+//
+// Important: Like in View, fn must not add or remove entities.
+func (ecs *ECS) UpdateAll(fn interface{}, types ...interface{}) error {
+	fnType := reflect.TypeOf(fn)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return ecs.misuse(fmt.Errorf("fn not function"))
+	}
+
+	queryTypes := make([]interface{}, 0, fnType.NumIn()+len(types))
+	for i := 0; i < fnType.NumIn(); i++ {
+		if fnType.In(i).Kind() != reflect.Ptr {
+			return ecs.misuse(fmt.Errorf("fn argument %d isn't a pointer", i))
+		}
+		queryTypes = append(queryTypes, ecs.names.typeName(fnType.In(i).Elem()))
+	}
+	queryTypes = append(queryTypes, types...)
+
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	ecs.enterView()
+	defer ecs.leaveView()
+
+	matched := 0
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("UpdateAll", queryTypes), len(ecs.entities), matched, time.Since(start))
+		}()
+	}
+
+	names := ecs.componentNames(queryTypes)
+	fnVal := reflect.ValueOf(fn)
+
+	unlock := ecs.lockComponents(names[:fnType.NumIn()]...)
+	defer unlock()
+
+	args := make([]reflect.Value, fnType.NumIn())
+
+	for i := range ecs.entities {
+		if !ecs.hasComponents(&ecs.entities[i], names) {
+			continue
+		}
+		matched++
+
+		for j := range args {
+			ptr, err := ecs.names.fetchComponent(ecs.entities[i].Ent, names[j])
+			if err != nil {
+				return ecs.misuse(fmt.Errorf("update on missing component '%s': %w", names[j], err))
+			}
+			args[j] = reflect.ValueOf(ptr)
+		}
+
+		ecs.preserve(ecs.entities[i].Ent.ID())
+		err := callError(fnVal.Call(args))
+		ecs.touch(ecs.entities[i].Ent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
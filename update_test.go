@@ -0,0 +1,50 @@
+package kinshi
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestECS_UpdateAll(t *testing.T) {
+	ecs := New()
+
+	idUnit, _ := ecs.AddEntity(&Unit{Pos: Pos{X: 1, Y: 1}})
+
+	dyn := &DynamicUnit{}
+	_ = dyn.SetComponent(&Pos{X: 2, Y: 2})
+	_ = dyn.SetComponent(&Velocity{X: 1, Y: 2})
+	idDyn, _ := ecs.AddEntity(dyn)
+
+	calls := 0
+	assert.NoError(t, ecs.UpdateAll(func(p *Pos, v *Velocity) {
+		p.X += int(v.X)
+		p.Y += int(v.Y)
+		calls++
+	}))
+	assert.Equal(t, 1, calls)
+
+	_ = ecs.MustGet(idDyn).View(func(p *Pos) {
+		assert.Equal(t, Pos{X: 3, Y: 4}, *p)
+	})
+	_ = ecs.MustGet(idUnit).View(func(p *Pos) {
+		assert.Equal(t, Pos{X: 1, Y: 1}, *p)
+	})
+
+	calls = 0
+	assert.NoError(t, ecs.UpdateAll(func(p *Pos) {
+		calls++
+	}, Health{}))
+	assert.Equal(t, 1, calls, "additional types weren't respected")
+
+	errStop := errors.New("stop")
+	calls = 0
+	assert.Equal(t, errStop, ecs.UpdateAll(func(p *Pos) error {
+		calls++
+		return errStop
+	}))
+	assert.Equal(t, 1, calls, "update didn't stop on error")
+
+	assert.Error(t, ecs.UpdateAll(5))
+	assert.Error(t, ecs.UpdateAll(func(p Pos) {}))
+}
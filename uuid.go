@@ -0,0 +1,160 @@
+package kinshi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// UUID is a optional globally unique id of a entity. In contrast to the
+// NetID it is meant to reference entities from outside of the game, e.g.
+// from a database or from other servers.
+type UUID [16]byte
+
+// UUIDNone is the zero UUID which marks a entity without UUID.
+var UUIDNone = UUID{}
+
+// NewUUID creates a random (version 4) UUID.
+func NewUUID() (UUID, error) {
+	var u UUID
+	if _, err := rand.Read(u[:]); err != nil {
+		return UUIDNone, err
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u, nil
+}
+
+// ParseUUID parses a UUID in the canonical form
+// "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx".
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return UUIDNone, fmt.Errorf("invalid uuid '%s'", s)
+	}
+
+	hexStr := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:]
+	if _, err := hex.Decode(u[:], []byte(hexStr)); err != nil {
+		return UUIDNone, fmt.Errorf("invalid uuid '%s': %w", s, err)
+	}
+
+	return u, nil
+}
+
+// String returns the canonical form of the UUID.
+func (u UUID) String() string {
+	buf := make([]byte, 36)
+	hex.Encode(buf[0:8], u[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], u[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], u[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], u[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], u[10:])
+	return string(buf)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (u UUID) MarshalText() ([]byte, error) {
+	return []byte(u.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (u *UUID) UnmarshalText(text []byte) error {
+	parsed, err := ParseUUID(string(text))
+	if err != nil {
+		return err
+	}
+	*u = parsed
+	return nil
+}
+
+func (ecs *ECS) bindUUID(id EntityID, uuid UUID) {
+	ecs.uuids[uuid] = id
+	ecs.entityUUIDs[id] = uuid
+}
+
+func (ecs *ECS) unbindUUID(id EntityID) {
+	if uuid, ok := ecs.entityUUIDs[id]; ok {
+		delete(ecs.uuids, uuid)
+		delete(ecs.entityUUIDs, id)
+	}
+}
+
+// AssignUUID assigns a random UUID to the entity. If the
+// entity already has a UUID it will be returned instead.
+func (ecs *ECS) AssignUUID(id EntityID) (UUID, error) {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	if _, _, ok := ecs.findEntity(id); !ok {
+		return UUIDNone, ErrNotFound
+	}
+
+	if uuid, ok := ecs.entityUUIDs[id]; ok {
+		return uuid, nil
+	}
+
+	for {
+		uuid, err := NewUUID()
+		if err != nil {
+			return UUIDNone, err
+		}
+
+		if _, ok := ecs.uuids[uuid]; ok {
+			continue
+		}
+
+		ecs.bindUUID(id, uuid)
+		return uuid, nil
+	}
+}
+
+// SetUUID binds a known UUID to the entity, e.g. the one
+// under which the entity is stored in a database.
+func (ecs *ECS) SetUUID(id EntityID, uuid UUID) error {
+	ecs.lock()
+	defer ecs.Unlock()
+
+	if uuid == UUIDNone {
+		return ErrNoID
+	}
+
+	if _, _, ok := ecs.findEntity(id); !ok {
+		return ErrNotFound
+	}
+
+	if other, ok := ecs.uuids[uuid]; ok && other != id {
+		return ErrAlreadyExists
+	}
+
+	ecs.unbindUUID(id)
+	ecs.bindUUID(id, uuid)
+
+	return nil
+}
+
+// UUID returns the UUID of the entity.
+func (ecs *ECS) UUID(id EntityID) (UUID, bool) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	uuid, ok := ecs.entityUUIDs[id]
+	return uuid, ok
+}
+
+// GetByUUID fetches a Entity by its UUID.
+func (ecs *ECS) GetByUUID(uuid UUID) (*EntityWrap, error) {
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	if id, ok := ecs.uuids[uuid]; ok {
+		if v, _, ok := ecs.findEntity(id); ok {
+			return &EntityWrap{parent: ecs, ent: v.Ent}, nil
+		}
+	}
+	return nil, ErrNotFound
+}
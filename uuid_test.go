@@ -0,0 +1,80 @@
+package kinshi
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseUUID(t *testing.T) {
+	u, err := NewUUID()
+	assert.NoError(t, err)
+	assert.NotEqual(t, UUIDNone, u)
+	assert.Equal(t, byte(0x40), u[6]&0xf0, "not a version 4 uuid")
+
+	parsed, err := ParseUUID(u.String())
+	assert.NoError(t, err)
+	assert.Equal(t, u, parsed)
+
+	parsed, err = ParseUUID("123e4567-e89b-12d3-a456-426614174000")
+	assert.NoError(t, err)
+	assert.Equal(t, "123e4567-e89b-12d3-a456-426614174000", parsed.String())
+
+	_, err = ParseUUID("123e4567e89b12d3a456426614174000")
+	assert.Error(t, err)
+	_, err = ParseUUID("123e4567-e89b-12d3-a456-42661417400z")
+	assert.Error(t, err)
+}
+
+func TestECS_UUID(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+
+	id, _ := ecs.AddEntity(&Unit{Name: Name{Value: "stored"}})
+	other, _ := ecs.AddEntity(&Unit{})
+
+	uuid, err := ecs.AssignUUID(id)
+	assert.NoError(t, err)
+
+	again, _ := ecs.AssignUUID(id)
+	assert.Equal(t, uuid, again, "uuid changed on second assign")
+
+	ew, err := ecs.GetByUUID(uuid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, id, ew.GetEntity().ID())
+	}
+
+	assert.ErrorIs(t, ecs.SetUUID(other, uuid), ErrAlreadyExists)
+	assert.ErrorIs(t, ecs.SetUUID(other, UUIDNone), ErrNoID)
+	_, err = ecs.AssignUUID(EntityID(100))
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(t, ecs.Marshal(buf))
+	assert.Contains(t, buf.String(), uuid.String())
+
+	loaded := New()
+	assert.NoError(t, loaded.RegisterEntity(&Unit{}))
+	assert.NoError(t, loaded.Unmarshal(buf))
+
+	got, ok := loaded.UUID(id)
+	assert.True(t, ok, "uuid wasn't preserved")
+	assert.Equal(t, uuid, got)
+	_, ok = loaded.UUID(other)
+	assert.False(t, ok)
+
+	// UUIDs are carried over by merges.
+	merged := New()
+	_, _ = merged.AddEntity(&Unit{})
+	_, err = merged.Merge(loaded)
+	assert.NoError(t, err)
+
+	ew, err = merged.GetByUUID(uuid)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "stored", ew.GetEntity().(*Unit).Name.Value)
+	}
+
+	assert.NoError(t, merged.RemoveEntity(ew.GetEntity()))
+	_, err = merged.GetByUUID(uuid)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
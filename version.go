@@ -0,0 +1,72 @@
+package kinshi
+
+func entityVersion(ent Entity) uint64 {
+	if v, ok := ent.(versioned); ok {
+		return v.loadVersion()
+	}
+	return 0
+}
+
+func setVersion(ent Entity, version uint64) {
+	if v, ok := ent.(versioned); ok {
+		v.storeVersion(version)
+	}
+}
+
+func bumpVersion(ent Entity) {
+	if v, ok := ent.(versioned); ok {
+		v.bumpVersion()
+	}
+}
+
+// touch bumps the version of the entity and marks it as dirty, so that
+// indexes derived from the components know that they are outdated.
+func (ecs *ECS) touch(ent Entity) {
+	bumpVersion(ent)
+	ecs.markDirty(ent.ID())
+}
+
+// touched touches the entity if the component write that returned err
+// succeeded. All writes that aren't done through a EntityWrap go through
+// it, so that versions and indexes see them.
+func (ecs *ECS) touched(ent Entity, err error) error {
+	if err == nil {
+		ecs.touch(ent)
+	}
+	return err
+}
+
+func claimVersion(ent Entity, version uint64) bool {
+	if v, ok := ent.(versioned); ok {
+		return v.claimVersion(version)
+	}
+	return true
+}
+
+// Version returns the current version of the wrapped Entity. The version
+// is incremented on every View, Set and UpdateAll on the entity and on
+// every change of its dynamic components. Views count as writes, because
+// the components can be changed through the pointers. Entities that don't
+// embed BaseEntity always have version 0. The versions are kept by
+// Marshal and Unmarshal.
+func (ew *EntityWrap) Version() uint64 {
+	return entityVersion(ew.ent)
+}
+
+// ViewIfVersion works like View but only calls fn if the entity hasn't
+// been changed since it had the given version, otherwise a error wrapping
+// ErrVersion is returned. This allows optimistic updates, e.g. by editor
+// clients or async jobs whose results are applied back later on.
+//
+// For example:
+//    version := ew.Version()
+//    path := computePath(...) // Takes a while
+//    err := ew.ViewIfVersion(version, func(m *Movement) {
+//        m.Path = path
+//    })
+//    if errors.Is(err, kinshi.ErrVersion) {
+//        // The entity changed in between, compute again
+//    }
+func (ew *EntityWrap) ViewIfVersion(version uint64, fn interface{}) error {
+	return ew.view(fn, &version)
+}
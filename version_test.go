@@ -0,0 +1,83 @@
+package kinshi
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEntityWrap_Version(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+
+	id, _ := ecs.AddEntity(&Unit{})
+	ew, _ := ecs.Get(id)
+
+	version := ew.Version()
+	assert.NoError(t, ew.View(func(p *Pos) {
+		p.X = 1
+	}))
+	assert.Equal(t, version+1, ew.Version())
+
+	assert.NoError(t, ew.Set(Pos{X: 2}))
+	assert.Equal(t, version+2, ew.Version())
+
+	assert.NoError(t, ecs.UpdateAll(func(p *Pos) {
+		p.Y = 3
+	}))
+	assert.Equal(t, version+3, ew.Version())
+
+	var buf bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&buf))
+
+	restored := New()
+	assert.NoError(t, restored.RegisterEntity(&Unit{}))
+	assert.NoError(t, restored.Unmarshal(&buf))
+
+	rew, err := restored.Get(id)
+	assert.NoError(t, err)
+	assert.Equal(t, ew.Version(), rew.Version(), "version not kept by marshal")
+
+	cew, _ := ecs.Clone().Get(id)
+	assert.Equal(t, ew.Version(), cew.Version(), "version not kept by clone")
+}
+
+func TestEntityWrap_ViewIfVersion(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&Unit{}))
+
+	id, _ := ecs.AddEntity(&Unit{})
+	ew, _ := ecs.Get(id)
+
+	version := ew.Version()
+	assert.NoError(t, ew.ViewIfVersion(version, func(p *Pos) {
+		p.X = 1
+	}))
+	assert.Equal(t, version+1, ew.Version())
+
+	called := false
+	err := ew.ViewIfVersion(version, func(p *Pos) {
+		called = true
+	})
+	assert.True(t, errors.Is(err, ErrVersion))
+	assert.False(t, called, "fn called on outdated version")
+	assert.Equal(t, version+1, ew.Version(), "failed view changed version")
+}
+
+func TestEntityWrap_VersionDynamic(t *testing.T) {
+	ecs := New()
+	assert.NoError(t, ecs.RegisterEntity(&DynamicUnit{}))
+	assert.NoError(t, ecs.RegisterComponent(Velocity{}))
+
+	id, _ := ecs.AddEntity(&DynamicUnit{})
+	ew, _ := ecs.Get(id)
+
+	version := ew.Version()
+	dyn := ew.ent.(DynamicEntity)
+	assert.NoError(t, dyn.SetComponent(&Velocity{X: 1}))
+	assert.Equal(t, version+1, ew.Version())
+
+	assert.NoError(t, dyn.RemoveComponent(Velocity{}))
+	assert.Equal(t, version+2, ew.Version())
+}
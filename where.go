@@ -0,0 +1,145 @@
+package kinshi
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+var boolType = reflect.TypeOf(false)
+
+// compileFilter turns a filter expression or a typed predicate into a
+// check on entities. The returned names are the components a typed
+// predicate requests.
+func (tn *typeNames) compileFilter(filter interface{}) (func(ent Entity) bool, []string, error) {
+	if src, ok := filter.(string); ok {
+		expr, err := parseExpr(tn, src)
+		if err != nil {
+			return nil, nil, fmt.Errorf("filter '%s': %w", src, err)
+		}
+
+		return func(ent Entity) bool {
+			v, err := expr(ent)
+			return err == nil && v == true
+		}, nil, nil
+	}
+
+	fnType := reflect.TypeOf(filter)
+	if fnType == nil || fnType.Kind() != reflect.Func {
+		return nil, nil, fmt.Errorf("filter is neither a expression nor a function")
+	}
+
+	if fnType.NumOut() != 1 || fnType.Out(0) != boolType {
+		return nil, nil, fmt.Errorf("filter function needs to return a bool")
+	}
+
+	for i := 0; i < fnType.NumIn(); i++ {
+		if fnType.In(i).Kind() != reflect.Ptr {
+			return nil, nil, fmt.Errorf("filter function arguments need to be pointers")
+		}
+	}
+
+	names := tn.viewSignature(fnType)
+	fn := reflect.ValueOf(filter)
+
+	return func(ent Entity) bool {
+		args := getCallArgs()
+		defer putCallArgs(args)
+
+		for i := range names {
+			ptr, err := tn.fetchComponent(ent, names[i])
+			if err != nil {
+				return false
+			}
+			*args = append(*args, reflect.ValueOf(ptr))
+		}
+
+		return fn.Call(*args)[0].Bool()
+	}, names, nil
+}
+
+// IterateWhere works like Iterate but only returns the entities that
+// satisfy the filter. The filter is evaluated during the scan, so no
+// wrappers are created for the entities that don't match and large
+// worlds are filtered by the same go routines that do the scan.
+//
+// The filter is either a expression or a function that takes pointers to
+// components and returns a bool. Components requested by the function
+// don't need to be listed in types again. Expressions reference fields as
+// "Component.Field" and support arithmetic, comparisons, &&, || and !, or
+// AND, OR and NOT. has('Component') checks for a component and tag('name')
+// for a tag component by name, ignoring the case. Entities with components
+// or fields that an expression can't evaluate don't match.
+//
+// The lock is held during the scan, so the filter must not use the ECS.
+//
+// For example you want to find all entities with less than half health:
+//    it, err := ecs.IterateWhere("Health.Value < Health.Max / 2")
+//
+// Or the same with a typed predicate:
+//    it, err := ecs.IterateWhere(func(h *Health) bool {
+//        return h.Value < h.Max/2
+//    })
+func (ecs *ECS) IterateWhere(filter interface{}, types ...interface{}) (EntityIterator, error) {
+	ecs.checkQueryTypes(types...)
+	ecs.countIterate()
+
+	pred, predNames, err := ecs.names.compileFilter(filter)
+	if err != nil {
+		return nil, ecs.misuse(err)
+	}
+
+	rv := ecs.reads()
+
+	var foundEnts []*EntityWrap
+
+	if ecs.profiler != nil {
+		start := time.Now()
+		defer func() {
+			ecs.profiler.record(ecs.queryName("IterateWhere", types), len(rv.entries), len(foundEnts), time.Since(start))
+		}()
+	}
+
+	names := append(ecs.componentNames(types), predNames...)
+
+	ecs.rlock()
+	defer ecs.RUnlock()
+
+	foundEnts, _ = ecs.scan(context.Background(), ecs.queryLabels("IterateWhere", types), rv, rv.routines, func(i int) bool {
+		return rv.has(i, names) && pred(rv.entries[i].Ent)
+	})
+
+	return foundEnts, nil
+}
+
+// Where returns a new iterator with the entities of it that satisfy the
+// filter, see IterateWhere for the supported filters. As the wrappers
+// already exist, IterateWhere should be preferred for large worlds.
+//
+// For example:
+//    it, err := ecs.Iterate(Health{}).Where("Health.Value < Health.Max / 2")
+func (it EntityIterator) Where(filter interface{}) (EntityIterator, error) {
+	names := defaultNames
+	if len(it) > 0 {
+		names = it[0].parent.names
+	}
+
+	pred, _, err := names.compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	var res EntityIterator
+	for i := range it {
+		it[i].rlock()
+		ok := pred(it[i].ent)
+		it[i].runlock()
+
+		if ok {
+			res = append(res, it[i])
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,58 @@
+package kinshi
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestIterateWhere(t *testing.T) {
+	ecs := New()
+
+	for i := 1; i <= 10; i++ {
+		_, _ = ecs.AddEntity(&Unit{Health: Health{Value: i, Max: 10}})
+	}
+	_, _ = ecs.AddEntity(&DynamicUnit{})
+
+	it, err := ecs.IterateWhere("Health.Value < Health.Max / 2")
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{1, 2, 3, 4}, iteratorIDs(it))
+
+	it, err = ecs.IterateWhere(func(h *Health) bool {
+		return h.Value > 8
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{9, 10}, iteratorIDs(it))
+
+	// The filter is combined with the types.
+	it, err = ecs.IterateWhere("true", Name{})
+	assert.NoError(t, err)
+	assert.Len(t, it, 11)
+	it, err = ecs.IterateWhere("true", Name{}, Health{})
+	assert.NoError(t, err)
+	assert.Len(t, it, 10)
+
+	it, err = ecs.Iterate(Health{}).Where("Health.Value % 5 == 0")
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{5, 10}, iteratorIDs(it))
+
+	_, err = ecs.IterateWhere("Health.Value <")
+	assert.Error(t, err)
+	_, err = ecs.IterateWhere(func(h Health) bool { return true })
+	assert.Error(t, err)
+	_, err = ecs.IterateWhere(func(h *Health) {})
+	assert.Error(t, err)
+	_, err = ecs.Iterate().Where(42)
+	assert.Error(t, err)
+}
+
+func TestIterateWhere_Parallel(t *testing.T) {
+	ecs := New(WithRoutines(4), WithParallelThreshold(100))
+
+	for i := 0; i < 1000; i++ {
+		_, _ = ecs.AddEntity(&Unit{Health: Health{Value: i}})
+	}
+
+	it, err := ecs.IterateWhere("Health.Value >= 990")
+	assert.NoError(t, err)
+	assert.Equal(t, []EntityID{991, 992, 993, 994, 995, 996, 997, 998, 999, 1000}, iteratorIDs(it))
+}
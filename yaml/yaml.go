@@ -0,0 +1,220 @@
+// Package yaml reads and writes the snapshots, scenes and entities of a
+// kinshi.ECS as YAML, which is more pleasant to write by hand than JSON,
+// e.g. for prefabs like item templates or enemy archetypes. The documents
+// follow the JSON form of kinshi.ECS.Marshal, so the same components need
+// to be registered and the same decode hooks apply:
+//    - Type: Orc
+//      Components:
+//        Name:
+//          Value: Grunt
+//        Health:
+//          Value: 50
+//          Max: 50
+//      Tags: [Hostile]
+//
+// Missing ids are fine for hand written entities, the ECS assigns them.
+// Snapshots and scenes can be split into multiple documents, their lists
+// of entities are concatenated. Anchors and merge keys can be used to
+// share components between the entities of a file:
+//    - &grunt
+//      Type: Orc
+//      Components:
+//        Health: {Value: 50, Max: 50}
+//    - <<: *grunt
+//      Tags: [Hostile]
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BigJk/kinshi"
+	yamlv3 "gopkg.in/yaml.v3"
+	"io"
+)
+
+// Marshal writes all entities of the ECS as YAML.
+func Marshal(w io.Writer, ecs *kinshi.ECS) error {
+	var buf bytes.Buffer
+	if err := ecs.Marshal(&buf); err != nil {
+		return err
+	}
+	return fromJSON(w, buf.Bytes())
+}
+
+// Unmarshal reads a YAML snapshot and replaces all entities of the ECS,
+// like kinshi.ECS.Unmarshal.
+func Unmarshal(r io.Reader, ecs *kinshi.ECS) error {
+	data, err := toJSON(r, true)
+	if err != nil {
+		return err
+	}
+	return ecs.Unmarshal(bytes.NewReader(data))
+}
+
+// LoadScene reads a list of entities and adds them to the ECS like
+// kinshi.ECS.LoadScene, e.g. to spawn a prefab.
+func LoadScene(r io.Reader, ecs *kinshi.ECS) (kinshi.SceneID, error) {
+	data, err := toJSON(r, true)
+	if err != nil {
+		return 0, err
+	}
+	return ecs.LoadScene(bytes.NewReader(data))
+}
+
+// MarshalEntity encodes a single entity as YAML.
+func MarshalEntity(ecs *kinshi.ECS, id kinshi.EntityID) ([]byte, error) {
+	data, err := ecs.MarshalEntity(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := fromJSON(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalEntity decodes a single entity in the form of MarshalEntity
+// and adds it like kinshi.ECS.UnmarshalEntity.
+func UnmarshalEntity(ecs *kinshi.ECS, data []byte) (kinshi.EntityID, error) {
+	data, err := toJSON(bytes.NewReader(data), false)
+	if err != nil {
+		return kinshi.EntityNone, err
+	}
+	return ecs.UnmarshalEntity(data)
+}
+
+// fromJSON writes the JSON data as YAML. The order of the object keys is
+// kept, so the fields of components are in order of declaration.
+func fromJSON(w io.Writer, data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	node, err := nodeOf(dec)
+	if err != nil {
+		return err
+	}
+
+	enc := yamlv3.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// nodeOf reads the next JSON value from the decoder as YAML node.
+func nodeOf(dec *json.Decoder) (*yamlv3.Node, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok := tok.(type) {
+	case json.Delim:
+		node := &yamlv3.Node{Kind: yamlv3.MappingNode, Tag: "!!map"}
+		if tok == '[' {
+			node = &yamlv3.Node{Kind: yamlv3.SequenceNode, Tag: "!!seq"}
+		}
+
+		for dec.More() {
+			if node.Kind == yamlv3.MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+
+			child, err := nodeOf(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, child)
+		}
+
+		// The closing delimiter.
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case json.Number:
+		if _, err := tok.Int64(); err == nil {
+			return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!int", Value: tok.String()}, nil
+		}
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!float", Value: tok.String()}, nil
+	case string:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!str", Value: tok}, nil
+	case bool:
+		return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(tok)}, nil
+	}
+	return &yamlv3.Node{Kind: yamlv3.ScalarNode, Tag: "!!null", Value: "null"}, nil
+}
+
+// toJSON reads YAML and converts it into JSON. If list is set every
+// document needs to be a list and the lists are concatenated, otherwise
+// only a single document is allowed.
+func toJSON(r io.Reader, list bool) ([]byte, error) {
+	var docs []interface{}
+
+	dec := yamlv3.NewDecoder(r)
+	for {
+		var v interface{}
+		if err := dec.Decode(&v); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		docs = append(docs, generic(v))
+	}
+
+	if !list {
+		switch len(docs) {
+		case 0:
+			return json.Marshal(nil)
+		case 1:
+			return json.Marshal(docs[0])
+		}
+		return nil, fmt.Errorf("expected a single document but got %d", len(docs))
+	}
+
+	var all []interface{}
+	for i := range docs {
+		if docs[i] == nil {
+			continue
+		}
+
+		ents, ok := docs[i].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("document %d isn't a list of entities", i+1)
+		}
+		all = append(all, ents...)
+	}
+	return json.Marshal(all)
+}
+
+// generic converts a decoded YAML value into a value that can be
+// encoded as JSON.
+func generic(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = generic(v[k])
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k := range v {
+			m[fmt.Sprint(k)] = generic(v[k])
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = generic(v[i])
+		}
+		return v
+	}
+	return v
+}
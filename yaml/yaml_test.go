@@ -0,0 +1,162 @@
+package yaml
+
+import (
+	"bytes"
+	"github.com/BigJk/kinshi/internal/testutil"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+const prefab = `
+- Type: Orc
+  Components:
+    Name:
+      Value: Grunt
+    Health:
+      Value: 50
+      Max: 50
+    Loot:
+      Chance: 0.5
+      Items: {gold: 3}
+  Tags: [Hostile]
+- Type: Orc
+  Components:
+    Name: {Value: "true"}
+`
+
+func TestLoadScene(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	scene, err := LoadScene(strings.NewReader(prefab), ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids, _ := ecs.SceneEntities(scene)
+	assert.Len(t, ids, 2)
+
+	ew := ecs.MustGet(ids[0])
+	assert.Equal(t, testutil.Health{Value: 50, Max: 50}, ew.GetEntity().(*testutil.Orc).Health)
+	assert.True(t, ew.Has(testutil.Hostile{}))
+
+	var loot testutil.Loot
+	assert.NoError(t, ew.View(func(l *testutil.Loot) { loot = *l }))
+	assert.Equal(t, testutil.Loot{Chance: 0.5, Items: map[string]int{"gold": 3}}, loot)
+	assert.Equal(t, "true", ecs.MustGet(ids[1]).GetEntity().(*testutil.Orc).Name.Value)
+
+	_, err = LoadScene(strings.NewReader("- Type: [Orc"), ecs)
+	assert.Error(t, err)
+}
+
+func TestLoadScene_Anchors(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	scene, err := LoadScene(strings.NewReader(`
+- &grunt
+  Type: Orc
+  Components:
+    Health: &health {Value: 50, Max: 50}
+    Loot: {Chance: 0.5}
+  Tags: [Hostile]
+- <<: *grunt
+  Components:
+    Name: {Value: Boss}
+    Health: *health
+`), ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids, _ := ecs.SceneEntities(scene)
+	if !assert.Len(t, ids, 2) {
+		return
+	}
+
+	boss := ecs.MustGet(ids[1])
+	assert.Equal(t, testutil.Health{Value: 50, Max: 50}, boss.GetEntity().(*testutil.Orc).Health)
+	assert.Equal(t, "Boss", boss.GetEntity().(*testutil.Orc).Name.Value)
+	assert.True(t, boss.Has(testutil.Hostile{}))
+
+	// Merge keys are shallow, so the components of the grunt are replaced.
+	assert.False(t, boss.Has(testutil.Loot{}))
+	assert.True(t, ecs.MustGet(ids[0]).Has(testutil.Loot{}))
+}
+
+func TestLoadScene_Documents(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	scene, err := LoadScene(strings.NewReader(`
+- Type: Orc
+  Components:
+    Name: {Value: Grunt}
+---
+---
+- Type: Orc
+  Components:
+    Name: {Value: Peon}
+- Type: Unit
+`), ecs)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	ids, _ := ecs.SceneEntities(scene)
+	if !assert.Len(t, ids, 3) {
+		return
+	}
+	assert.Equal(t, "Grunt", ecs.MustGet(ids[0]).GetEntity().(*testutil.Orc).Name.Value)
+	assert.Equal(t, "Peon", ecs.MustGet(ids[1]).GetEntity().(*testutil.Orc).Name.Value)
+
+	_, err = LoadScene(strings.NewReader("- Type: Orc\n---\nType: Orc\n"), ecs)
+	assert.Error(t, err)
+
+	_, err = UnmarshalEntity(ecs, []byte("Type: Orc\n---\nType: Orc\n"))
+	assert.Error(t, err)
+}
+
+func TestMarshalEntity(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	id, _ := ecs.AddEntity(&testutil.Orc{Name: testutil.Name{Value: "true"}, Health: testutil.Health{Value: 1, Max: 2}})
+	assert.NoError(t, ecs.MustGet(id).Set(testutil.Hostile{}))
+
+	data, err := MarshalEntity(ecs, id)
+	assert.NoError(t, err)
+	assert.Equal(t, `ID: 1
+Version: 2
+Type: Orc
+Components:
+  Health:
+    Value: 1
+    Max: 2
+  Name:
+    Value: "true"
+Tags:
+  - Hostile
+`, string(data))
+
+	other := testutil.NewECS(t)
+	got, err := UnmarshalEntity(other, data)
+	assert.NoError(t, err)
+	assert.Equal(t, id, got)
+
+	a, _ := ecs.MarshalEntity(id)
+	b, _ := other.MarshalEntity(id)
+	assert.JSONEq(t, string(a), string(b))
+}
+
+func TestMarshal(t *testing.T) {
+	ecs := testutil.NewECS(t)
+	for i := 0; i < 5; i++ {
+		_, _ = ecs.AddEntity(&testutil.Orc{Health: testutil.Health{Value: i}})
+	}
+	assert.NoError(t, ecs.MustGet(2).Set(testutil.Loot{Chance: 1e-9}))
+
+	var buf bytes.Buffer
+	assert.NoError(t, Marshal(&buf, ecs))
+
+	other := testutil.NewECS(t)
+	assert.NoError(t, Unmarshal(&buf, other))
+
+	var want, got bytes.Buffer
+	assert.NoError(t, ecs.Marshal(&want))
+	assert.NoError(t, other.Marshal(&got))
+	assert.JSONEq(t, want.String(), got.String())
+}